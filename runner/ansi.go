@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// ansiStripper removes ANSI escape sequences (CSI such as colors and cursor
+// movement, OSC such as window titles and hyperlinks, and two-byte escapes)
+// from a byte stream before passing it on. It is a state machine so that a
+// sequence split across writes is still recognized.
+type ansiStripper struct {
+	w     io.Writer
+	state ansiState
+}
+
+type ansiState int
+
+const (
+	ansiText    ansiState = iota
+	ansiEscape            // after ESC
+	ansiCSI               // inside ESC [ ... final byte
+	ansiOSC               // inside ESC ] ... BEL or ST
+	ansiOSCEsc            // ESC seen inside OSC, expecting \ for ST
+	ansiCharset           // ESC ( / ESC ) etc., one more byte follows
+)
+
+func newANSIStripper(w io.Writer) *ansiStripper {
+	return &ansiStripper{w: w}
+}
+
+// stripANSI returns s without escape sequences.
+func stripANSI(s string) string {
+	var b strings.Builder
+	newANSIStripper(&b).Write([]byte(s))
+	return b.String()
+}
+
+func (s *ansiStripper) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+	for _, c := range p {
+		switch s.state {
+		case ansiText:
+			switch c {
+			case 0x1b:
+				s.state = ansiEscape
+			case 0x9b: // 8-bit CSI
+				s.state = ansiCSI
+			default:
+				out = append(out, c)
+			}
+		case ansiEscape:
+			switch {
+			case c == '[':
+				s.state = ansiCSI
+			case c == ']':
+				s.state = ansiOSC
+			case c >= '(' && c <= '/':
+				s.state = ansiCharset
+			default:
+				// Two-byte sequence such as ESC 7 or ESC M.
+				s.state = ansiText
+			}
+		case ansiCSI:
+			// Parameter and intermediate bytes are 0x20-0x3f; the final byte ends it.
+			if c >= 0x40 && c <= 0x7e {
+				s.state = ansiText
+			} else if c < 0x20 || c > 0x7e {
+				// Malformed sequence: drop it and resume with this byte.
+				s.state = ansiText
+				if c != 0x1b {
+					out = append(out, c)
+				} else {
+					s.state = ansiEscape
+				}
+			}
+		case ansiOSC:
+			switch c {
+			case 0x07:
+				s.state = ansiText
+			case 0x1b:
+				s.state = ansiOSCEsc
+			}
+		case ansiOSCEsc:
+			if c == '\\' {
+				s.state = ansiText
+			} else {
+				s.state = ansiOSC
+			}
+		case ansiCharset:
+			s.state = ansiText
+		}
+	}
+	if len(out) > 0 {
+		if _, err := s.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Artifact is a file the script left in its working directory that matched
+// one of the request's collectArtifacts patterns. Skipped explains why a
+// match was not returned; Content is then empty.
+type Artifact struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Content string `json:"content,omitempty"`
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// collectArtifacts globs patterns inside dir and returns the matched regular
+// files base64-encoded. Files over perFile, or that would push the total past
+// total, are listed as skipped rather than failing the job; so are matches
+// that resolve outside dir.
+func collectArtifacts(dir string, patterns []string, perFile, total int64) []Artifact {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		root = dir
+	}
+
+	var (
+		artifacts []Artifact
+		seen      = make(map[string]bool)
+		used      int64
+	)
+	for _, pattern := range patterns {
+		if !filepath.IsLocal(pattern) {
+			artifacts = append(artifacts, Artifact{Path: pattern, Skipped: "pattern must be relative and inside the working directory"})
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			artifacts = append(artifacts, Artifact{Path: pattern, Skipped: fmt.Sprintf("invalid pattern: %v", err)})
+			continue
+		}
+		if len(matches) == 0 {
+			artifacts = append(artifacts, Artifact{Path: pattern, Skipped: "no files matched"})
+			continue
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			rel, err := filepath.Rel(dir, match)
+			if err != nil || seen[rel] {
+				continue
+			}
+			seen[rel] = true
+
+			a := Artifact{Path: filepath.ToSlash(rel)}
+			info, err := os.Lstat(match)
+			switch {
+			case err != nil:
+				a.Skipped = err.Error()
+			case info.IsDir():
+				continue
+			case !info.Mode().IsRegular():
+				a.Skipped = "not a regular file"
+			case !insideDir(root, match):
+				a.Skipped = "outside the working directory"
+			case info.Size() > perFile:
+				a.Size = info.Size()
+				a.Skipped = fmt.Sprintf("exceeds the %d byte per-file limit", perFile)
+			case used+info.Size() > total:
+				a.Size = info.Size()
+				a.Skipped = fmt.Sprintf("exceeds the %d byte total artifact limit", total)
+			default:
+				data, err := os.ReadFile(match)
+				if err != nil {
+					a.Skipped = err.Error()
+					break
+				}
+				a.Size = int64(len(data))
+				a.Content = base64.StdEncoding.EncodeToString(data)
+				used += a.Size
+			}
+			artifacts = append(artifacts, a)
+		}
+	}
+	return artifacts
+}
+
+// insideDir reports whether path, with symlinks resolved, is within root.
+func insideDir(root, path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
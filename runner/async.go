@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Statuses reported in SubmitAck.Status.
+const (
+	submitQueued    = "queued"    // waiting for a worker
+	submitWaiting   = "waiting"   // held until an earlier job of its tenant or PublicID finishes
+	submitCompleted = "completed" // answered already, e.g. from the cache
+)
+
+// SubmitAck is the immediate reply to a request with a replySubject and no
+// runAt; the RunResult follows on the reply subject.
+type SubmitAck struct {
+	PublicID     string `json:"publicId"`
+	Status       string `json:"status"`
+	ReplySubject string `json:"replySubject"`
+	// QueuePosition is how many jobs were waiting for a worker, this one
+	// included, when it was queued; zero if a worker was free.
+	QueuePosition int `json:"queuePosition,omitempty"`
+	// Metadata is the request's metadata, unchanged.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// deferReply redirects a job's result to its ReplySubject. The original
+// request is answered with a SubmitAck once the job is queued, so callers
+// don't have to hold the request open for as long as the job runs.
+func deferReply(job *pendingJob) {
+	deferred := *job.msg
+	deferred.Reply = job.req.ReplySubject
+	if job.msg.Reply != "" {
+		job.ack.Store(job.msg)
+	}
+	job.msg = &deferred
+}
+
+// acknowledge sends the job's SubmitAck, if it is still owed one.
+func (j *pendingJob) acknowledge(status string) {
+	m := j.ack.Swap(nil)
+	if m == nil {
+		return
+	}
+	ack := SubmitAck{PublicID: j.req.PublicID, Status: status, ReplySubject: j.req.ReplySubject, Metadata: j.req.Metadata}
+	if status == submitQueued {
+		ack.QueuePosition = j.queuePosition
+	}
+	log.Printf("[ASYNC] %s %s, result to %s", j.req.PublicID, status, j.req.ReplySubject)
+	j.runner.respond(m, ack)
+}
+
+// validateReplySubject checks that subject is a literal NATS subject under
+// one of the allowed prefixes, so jobs can't publish onto the runner's own
+// or other internal subjects.
+func (c *Config) validateReplySubject(subject string) error {
+	if err := literalSubject(subject); err != nil {
+		return err
+	}
+	for _, prefix := range c.ReplySubjectPrefixes {
+		if subjectHasPrefix(subject, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("must start with one of %s", strings.Join(c.ReplySubjectPrefixes, ", "))
+}
+
+// literalSubject checks that s is a valid NATS subject without wildcards.
+func literalSubject(s string) error {
+	if s == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	if strings.ContainsAny(s, " \t\r\n") {
+		return fmt.Errorf("must not contain whitespace")
+	}
+	for _, token := range strings.Split(s, ".") {
+		switch token {
+		case "":
+			return fmt.Errorf("must not have empty tokens")
+		case "*", ">":
+			return fmt.Errorf("must not contain wildcards")
+		}
+	}
+	return nil
+}
+
+// subjectHasPrefix reports whether subject is prefix or lies under it.
+// A prefix ending in '.' matches only subjects below it; otherwise it must
+// end at a token boundary.
+func subjectHasPrefix(subject, prefix string) bool {
+	if !strings.HasPrefix(subject, prefix) {
+		return false
+	}
+	return strings.HasSuffix(prefix, ".") || len(subject) == len(prefix) || subject[len(prefix)] == '.'
+}
+
+// publishToResults redirects the reply to a request that arrived without a
+// reply inbox, as fire-and-forget submissions do, to the results subject.
+// The RunResult carries the PublicID for consumers there to match on.
+func (r *Runner) publishToResults(m *nats.Msg, publicID string) *nats.Msg {
+	n := r.resultsPublished.Add(1)
+	log.Printf("[ASYNC] %s has no reply subject, result to %s (%d so far)", publicID, r.cfg.ResultsSubject, n)
+	redirected := *m
+	redirected.Reply = r.cfg.ResultsSubject
+	return &redirected
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxBatchEntries caps the number of scripts in one batch request.
+const maxBatchEntries = 256
+
+// BatchRequest runs several scripts with one round trip on
+// runner.execute.batch, or runner.execute.tenant.<tenant>.batch. Entries are
+// fanned out across the worker pool like individual requests; TimeoutMs
+// bounds the batch as a whole.
+type BatchRequest struct {
+	V         int          `json:"v,omitempty"`
+	PublicID  string       `json:"publicId"`
+	Entries   []BatchEntry `json:"entries"`
+	TimeoutMs int          `json:"timeoutMs,omitempty"`
+}
+
+// BatchEntry is one script of a batch. ID identifies it in the reply and
+// defaults to its index; the entry's PublicID defaults to "<batch>/<id>".
+type BatchEntry struct {
+	ID string `json:"id"`
+	RunRequest
+}
+
+// BatchResult holds one result per entry, in request order.
+type BatchResult struct {
+	V             int                `json:"v"`
+	RunnerID      string             `json:"runnerId,omitempty"`
+	RunnerVersion string             `json:"runnerVersion,omitempty"`
+	PublicID      string             `json:"publicId"`
+	Results       []BatchEntryResult `json:"results"`
+	TimedOut      bool               `json:"timedOut,omitempty"`
+	DurationMs    int64              `json:"durationMs"`
+	Error         string             `json:"error,omitempty"`
+	ErrorCode     string             `json:"errorCode,omitempty"`
+	FieldErrors   []FieldError       `json:"fieldErrors,omitempty"`
+}
+
+type BatchEntryResult struct {
+	ID string `json:"id"`
+	RunResult
+}
+
+// batchRun collects entry results as workers produce them.
+type batchRun struct {
+	mu      sync.Mutex
+	results []BatchEntryResult
+	done    []bool
+	left    int
+	closed  bool
+	allDone chan struct{}
+}
+
+// complete records the result of entry i. Results arriving after the batch
+// has been answered are dropped.
+func (b *batchRun) complete(i int, res RunResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.done[i] {
+		return
+	}
+	b.results[i].RunResult = res
+	b.done[i] = true
+	b.left--
+	if b.left == 0 {
+		close(b.allDone)
+	}
+}
+
+func (r *Runner) handleBatch(m *nats.Msg) {
+	if err := r.cfg.checkRequestSize(len(m.Data)); err != nil {
+		log.Printf("[BATCH] Rejecting request: %v", err)
+		r.respond(m, BatchResult{Error: err.Error(), ErrorCode: errorCodeValidation})
+		return
+	}
+	var breq BatchRequest
+	data, err := requestJSON(m, "BatchRequest")
+	if err == nil {
+		err = decodeRequest(data, &breq, r.cfg.strictDecoding(m.Header))
+	}
+	if err != nil {
+		log.Printf("Bad batch data: %v: %s", err, logPayload(m.Data))
+		if m.Reply != "" {
+			res := badRequest(err)
+			r.respond(m, BatchResult{Error: res.Error, ErrorCode: res.ErrorCode, FieldErrors: res.FieldErrors})
+		}
+		return
+	}
+	if _, err := protocolVersion(breq.V); err != nil {
+		log.Printf("[BATCH] Rejecting %s: %v", breq.PublicID, err)
+		r.respond(m, BatchResult{PublicID: breq.PublicID, Error: err.Error(), ErrorCode: errorCodeUnsupportedVersion})
+		return
+	}
+	if err := validateBatch(&breq); err != nil {
+		log.Printf("[BATCH] Rejecting %s: %v", breq.PublicID, err)
+		r.respond(m, BatchResult{PublicID: breq.PublicID, Error: err.Error(), ErrorCode: errorCodeValidation})
+		return
+	}
+
+	start := time.Now()
+	timeout := r.cfg.MaxBatchTimeout
+	if t := time.Duration(breq.TimeoutMs) * time.Millisecond; t > 0 && t < timeout {
+		timeout = t
+	}
+	b := &batchRun{
+		results: make([]BatchEntryResult, len(breq.Entries)),
+		done:    make([]bool, len(breq.Entries)),
+		left:    len(breq.Entries),
+		allDone: make(chan struct{}),
+	}
+	log.Printf("[BATCH] %s: %d entries (timeout %v)", breq.PublicID, len(breq.Entries), timeout)
+
+	deadline := start.Add(timeout)
+	for i := range breq.Entries {
+		e := &breq.Entries[i]
+		b.results[i].ID = e.ID
+		r.acceptEntry(m, e, deadline, func(res RunResult) { b.complete(i, res) })
+	}
+	// Wait off the subscription goroutine so other batches aren't held up.
+	go r.awaitBatch(m, &breq, b, start, timeout)
+}
+
+// awaitBatch replies once every entry has finished or the batch timeout
+// passes, whichever is first. Entries still queued or running at the timeout
+// are canceled and reported as timed out.
+func (r *Runner) awaitBatch(m *nats.Msg, breq *BatchRequest, b *batchRun, start time.Time, timeout time.Duration) {
+	timer := time.NewTimer(time.Until(start.Add(timeout)))
+	defer timer.Stop()
+	res := BatchResult{PublicID: breq.PublicID}
+	select {
+	case <-b.allDone:
+	case <-timer.C:
+		res.TimedOut = true
+	}
+	b.mu.Lock()
+	b.closed = true
+	for i, done := range b.done {
+		if done {
+			continue
+		}
+		// Stop whatever is still queued or running; its result will be
+		// dropped.
+		r.cancelJobs(breq.Entries[i].PublicID)
+		b.results[i].RunResult = RunResult{
+			ExitCode:  -1,
+			Error:     fmt.Sprintf("batch timeout of %v reached", timeout),
+			ErrorCode: errorCodeTimeout,
+		}
+	}
+	res.Results = b.results
+	b.mu.Unlock()
+
+	res.DurationMs = time.Since(start).Milliseconds()
+	log.Printf("[BATCH] Sent reply for: %s (%d entries, timed out: %v)", breq.PublicID, len(res.Results), res.TimedOut)
+	r.respond(m, res)
+}
+
+// validateBatch checks the envelope and fills in defaulted entry IDs. Problems
+// with individual entries are reported per entry instead.
+func validateBatch(breq *BatchRequest) error {
+	if len(breq.Entries) == 0 {
+		return fmt.Errorf("batch has no entries")
+	}
+	if len(breq.Entries) > maxBatchEntries {
+		return fmt.Errorf("batch has %d entries (max %d)", len(breq.Entries), maxBatchEntries)
+	}
+	seen := make(map[string]bool, len(breq.Entries))
+	for i := range breq.Entries {
+		e := &breq.Entries[i]
+		if e.ID == "" {
+			e.ID = strconv.Itoa(i)
+		}
+		if seen[e.ID] {
+			return fmt.Errorf("duplicate entry id %q", e.ID)
+		}
+		seen[e.ID] = true
+		if e.PublicID == "" {
+			e.PublicID = breq.PublicID + "/" + e.ID
+		}
+	}
+	return nil
+}
+
+// acceptEntry admits one batch entry as a job that reports to done rather
+// than to a NATS requester. Its deadline is capped by the batch's.
+func (r *Runner) acceptEntry(m *nats.Msg, e *BatchEntry, deadline time.Time, done func(RunResult)) {
+	if _, err := protocolVersion(e.V); err != nil {
+		done(unsupportedVersion(err))
+		return
+	}
+	if e.RunAt != nil {
+		done(RunResult{ExitCode: 1, Error: "runAt is not supported in batch entries", ErrorCode: errorCodeValidation})
+		return
+	}
+	if e.ReplySubject != "" || e.WebhookURL != "" || e.ChunkedReply {
+		done(RunResult{ExitCode: 1, Error: "replySubject, webhookUrl and chunkedReply are not supported in batch entries", ErrorCode: errorCodeValidation})
+		return
+	}
+	if err := r.applySubjectTenant(m, &e.RunRequest); err != nil {
+		done(RunResult{ExitCode: 1, Error: err.Error(), ErrorCode: errorCodePermissionDenied})
+		return
+	}
+	if errs := r.cfg.validateRequest(&e.RunRequest); len(errs) > 0 {
+		done(errs.result())
+		return
+	}
+	priority, _ := parsePriority(e.Priority) // checked by validateRequest
+	job := &pendingJob{
+		msg:        m,
+		req:        e.RunRequest,
+		tenant:     r.cfg.tenantOf(&e.RunRequest),
+		serialized: e.Serialize || r.cfg.SerializeByID,
+		priority:   priority,
+		done:       done,
+	}
+	job.deadline = r.cfg.jobDeadline(&job.req, m.Header, time.Now())
+	if job.deadline.IsZero() || deadline.Before(job.deadline) {
+		job.deadline = deadline
+	}
+	r.accept(job)
+}
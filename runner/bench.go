@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// maxBenchmarks caps how many benchmarks a result reports.
+const maxBenchmarks = 200
+
+// Benchmark is one measurement from a bench mode run. Times are in
+// nanoseconds per iteration; a benchmark that threw has only Error set.
+type Benchmark struct {
+	Name  string  `json:"name"`
+	Group string  `json:"group,omitempty"`
+	N     int64   `json:"n,omitempty"`
+	AvgNs float64 `json:"avgNs,omitempty"`
+	MinNs float64 `json:"minNs,omitempty"`
+	MaxNs float64 `json:"maxNs,omitempty"`
+	P75Ns float64 `json:"p75Ns,omitempty"`
+	P99Ns float64 `json:"p99Ns,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+// benchReport is the part of deno bench --json output the runner reads.
+type benchReport struct {
+	Version int `json:"version"`
+	Benches []struct {
+		Name    string `json:"name"`
+		Group   string `json:"group"`
+		Results []struct {
+			Ok *struct {
+				N   int64   `json:"n"`
+				Min float64 `json:"min"`
+				Max float64 `json:"max"`
+				Avg float64 `json:"avg"`
+				P75 float64 `json:"p75"`
+				P99 float64 `json:"p99"`
+			} `json:"ok"`
+			Failed json.RawMessage `json:"failed"`
+		} `json:"results"`
+	} `json:"benches"`
+}
+
+// parseBenchReport finds deno's report in stdout. Whatever the benchmarks
+// print goes to stdout too, so the report is the first top-level JSON object
+// that decodes as one.
+func parseBenchReport(stdout string) (*benchReport, bool) {
+	for i := 0; i < len(stdout); {
+		if stdout[i] == '{' {
+			var report benchReport
+			if json.NewDecoder(strings.NewReader(stdout[i:])).Decode(&report) == nil && report.Version > 0 {
+				return &report, true
+			}
+		}
+		next := strings.Index(stdout[i:], "\n{")
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return nil, false
+}
+
+// benchError extracts the message from a failed benchmark, which deno
+// reports as a JS error object.
+func benchError(raw json.RawMessage) string {
+	var msg string
+	if json.Unmarshal(raw, &msg) == nil {
+		return msg
+	}
+	var jsErr struct {
+		Message          string `json:"message"`
+		ExceptionMessage string `json:"exceptionMessage"`
+	}
+	json.Unmarshal(raw, &jsErr)
+	if jsErr.ExceptionMessage != "" {
+		return jsErr.ExceptionMessage
+	}
+	if jsErr.Message != "" {
+		return jsErr.Message
+	}
+	return "benchmark failed"
+}
+
+// fillBench adds the measurements from deno's report to res.
+func fillBench(res *RunResult, req *RunRequest) {
+	report, ok := parseBenchReport(res.Stdout)
+	if !ok {
+		if res.ErrorCode == "" {
+			log.Printf("[BENCH] No bench report in the output of %s", req.PublicID)
+		}
+		return
+	}
+	for _, b := range report.Benches {
+		for _, r := range b.Results {
+			if len(res.Benchmarks) == maxBenchmarks {
+				return
+			}
+			bench := Benchmark{Name: b.Name, Group: b.Group}
+			switch {
+			case r.Ok != nil:
+				bench.N = r.Ok.N
+				bench.AvgNs, bench.MinNs, bench.MaxNs = r.Ok.Avg, r.Ok.Min, r.Ok.Max
+				bench.P75Ns, bench.P99Ns = r.Ok.P75, r.Ok.P99
+			case len(r.Failed) > 0:
+				bench.Error = benchError(r.Failed)
+			default:
+				continue
+			}
+			res.Benchmarks = append(res.Benchmarks, bench)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// resultCache is an in-memory LRU of results with a TTL. It backs the result
+// cache for cacheable requests, keyed by a hash of everything that
+// influences what the script does, and the idempotency store.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	keep    func(RunResult) bool // which results are worth storing
+	order   *list.List           // front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	res     RunResult
+	expires time.Time
+}
+
+func newResultCache(ttl time.Duration, max int, keep func(RunResult) bool) *resultCache {
+	return &resultCache{
+		ttl:     ttl,
+		max:     max,
+		keep:    keep,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// cacheKey hashes the parts of a request that determine its result. Routing
+// and scheduling fields (PublicID, tenant, metadata, timeout, deadline,
+// cache flags) are left out so identical code from different callers shares
+// an entry.
+func cacheKey(req *RunRequest) string {
+	k := *req
+	k.PublicID = ""
+	k.Tenant = ""
+	k.Serialize = false
+	k.TimeoutMs = 0
+	k.Deadline = nil
+	k.RunAt = nil
+	k.ReplySubject = ""
+	k.WebhookURL = ""
+	k.Metadata = nil
+	k.Cacheable = false
+	k.NoCache = false
+	k.Coalesce = false
+	k.IdempotencyKey = ""
+	data, _ := json.Marshal(k)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// lookup returns a copy of a fresh stored result.
+func (c *resultCache) lookup(key string) (RunResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return RunResult{}, false
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return RunResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return e.res, true
+}
+
+// store records res under key if it is worth caching, evicting the least
+// recently used entry when full.
+func (c *resultCache) store(key string, res RunResult) {
+	if !c.keep(res) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, res: res, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheable reports whether res may be served to later cacheable requests.
+// Successes always are; failures only when enabled and only if the script
+// itself was at fault, never when the runner was busy, canceled or broken.
+func (c *Config) cacheable(res RunResult) bool {
+	if res.ErrorCode == "" {
+		return true
+	}
+	if !c.CacheFailures {
+		return false
+	}
+	switch res.ErrorCode {
+	case errorCodeRuntime, errorCodeTestsFailed, errorCodeTypeCheck, errorCodeLint,
+		errorCodeFormat, errorCodeNotFormatted,
+		errorCodeTimeout, errorCodeCPULimit, errorCodeOOM,
+		errorCodeProcessLimit, errorCodeDiskQuota, errorCodePermissionDenied, errorCodeValidation:
+		return true
+	}
+	return false
+}
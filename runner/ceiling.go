@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envTenantPermissions parses per-tenant permission ceilings:
+// "tenant=flag flag;tenant=flag", with "*" for tenants not listed. Flags
+// are separated by spaces since their values contain commas.
+func envTenantPermissions(name string) (map[string][]string, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	m := make(map[string][]string)
+	for _, entry := range strings.Split(v, ";") {
+		tenant, flags, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || tenant == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: want tenant=flags", name, entry)
+		}
+		perms, err := validatePermissions(strings.Fields(flags))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry for %q: %v", name, tenant, err)
+		}
+		m[tenant] = perms
+	}
+	return m, nil
+}
+
+// checkPermissionCeiling reports the first of perms that goes beyond the
+// tenant's ceiling. A ceiling flag without a value covers any value of that
+// flag; one with values covers requests for a subset of them. Deny flags
+// only take permissions away and are always allowed. Tenants without a
+// ceiling, and no "*" entry, are limited only by the blocked flags.
+func (c *Config) checkPermissionCeiling(tenant string, perms []string) error {
+	ceiling, ok := c.TenantPermissions[tenant]
+	if !ok {
+		if ceiling, ok = c.TenantPermissions["*"]; !ok {
+			return nil
+		}
+	}
+	for _, perm := range perms {
+		if !withinCeiling(perm, ceiling) {
+			return fmt.Errorf("%s exceeds the permissions allowed for tenant %q", perm, tenant)
+		}
+	}
+	return nil
+}
+
+func withinCeiling(perm string, ceiling []string) bool {
+	name, value, hasValue := strings.Cut(perm, "=")
+	if strings.HasPrefix(name, "--deny-") {
+		return true
+	}
+	for _, allowed := range ceiling {
+		allowedName, allowedValue, limited := strings.Cut(allowed, "=")
+		if allowedName != name {
+			continue
+		}
+		if !limited {
+			return true
+		}
+		if !hasValue {
+			return false // asks for all of what the ceiling only grants in part
+		}
+		granted := make(map[string]bool)
+		for _, v := range strings.Split(allowedValue, ",") {
+			granted[v] = true
+		}
+		for _, v := range strings.Split(value, ",") {
+			if !granted[v] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const cgroupMount = "/sys/fs/cgroup"
+
+// cgroupManager creates a transient cgroup v2 leaf per job underneath base.
+type cgroupManager struct {
+	base string
+	seq  atomic.Uint64
+}
+
+// cgroupLimits are the per-job cgroup settings; zero values are left unlimited.
+type cgroupLimits struct {
+	MemoryMax int64
+	PidsMax   int64
+}
+
+// jobCgroup is the cgroup a single deno process runs in.
+type jobCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// setupCgroups prepares a cgroup subtree the runner is allowed to manage.
+// When root is empty, the runner's own cgroup is used: the runner moves itself
+// into a "supervisor" leaf so the parent can delegate controllers to job
+// cgroups (cgroup v2 forbids processes in non-leaf cgroups with controllers).
+func setupCgroups(root string, limits cgroupLimits) (*cgroupManager, error) {
+	if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+		return nil, errors.New("cgroup v2 is not mounted")
+	}
+
+	base := root
+	if base == "" {
+		own, err := ownCgroup()
+		if err != nil {
+			return nil, err
+		}
+		base = filepath.Join(cgroupMount, own)
+
+		supervisor := filepath.Join(base, "supervisor")
+		if err := os.MkdirAll(supervisor, 0o755); err != nil {
+			return nil, fmt.Errorf("create supervisor cgroup: %w", err)
+		}
+		if err := writeCgroupFile(supervisor, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+			return nil, fmt.Errorf("move runner into supervisor cgroup: %w", err)
+		}
+	} else if err := os.MkdirAll(base, 0o755); err != nil {
+		return nil, fmt.Errorf("create cgroup root: %w", err)
+	}
+
+	if limits.MemoryMax > 0 {
+		if err := writeCgroupFile(base, "cgroup.subtree_control", "+memory"); err != nil {
+			return nil, fmt.Errorf("enable memory controller: %w", err)
+		}
+	}
+	if limits.PidsMax > 0 {
+		if err := writeCgroupFile(base, "cgroup.subtree_control", "+pids"); err != nil {
+			return nil, fmt.Errorf("enable pids controller: %w", err)
+		}
+	}
+	return &cgroupManager{base: base}, nil
+}
+
+// ownCgroup returns the cgroup v2 path of the current process.
+func ownCgroup() (string, error) {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+			return path, nil
+		}
+	}
+	return "", errors.New("no cgroup v2 entry in /proc/self/cgroup")
+}
+
+// create makes a new job cgroup with the given limits applied.
+func (m *cgroupManager) create(limits cgroupLimits) (*jobCgroup, error) {
+	path := filepath.Join(m.base, fmt.Sprintf("job-%d", m.seq.Add(1)))
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, err
+	}
+	cg := &jobCgroup{path: path}
+	if limits.MemoryMax > 0 {
+		if err := writeCgroupFile(path, "memory.max", strconv.FormatInt(limits.MemoryMax, 10)); err != nil {
+			cg.cleanup()
+			return nil, err
+		}
+		// Without this the limit can be dodged by swapping; not every host has swap accounting.
+		_ = writeCgroupFile(path, "memory.swap.max", "0")
+	}
+	if limits.PidsMax > 0 {
+		if err := writeCgroupFile(path, "pids.max", strconv.FormatInt(limits.PidsMax, 10)); err != nil {
+			cg.cleanup()
+			return nil, err
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		cg.cleanup()
+		return nil, err
+	}
+	cg.dir = dir
+	return cg, nil
+}
+
+// apply makes the child start directly inside the cgroup (clone3 CLONE_INTO_CGROUP),
+// so there is no window where it runs unconstrained.
+func (cg *jobCgroup) apply(attr *syscall.SysProcAttr) {
+	attr.UseCgroupFD = true
+	attr.CgroupFD = int(cg.dir.Fd())
+}
+
+// oomKilled reports whether the kernel OOM killer fired inside the cgroup.
+func (cg *jobCgroup) oomKilled() bool {
+	return cg.eventCount("memory.events", "oom_kill") > 0
+}
+
+// pidsLimitHit reports whether a fork or thread creation was refused by pids.max.
+func (cg *jobCgroup) pidsLimitHit() bool {
+	return cg.eventCount("pids.events", "max") > 0
+}
+
+// memoryPeak returns memory.peak, the cgroup's high-water mark in bytes. It
+// needs Linux 5.19 or later; ok is false when the file is unavailable.
+func (cg *jobCgroup) memoryPeak() (int64, bool) {
+	data, err := os.ReadFile(filepath.Join(cg.path, "memory.peak"))
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return n, err == nil
+}
+
+// eventCount reads a counter from a flat-keyed cgroup events file.
+func (cg *jobCgroup) eventCount(file, key string) int {
+	data, err := os.ReadFile(filepath.Join(cg.path, file))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, key+" "); ok {
+			n, _ := strconv.Atoi(v)
+			return n
+		}
+	}
+	return 0
+}
+
+// cleanup kills anything left in the cgroup and removes it.
+func (cg *jobCgroup) cleanup() {
+	if cg.dir != nil {
+		cg.dir.Close()
+	}
+	_ = writeCgroupFile(cg.path, "cgroup.kill", "1")
+	for i := 0; i < 50; i++ {
+		err := os.Remove(cg.path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	log.Printf("[CGROUP] Failed to remove %s", cg.path)
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	return os.WriteFile(filepath.Join(dir, name), []byte(value), 0)
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// cgroups are Linux-only; on other platforms jobs run without them.
+type cgroupManager struct{ base string }
+
+type jobCgroup struct{}
+
+type cgroupLimits struct {
+	MemoryMax int64
+	PidsMax   int64
+}
+
+func setupCgroups(string, cgroupLimits) (*cgroupManager, error) {
+	return nil, errors.New("cgroups require Linux")
+}
+
+func (m *cgroupManager) create(cgroupLimits) (*jobCgroup, error) {
+	return nil, errors.New("cgroups require Linux")
+}
+
+func (cg *jobCgroup) apply(*syscall.SysProcAttr) {}
+func (cg *jobCgroup) oomKilled() bool            { return false }
+func (cg *jobCgroup) pidsLimitHit() bool         { return false }
+func (cg *jobCgroup) memoryPeak() (int64, bool)  { return 0, false }
+func (cg *jobCgroup) cleanup()                   {}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Chunked replies, for requests with chunkedReply set, split the encoded
+// RunResult into messages that each fit under the server's max_payload,
+// sent in order to the reply subject. Each carries its 1-based index in
+// Runner-Chunk. A terminator with an empty body follows, carrying the other
+// reply headers, Runner-Chunks with the number of chunks and
+// Runner-Chunk-Checksum with "sha256=" and the hex SHA-256 of the chunks
+// joined. A result that fits in one message is sent as one chunk, so
+// clients always read until the terminator.
+const (
+	chunkHeader         = "Runner-Chunk"
+	chunkCountHeader    = "Runner-Chunks"
+	chunkChecksumHeader = "Runner-Chunk-Checksum"
+)
+
+// defaultChunkBytes is the chunk size while the server's max_payload isn't
+// known.
+const defaultChunkBytes = 512 << 10
+
+// respondChunked sends res to the requester of m as a chunked reply.
+func (r *Runner) respondChunked(m *nats.Msg, res RunResult) {
+	reply := r.buildReply(m, res, false)
+	data := reply.Data
+	size := r.offload.limit()
+	if size <= 0 {
+		size = defaultChunkBytes
+	}
+	sum := sha256.Sum256(data)
+	n := 0
+	for off := 0; off == 0 || off < len(data); off += size {
+		n++
+		chunk := &nats.Msg{Subject: reply.Subject, Data: data[off:min(off+size, len(data))], Header: nats.Header{}}
+		chunk.Header.Set(chunkHeader, strconv.Itoa(n))
+		r.sendReply(m, chunk)
+	}
+	reply.Data = nil
+	reply.Header.Set(chunkCountHeader, strconv.Itoa(n))
+	reply.Header.Set(chunkChecksumHeader, "sha256="+hex.EncodeToString(sum[:]))
+	r.sendReply(m, reply)
+	if n > 1 {
+		log.Printf("[CHUNKED] Sent %d-byte result of %s in %d chunks", len(data), res.PublicID, n)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// chunkedReply requests res as a chunked reply from a subscriber calling
+// respondChunked with chunks of size bytes, and returns the chunks and the
+// terminator as received.
+func chunkedReply(t *testing.T, res RunResult, size int) ([]*nats.Msg, *nats.Msg) {
+	t.Helper()
+	s := runTestServer(t, "a", nil)
+	nc := connectTest(t, s.ClientURL())
+	r := testRunner(t)
+	r.nc = nc
+	r.offload = &offloader{nc: nc, threshold: size}
+	if _, err := nc.Subscribe("runner.execute", func(m *nats.Msg) { r.respondChunked(m, res) }); err != nil {
+		t.Fatal(err)
+	}
+
+	client := connectTest(t, s.ClientURL())
+	inbox := nats.NewInbox()
+	sub, err := client.SubscribeSync(inbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.PublishRequest("runner.execute", inbox, nil); err != nil {
+		t.Fatal(err)
+	}
+	var chunks []*nats.Msg
+	for {
+		msg, err := sub.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatalf("after %d chunks: %v", len(chunks), err)
+		}
+		if msg.Header.Get(chunkHeader) == "" {
+			return chunks, msg
+		}
+		chunks = append(chunks, msg)
+	}
+}
+
+// joinChunks joins chunks in index order as a client would, failing on
+// gaps, duplicates and data that does not match the terminator's checksum.
+func joinChunks(chunks []*nats.Msg, terminator *nats.Msg) ([]byte, string) {
+	count, err := strconv.Atoi(terminator.Header.Get(chunkCountHeader))
+	if err != nil {
+		return nil, "no chunk count"
+	}
+	byIndex := map[int][]byte{}
+	for _, c := range chunks {
+		i, _ := strconv.Atoi(c.Header.Get(chunkHeader))
+		if _, dup := byIndex[i]; dup {
+			return nil, "duplicate chunk " + strconv.Itoa(i)
+		}
+		byIndex[i] = c.Data
+	}
+	var data []byte
+	for i := 1; i <= count; i++ {
+		part, ok := byIndex[i]
+		if !ok {
+			return nil, "missing chunk " + strconv.Itoa(i)
+		}
+		data = append(data, part...)
+	}
+	if len(byIndex) != count {
+		return nil, "extra chunks"
+	}
+	sum := sha256.Sum256(data)
+	if terminator.Header.Get(chunkChecksumHeader) != "sha256="+hex.EncodeToString(sum[:]) {
+		return nil, "checksum mismatch"
+	}
+	return data, ""
+}
+
+func TestRespondChunked(t *testing.T) {
+	res := RunResult{PublicID: "job-1", Output: strings.Repeat("0123456789", 1000), ExitCode: 3}
+	for _, size := range []int{100, 1000, 1 << 20} {
+		t.Run(strconv.Itoa(size), func(t *testing.T) {
+			chunks, terminator := chunkedReply(t, res, size)
+			for i, c := range chunks {
+				if c.Header.Get(chunkHeader) != strconv.Itoa(i+1) {
+					t.Fatalf("chunk %d arrived as %q", i+1, c.Header.Get(chunkHeader))
+				}
+				if len(c.Data) > size || (i < len(chunks)-1 && len(c.Data) != size) {
+					t.Fatalf("chunk %d is %d bytes with size %d", i+1, len(c.Data), size)
+				}
+			}
+			if len(terminator.Data) != 0 || terminator.Header.Get(runnerIDHeader) != runnerID {
+				t.Errorf("terminator: %d bytes, headers %v", len(terminator.Data), terminator.Header)
+			}
+			data, problem := joinChunks(chunks, terminator)
+			if problem != "" {
+				t.Fatal(problem)
+			}
+			var got RunResult
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.PublicID != res.PublicID || got.Output != res.Output || got.ExitCode != res.ExitCode {
+				t.Errorf("reassembled %+v", got)
+			}
+			if size == 1<<20 && len(chunks) != 1 {
+				t.Errorf("small result sent in %d chunks", len(chunks))
+			}
+		})
+	}
+}
+
+// TestRespondChunkedDamage checks the terminator lets a client detect chunks
+// that were lost, repeated, reordered or corrupted on the way.
+func TestRespondChunkedDamage(t *testing.T) {
+	res := RunResult{PublicID: "job-1", Output: strings.Repeat("abcdefghij", 100)}
+	chunks, terminator := chunkedReply(t, res, 64)
+	if len(chunks) < 4 {
+		t.Fatalf("only %d chunks", len(chunks))
+	}
+	want, problem := joinChunks(chunks, terminator)
+	if problem != "" {
+		t.Fatal(problem)
+	}
+	renumber := func(c *nats.Msg, i int) *nats.Msg {
+		h := nats.Header{}
+		h.Set(chunkHeader, strconv.Itoa(i))
+		return &nats.Msg{Data: c.Data, Header: h}
+	}
+	corrupt := func(c *nats.Msg) *nats.Msg {
+		data := bytes.Clone(c.Data)
+		data[len(data)/2] ^= 0x20
+		return &nats.Msg{Data: data, Header: c.Header}
+	}
+	reversed := make([]*nats.Msg, len(chunks))
+	for i, c := range chunks {
+		reversed[len(chunks)-1-i] = c
+	}
+	tests := []struct {
+		name   string
+		chunks []*nats.Msg
+		want   string
+	}{
+		{"out of order", reversed, ""},
+		{"missing", append(append([]*nats.Msg(nil), chunks[:1]...), chunks[2:]...), "missing chunk 2"},
+		{"gap", append(append([]*nats.Msg(nil), chunks[:len(chunks)-1]...), renumber(chunks[len(chunks)-1], len(chunks)+1)), "missing chunk " + strconv.Itoa(len(chunks))},
+		{"duplicate", append(append([]*nats.Msg(nil), chunks...), chunks[1]), "duplicate chunk 2"},
+		{"swapped indexes", append([]*nats.Msg{renumber(chunks[0], 2), renumber(chunks[1], 1)}, chunks[2:]...), "checksum mismatch"},
+		{"corrupt", append([]*nats.Msg{corrupt(chunks[0])}, chunks[1:]...), "checksum mismatch"},
+		{"truncated", append([]*nats.Msg{{Data: chunks[0].Data[1:], Header: chunks[0].Header}}, chunks[1:]...), "checksum mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, problem := joinChunks(tt.chunks, terminator)
+			if problem != tt.want {
+				t.Fatalf("problem %q, want %q", problem, tt.want)
+			}
+			if tt.want == "" && !bytes.Equal(data, want) {
+				t.Error("reassembled different data")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "sync"
+
+// flightGroup tracks coalescable jobs that are queued or running, so identical
+// requests arriving meanwhile can wait for the same result instead of
+// starting another deno process.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string][]*pendingJob // key -> followers waiting on the leader
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{flights: make(map[string][]*pendingJob)}
+}
+
+// join attaches job to the in-flight job with this key and reports whether
+// there was one. If not, the caller becomes the leader for the key.
+func (g *flightGroup) join(key string, job *pendingJob) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	followers, ok := g.flights[key]
+	if !ok {
+		g.flights[key] = nil
+		return false
+	}
+	g.flights[key] = append(followers, job)
+	return true
+}
+
+// done ends the flight for key and returns the requests that joined it.
+// Requests arriving after this start a new flight.
+func (g *flightGroup) done(key string) []*pendingJob {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	followers := g.flights[key]
+	delete(g.flights, key)
+	return followers
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Results whose JSON encoding exceeds Config.CompressThreshold go out with
+// Output, Stdout and Stderr gzipped and base64-encoded, marked by
+// contentEncoding: "gzip" in the result and a Runner-Content-Encoding header
+// on the reply. For a batch the header means at least one entry is
+// compressed; each entry carries its own marker. Smaller results stay plain
+// text.
+const (
+	contentEncodingHeader = "Runner-Content-Encoding"
+	contentEncodingGzip   = "gzip"
+)
+
+// compress gzips res's output fields in place if its encoding is over
+// threshold, reporting whether it did. A threshold of zero disables
+// compression.
+func (res *RunResult) compress(threshold int64) bool {
+	if threshold <= 0 || res.ContentEncoding != "" {
+		return false
+	}
+	if res.Output == "" && res.Stdout == "" && res.Stderr == "" {
+		return false
+	}
+	data, err := json.Marshal(res)
+	if err != nil || int64(len(data)) <= threshold {
+		return false
+	}
+	res.Output = gzipBase64(res.Output)
+	res.Stdout = gzipBase64(res.Stdout)
+	res.Stderr = gzipBase64(res.Stderr)
+	res.ContentEncoding = contentEncodingGzip
+	return true
+}
+
+// gzipBase64 returns s gzipped and base64-encoded. Empty fields stay empty so
+// they're still left out of the reply.
+func gzipBase64(s string) string {
+	if s == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
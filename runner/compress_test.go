@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestReplyCompression(t *testing.T) {
+	big := RunResult{Output: strings.Repeat("output ", 100)}
+	tests := []struct {
+		threshold int64
+		res       RunResult
+		want      bool
+	}{
+		{threshold: 0, res: big},
+		{threshold: 400, res: big, want: true},
+		{threshold: 400, res: RunResult{Output: "small"}},
+		{threshold: 1 << 20, res: big},
+	}
+	for _, tt := range tests {
+		r := testRunner(t)
+		r.cfg.CompressThreshold = tt.threshold
+		reply := r.buildReply(&nats.Msg{Reply: "inbox"}, tt.res, false)
+		if got := reply.Header.Get(contentEncodingHeader) == contentEncodingGzip; got != tt.want {
+			t.Errorf("threshold %d, %d-byte output: compressed %v, want %v", tt.threshold, len(tt.res.Output), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,930 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds runner settings read from the environment at startup.
+type Config struct {
+	// NatsURLs are the servers to connect to, from the comma-separated
+	// NATS_URL. The client also adds the servers a cluster tells it about.
+	// A tls:// URL expects that server to require TLS; since the client
+	// applies TLS to the whole pool, TLS and plain URLs can't be mixed.
+	NatsURLs []string
+	// NatsNoRandomize tries NatsURLs in the order given rather than
+	// shuffled.
+	NatsNoRandomize bool
+	// NatsName is the connection's name as the server reports it; by
+	// default "runner " and the RunnerID.
+	NatsName string
+	// NatsCreds is the path of a .creds file (user JWT and seed) for
+	// decentralized auth, read again on every reconnect.
+	NatsCreds string
+	// NatsNkeySeed is a user nkey seed to authenticate with; a secret, so
+	// it is never logged.
+	NatsNkeySeed string
+	// NatsToken, or NatsUser and NatsPassword, authenticate on servers with
+	// token or password auth. See natsAuthMode for which credentials win
+	// when more than one kind is set.
+	NatsToken    string
+	NatsUser     string
+	NatsPassword string
+	// NatsTLSInsecure skips verifying the server's certificate, for testing
+	// only. NatsTLSMinVersion is the lowest TLS version accepted; zero
+	// leaves the default, 1.2.
+	NatsTLSInsecure   bool
+	NatsTLSMinVersion uint16
+	// natsTLS holds the CA bundle and client certificate, if any are set.
+	natsTLS *natsTLS
+	// NatsMaxReconnects caps reconnect attempts after losing the server;
+	// -1, the default, keeps trying for good.
+	NatsMaxReconnects int
+	// RunnerID names this instance in results; by default it is the
+	// hostname plus a random suffix.
+	RunnerID string
+
+	// DefaultTimeout is the wall-clock limit applied when a request doesn't
+	// specify its own timeoutMs, and MaxTimeout the most a request may ask
+	// for; neither default may exceed it.
+	DefaultTimeout time.Duration
+	MaxTimeout     time.Duration
+	// BenchTimeout replaces DefaultTimeout for bench mode, which runs
+	// CPU-bound loops until told to stop. BenchWeight is how many slots of
+	// its tenant's concurrency quota a bench job takes.
+	BenchTimeout time.Duration
+	BenchWeight  int
+
+	// MaxConcurrent is the number of jobs that may execute at the same time.
+	MaxConcurrent int
+	// MaxQueueDepth is how many jobs may wait for a worker before new ones are
+	// rejected as busy. It can be changed at runtime via runner.admin.queue.
+	MaxQueueDepth int
+	// MaxBatchTimeout bounds a whole runner.execute.batch request, and is
+	// used when the batch names no timeout of its own.
+	MaxBatchTimeout time.Duration
+	// DrainTimeout is how long accepted jobs get to finish after SIGTERM
+	// before they are killed.
+	DrainTimeout time.Duration
+	// MaxScheduled caps how many runAt jobs may be waiting at once.
+	MaxScheduled int
+	// DefaultTTL expires requests that name no deadline of their own once they
+	// have waited this long; zero means they never expire.
+	DefaultTTL time.Duration
+	// DeadlineSkew is how far past its deadline, by the runner's clock, a
+	// job may still start, for callers whose clocks run ahead.
+	DeadlineSkew time.Duration
+	// PriorityAging is how long a queued job waits to be treated as one
+	// priority level higher.
+	PriorityAging time.Duration
+
+	// Tenant quotas: jobs per tenant that may run at once (0 = unlimited),
+	// per-tenant overrides, and how many jobs a tenant at its quota may queue
+	// before further ones are rejected. Tenants come from RunRequest.Tenant or
+	// the PublicID prefix before TenantSeparator.
+	TenantMaxConcurrent int
+	TenantLimits        map[string]int
+	TenantMaxQueued     int
+	TenantSeparator     string
+	// TenantSubjects takes jobs on per-tenant subjects too, whose tenant
+	// overrides the payload's; TenantSubjectStrict rejects jobs whose
+	// payload claims a different one. See tenantsubjects.go.
+	TenantSubjects      bool
+	TenantSubjectStrict bool
+	// TenantPermissions caps the permissions each tenant's jobs may ask for,
+	// with "*" for tenants not listed; see checkPermissionCeiling.
+	TenantPermissions map[string][]string
+	// TenantWeights sets how many queued jobs a tenant may start per
+	// round-robin turn; tenants not listed have weight 1.
+	TenantWeights map[string]int
+
+	// SerializeByID serializes every job per PublicID, as if each request set
+	// serialize. SerializeMaxQueued caps how many may wait per PublicID.
+	SerializeByID      bool
+	SerializeMaxQueued int
+
+	// KillGrace is how long a job gets between SIGTERM and SIGKILL when the
+	// runner stops it.
+	KillGrace time.Duration
+
+	// MaxRequestBytes caps the size of a request message and MaxCodeBytes
+	// the size of its code; larger requests are refused before anything runs.
+	MaxRequestBytes int64
+	MaxCodeBytes    int64
+	// MaxEvalBytes is the tighter cap on code in eval mode, which is meant
+	// for one-liners.
+	MaxEvalBytes int64
+
+	// MaxOutputBytes caps how much of each output stream is kept in memory.
+	// OutputTailBytes of that budget is reserved for the end of the stream.
+	MaxOutputBytes  int64
+	OutputTailBytes int64
+	// MaxValueBytes caps the JSON value a returnValue job may hand back.
+	MaxValueBytes int64
+	// CompressThreshold is the encoded result size above which output
+	// fields are sent gzipped; zero disables compression.
+	CompressThreshold int64
+	// OutputRateLimit kills a job whose combined output averages more than
+	// this many bytes per second over OutputRateWindow; zero disables it.
+	OutputRateLimit  int64
+	OutputRateWindow time.Duration
+
+	// V8HeapMB is the default --max-old-space-size passed to deno; requests may
+	// ask for a different value up to V8HeapCeilingMB.
+	V8HeapMB        int
+	V8HeapCeilingMB int
+
+	// MemoryLimit is the cgroup memory.max applied to each job (0 disables it).
+	// PidsLimit is the cgroup pids.max, falling back to RLIMIT_NPROC when
+	// cgroups are unavailable; that is only set once deno has started (see
+	// rlimits). CgroupRoot overrides the cgroup directory job cgroups are
+	// created under.
+	MemoryLimit int64
+	PidsLimit   int
+	CgroupRoot  string
+
+	// CPULimit is the CPU time (not wall-clock) a job may consume, enforced
+	// with RLIMIT_CPU at whole-second granularity. Zero disables it.
+	CPULimit time.Duration
+
+	// JobNice is the nice value (0-19) job processes run at, and JobIOClass
+	// ("best-effort" or "idle") with JobIOPriority (0-7) their I/O
+	// scheduling; the zero values leave what the runner has.
+	JobNice       int
+	JobIOClass    string
+	JobIOPriority int
+
+	// StrictRequests rejects requests with fields the runner doesn't know,
+	// unless the request's Runner-Strict header says otherwise.
+	StrictRequests bool
+
+	// RequireNetIsolation refuses to start the runner if jobs without
+	// network permissions can't be put in their own network namespace.
+	RequireNetIsolation bool
+
+	// UnstableFeatures are the --unstable-* flags requests may ask for in
+	// unstableFeatures. None are allowed unless the operator lists them.
+	UnstableFeatures map[string]bool
+	// ImportAllowlist are the hosts (host[:port]) a request's import map may
+	// point at; jsr: and npm: targets count as jsr.io and registry.npmjs.org.
+	ImportAllowlist []string
+	// DenoConfigDenylist are top-level deno.json keys removed from a
+	// request's denoConfig, on top of tasks, which always is.
+	DenoConfigDenylist []string
+	// NpmTenants are the tenants whose requests may enable npm: specifiers;
+	// "*" allows every tenant. Everyone else runs with --no-npm.
+	NpmTenants map[string]bool
+	// NoRemote runs every job as if it set remoteImports: false, loading
+	// modules only from the shared cache. PrecacheModules are specifiers
+	// downloaded into that cache at startup so they stay usable.
+	NoRemote        bool
+	PrecacheModules []string
+
+	// LogMetadataKeys are the RunRequest.Metadata keys included in the
+	// runner's log lines for a job; other metadata is never logged.
+	LogMetadataKeys []string
+	// ReplySubjectPrefixes are the subjects RunRequest.ReplySubject may lie
+	// under. The default allows only inboxes.
+	ReplySubjectPrefixes []string
+	// SubjectPrefix is the first token or tokens of every subject the
+	// runner subscribes and publishes to: <prefix>.execute, <prefix>.cancel,
+	// <prefix>.info and so on, "runner" by default. Runners with different
+	// prefixes can share a NATS cluster without seeing each other's jobs.
+	SubjectPrefix string
+	// ResultsSubject is where the runner publishes the results of requests
+	// that came without a reply inbox or a ReplySubject.
+	ResultsSubject string
+	// QueueGroup is the NATS queue group runners subscribe to job subjects
+	// in, so each job goes to one of them. Broadcast subscribes without one,
+	// and every runner then runs every job; only for single runners.
+	QueueGroup string
+	Broadcast  bool
+	// WebhookAllowlist holds the schemes and hosts RunRequest.WebhookURL may
+	// point at; webhooks are disabled when it is empty. Deliveries are
+	// signed with WebhookSecret and retried up to WebhookRetries times,
+	// waiting WebhookBackoff, doubled each time, in between. WebhookTimeout
+	// bounds each attempt.
+	WebhookAllowlist []*url.URL
+	WebhookSecret    string
+	WebhookRetries   int
+	WebhookBackoff   time.Duration
+	WebhookTimeout   time.Duration
+
+	// WorkDir is the base directory per-job scratch directories are created in.
+	// Point it at a tmpfs to keep job files off disk.
+	WorkDir string
+	// DiskQuota caps the bytes a job may store in its working directory (0 disables it).
+	DiskQuota int64
+	// WarmPoolSize is how many deno processes are kept started ahead of time
+	// for zero-permission jobs; zero disables the warm pool.
+	WarmPoolSize int
+	// MaxRetries is how many times a job that failed for infrastructure
+	// reasons (spawn error, crash or kill signal from outside the runner) is
+	// run again, waiting RetryBackoff, doubled each time, in between.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// CacheTTL and CacheMaxEntries bound the result cache for cacheable
+	// requests; CacheFailures also caches runs that failed in the script.
+	CacheTTL        time.Duration
+	CacheMaxEntries int
+	CacheFailures   bool
+	// IdempotencyTTL and IdempotencyMaxEntries bound how long and how many
+	// idempotency keys' results are remembered. IdempotencyBucket, when
+	// set, is a JetStream KV bucket they are also kept in, so that runners
+	// sharing it replay each other's results and a restart forgets nothing.
+	IdempotencyTTL        time.Duration
+	IdempotencyMaxEntries int
+	IdempotencyBucket     string
+	// WorkStream, when set, is a JetStream work-queue stream on WorkSubject
+	// the runner also takes jobs from, through the durable pull consumer
+	// WorkConsumer it shares with the other runners. Unlike core requests,
+	// jobs there survive runners being down: a job is acked once answered
+	// and naked, to be redelivered after WorkNakDelay, when the runner could
+	// not run it. WorkAckWait is how long a job may go without being acked
+	// or reported in progress before JetStream redelivers it.
+	WorkStream   string
+	WorkSubject  string
+	WorkConsumer string
+	WorkAckWait  time.Duration
+	WorkNakDelay time.Duration
+	// WorkMaxDeliver is how many times a job from the work queue is tried.
+	// A job that still fails to run, or keeps the runner from answering it,
+	// goes to DeadLetterSubject instead, as do undecodable ones.
+	WorkMaxDeliver    int
+	DeadLetterSubject string
+	// ResultBucket, when set, is a JetStream KV bucket results are kept in
+	// for ResultTTL, by PublicID, to be looked up on runner.result.<id>.
+	// Results over ResultMaxBytes are stored cut down.
+	ResultBucket   string
+	ResultTTL      time.Duration
+	ResultMaxBytes int64
+	// ResultObjectBucket, when set, is a JetStream object store replies over
+	// ResultOffloadBytes are uploaded to, for ResultObjectTTL, with the
+	// reply pointing at them. ResultOffloadBytes defaults to what fits in
+	// the server's max_payload.
+	ResultObjectBucket string
+	ResultObjectTTL    time.Duration
+	ResultOffloadBytes int64
+	// StripANSI removes terminal escape sequences from job output by default.
+	StripANSI bool
+	// StdinIdleTimeout stops an interactive job that has had no input for this
+	// long while its stdin is still open.
+	StdinIdleTimeout time.Duration
+	// HeartbeatInterval is how often a running job publishes a progress event.
+	HeartbeatInterval time.Duration
+	// MaxInputFilesBytes caps the decoded size of all RunRequest.Files together.
+	MaxInputFilesBytes int64
+	// MaxSourceBytes caps a source object, which isn't held to the limits
+	// on inline code and files.
+	MaxSourceBytes int64
+	// MaxArtifactBytes and MaxArtifactsTotalBytes cap each collected artifact
+	// and all of a job's artifacts together; larger files are skipped.
+	MaxArtifactBytes       int64
+	MaxArtifactsTotalBytes int64
+
+	// ExecCredential, when set, is the unprivileged identity deno runs as.
+	ExecCredential *jobCredential
+}
+
+// loadConfig reads runner settings from the environment.
+// Invalid values are reported as errors so the runner fails fast at startup.
+func loadConfig() (*Config, error) {
+	cfg := &Config{
+		NatsURLs:               envList("NATS_URL"),
+		NatsName:               os.Getenv("RUNNER_NATS_NAME"),
+		NatsCreds:              os.Getenv("NATS_CREDS"),
+		NatsNkeySeed:           os.Getenv("NATS_NKEY_SEED"),
+		NatsToken:              os.Getenv("NATS_TOKEN"),
+		NatsUser:               os.Getenv("NATS_USER"),
+		NatsPassword:           os.Getenv("NATS_PASSWORD"),
+		RunnerID:               os.Getenv("RUNNER_ID"),
+		DefaultTimeout:         30 * time.Second,
+		MaxTimeout:             defaultMaxTimeout,
+		BenchTimeout:           10 * time.Second,
+		BenchWeight:            2,
+		KillGrace:              2 * time.Second,
+		HeartbeatInterval:      10 * time.Second,
+		StdinIdleTimeout:       60 * time.Second,
+		CacheTTL:               5 * time.Minute,
+		MaxRetries:             1,
+		RetryBackoff:           200 * time.Millisecond,
+		CacheMaxEntries:        1000,
+		IdempotencyTTL:         time.Hour,
+		IdempotencyMaxEntries:  10000,
+		MaxConcurrent:          runtime.NumCPU(),
+		MaxQueueDepth:          64,
+		PriorityAging:          10 * time.Second,
+		MaxScheduled:           1000,
+		MaxBatchTimeout:        10 * time.Minute,
+		DrainTimeout:           20 * time.Second,
+		TenantMaxQueued:        16,
+		TenantSeparator:        ":",
+		SerializeMaxQueued:     8,
+		MaxRequestBytes:        8 << 20,
+		MaxCodeBytes:           1 << 20,
+		MaxEvalBytes:           4 << 10,
+		DenoConfigDenylist:     []string{"workspace", "links", "patch"},
+		ReplySubjectPrefixes:   []string{"_INBOX."},
+		SubjectPrefix:          "runner",
+		QueueGroup:             "runners",
+		WorkConsumer:           "runners",
+		WorkAckWait:            30 * time.Second,
+		WorkNakDelay:           5 * time.Second,
+		WorkMaxDeliver:         5,
+		ResultTTL:              24 * time.Hour,
+		ResultMaxBytes:         512 << 10,
+		ResultObjectTTL:        time.Hour,
+		WebhookRetries:         3,
+		WebhookBackoff:         time.Second,
+		WebhookTimeout:         10 * time.Second,
+		MaxOutputBytes:         256 << 10,
+		OutputTailBytes:        16 << 10,
+		MaxValueBytes:          1 << 20,
+		CompressThreshold:      64 << 10,
+		V8HeapMB:               512,
+		MaxInputFilesBytes:     1 << 20,
+		MaxSourceBytes:         64 << 20,
+		MaxArtifactBytes:       1 << 20,
+		MaxArtifactsTotalBytes: 4 << 20,
+		CgroupRoot:             os.Getenv("RUNNER_CGROUP_ROOT"),
+		WorkDir:                os.Getenv("RUNNER_WORK_DIR"),
+	}
+	if cfg.RunnerID == "" {
+		cfg.RunnerID = defaultRunnerID()
+	}
+	if len(cfg.NatsURLs) == 0 {
+		cfg.NatsURLs = []string{"127.0.0.1:4222"}
+	}
+	if cfg.NatsName == "" {
+		cfg.NatsName = "runner " + cfg.RunnerID
+	}
+
+	var err error
+	if err = checkNatsURLs(cfg.NatsURLs); err != nil {
+		return nil, err
+	}
+	if err = cfg.checkNatsAuth(); err != nil {
+		return nil, err
+	}
+	if err = cfg.checkNatsTLS(); err != nil {
+		return nil, err
+	}
+	if s := os.Getenv("RUNNER_SUBJECT_PREFIX"); s != "" {
+		if err := literalSubject(s); err != nil {
+			return nil, fmt.Errorf("RUNNER_SUBJECT_PREFIX: %q %v", s, err)
+		}
+		cfg.SubjectPrefix = s
+	}
+	cfg.ResultsSubject = cfg.subject("results")
+	cfg.WorkSubject = cfg.subject("work")
+	cfg.DeadLetterSubject = cfg.subject("dlq")
+	if cfg.NatsNoRandomize, err = envBool("RUNNER_NATS_NO_RANDOMIZE", false); err != nil {
+		return nil, err
+	}
+	cfg.NatsMaxReconnects = -1
+	if v := os.Getenv("RUNNER_NATS_MAX_RECONNECTS"); v != "" {
+		if cfg.NatsMaxReconnects, err = strconv.Atoi(v); err != nil || cfg.NatsMaxReconnects < -1 {
+			return nil, fmt.Errorf("invalid RUNNER_NATS_MAX_RECONNECTS %q: must be -1 or a count", v)
+		}
+	}
+	if cfg.MaxTimeout, err = envDuration("RUNNER_MAX_TIMEOUT", cfg.MaxTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.DefaultTimeout, err = envDuration("RUNNER_DEFAULT_TIMEOUT", cfg.DefaultTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.DefaultTimeout > cfg.MaxTimeout {
+		return nil, fmt.Errorf("RUNNER_DEFAULT_TIMEOUT must not exceed RUNNER_MAX_TIMEOUT (%v)", cfg.MaxTimeout)
+	}
+	if cfg.BenchTimeout, err = envDuration("RUNNER_BENCH_TIMEOUT", cfg.BenchTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.BenchTimeout > cfg.MaxTimeout {
+		return nil, fmt.Errorf("RUNNER_BENCH_TIMEOUT must not exceed RUNNER_MAX_TIMEOUT (%v)", cfg.MaxTimeout)
+	}
+	if cfg.BenchWeight, err = envInt("RUNNER_BENCH_WEIGHT", cfg.BenchWeight); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConcurrent, err = envInt("RUNNER_MAX_CONCURRENT", cfg.MaxConcurrent); err != nil {
+		return nil, err
+	}
+	if cfg.MaxQueueDepth, err = envInt("RUNNER_MAX_QUEUE_DEPTH", cfg.MaxQueueDepth); err != nil {
+		return nil, err
+	}
+	if cfg.DrainTimeout, err = envDuration("RUNNER_DRAIN_TIMEOUT", cfg.DrainTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBatchTimeout, err = envDuration("RUNNER_MAX_BATCH_TIMEOUT", cfg.MaxBatchTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.MaxScheduled, err = envInt("RUNNER_MAX_SCHEDULED", cfg.MaxScheduled); err != nil {
+		return nil, err
+	}
+	if cfg.DefaultTTL, err = envDuration("RUNNER_DEFAULT_TTL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DeadlineSkew, err = envDuration("RUNNER_DEADLINE_SKEW", 0); err != nil {
+		return nil, err
+	}
+	if cfg.PriorityAging, err = envDuration("RUNNER_PRIORITY_AGING", cfg.PriorityAging); err != nil {
+		return nil, err
+	}
+	if cfg.TenantMaxConcurrent, err = envInt("RUNNER_TENANT_MAX_CONCURRENT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.TenantLimits, err = envIntMap("RUNNER_TENANT_LIMITS"); err != nil {
+		return nil, err
+	}
+	if cfg.TenantPermissions, err = envTenantPermissions("RUNNER_TENANT_PERMISSIONS"); err != nil {
+		return nil, err
+	}
+	if cfg.TenantWeights, err = envIntMap("RUNNER_TENANT_WEIGHTS"); err != nil {
+		return nil, err
+	}
+	if cfg.TenantMaxQueued, err = envInt("RUNNER_TENANT_MAX_QUEUED", cfg.TenantMaxQueued); err != nil {
+		return nil, err
+	}
+	if v := os.Getenv("RUNNER_TENANT_SEPARATOR"); v != "" {
+		cfg.TenantSeparator = v
+	}
+	if cfg.TenantSubjects, err = envBool("RUNNER_TENANT_SUBJECTS", false); err != nil {
+		return nil, err
+	}
+	if cfg.TenantSubjectStrict, err = envBool("RUNNER_TENANT_SUBJECT_STRICT", false); err != nil {
+		return nil, err
+	}
+	if cfg.SerializeByID, err = envBool("RUNNER_SERIALIZE_BY_ID", false); err != nil {
+		return nil, err
+	}
+	if cfg.SerializeMaxQueued, err = envInt("RUNNER_SERIALIZE_MAX_QUEUED", cfg.SerializeMaxQueued); err != nil {
+		return nil, err
+	}
+	if cfg.KillGrace, err = envDuration("RUNNER_KILL_GRACE", cfg.KillGrace); err != nil {
+		return nil, err
+	}
+	if cfg.WarmPoolSize, err = envCount("RUNNER_WARM_POOL_SIZE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRetries, err = envCount("RUNNER_MAX_RETRIES", cfg.MaxRetries); err != nil {
+		return nil, err
+	}
+	if cfg.RetryBackoff, err = envDuration("RUNNER_RETRY_BACKOFF", cfg.RetryBackoff); err != nil {
+		return nil, err
+	}
+	if cfg.CacheTTL, err = envDuration("RUNNER_CACHE_TTL", cfg.CacheTTL); err != nil {
+		return nil, err
+	}
+	if cfg.CacheMaxEntries, err = envInt("RUNNER_CACHE_MAX_ENTRIES", cfg.CacheMaxEntries); err != nil {
+		return nil, err
+	}
+	if cfg.CacheFailures, err = envBool("RUNNER_CACHE_FAILURES", false); err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyTTL, err = envDuration("RUNNER_IDEMPOTENCY_TTL", cfg.IdempotencyTTL); err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyMaxEntries, err = envInt("RUNNER_IDEMPOTENCY_MAX_ENTRIES", cfg.IdempotencyMaxEntries); err != nil {
+		return nil, err
+	}
+	cfg.IdempotencyBucket = os.Getenv("RUNNER_IDEMPOTENCY_KV_BUCKET")
+	cfg.ResultBucket = os.Getenv("RUNNER_RESULT_KV_BUCKET")
+	if cfg.ResultTTL, err = envDuration("RUNNER_RESULT_TTL", cfg.ResultTTL); err != nil {
+		return nil, err
+	}
+	if cfg.ResultMaxBytes, err = envBytes("RUNNER_RESULT_MAX_BYTES", cfg.ResultMaxBytes); err != nil {
+		return nil, err
+	}
+	cfg.ResultObjectBucket = os.Getenv("RUNNER_RESULT_OBJECT_BUCKET")
+	if cfg.ResultObjectTTL, err = envDuration("RUNNER_RESULT_OBJECT_TTL", cfg.ResultObjectTTL); err != nil {
+		return nil, err
+	}
+	if cfg.ResultOffloadBytes, err = envBytes("RUNNER_RESULT_OFFLOAD_BYTES", 0); err != nil {
+		return nil, err
+	}
+	cfg.WorkStream = os.Getenv("RUNNER_WORK_STREAM")
+	if s := os.Getenv("RUNNER_WORK_SUBJECT"); s != "" {
+		if err := literalSubject(s); err != nil {
+			return nil, fmt.Errorf("RUNNER_WORK_SUBJECT: %q %v", s, err)
+		}
+		cfg.WorkSubject = s
+	}
+	if s := os.Getenv("RUNNER_WORK_CONSUMER"); s != "" {
+		cfg.WorkConsumer = s
+	}
+	if cfg.WorkAckWait, err = envDuration("RUNNER_WORK_ACK_WAIT", cfg.WorkAckWait); err != nil {
+		return nil, err
+	}
+	if cfg.WorkNakDelay, err = envDuration("RUNNER_WORK_NAK_DELAY", cfg.WorkNakDelay); err != nil {
+		return nil, err
+	}
+	if cfg.WorkMaxDeliver, err = envInt("RUNNER_WORK_MAX_DELIVER", cfg.WorkMaxDeliver); err != nil {
+		return nil, err
+	}
+	if s := os.Getenv("RUNNER_DEAD_LETTER_SUBJECT"); s != "" {
+		if err := literalSubject(s); err != nil {
+			return nil, fmt.Errorf("RUNNER_DEAD_LETTER_SUBJECT: %q %v", s, err)
+		}
+		cfg.DeadLetterSubject = s
+	}
+	if cfg.StrictRequests, err = envBool("RUNNER_STRICT_REQUESTS", false); err != nil {
+		return nil, err
+	}
+	if cfg.RequireNetIsolation, err = envBool("RUNNER_REQUIRE_NET_ISOLATION", false); err != nil {
+		return nil, err
+	}
+	if cfg.StripANSI, err = envBool("RUNNER_STRIP_ANSI", false); err != nil {
+		return nil, err
+	}
+	if cfg.StdinIdleTimeout, err = envDuration("RUNNER_STDIN_IDLE_TIMEOUT", cfg.StdinIdleTimeout); err != nil {
+		return nil, err
+	}
+	if cfg.HeartbeatInterval, err = envDuration("RUNNER_HEARTBEAT_INTERVAL", cfg.HeartbeatInterval); err != nil {
+		return nil, err
+	}
+	if cfg.MaxRequestBytes, err = envBytes("RUNNER_MAX_REQUEST_BYTES", cfg.MaxRequestBytes); err != nil {
+		return nil, err
+	}
+	if cfg.MaxCodeBytes, err = envBytes("RUNNER_MAX_CODE_BYTES", cfg.MaxCodeBytes); err != nil {
+		return nil, err
+	}
+	if cfg.MaxEvalBytes, err = envBytes("RUNNER_MAX_EVAL_BYTES", cfg.MaxEvalBytes); err != nil {
+		return nil, err
+	}
+	if cfg.UnstableFeatures, err = envUnstableFeatures("RUNNER_UNSTABLE_FEATURES"); err != nil {
+		return nil, err
+	}
+	cfg.ImportAllowlist = envList("RUNNER_IMPORT_ALLOWLIST")
+	for _, tenant := range envList("RUNNER_NPM_TENANTS") {
+		if cfg.NpmTenants == nil {
+			cfg.NpmTenants = make(map[string]bool)
+		}
+		cfg.NpmTenants[tenant] = true
+	}
+	if cfg.NoRemote, err = envBool("RUNNER_NO_REMOTE", false); err != nil {
+		return nil, err
+	}
+	cfg.PrecacheModules = envList("RUNNER_PRECACHE_MODULES")
+	cfg.LogMetadataKeys = envList("RUNNER_LOG_METADATA_KEYS")
+	if os.Getenv("RUNNER_REPLY_SUBJECT_PREFIXES") != "" {
+		cfg.ReplySubjectPrefixes = envList("RUNNER_REPLY_SUBJECT_PREFIXES")
+	}
+	if cfg.WebhookAllowlist, err = envWebhookAllowlist("RUNNER_WEBHOOK_ALLOWLIST"); err != nil {
+		return nil, err
+	}
+	cfg.WebhookSecret = os.Getenv("RUNNER_WEBHOOK_SECRET")
+	if len(cfg.WebhookAllowlist) > 0 && cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("RUNNER_WEBHOOK_SECRET is required with RUNNER_WEBHOOK_ALLOWLIST")
+	}
+	if cfg.WebhookRetries, err = envCount("RUNNER_WEBHOOK_RETRIES", cfg.WebhookRetries); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookBackoff, err = envDuration("RUNNER_WEBHOOK_BACKOFF", cfg.WebhookBackoff); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookTimeout, err = envDuration("RUNNER_WEBHOOK_TIMEOUT", cfg.WebhookTimeout); err != nil {
+		return nil, err
+	}
+	if s := os.Getenv("RUNNER_QUEUE_GROUP"); s != "" {
+		if err := literalSubject(s); err != nil || strings.Contains(s, ".") {
+			return nil, fmt.Errorf("RUNNER_QUEUE_GROUP: %q must be a single token", s)
+		}
+		cfg.QueueGroup = s
+	}
+	if cfg.Broadcast, err = envBool("RUNNER_BROADCAST", false); err != nil {
+		return nil, err
+	}
+	if s := os.Getenv("RUNNER_RESULTS_SUBJECT"); s != "" {
+		if err := literalSubject(s); err != nil {
+			return nil, fmt.Errorf("RUNNER_RESULTS_SUBJECT: %q %v", s, err)
+		}
+		cfg.ResultsSubject = s
+	}
+	for _, prefix := range cfg.ReplySubjectPrefixes {
+		if err := literalSubject(strings.TrimSuffix(prefix, ".")); err != nil {
+			return nil, fmt.Errorf("RUNNER_REPLY_SUBJECT_PREFIXES: %q %v", prefix, err)
+		}
+	}
+	if os.Getenv("RUNNER_DENO_CONFIG_DENYLIST") != "" {
+		cfg.DenoConfigDenylist = envList("RUNNER_DENO_CONFIG_DENYLIST")
+	}
+	if cfg.MaxOutputBytes, err = envBytes("RUNNER_MAX_OUTPUT_BYTES", cfg.MaxOutputBytes); err != nil {
+		return nil, err
+	}
+	if cfg.OutputTailBytes, err = envBytes("RUNNER_OUTPUT_TAIL_BYTES", cfg.OutputTailBytes); err != nil {
+		return nil, err
+	}
+	if cfg.OutputTailBytes > cfg.MaxOutputBytes {
+		cfg.OutputTailBytes = cfg.MaxOutputBytes
+	}
+	if cfg.MaxValueBytes, err = envBytes("RUNNER_MAX_VALUE_BYTES", cfg.MaxValueBytes); err != nil {
+		return nil, err
+	}
+	if cfg.CompressThreshold, err = envByteCount("RUNNER_COMPRESS_THRESHOLD", cfg.CompressThreshold); err != nil {
+		return nil, err
+	}
+	if cfg.OutputRateLimit, err = envBytes("RUNNER_OUTPUT_RATE_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.OutputRateWindow, err = envDuration("RUNNER_OUTPUT_RATE_WINDOW", 2*time.Second); err != nil {
+		return nil, err
+	}
+
+	if cfg.V8HeapMB, err = envInt("RUNNER_V8_HEAP_MB", cfg.V8HeapMB); err != nil {
+		return nil, err
+	}
+	if cfg.V8HeapCeilingMB, err = envInt("RUNNER_V8_HEAP_CEILING_MB", cfg.V8HeapMB); err != nil {
+		return nil, err
+	}
+	if cfg.V8HeapCeilingMB < cfg.V8HeapMB {
+		return nil, fmt.Errorf("RUNNER_V8_HEAP_CEILING_MB (%d) must be at least RUNNER_V8_HEAP_MB (%d)", cfg.V8HeapCeilingMB, cfg.V8HeapMB)
+	}
+	if cfg.MemoryLimit, err = envBytes("RUNNER_MEMORY_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.PidsLimit, err = envInt("RUNNER_PIDS_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CPULimit, err = envDuration("RUNNER_CPU_LIMIT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CPULimit > 0 && cfg.CPULimit < time.Second {
+		return nil, fmt.Errorf("RUNNER_CPU_LIMIT must be at least 1s")
+	}
+	cfg.CPULimit = cfg.CPULimit.Truncate(time.Second)
+	if cfg.JobNice, err = envCount("RUNNER_JOB_NICE", 0); err != nil {
+		return nil, err
+	}
+	if cfg.JobNice > 19 {
+		return nil, fmt.Errorf("RUNNER_JOB_NICE must be between 0 and 19")
+	}
+	cfg.JobIOClass = os.Getenv("RUNNER_JOB_IO_CLASS")
+	if cfg.JobIOClass != "" && cfg.JobIOClass != "best-effort" && cfg.JobIOClass != "idle" {
+		return nil, fmt.Errorf("RUNNER_JOB_IO_CLASS must be best-effort or idle, got %q", cfg.JobIOClass)
+	}
+	if cfg.JobIOPriority, err = envCount("RUNNER_JOB_IO_PRIORITY", 4); err != nil {
+		return nil, err
+	}
+	if cfg.JobIOPriority > 7 {
+		return nil, fmt.Errorf("RUNNER_JOB_IO_PRIORITY must be between 0 and 7")
+	}
+	if cfg.ExecCredential, err = execCredential(); err != nil {
+		return nil, err
+	}
+	if cfg.WorkDir == "" {
+		cfg.WorkDir = os.TempDir()
+	}
+	if cfg.DiskQuota, err = envBytes("RUNNER_DISK_QUOTA", 0); err != nil {
+		return nil, err
+	}
+	if cfg.MaxInputFilesBytes, err = envBytes("RUNNER_MAX_INPUT_FILES_BYTES", cfg.MaxInputFilesBytes); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSourceBytes, err = envBytes("RUNNER_MAX_SOURCE_BYTES", cfg.MaxSourceBytes); err != nil {
+		return nil, err
+	}
+	if cfg.MaxArtifactBytes, err = envBytes("RUNNER_MAX_ARTIFACT_BYTES", cfg.MaxArtifactBytes); err != nil {
+		return nil, err
+	}
+	if cfg.MaxArtifactsTotalBytes, err = envBytes("RUNNER_MAX_ARTIFACTS_BYTES", cfg.MaxArtifactsTotalBytes); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(cfg.WorkDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("RUNNER_WORK_DIR %q is not a directory", cfg.WorkDir)
+	}
+	probe, err := os.MkdirTemp(cfg.WorkDir, "runner-probe-")
+	if err != nil {
+		return nil, fmt.Errorf("RUNNER_WORK_DIR %q is not writable: %w", cfg.WorkDir, err)
+	}
+	os.Remove(probe)
+
+	return cfg, nil
+}
+
+// tenantOf returns the tenant a request is accounted to.
+func (c *Config) tenantOf(req *RunRequest) string {
+	if req.Tenant != "" {
+		return req.Tenant
+	}
+	if prefix, _, ok := strings.Cut(req.PublicID, c.TenantSeparator); ok {
+		return prefix
+	}
+	return ""
+}
+
+// tenantLimit returns the concurrency quota for a tenant (0 = unlimited).
+func (c *Config) tenantLimit(tenant string) int {
+	if n, ok := c.TenantLimits[tenant]; ok {
+		return n
+	}
+	return c.TenantMaxConcurrent
+}
+
+// tenantWeight returns a tenant's share of the worker pool when jobs queue.
+func (c *Config) tenantWeight(tenant string) int {
+	if n, ok := c.TenantWeights[tenant]; ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// schedPriority returns the CPU and I/O priority jobs are started with.
+func (c *Config) schedPriority() schedPriority {
+	s := schedPriority{Nice: c.JobNice, IOLevel: c.JobIOPriority}
+	switch c.JobIOClass {
+	case "best-effort":
+		s.IOClass = ioClassBestEffort
+	case "idle":
+		s.IOClass = ioClassIdle
+		s.IOLevel = 0
+	}
+	return s
+}
+
+// checkRequestSize refuses request messages over MaxRequestBytes.
+func (c *Config) checkRequestSize(n int) error {
+	if int64(n) > c.MaxRequestBytes {
+		return fmt.Errorf("request is %d bytes (max %d)", n, c.MaxRequestBytes)
+	}
+	return nil
+}
+
+// cgroupLimits returns the per-job cgroup settings derived from the config.
+func (c *Config) cgroupLimits() cgroupLimits {
+	return cgroupLimits{MemoryMax: c.MemoryLimit, PidsMax: int64(c.PidsLimit)}
+}
+
+// envDuration parses a positive duration (e.g. "30s") from the environment,
+// returning def when the variable is unset.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, v)
+	}
+	return d, nil
+}
+
+// envBool parses a boolean ("true", "1", "false", ...) from the environment.
+func envBool(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	return b, nil
+}
+
+// envInt parses a positive integer from the environment, returning def when unset.
+func envInt(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, v)
+	}
+	return n, nil
+}
+
+// envCount parses a non-negative integer from the environment, returning def when unset.
+func envCount(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", name, v)
+	}
+	return n, nil
+}
+
+// envList parses a comma-separated list from the environment, dropping
+// empty entries.
+func envList(name string) []string {
+	var list []string
+	for _, entry := range strings.Split(os.Getenv(name), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// envIntMap parses "key=n,key2=m" from the environment.
+func envIntMap(name string) (map[string]int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	m := make(map[string]int)
+	for _, pair := range strings.Split(v, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		n, err := strconv.Atoi(val)
+		if !ok || key == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: want key=n", name, pair)
+		}
+		m[key] = n
+	}
+	return m, nil
+}
+
+// envBytes parses a byte size from the environment, returning def when unset.
+func envBytes(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := parseBytes(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be positive", name, v)
+	}
+	return n, nil
+}
+
+// envByteCount is envBytes for settings where 0 is meaningful.
+func envByteCount(name string, def int64) (int64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := parseBytes(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+	}
+	return n, nil
+}
+
+// parseBytes parses sizes like "512", "256K", "64M" or "1G" (binary units).
+func parseBytes(v string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	s = strings.TrimSuffix(s, "B")
+	shift := 0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		shift = 10
+	case strings.HasSuffix(s, "M"):
+		shift = 20
+	case strings.HasSuffix(s, "G"):
+		shift = 30
+	}
+	if shift > 0 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a byte size")
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n << shift, nil
+}
+
+// checkNatsURLs checks the schemes of NATS_URL's entries, which may leave
+// the scheme out for nats://, and that they agree on TLS.
+func checkNatsURLs(urls []string) error {
+	tlsURLs := 0
+	for _, s := range urls {
+		scheme, _, ok := strings.Cut(s, "://")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(scheme) {
+		case "tls", "wss":
+			tlsURLs++
+		case "nats", "ws":
+		default:
+			return fmt.Errorf("invalid NATS_URL entry %q: scheme must be nats, tls, ws or wss", redactURL(s))
+		}
+	}
+	if tlsURLs > 0 && tlsURLs < len(urls) {
+		return fmt.Errorf("invalid NATS_URL: TLS (tls://, wss://) and plain URLs can't be mixed")
+	}
+	return nil
+}
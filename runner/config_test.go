@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxTimeoutConfig(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want time.Duration
+		err  string
+	}{
+		{env: nil, want: defaultMaxTimeout},
+		{env: map[string]string{"RUNNER_MAX_TIMEOUT": "1h", "RUNNER_DEFAULT_TIMEOUT": "10m"}, want: time.Hour},
+		{env: map[string]string{"RUNNER_MAX_TIMEOUT": "10s"}, err: "RUNNER_DEFAULT_TIMEOUT must not exceed RUNNER_MAX_TIMEOUT (10s)"},
+		{env: map[string]string{"RUNNER_MAX_TIMEOUT": "1m", "RUNNER_BENCH_TIMEOUT": "2m"}, err: "RUNNER_BENCH_TIMEOUT must not exceed RUNNER_MAX_TIMEOUT (1m0s)"},
+		{env: map[string]string{"RUNNER_DEFAULT_TIMEOUT": "6m"}, err: "RUNNER_DEFAULT_TIMEOUT must not exceed RUNNER_MAX_TIMEOUT (5m0s)"},
+		{env: map[string]string{"RUNNER_MAX_TIMEOUT": "0s"}, err: "must be positive"},
+	}
+	for _, tt := range tests {
+		for k, v := range tt.env {
+			t.Setenv(k, v)
+		}
+		cfg, err := loadConfig()
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: err = %v, want %q", tt.env, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%v: %v", tt.env, err)
+		case cfg.MaxTimeout != tt.want:
+			t.Errorf("%v: MaxTimeout %v, want %v", tt.env, cfg.MaxTimeout, tt.want)
+		}
+		for k := range tt.env {
+			t.Setenv(k, "")
+		}
+	}
+}
+
+func TestCompressThresholdConfig(t *testing.T) {
+	tests := []struct {
+		v    string
+		want int64
+		err  string
+	}{
+		{v: "", want: 64 << 10},
+		{v: "0", want: 0},
+		{v: "1M", want: 1 << 20},
+		{v: "-1", err: "must not be negative"},
+		{v: "lots", err: "not a byte size"},
+	}
+	for _, tt := range tests {
+		t.Setenv("RUNNER_COMPRESS_THRESHOLD", tt.v)
+		cfg, err := loadConfig()
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: err = %v, want %q", tt.v, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("%q: %v", tt.v, err)
+		case cfg.CompressThreshold != tt.want:
+			t.Errorf("%q: CompressThreshold %d, want %d", tt.v, cfg.CompressThreshold, tt.want)
+		}
+	}
+}
+
+// TestBytesConfigPositive checks settings read with envBytes still refuse 0.
+func TestBytesConfigPositive(t *testing.T) {
+	t.Setenv("RUNNER_MAX_VALUE_BYTES", "0")
+	if _, err := loadConfig(); err == nil || !strings.Contains(err.Error(), "RUNNER_MAX_VALUE_BYTES \"0\": must be positive") {
+		t.Errorf("err = %v", err)
+	}
+}
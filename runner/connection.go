@@ -0,0 +1,219 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// connFlushTimeout bounds the round trip that confirms the server has the
+// runner's subscriptions after a reconnect.
+const connFlushTimeout = 5 * time.Second
+
+// connTracker follows the NATS connection through disconnects and
+// reconnects, for the logs and runner.info.
+type connTracker struct {
+	mu             sync.Mutex
+	disconnectedAt time.Time // zero while connected
+	lastOutage     time.Duration
+	totalOutage    time.Duration
+}
+
+// ConnectionInfo describes the runner's NATS connection in InfoResult.
+type ConnectionInfo struct {
+	Status     string `json:"status"`
+	Reconnects uint64 `json:"reconnects"`
+	// DisconnectedMs is how long the current outage has lasted; zero while
+	// connected. LastOutageMs is how long the last one that ended lasted,
+	// and TotalOutageMs the sum of all of them since startup.
+	DisconnectedMs int64 `json:"disconnectedMs,omitempty"`
+	LastOutageMs   int64 `json:"lastOutageMs,omitempty"`
+	TotalOutageMs  int64 `json:"totalOutageMs,omitempty"`
+	// Server is the URL of the server the runner is connected to, with any
+	// credentials redacted, and ServerID, ServerName and Cluster what that
+	// server says of itself; all empty while disconnected.
+	Server     string `json:"server,omitempty"`
+	ServerID   string `json:"serverId,omitempty"`
+	ServerName string `json:"serverName,omitempty"`
+	Cluster    string `json:"cluster,omitempty"`
+	// Servers is the pool the client fails over between: the configured
+	// URLs and the ones the cluster announced.
+	Servers []string `json:"servers"`
+}
+
+// natsOptions returns the options the runner connects with.
+func (c *Config) natsOptions() []nats.Option {
+	opts := []nats.Option{
+		nats.Name(c.NatsName),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(c.NatsMaxReconnects),
+		nats.DiscoveredServersHandler(func(nc *nats.Conn) {
+			log.Printf("[NATS] Discovered servers %s; pool is now %s", strings.Join(nc.DiscoveredServers(), ", "), strings.Join(nc.Servers(), ", "))
+		}),
+		// Failed connects, TLS handshakes among them, are otherwise only
+		// retried quietly.
+		nats.ReconnectErrHandler(func(nc *nats.Conn, err error) {
+			log.Printf("[NATS] Connect attempt failed: %v", err)
+		}),
+		nats.ConnectHandler(func(nc *nats.Conn) {
+			log.Printf("[NATS] Connected to %s (%s)", redactURL(nc.ConnectedUrl()), nc.ConnectedServerName())
+		}),
+	}
+	if c.NatsNoRandomize {
+		opts = append(opts, nats.DontRandomize())
+	}
+	opts = append(opts, c.natsTLSOptions()...)
+	return append(opts, c.natsAuthOptions()...)
+}
+
+// redactURL returns s with any credentials in it replaced, for logs. A URL
+// without a scheme is taken as nats://, as the client does.
+func redactURL(s string) string {
+	bare := !strings.Contains(s, "://")
+	u, err := url.Parse(s)
+	if bare {
+		u, err = url.Parse("nats://" + s)
+	}
+	if err != nil {
+		if strings.Contains(s, "@") {
+			return "(unparseable URL)"
+		}
+		return s
+	}
+	if u.User == nil {
+		return s
+	}
+	u.User = url.User("redacted")
+	if bare {
+		return strings.TrimPrefix(u.String(), "nats://")
+	}
+	return u.String()
+}
+
+// redactURLs joins urls with their credentials redacted, for logs.
+func redactURLs(urls []string) string {
+	redacted := make([]string, len(urls))
+	for i, s := range urls {
+		redacted[i] = redactURL(s)
+	}
+	return strings.Join(redacted, ", ")
+}
+
+// subscription is one of the runner's long-lived subscriptions, kept so it
+// can be made again if a reconnect loses it.
+type subscription struct {
+	subject string
+	queue   string // empty for a plain subscription
+	handler nats.MsgHandler
+	mu      sync.Mutex
+	sub     *nats.Subscription
+}
+
+func (s *subscription) current() *nats.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sub
+}
+
+// subscribe subscribes handler to subject, in queue unless it is empty,
+// and tracks the subscription.
+func (r *Runner) subscribe(subject, queue string, handler nats.MsgHandler) (*subscription, error) {
+	s := &subscription{subject: subject, queue: queue, handler: handler}
+	var err error
+	if s.sub, err = r.nc.QueueSubscribe(subject, queue, handler); err != nil {
+		return nil, err
+	}
+	r.subsMu.Lock()
+	r.subs = append(r.subs, s)
+	r.subsMu.Unlock()
+	return s, nil
+}
+
+// watchConnection logs the connection's state changes and checks the
+// runner's subscriptions after each reconnect.
+func (r *Runner) watchConnection() {
+	r.nc.SetDisconnectErrHandler(func(nc *nats.Conn, err error) {
+		r.conn.mu.Lock()
+		if r.conn.disconnectedAt.IsZero() {
+			r.conn.disconnectedAt = time.Now()
+		}
+		r.conn.mu.Unlock()
+		log.Printf("[NATS] Disconnected: %v", err)
+	})
+	r.nc.SetReconnectHandler(func(nc *nats.Conn) {
+		r.conn.mu.Lock()
+		outage := time.Duration(0)
+		if !r.conn.disconnectedAt.IsZero() {
+			outage = time.Since(r.conn.disconnectedAt)
+			r.conn.lastOutage = outage
+			r.conn.totalOutage += outage
+			r.conn.disconnectedAt = time.Time{}
+		}
+		r.conn.mu.Unlock()
+		log.Printf("[NATS] Reconnected to %s after %v (reconnect %d)", redactURL(nc.ConnectedUrl()), outage.Round(time.Millisecond), nc.Stats().Reconnects)
+		go r.verifySubscriptions()
+	})
+	r.nc.SetClosedHandler(func(nc *nats.Conn) {
+		if err := nc.LastError(); err != nil {
+			log.Printf("[NATS] Connection closed: %v", err)
+			return
+		}
+		log.Printf("[NATS] Connection closed")
+	})
+}
+
+// verifySubscriptions makes again any subscription the reconnect lost, and
+// flushes so the server is known to have them all.
+func (r *Runner) verifySubscriptions() {
+	if r.draining.Load() {
+		return
+	}
+	r.subsMu.Lock()
+	subs := append([]*subscription(nil), r.subs...)
+	r.subsMu.Unlock()
+	for _, s := range subs {
+		s.mu.Lock()
+		if s.sub == nil || !s.sub.IsValid() {
+			sub, err := r.nc.QueueSubscribe(s.subject, s.queue, s.handler)
+			if err != nil {
+				log.Printf("[NATS] Failed to resubscribe to %s: %v", s.subject, err)
+			} else {
+				log.Printf("[NATS] Resubscribed to %s", s.subject)
+				s.sub = sub
+			}
+		}
+		s.mu.Unlock()
+	}
+	if err := r.nc.FlushTimeout(connFlushTimeout); err != nil {
+		log.Printf("[NATS] Subscriptions not confirmed after reconnect: %v", err)
+		return
+	}
+	log.Printf("[NATS] %d subscriptions confirmed after reconnect", len(subs))
+}
+
+// connectionInfo reports the connection's state for runner.info.
+func (r *Runner) connectionInfo() ConnectionInfo {
+	r.conn.mu.Lock()
+	defer r.conn.mu.Unlock()
+	info := ConnectionInfo{
+		Status:        r.nc.Status().String(),
+		Reconnects:    r.nc.Stats().Reconnects,
+		LastOutageMs:  r.conn.lastOutage.Milliseconds(),
+		TotalOutageMs: r.conn.totalOutage.Milliseconds(),
+		Servers:       r.nc.Servers(),
+	}
+	if r.nc.IsConnected() {
+		info.Server = redactURL(r.nc.ConnectedUrl())
+		info.ServerID = r.nc.ConnectedServerId()
+		info.ServerName = r.nc.ConnectedServerName()
+		info.Cluster = r.nc.ConnectedClusterName()
+	}
+	if !r.conn.disconnectedAt.IsZero() {
+		info.DisconnectedMs = time.Since(r.conn.disconnectedAt).Milliseconds()
+	}
+	return info
+}
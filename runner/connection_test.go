@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+// runTestServer starts an embedded NATS server on a free port, stopped when
+// the test ends.
+func runTestServer(t *testing.T, name string, mutate func(*server.Options)) *server.Server {
+	t.Helper()
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1
+	opts.ServerName = name
+	if mutate != nil {
+		mutate(&opts)
+	}
+	s := natstest.RunServer(&opts)
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func connectTest(t *testing.T, url string, opts ...nats.Option) *nats.Conn {
+	t.Helper()
+	nc, err := nats.Connect(url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+// recorder counts the messages each of several subscribers received.
+type recorder struct {
+	mu  sync.Mutex
+	got map[string][]string // data -> subscribers that got it
+}
+
+func (rec *recorder) handler(name string) nats.MsgHandler {
+	return func(m *nats.Msg) {
+		rec.mu.Lock()
+		rec.got[string(m.Data)] = append(rec.got[string(m.Data)], name)
+		rec.mu.Unlock()
+	}
+}
+
+// waitFor waits until n messages have been received in all.
+func (rec *recorder) waitFor(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec.mu.Lock()
+		total := 0
+		for _, subs := range rec.got {
+			total += len(subs)
+		}
+		rec.mu.Unlock()
+		if total >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d messages, want %d", total, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestSubscribeShared checks two runners in the queue group each job goes
+// to exactly one of them, and with Broadcast to both.
+func TestSubscribeShared(t *testing.T) {
+	const jobs = 200
+	for _, broadcast := range []bool{false, true} {
+		t.Run(fmt.Sprintf("broadcast=%v", broadcast), func(t *testing.T) {
+			s := runTestServer(t, "a", nil)
+			cfg := &Config{QueueGroup: "runners", Broadcast: broadcast}
+			rec := &recorder{got: map[string][]string{}}
+			var conns []*nats.Conn
+			for _, name := range []string{"r1", "r2"} {
+				r := &Runner{cfg: cfg, nc: connectTest(t, s.ClientURL())}
+				if _, err := r.subscribeShared("runner.execute", rec.handler(name)); err != nil {
+					t.Fatal(err)
+				}
+				if err := r.nc.Flush(); err != nil {
+					t.Fatal(err)
+				}
+				conns = append(conns, r.nc)
+			}
+
+			client := connectTest(t, s.ClientURL())
+			for i := 0; i < jobs; i++ {
+				if err := client.Publish("runner.execute", []byte(fmt.Sprint(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			client.Flush()
+			want := 1
+			if broadcast {
+				want = 2
+			}
+			// Once each subscriber's flush returns the server has sent it
+			// everything; wait for the handlers to catch up.
+			for _, nc := range conns {
+				nc.Flush()
+			}
+			rec.waitFor(t, jobs*want)
+
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			per := map[string]int{}
+			for i := 0; i < jobs; i++ {
+				subs := rec.got[fmt.Sprint(i)]
+				if len(subs) != want {
+					t.Fatalf("job %d delivered to %v, want %d subscribers", i, subs, want)
+				}
+				for _, name := range subs {
+					per[name]++
+				}
+			}
+			if !broadcast && (per["r1"] == 0 || per["r2"] == 0) {
+				t.Errorf("queue group did not share the load: %v", per)
+			}
+		})
+	}
+}
+
+// TestFailover checks the runner moves to another server when the one it is
+// on dies, keeps taking jobs there and reports the new server, both between
+// listed servers and onto one the cluster announced.
+func TestFailover(t *testing.T) {
+	t.Run("listed", func(t *testing.T) {
+		a := runTestServer(t, "a", nil)
+		b := runTestServer(t, "b", nil)
+		checkFailover(t, a, b, []string{a.ClientURL(), b.ClientURL()})
+	})
+	t.Run("discovered", func(t *testing.T) {
+		cluster := func(o *server.Options) {
+			o.Cluster.Name = "c"
+			o.Cluster.Host = "127.0.0.1"
+			o.Cluster.Port = -1
+		}
+		a := runTestServer(t, "a", cluster)
+		b := runTestServer(t, "b", func(o *server.Options) {
+			cluster(o)
+			o.Routes = server.RoutesFromStr(fmt.Sprintf("nats://127.0.0.1:%d", a.ClusterAddr().Port))
+		})
+		deadline := time.Now().Add(5 * time.Second)
+		for a.NumRoutes() == 0 || b.NumRoutes() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("cluster did not form")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		checkFailover(t, a, b, []string{a.ClientURL()})
+	})
+}
+
+// checkFailover connects a runner to urls, the first of them first's, shuts
+// first down and checks jobs submitted on second are still answered.
+func checkFailover(t *testing.T, first, second *server.Server, urls []string) {
+	t.Helper()
+	cfg := &Config{NatsURLs: urls, NatsName: "runner test", NatsMaxReconnects: -1, NatsNoRandomize: true, QueueGroup: "runners"}
+	opts := append(cfg.natsOptions(), nats.ReconnectWait(20*time.Millisecond), nats.ReconnectJitter(0, 0))
+	r := &Runner{cfg: cfg, nc: connectTest(t, strings.Join(urls, ","), opts...)}
+	r.watchConnection()
+	if _, err := r.subscribeShared("runner.execute", func(m *nats.Msg) { m.Respond(append([]byte("done "), m.Data...)) }); err != nil {
+		t.Fatal(err)
+	}
+	request := func(srv *server.Server, job string) {
+		t.Helper()
+		client := connectTest(t, srv.ClientURL())
+		reply, err := client.Request("runner.execute", []byte(job), 5*time.Second)
+		if err != nil {
+			t.Fatalf("%s on %s: %v", job, srv.Name(), err)
+		}
+		if string(reply.Data) != "done "+job {
+			t.Fatalf("%s: reply %q", job, reply.Data)
+		}
+	}
+	request(first, "one")
+	if info := r.connectionInfo(); info.ServerName != first.Name() {
+		t.Fatalf("connected to %q, want %q", info.ServerName, first.Name())
+	}
+	if len(urls) == 1 {
+		// The pool holds the server the cluster announced once its INFO
+		// arrives.
+		deadline := time.Now().Add(5 * time.Second)
+		for len(r.nc.Servers()) < 2 {
+			if time.Now().After(deadline) {
+				t.Fatalf("pool %v never grew", r.nc.Servers())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	first.Shutdown()
+	deadline := time.Now().Add(5 * time.Second)
+	for r.connectionInfo().ServerName != second.Name() {
+		if time.Now().After(deadline) {
+			t.Fatalf("did not fail over: %+v", r.connectionInfo())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := r.nc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	request(second, "two")
+
+	info := r.connectionInfo()
+	if info.Status != "CONNECTED" || info.Reconnects != 1 || info.Server == "" {
+		t.Errorf("after failover: %+v", info)
+	}
+}
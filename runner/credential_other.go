@@ -0,0 +1,23 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+type jobCredential struct {
+	Uid uint32
+	Gid uint32
+}
+
+func execCredential() (*jobCredential, error) {
+	if os.Getenv("RUNNER_EXEC_USER") != "" || os.Getenv("RUNNER_EXEC_UID") != "" || os.Getenv("RUNNER_EXEC_GID") != "" {
+		return nil, errors.New("running jobs as another user requires Unix")
+	}
+	return nil, nil
+}
+
+func setCredential(*syscall.SysProcAttr, *jobCredential) {}
@@ -0,0 +1,66 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// jobCredential is the identity jobs run as.
+type jobCredential = syscall.Credential
+
+// execCredential resolves RUNNER_EXEC_USER (a user name or numeric UID) and the
+// optional RUNNER_EXEC_UID/RUNNER_EXEC_GID overrides. It returns nil when jobs
+// should run with the runner's own credentials. Supplementary groups are
+// always cleared.
+func execCredential() (*jobCredential, error) {
+	name := os.Getenv("RUNNER_EXEC_USER")
+	uidStr, gidStr := os.Getenv("RUNNER_EXEC_UID"), os.Getenv("RUNNER_EXEC_GID")
+	if name == "" && uidStr == "" && gidStr == "" {
+		return nil, nil
+	}
+
+	if name != "" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			if u, err = user.LookupId(name); err != nil {
+				return nil, fmt.Errorf("RUNNER_EXEC_USER %q does not exist", name)
+			}
+		}
+		if uidStr == "" {
+			uidStr = u.Uid
+		}
+		if gidStr == "" {
+			gidStr = u.Gid
+		}
+	}
+	if uidStr == "" || gidStr == "" {
+		return nil, fmt.Errorf("RUNNER_EXEC_UID and RUNNER_EXEC_GID must be set together (or use RUNNER_EXEC_USER)")
+	}
+
+	uid, err := strconv.ParseUint(uidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RUNNER_EXEC_UID %q: %w", uidStr, err)
+	}
+	gid, err := strconv.ParseUint(gidStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RUNNER_EXEC_GID %q: %w", gidStr, err)
+	}
+	if uid == 0 || gid == 0 {
+		return nil, fmt.Errorf("refusing to run jobs as uid/gid 0")
+	}
+	if euid := os.Geteuid(); euid != 0 && uint64(euid) != uid {
+		return nil, fmt.Errorf("switching jobs to uid %d requires the runner to run as root (running as %d)", uid, euid)
+	}
+	return &jobCredential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}, nil
+}
+
+// setCredential makes the process attr describes run as cred, or as the
+// runner itself when cred is nil.
+func setCredential(attr *syscall.SysProcAttr, cred *jobCredential) {
+	attr.Credential = cred
+}
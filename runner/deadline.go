@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Headers a caller may set to bound how long its request stays relevant.
+const (
+	// deadlineHeader carries an absolute RFC3339 deadline, used when the body
+	// does not set one.
+	deadlineHeader = "Runner-Deadline"
+	// sentAtHeader is when the caller sent the request (RFC3339); the default
+	// TTL counts from it rather than from when the runner received it.
+	sentAtHeader = "Runner-Sent-At"
+)
+
+// requestDeadline is RunRequest.Deadline: either an absolute time or a
+// number of milliseconds relative to when the request was received.
+type requestDeadline struct {
+	At       time.Time
+	Relative time.Duration
+}
+
+func (d *requestDeadline) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("deadline: want RFC3339 time or milliseconds: %w", err)
+		}
+		d.At = t
+		return nil
+	}
+	ms, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || ms < 0 {
+		return fmt.Errorf("deadline: want RFC3339 time or milliseconds, got %s", data)
+	}
+	d.Relative = time.Duration(ms) * time.Millisecond
+	return nil
+}
+
+func (d requestDeadline) MarshalJSON() ([]byte, error) {
+	if !d.At.IsZero() {
+		return json.Marshal(d.At.Format(time.RFC3339Nano))
+	}
+	return json.Marshal(d.Relative.Milliseconds())
+}
+
+// jobDeadline works out when a request received at now expires: its own
+// deadline if it has one, then the deadline header, then the default TTL.
+// The zero time means never.
+func (c *Config) jobDeadline(req *RunRequest, h nats.Header, now time.Time) time.Time {
+	if d := req.Deadline; d != nil {
+		if !d.At.IsZero() {
+			return d.At
+		}
+		return now.Add(d.Relative)
+	}
+	if t, err := time.Parse(time.RFC3339, h.Get(deadlineHeader)); err == nil {
+		return t
+	}
+	if c.DefaultTTL <= 0 {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, h.Get(sentAtHeader)); err == nil {
+		return t.Add(c.DefaultTTL)
+	}
+	return now.Add(c.DefaultTTL)
+}
+
+// deadlineLeft returns how long job has until its deadline, allowing for
+// DeadlineSkew between the caller's clock and the runner's, and whether
+// that is still in the future. Jobs without a deadline always have time.
+func (r *Runner) deadlineLeft(job *pendingJob) (time.Duration, bool) {
+	if job.deadline.IsZero() {
+		return 0, true
+	}
+	left := time.Until(job.deadline.Add(r.cfg.DeadlineSkew))
+	return left, left > 0
+}
+
+// deadlineFields renders the inputs of a deadline decision for a log line,
+// for working out clock skew between a caller and the runner.
+func (r *Runner) deadlineFields(job *pendingJob) string {
+	return fmt.Sprintf(" deadline=%s now=%s skew=%v", job.deadline.UTC().Format(time.RFC3339Nano),
+		time.Now().UTC().Format(time.RFC3339Nano), r.cfg.DeadlineSkew)
+}
+
+func expiredResult(job *pendingJob) RunResult {
+	return RunResult{
+		ExitCode:  -1,
+		Error:     fmt.Sprintf("deadline %s passed before the job started", job.deadline.Format(time.RFC3339Nano)),
+		ErrorCode: errorCodeExpired,
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// strictHeader turns strict decoding on or off for a single request,
+// overriding RUNNER_STRICT_REQUESTS.
+const strictHeader = "Runner-Strict"
+
+// maxLoggedPayload caps how much of a malformed request is echoed to the log.
+const maxLoggedPayload = 256
+
+// describeJSONError turns a decoding error into a message that says where
+// the request went wrong.
+func describeJSONError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("malformed JSON at offset %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "request"
+		}
+		return fmt.Sprintf("field %q at offset %d: expected %s, got %s", field, typeErr.Offset, typeErr.Type, typeErr.Value)
+	}
+	return fmt.Sprintf("invalid request: %v", err)
+}
+
+// logPayload quotes data for a log line, truncated to maxLoggedPayload bytes.
+func logPayload(data []byte) string {
+	if len(data) <= maxLoggedPayload {
+		return strconv.Quote(string(data))
+	}
+	return fmt.Sprintf("%s... (%d bytes)", strconv.Quote(string(data[:maxLoggedPayload])), len(data))
+}
+
+// badRequest is the reply to a request that could not be decoded. Unknown
+// fields in strict mode are reported as a validation failure instead.
+func badRequest(err error) RunResult {
+	var errs validationErrors
+	if errors.As(err, &errs) {
+		return errs.result()
+	}
+	return RunResult{ExitCode: 1, Error: describeJSONError(err), ErrorCode: errorCodeBadRequest}
+}
+
+// strictDecoding reports whether a request with header h must not contain
+// fields the runner doesn't know.
+func (c *Config) strictDecoding(h nats.Header) bool {
+	if b, err := strconv.ParseBool(h.Get(strictHeader)); err == nil {
+		return b
+	}
+	return c.StrictRequests
+}
+
+// decodeRequest unmarshals data into v. In strict mode unknown fields are
+// an error, returned as validationErrors naming each of them.
+func decodeRequest(data []byte, v any, strict bool) error {
+	if !strict {
+		return json.Unmarshal(data, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil || !strings.HasPrefix(err.Error(), "json: unknown field") {
+		return err
+	}
+	// The decoder stops at the first one; list them all.
+	var errs validationErrors
+	for _, name := range unknownFields(data, reflect.TypeOf(v).Elem(), "") {
+		errs.add(name, "unknown field")
+	}
+	if len(errs) == 0 {
+		return err
+	}
+	return errs
+}
+
+// unknownFields returns the keys of the JSON object data that don't match a
+// field of struct type t, descending into slices of structs. Matching is
+// case-insensitive, as in encoding/json.
+func unknownFields(data []byte, t reflect.Type, prefix string) []string {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return nil
+	}
+	known := make(map[string]reflect.Type)
+	collectFields(t, known)
+	var unknown []string
+	for key, raw := range obj {
+		ft, ok := known[strings.ToLower(key)]
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct {
+			var items []json.RawMessage
+			if json.Unmarshal(raw, &items) == nil {
+				for i, item := range items {
+					unknown = append(unknown, unknownFields(item, ft.Elem(), fmt.Sprintf("%s%s[%d].", prefix, key, i))...)
+				}
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// collectFields maps the lower-cased JSON names of t's fields, including
+// those of embedded structs, to their types.
+func collectFields(t reflect.Type, into map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && name == "" {
+			collectFields(f.Type, into)
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		into[strings.ToLower(name)] = f.Type
+	}
+}
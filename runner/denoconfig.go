@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// denoConfigName is where a request's deno configuration is written in the
+// working directory.
+const denoConfigName = "deno.json"
+
+// denoConfig is a request's deno.json after sanitizing.
+type denoConfig struct {
+	data     []byte
+	stripped []string // the keys removed, for the log
+	imports  bool     // the config has imports or scopes, which act as an import map
+	hosts    []string // remote hosts those point at
+}
+
+// sanitizeDenoConfig checks a request's deno.json and removes what a job
+// may not configure: tasks, keys in the configured denylist, lockfile and
+// import map paths outside the working directory, and unstable features
+// the runner doesn't allow. Imports and scopes are held to the import
+// allowlist like RunRequest.ImportMap, and are an error if they break it.
+func (c *Config) sanitizeDenoConfig(raw json.RawMessage) (*denoConfig, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &config); err != nil || config == nil {
+		return nil, fmt.Errorf("must be a JSON object")
+	}
+	cfg := &denoConfig{}
+	strip := func(key string) {
+		if _, ok := config[key]; ok {
+			delete(config, key)
+			cfg.stripped = append(cfg.stripped, key)
+		}
+	}
+	strip("tasks")
+	for _, key := range c.DenoConfigDenylist {
+		strip(key)
+	}
+	if lock, ok := config["lock"]; ok && !localConfigPath(lock, true) {
+		strip("lock")
+	}
+	if importMap, ok := config["importMap"]; ok && !localConfigPath(importMap, false) {
+		strip("importMap")
+	}
+	if unstable, ok := config["unstable"]; ok {
+		var features []string
+		if json.Unmarshal(unstable, &features) != nil {
+			return nil, fmt.Errorf("unstable must be a list of feature names")
+		}
+		allowed := make([]string, 0, len(features))
+		for _, name := range features {
+			if c.UnstableFeatures["--unstable-"+name] {
+				allowed = append(allowed, name)
+			}
+		}
+		if len(allowed) < len(features) {
+			cfg.stripped = append(cfg.stripped, "unstable")
+		}
+		config["unstable"], _ = json.Marshal(allowed)
+	}
+
+	_, hasImports := config["imports"]
+	_, hasScopes := config["scopes"]
+	if hasImports || hasScopes {
+		m, _ := json.Marshal(map[string]json.RawMessage{"imports": config["imports"], "scopes": config["scopes"]})
+		hosts, err := c.parseImportMap(m)
+		if err != nil {
+			return nil, fmt.Errorf("imports: %v", err)
+		}
+		cfg.imports, cfg.hosts = true, hosts
+	}
+
+	sort.Strings(cfg.stripped)
+	var err error
+	cfg.data, err = json.Marshal(config)
+	return cfg, err
+}
+
+// localConfigPath reports whether a path setting in deno.json stays inside
+// the working directory the config is written to. Settings that are not a
+// path (lock may also be a bool) are fine; objectPath allows lock's
+// {"path": ...} form.
+func localConfigPath(v json.RawMessage, objectPath bool) bool {
+	var path string
+	if json.Unmarshal(v, &path) != nil {
+		var obj struct {
+			Path string `json:"path"`
+		}
+		if !objectPath || json.Unmarshal(v, &obj) != nil || obj.Path == "" {
+			return true // not a path; deno rejects values it can't use
+		}
+		path = obj.Path
+	}
+	return filepath.IsLocal(path)
+}
+
+// denoConfigFlag returns the --config flag for req, if it has one.
+func denoConfigFlag(req *RunRequest, workdir string) []string {
+	if len(req.DenoConfig) == 0 {
+		return nil
+	}
+	return []string{"--config=" + filepath.Join(workdir, denoConfigName)}
+}
+
+// writeDenoConfig writes a sanitized config into dir. It refuses to
+// overwrite an input file of the same name.
+func writeDenoConfig(dir string, config *denoConfig) error {
+	f, err := os.OpenFile(filepath.Join(dir, denoConfigName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(config.data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
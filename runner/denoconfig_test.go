@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeDenoConfig(t *testing.T) {
+	c := &Config{
+		DenoConfigDenylist: []string{"workspace", "links"},
+		UnstableFeatures:   map[string]bool{"--unstable-kv": true},
+		ImportAllowlist:    []string{"esm.sh"},
+	}
+	tests := []struct {
+		name     string
+		config   string
+		want     string // the sanitized JSON, when not an error
+		stripped []string
+		hosts    []string
+		err      string
+	}{
+		{name: "compiler options kept", config: `{"compilerOptions":{"strict":false,"jsx":"react-jsx"}}`, want: `{"compilerOptions":{"strict":false,"jsx":"react-jsx"}}`},
+		{name: "tasks", config: `{"tasks":{"x":"rm -rf /"},"fmt":{}}`, want: `{"fmt":{}}`, stripped: []string{"tasks"}},
+		{name: "denylist", config: `{"workspace":["./a"],"links":[],"lint":{}}`, want: `{"lint":{}}`, stripped: []string{"links", "workspace"}},
+		{name: "lock bool", config: `{"lock":false}`, want: `{"lock":false}`},
+		{name: "lock inside", config: `{"lock":"deno.lock"}`, want: `{"lock":"deno.lock"}`},
+		{name: "lock object inside", config: `{"lock":{"path":"locks/deno.lock","frozen":true}}`, want: `{"lock":{"path":"locks/deno.lock","frozen":true}}`},
+		{name: "lock parent", config: `{"lock":"../deno.lock"}`, want: `{}`, stripped: []string{"lock"}},
+		{name: "lock absolute", config: `{"lock":"/etc/passwd"}`, want: `{}`, stripped: []string{"lock"}},
+		{name: "lock object outside", config: `{"lock":{"path":"a/../../x"}}`, want: `{}`, stripped: []string{"lock"}},
+		{name: "import map inside", config: `{"importMap":"map.json"}`, want: `{"importMap":"map.json"}`},
+		{name: "import map outside", config: `{"importMap":"/srv/map.json"}`, want: `{}`, stripped: []string{"importMap"}},
+		{name: "unstable allowed", config: `{"unstable":["kv"]}`, want: `{"unstable":["kv"]}`},
+		{name: "unstable filtered", config: `{"unstable":["kv","ffi"]}`, want: `{"unstable":["kv"]}`, stripped: []string{"unstable"}},
+		{name: "unstable malformed", config: `{"unstable":"ffi"}`, err: "unstable must be a list of feature names"},
+		{name: "imports allowed", config: `{"imports":{"preact":"https://esm.sh/preact","std/":"./std/"}}`, want: `{"imports":{"preact":"https://esm.sh/preact","std/":"./std/"}}`, hosts: []string{"esm.sh"}},
+		{name: "scopes checked", config: `{"scopes":{"./":{"x":"https://evil.example/x.js"}}}`, err: "imports: \"x\": evil.example is not in the import allowlist"},
+		{name: "imports escape", config: `{"imports":{"x":"./../x.js"}}`, err: "relative targets must stay in the working directory"},
+		{name: "array", config: `[]`, err: "must be a JSON object"},
+		{name: "null", config: `null`, err: "must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.sanitizeDenoConfig(json.RawMessage(tt.config))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotJSON, wantJSON any
+			json.Unmarshal(got.data, &gotJSON)
+			json.Unmarshal([]byte(tt.want), &wantJSON)
+			if !reflect.DeepEqual(gotJSON, wantJSON) {
+				t.Errorf("sanitized to %s, want %s", got.data, tt.want)
+			}
+			if !reflect.DeepEqual(got.stripped, tt.stripped) {
+				t.Errorf("stripped %q, want %q", got.stripped, tt.stripped)
+			}
+			if !reflect.DeepEqual(got.hosts, tt.hosts) || got.imports != (tt.hosts != nil) {
+				t.Errorf("imports %v hosts %q, want hosts %q", got.imports, got.hosts, tt.hosts)
+			}
+		})
+	}
+}
+
+// jsxConfig has deno compile JSX to calls of a factory the code defines,
+// so the output shows the config was used.
+const jsxConfig = `{"compilerOptions":{"jsx":"react","jsxFactory":"h","jsxFragmentFactory":"Frag"},"tasks":{"dev":"deno run -A x.ts"}}`
+
+const jsxCode = `const h = (tag: string, _props: unknown, ...children: string[]) => tag + "(" + children.join(",") + ")";
+const Frag = "frag";
+console.log(<b>hi<i>there</i></b>, <>x</>);`
+
+// TestDenoConfigJSXArgs checks a tsx job runs with --config pointing at the
+// sanitized deno.json in its working directory.
+func TestDenoConfigJSXArgs(t *testing.T) {
+	fakeDeno(t, `for a in "$@"; do
+	case "$a" in
+	--config=*) echo "config: $(cat "${a#--config=}")";;
+	--ext=*) echo "$a";;
+	esac
+done`)
+	r := testRunner(t)
+	logs := captureLog(t)
+	res := r.executeIn(&RunRequest{PublicID: "jsx", Code: jsxCode, Language: "tsx", DenoConfig: json.RawMessage(jsxConfig)}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	want := "config: " + `{"compilerOptions":{"jsx":"react","jsxFactory":"h","jsxFragmentFactory":"Frag"}}` + "\n--ext=tsx\n"
+	if res.Stdout != want {
+		t.Errorf("stdout %q, want %q", res.Stdout, want)
+	}
+	if !strings.Contains(logs.String(), "Removed tasks from the deno config of jsx") {
+		t.Errorf("stripping tasks was not logged: %s", logs)
+	}
+}
+
+// TestDenoConfigJSXCompile runs the same job through a real deno, when one
+// is installed.
+func TestDenoConfigJSXCompile(t *testing.T) {
+	if _, err := exec.LookPath("deno"); err != nil {
+		t.Skip("deno is not installed")
+	}
+	r := testRunner(t)
+	res := r.executeIn(&RunRequest{PublicID: "jsx", Code: jsxCode, Language: "tsx", DenoConfig: json.RawMessage(jsxConfig)}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s); stderr %q", res.ErrorCode, res.Error, res.Stderr)
+	}
+	if want := "b(hi,i(there)) frag(x)\n"; res.Stdout != want {
+		t.Errorf("stdout %q, want %q", res.Stdout, want)
+	}
+}
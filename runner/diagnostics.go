@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDiagnostics caps how many diagnostics a result carries.
+const maxDiagnostics = 200
+
+// Diagnostic is one problem deno found in the code without running it.
+// Lines and columns are 1-based; End is set when deno reports a range.
+type Diagnostic struct {
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndLine   int    `json:"endLine,omitempty"`
+	EndColumn int    `json:"endColumn,omitempty"`
+	Message   string `json:"message"`
+	// Code is the TypeScript error code, e.g. "TS2322", or the lint rule,
+	// e.g. "no-unused-vars".
+	Code string `json:"code,omitempty"`
+	Hint string `json:"hint,omitempty"`
+}
+
+var (
+	checkDiagHeader = regexp.MustCompile(`^(?:error: )?(TS\d+) \[(?:ERROR|WARNING)\]: (.*)$`)
+	checkDiagAt     = regexp.MustCompile(`^\s+at (.+):(\d+):(\d+)$`)
+)
+
+// parseCheckDiagnostics extracts deno check's diagnostics from its stderr:
+// a "TSxxxx [ERROR]: message" line, the offending source, then
+// "at file:///path:line:col". Paths are made relative to workdir.
+func parseCheckDiagnostics(stderr, workdir string) []Diagnostic {
+	var diags []Diagnostic
+	var cur *Diagnostic
+	for _, line := range strings.Split(stripANSI(stderr), "\n") {
+		if m := checkDiagHeader.FindStringSubmatch(line); m != nil {
+			if len(diags) == maxDiagnostics {
+				break
+			}
+			diags = append(diags, Diagnostic{Code: m[1], Message: m[2]})
+			cur = &diags[len(diags)-1]
+			continue
+		}
+		if m := checkDiagAt.FindStringSubmatch(line); m != nil && cur != nil && cur.File == "" {
+			cur.File = relativeToWorkdir(m[1], workdir)
+			cur.Line, _ = strconv.Atoi(m[2])
+			cur.Column, _ = strconv.Atoi(m[3])
+		}
+	}
+	return diags
+}
+
+// relativeToWorkdir turns a file URL or path under workdir into a path
+// relative to it; anything else is returned as is.
+func relativeToWorkdir(file, workdir string) string {
+	path := strings.TrimPrefix(file, "file://")
+	if rel, err := filepath.Rel(workdir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+// fillTypeCheck adds deno check's diagnostics to res. A plain non-zero exit
+// means the code has type errors and is reported as TYPECHECK_FAILED.
+func fillTypeCheck(res *RunResult, workdir string) {
+	res.Diagnostics = parseCheckDiagnostics(res.Stderr, workdir)
+	if res.ErrorCode != errorCodeRuntime || res.ExitSignal != nil {
+		return
+	}
+	res.ErrorCode = errorCodeTypeCheck
+	res.Error = "type check failed"
+	if n := len(res.Diagnostics); n > 0 {
+		res.Error = fmt.Sprintf("type check failed with %d errors", n)
+	}
+}
+
+// lintReport is the output of deno lint --json. Columns are 0-based.
+type lintReport struct {
+	Diagnostics []struct {
+		Filename string `json:"filename"`
+		Range    struct {
+			Start struct{ Line, Col int }
+			End   struct{ Line, Col int }
+		} `json:"range"`
+		Message string `json:"message"`
+		Code    string `json:"code"`
+		Hint    string `json:"hint"`
+	} `json:"diagnostics"`
+	// Errors are files that couldn't be parsed.
+	Errors []struct {
+		FilePath string `json:"file_path"`
+		Message  string `json:"message"`
+	} `json:"errors"`
+}
+
+// fillLint adds deno lint's findings to res. Lint problems and parse errors
+// make deno exit non-zero, which is reported as LINT_FAILED.
+func fillLint(res *RunResult, req *RunRequest, workdir string) {
+	var report lintReport
+	if err := json.Unmarshal([]byte(res.Stdout), &report); err != nil {
+		if res.ErrorCode == "" {
+			log.Printf("[LINT] Unreadable lint report for %s: %v", req.PublicID, err)
+		}
+		return
+	}
+	for _, e := range report.Errors {
+		if len(res.Diagnostics) == maxDiagnostics {
+			break
+		}
+		res.Diagnostics = append(res.Diagnostics, Diagnostic{File: relativeToWorkdir(e.FilePath, workdir), Message: e.Message})
+	}
+	for _, d := range report.Diagnostics {
+		if len(res.Diagnostics) == maxDiagnostics {
+			break
+		}
+		res.Diagnostics = append(res.Diagnostics, Diagnostic{
+			File:      relativeToWorkdir(d.Filename, workdir),
+			Line:      d.Range.Start.Line,
+			Column:    d.Range.Start.Col + 1,
+			EndLine:   d.Range.End.Line,
+			EndColumn: d.Range.End.Col + 1,
+			Message:   d.Message,
+			Code:      d.Code,
+			Hint:      d.Hint,
+		})
+	}
+	if res.ErrorCode != errorCodeRuntime || res.ExitSignal != nil {
+		return
+	}
+	res.ErrorCode = errorCodeLint
+	res.Error = fmt.Sprintf("lint found %d problems", len(report.Diagnostics)+len(report.Errors))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// Jobs pass through up to three stages before a worker picks them up:
+//
+//  1. the per-PublicID gate, for serialized jobs
+//  2. the per-tenant gate
+//  3. the worker pool's bounded queue
+//
+// A job held at a gate waits there without occupying a worker or a queue
+// slot. When a job finishes, finish releases its gates in reverse order and
+// moves whichever jobs were waiting on them forward.
+
+// dispatch admits a freshly received job. From here on the job counts as
+// accepted until reply answers it.
+func (r *Runner) dispatch(job *pendingJob) {
+	r.active.Add(1)
+	job.received = time.Now()
+	r.jobs.hold(job)
+	if job.serialized {
+		ok, full := r.serial.admit(job.req.PublicID, job, 1)
+		switch {
+		case full:
+			log.Printf("[SERIAL] Rejecting %s: too many jobs queued for this PublicID", job.req.PublicID)
+			r.reply(job, RunResult{ExitCode: -1, Error: "too many queued jobs for this publicId", ErrorCode: errorCodeBusy})
+			return
+		case !ok:
+			log.Printf("[SERIAL] %s waiting for the previous job with the same PublicID", job.req.PublicID)
+			job.acknowledge(submitWaiting)
+			return
+		}
+	}
+	r.admitTenant(job, false)
+}
+
+// admitTenant moves a job through the tenant gate into the worker pool.
+// force is set for jobs that were already accepted and parked at an earlier
+// gate; they bypass the busy check so they are never rejected after waiting.
+func (r *Runner) admitTenant(job *pendingJob, force bool) {
+	ok, full := r.tenants.admit(job.tenant, job, r.tenantSlots(job))
+	switch {
+	case full:
+		log.Printf("[TENANT] Rejecting %s: tenant %q is over its concurrency quota", job.req.PublicID, job.tenant)
+		r.releaseSerial(job)
+		r.reply(job, RunResult{ExitCode: -1, Error: "tenant concurrency exceeded", ErrorCode: errorCodeBusy})
+		return
+	case !ok:
+		log.Printf("[TENANT] %s waiting for a slot of tenant %q", job.req.PublicID, job.tenant)
+		job.acknowledge(submitWaiting)
+		return
+	}
+
+	log.Printf("[REQ] Queued code for: %s%s", job.req.PublicID, r.cfg.metadataLogFields(job.req.Metadata))
+	if err := r.pool.submit(job, force); err != nil {
+		r.finish(job)
+		r.reply(job, submitFailed(err))
+		return
+	}
+	job.acknowledge(submitQueued)
+}
+
+// submitFailed is the result for a job the worker pool would not take.
+func submitFailed(err error) RunResult {
+	if errors.Is(err, errPoolClosed) {
+		return shutdownResult()
+	}
+	return RunResult{ExitCode: -1, Error: err.Error(), ErrorCode: errorCodeBusy}
+}
+
+// finish releases the gates held by job, dispatching the next waiting job at
+// each of them.
+func (r *Runner) finish(job *pendingJob) {
+	for _, next := range r.tenants.release(job.tenant, r.tenantSlots(job)) {
+		log.Printf("[TENANT] Dispatching %s for tenant %q", next.req.PublicID, next.tenant)
+		if err := r.pool.submit(next, true); err != nil {
+			r.finish(next)
+			r.reply(next, submitFailed(err))
+		}
+	}
+	r.releaseSerial(job)
+}
+
+// tenantSlots is how many of its tenant's concurrency slots job holds.
+func (r *Runner) tenantSlots(job *pendingJob) int {
+	if jobMode(&job.req) == modeBench {
+		return r.cfg.BenchWeight
+	}
+	return 1
+}
+
+// releaseSerial frees job's PublicID for the next serialized job, if any.
+func (r *Runner) releaseSerial(job *pendingJob) {
+	if !job.serialized {
+		return
+	}
+	// Serialized jobs hold one slot each, so at most one is returned.
+	for _, next := range r.serial.release(job.req.PublicID, 1) {
+		log.Printf("[SERIAL] Dispatching next job for %s", next.req.PublicID)
+		r.admitTenant(next, true)
+	}
+}
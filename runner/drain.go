@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// errPoolClosed is returned by submit once the runner has stopped draining.
+var errPoolClosed = errors.New("runner shutting down")
+
+// shutdown stops the runner gracefully. It stops taking new requests, lets
+// accepted jobs, queued or running, finish for up to cfg.DrainTimeout, then
+// kills whatever is left and answers it with RUNNER_SHUTDOWN. Requests
+// that had already arrived when it started are answered RUNNER_SHUTDOWN
+// too, so their callers can retry elsewhere at once. It returns the number
+// of accepted jobs that were aborted.
+func (r *Runner) shutdown(intake []*subscription) int64 {
+	r.draining.Store(true)
+	for _, s := range intake {
+		if err := s.current().Drain(); err != nil {
+			log.Printf("[SHUTDOWN] Failed to drain subscription to %s: %v", s.subject, err)
+		}
+	}
+	r.scheduler.cancelAll(errRunnerShutdown)
+	r.warm.close()
+
+	accepted := r.active.Load()
+	log.Printf("[SHUTDOWN] Draining %d accepted job(s) for up to %v", accepted, r.cfg.DrainTimeout)
+	if r.waitDrained(r.cfg.DrainTimeout) {
+		log.Printf("[SHUTDOWN] Drained: %d job(s) completed, 0 aborted", accepted)
+		return 0
+	}
+
+	left := r.active.Load()
+	log.Printf("[SHUTDOWN] Drain timeout reached: %d job(s) completed, %d aborted", max(accepted-left, 0), left)
+	for _, job := range r.pool.close() {
+		r.finish(job)
+		r.reply(job, shutdownResult())
+	}
+	log.Printf("[SHUTDOWN] Killing %d in-flight job(s)", r.jobs.cancelAll(errRunnerShutdown))
+	r.jobs.waitIdle(shutdownGrace)
+	return left
+}
+
+// drainConn drains the NATS connection, so replies still buffered are
+// flushed before the runner exits, and waits up to timeout for it to close.
+func drainConn(nc *nats.Conn, timeout time.Duration) bool {
+	if err := nc.Drain(); err != nil {
+		log.Printf("[SHUTDOWN] Failed to drain NATS connection: %v", err)
+		return false
+	}
+	deadline := time.Now().Add(timeout)
+	for !nc.IsClosed() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+// waitDrained blocks until every accepted job has been answered or the
+// timeout elapses.
+func (r *Runner) waitDrained(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for r.active.Load() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return true
+}
+
+func shutdownResult() RunResult {
+	return RunResult{ExitCode: -1, Error: errRunnerShutdown.Error(), ErrorCode: errorCodeShutdown}
+}
+
+// close empties the backlog and makes later submits fail with errPoolClosed.
+// It returns the jobs that were still waiting for a worker.
+func (p *workerPool) close() []*pendingJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	var jobs []*pendingJob
+	for _, tq := range p.ring {
+		for _, q := range tq.queues {
+			jobs = append(jobs, q...)
+		}
+		delete(p.tenants, tq.name)
+	}
+	p.ring, p.turn, p.credit, p.queued = nil, 0, 0, 0
+	return jobs
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Requests are JSON unless a Content-Type header says otherwise, and replies
+// come back in the request's encoding with the same header:
+//
+//   - application/msgpack (see msgpack.go). A payload starting with a msgpack
+//     map is taken as msgpack even without the header; JSON objects start
+//     with '{', so the first byte tells them apart.
+//   - application/protobuf, with the messages in proto/runner.proto (see
+//     proto.go). Replies with no protobuf definition are sent as JSON,
+//     labelled application/json.
+//
+// Field names, omitempty and the shape of every value are those of the JSON
+// encoding, so all three describe the same documents.
+const (
+	contentTypeHeader   = "Content-Type"
+	contentTypeJSON     = "application/json"
+	contentTypeMsgpack  = "application/msgpack"
+	contentTypeProtobuf = "application/protobuf"
+)
+
+type wireFormat int
+
+const (
+	wireJSON wireFormat = iota
+	wireMsgpack
+	wireProtobuf
+)
+
+// requestFormat reports how m's payload is encoded.
+func requestFormat(m *nats.Msg) wireFormat {
+	ct, _, _ := strings.Cut(m.Header.Get(contentTypeHeader), ";")
+	switch strings.ToLower(strings.TrimSpace(ct)) {
+	case contentTypeMsgpack:
+		return wireMsgpack
+	case contentTypeProtobuf, "application/x-protobuf":
+		return wireProtobuf
+	}
+	if len(m.Data) > 0 {
+		if b := m.Data[0]; b&0xf0 == 0x80 || b == 0xde || b == 0xdf { // fixmap, map16, map32
+			return wireMsgpack
+		}
+	}
+	return wireJSON
+}
+
+// requestJSON returns m's payload, a message of the named type, as JSON so
+// every request goes through the same decoding and validation.
+func requestJSON(m *nats.Msg, message string) ([]byte, error) {
+	switch requestFormat(m) {
+	case wireMsgpack:
+		return msgpackToJSON(m.Data)
+	case wireProtobuf:
+		return protoToJSON(m.Data, message)
+	}
+	return m.Data, nil
+}
+
+// encodeReply encodes v for whoever sent m, returning the content type to
+// announce; none for plain JSON requests.
+func encodeReply(m *nats.Msg, v any) ([]byte, string, error) {
+	switch requestFormat(m) {
+	case wireMsgpack:
+		data, err := appendMsgpack(nil, reflect.ValueOf(v))
+		return data, contentTypeMsgpack, err
+	case wireProtobuf:
+		if data, ok, err := encodeProto(v); ok {
+			return data, contentTypeProtobuf, err
+		}
+		data, err := json.Marshal(v)
+		return data, contentTypeJSON, err
+	}
+	data, err := json.Marshal(v)
+	return data, "", err
+}
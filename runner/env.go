@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// envNamePattern is the portable shell variable name syntax.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedEnvNames may not be set by a request: they either steer deno and
+// the dynamic loader or belong to the runner itself.
+var reservedEnvNames = map[string]bool{
+	"PATH":     true,
+	"HOME":     true,
+	"TMPDIR":   true,
+	"NO_COLOR": true,
+}
+
+// reservedEnvPrefixes match whole families of runner/runtime variables.
+var reservedEnvPrefixes = []string{"DENO_", "LD_", "NATS_", "RUNNER_", "V8_"}
+
+// validateEnv checks that every key in env is a well-formed variable name
+// that does not collide with the runner's own configuration.
+func validateEnv(env map[string]string) error {
+	for _, key := range sortedKeys(env) {
+		if !envNamePattern.MatchString(key) {
+			return fmt.Errorf("invalid variable name: %q", key)
+		}
+		upper := strings.ToUpper(key)
+		if reservedEnvNames[upper] {
+			return fmt.Errorf("reserved variable: %s", key)
+		}
+		for _, prefix := range reservedEnvPrefixes {
+			if strings.HasPrefix(upper, prefix) {
+				return fmt.Errorf("reserved variable: %s", key)
+			}
+		}
+		if strings.ContainsRune(env[key], 0) {
+			return fmt.Errorf("value of %s contains a NUL byte", key)
+		}
+	}
+	return nil
+}
+
+// jobEnv builds the script's environment from a clean base rather than the
+// runner's own, which holds connection settings the script has no business
+// seeing. Only what deno needs to find itself and its cache carries over.
+func jobEnv(workdir string, env map[string]string) []string {
+	out := []string{"TMPDIR=" + workdir}
+	for _, key := range []string{"PATH", "HOME", "DENO_DIR"} {
+		if v, ok := os.LookupEnv(key); ok {
+			out = append(out, key+"="+v)
+		}
+	}
+	for _, key := range sortedKeys(env) {
+		out = append(out, key+"="+env[key])
+	}
+	return out
+}
+
+// withEnvScope grants read access to exactly the request's variables. An
+// unscoped --allow-env already covers them; a scoped one is widened in place
+// so deno sees a single flag.
+func withEnvScope(perms []string, env map[string]string) []string {
+	if len(env) == 0 {
+		return perms
+	}
+	keys := sortedKeys(env)
+	for i, perm := range perms {
+		switch {
+		case perm == "--allow-env":
+			return perms
+		case strings.HasPrefix(perm, "--allow-env="):
+			perms[i] = perm + "," + strings.Join(keys, ",")
+			return perms
+		}
+	}
+	return append(perms, "--allow-env="+strings.Join(keys, ","))
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,653 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// execute runs a single request to completion on the given worker slot. A
+// non-zero deadline caps how long it may run.
+func (r *Runner) execute(req *RunRequest, slot int, deadline time.Time) RunResult {
+	if req.Source != nil {
+		resolved, err := r.fetchSource(req)
+		if err != nil {
+			log.Printf("[ERROR] Fetching source of %s: %v", req.PublicID, err)
+			return sourceFailed(err)
+		}
+		req = &resolved
+	}
+	if len(req.Steps) > 0 {
+		return r.executeSteps(req, slot, deadline)
+	}
+	return r.executeIn(req, slot, deadline, nil)
+}
+
+// executeIn runs req in a working directory of its own, or in shared's when
+// it is a step of a pipeline.
+func (r *Runner) executeIn(req *RunRequest, slot int, deadline time.Time, shared *jobDir) (res RunResult) {
+	// Time left before the deadline, skew allowed for; zero means none.
+	var untilDeadline time.Duration
+	if !deadline.IsZero() {
+		if untilDeadline = time.Until(deadline.Add(r.cfg.DeadlineSkew)); untilDeadline <= 0 {
+			log.Printf("[EXPIRED] Not starting %s: its deadline %s has passed", req.PublicID, deadline.UTC().Format(time.RFC3339Nano))
+			return RunResult{ExitCode: -1, Error: fmt.Sprintf("deadline %s passed before the job started", deadline.Format(time.RFC3339Nano)), ErrorCode: errorCodeExpired}
+		}
+	}
+	log.Printf("[REQ] Running code for: %s (worker %d)", req.PublicID, slot)
+	startTime := time.Now()
+	log.Printf("[START] Job started at: %s", startTime.Format(time.RFC3339))
+
+	// 3. Validate and sanitize permissions
+	validatedPerms, validationErr := validatePermissions(req.Permissions)
+	if validationErr != nil {
+		log.Printf("[ERROR] Permission validation failed: %v", validationErr)
+		return RunResult{
+			Output:    "",
+			ExitCode:  1,
+			Error:     fmt.Sprintf("Permission validation failed: %v", validationErr),
+			ErrorCode: errorCodePermissionDenied,
+		}
+	}
+	if err := r.cfg.checkPermissionCeiling(r.cfg.tenantOf(req), validatedPerms); err != nil {
+		log.Printf("[ERROR] Permission ceiling exceeded for %s: %v", req.PublicID, err)
+		return RunResult{ExitCode: 1, Error: fmt.Sprintf("Permission validation failed: %v", err), ErrorCode: errorCodePermissionDenied}
+	}
+
+	if err := validateEnv(req.Env); err != nil {
+		log.Printf("[ERROR] Env validation failed for %s: %v", req.PublicID, err)
+		return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid env: %v", err), ErrorCode: errorCodeValidation}
+	}
+	validatedPerms = withEnvScope(validatedPerms, req.Env)
+	if err := validateScriptArgs(req.Args); err != nil {
+		log.Printf("[ERROR] Args validation failed for %s: %v", req.PublicID, err)
+		return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid args: %v", err), ErrorCode: errorCodeValidation}
+	}
+
+	// Each job gets its own scratch directory, removed on every exit path. A
+	// warm process comes with one already; the steps of a pipeline share
+	// one, which the pipeline owns.
+	var (
+		warm    *warmProcess
+		workdir string
+		quota   *diskQuota
+		err     error
+	)
+	if shared == nil {
+		warm = r.warm.take(req)
+	}
+	switch {
+	case shared != nil:
+		workdir, quota = shared.path, shared.quota
+	case warm != nil:
+		workdir, quota = warm.workdir, warm.quota
+	default:
+		if workdir, err = createWorkdir(r.cfg.WorkDir, r.cfg.ExecCredential); err != nil {
+			log.Printf("[ERROR] Failed to create working directory: %v", err)
+			return RunResult{ExitCode: -1, Error: fmt.Sprintf("failed to prepare working directory: %v", err), ErrorCode: errorCodeInternal}
+		}
+		if r.cfg.DiskQuota > 0 {
+			quota = newDiskQuota(workdir, r.cfg.DiskQuota, r.cfg.ExecCredential)
+		}
+	}
+	if shared == nil {
+		defer os.RemoveAll(workdir)
+		if quota != nil {
+			defer quota.release()
+		}
+	}
+	// Each step of a pipeline gets the request's configuration files
+	// afresh, so a step with write access can't change them for the next.
+	first := shared == nil || !shared.prepared
+	if !first {
+		if err := shared.removeConfigFiles(req); err != nil {
+			log.Printf("[ERROR] Failed to reset configuration files for %s: %v", req.PublicID, err)
+			return RunResult{ExitCode: -1, Error: fmt.Sprintf("failed to prepare working directory: %v", err), ErrorCode: errorCodeInternal}
+		}
+	}
+	if len(req.Files) > 0 {
+		if err := writeInputFiles(workdir, req.Files, r.cfg.inputFilesLimit(req), r.cfg.ExecCredential); err != nil {
+			log.Printf("[ERROR] Invalid input files for %s: %v", req.PublicID, err)
+			return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid input files: %v", err), ErrorCode: errorCodeValidation}
+		}
+	}
+	// Import maps, the request's own or the imports in its deno.json,
+	// narrow which hosts it may import from.
+	var importHosts []string
+	mapsImports := false
+	if len(req.ImportMap) > 0 {
+		hosts, err := r.cfg.parseImportMap(req.ImportMap)
+		if err == nil {
+			err = writeImportMap(workdir, req.ImportMap)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Invalid import map for %s: %v", req.PublicID, err)
+			return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid import map: %v", err), ErrorCode: errorCodeValidation}
+		}
+		importHosts, mapsImports = hosts, true
+	}
+	if len(req.DenoConfig) > 0 {
+		config, err := r.cfg.sanitizeDenoConfig(req.DenoConfig)
+		if err == nil {
+			err = writeDenoConfig(workdir, config)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Invalid deno config for %s: %v", req.PublicID, err)
+			return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid denoConfig: %v", err), ErrorCode: errorCodeValidation}
+		}
+		if len(config.stripped) > 0 && first {
+			log.Printf("[CONFIG] Removed %s from the deno config of %s", strings.Join(config.stripped, ", "), req.PublicID)
+		}
+		if config.imports {
+			importHosts, mapsImports = mergeHosts(importHosts, config.hosts), true
+		}
+	}
+	if mapsImports {
+		validatedPerms = withImportMap(validatedPerms, r.cfg.ImportAllowlist, importHosts)
+	}
+	if req.Lockfile != "" {
+		if err := writeLockfile(workdir, req.Lockfile); err != nil {
+			log.Printf("[ERROR] Failed to write lockfile for %s: %v", req.PublicID, err)
+			return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid lockfile: %v", err), ErrorCode: errorCodeValidation}
+		}
+	}
+	if shared != nil {
+		shared.prepared = true
+	}
+	validatedPerms = resolvePermissionPaths(validatedPerms, workdir)
+	if req.ReturnValue {
+		validatedPerms = withValueScope(validatedPerms, workdir)
+	}
+
+	// The code normally arrives on stdin; a stdin payload, interactive
+	// input or a mode that can't read stdin moves it to a file, and eval
+	// mode passes it on the command line. An entrypoint is already a file.
+	script := "-"
+	var stdin io.Reader = strings.NewReader(req.Code)
+	var stdinData []byte
+	if req.Stdin != nil || req.InteractiveStdin || jobModes[jobMode(req)].file || req.Mode == modeEval || req.Entrypoint != "" {
+		if req.Stdin != nil {
+			if stdinData, err = decodeStdin(*req.Stdin, req.StdinEncoding); err != nil {
+				log.Printf("[ERROR] Invalid stdin for %s: %v", req.PublicID, err)
+				return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid stdin: %v", err), ErrorCode: errorCodeValidation}
+			}
+		}
+		switch {
+		case req.Entrypoint != "":
+			if script, err = entrypointPath(workdir, req.Entrypoint); err != nil {
+				log.Printf("[ERROR] Invalid entrypoint for %s: %v", req.PublicID, err)
+				return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid entrypoint: %v", err), ErrorCode: errorCodeValidation}
+			}
+		case req.Mode == modeEval:
+			script = evalScript(req)
+		default:
+			if script, err = writeScriptFile(workdir, req.Code, languageExts[req.Language]); err != nil {
+				log.Printf("[ERROR] Failed to write script for %s: %v", req.PublicID, err)
+				return RunResult{ExitCode: -1, Error: fmt.Sprintf("failed to prepare working directory: %v", err), ErrorCode: errorCodeInternal}
+			}
+		}
+		stdin = bytes.NewReader(stdinData)
+	}
+
+	// 4. Build Deno command with secure permissions
+	heapMB := heapLimitMB(req.MaxHeapMB, r.cfg)
+	args := r.cfg.denoArgs(req, validatedPerms, heapMB, script, workdir)
+
+	defTimeout := r.cfg.DefaultTimeout
+	if jobMode(req) == modeBench {
+		defTimeout = r.cfg.BenchTimeout
+	}
+	timeout, runnerLimit := jobTimeout(req.TimeoutMs, defTimeout, r.cfg.MaxTimeout)
+	deadlineLimit := untilDeadline > 0 && untilDeadline < timeout
+	if deadlineLimit {
+		log.Printf("[DEADLINE] Capping the timeout of %s at %v to end by its deadline", req.PublicID, untilDeadline.Round(time.Millisecond))
+		timeout = untilDeadline
+	}
+
+	// DurationMs covers the process's lifetime: from spawn, or for a warm
+	// process from when it is handed the job, until Wait returns.
+	procStart := time.Now()
+	var proc *jobProcess
+	var value *valuePipe // fd 3, for returnValue; warm processes never have one
+	if warm != nil {
+		log.Printf("[WARM] Running %s on a pre-started deno process (timeout: %v)", req.PublicID, timeout)
+		proc = warm.proc
+	} else {
+		log.Printf("[PERMISSIONS] Using flags: %v (timeout: %v)", args, timeout)
+		if req.InteractiveStdin {
+			stdin = nil // fed through proc.stdin instead
+		}
+		env := jobEnv(workdir, req.Env)
+		var extraFiles []*os.File
+		if req.ReturnValue {
+			if value, err = newValuePipe(r.cfg.MaxValueBytes); err != nil {
+				log.Printf("[ERROR] Failed to create result pipe for %s: %v", req.PublicID, err)
+				return RunResult{ExitCode: -1, Error: fmt.Sprintf("failed to create result pipe: %v", err), ErrorCode: errorCodeInternal}
+			}
+			env = append(env, valueEnv(workdir)...)
+			extraFiles = []*os.File{value.w}
+		}
+		// npm packages are downloaded as the job loads, so it needs the network.
+		offline := !needsNetwork(validatedPerms) && !req.Npm
+		proc, err = r.spawn(args, workdir, env, stdin, offline, extraFiles)
+		if value != nil {
+			value.started()
+		}
+		if err != nil {
+			if value != nil {
+				value.wait()
+			}
+			log.Printf("[ERROR] Failed to start deno: %v", err)
+			return RunResult{
+				ExitCode:  -1,
+				Error:     fmt.Sprintf("spawn failed: %v", err),
+				ErrorCode: errorCodeSpawnFailed,
+				retryable: true,
+			}
+		}
+	}
+	defer proc.cleanup()
+	cmd, ctx, cancelJob, term, cg := proc.cmd, proc.ctx, proc.cancel, proc.term, proc.cg
+	timer := time.AfterFunc(timeout, func() { cancelJob(context.DeadlineExceeded) })
+	defer timer.Stop()
+
+	job := r.jobs.add(req.PublicID, startTime, cancelJob)
+	defer r.jobs.remove(job)
+	if quota != nil {
+		quota.watch(ctx, func() { cancelJob(errDiskQuota) })
+	}
+
+	var stdinFwd *stdinForwarder
+	if req.InteractiveStdin {
+		if stdinFwd, err = r.forwardStdin(req.PublicID, proc.stdin, stdinData, func() { cancelJob(errStdinIdle) }); err != nil {
+			log.Printf("[WARN] Failed to attach interactive stdin for %s, closing it: %v", req.PublicID, err)
+			proc.stdin.Close()
+		}
+	}
+
+	out := newOutputCapture(r.cfg.MaxOutputBytes, r.cfg.OutputTailBytes)
+	stdout := io.MultiWriter(out.Stdout(), job.tail.writer("stdout"))
+	stderr := io.MultiWriter(out.Stderr(), job.tail.writer("stderr"))
+	var lines *lineRecorder
+	if req.Timestamps {
+		lines = newLineRecorder()
+		stdout = io.MultiWriter(stdout, lines.writer("stdout"))
+		stderr = io.MultiWriter(stderr, lines.writer("stderr"))
+	}
+	var streamer *outputStreamer
+	if req.Stream || req.InteractiveStdin {
+		streamer = newOutputStreamer(r.nc, r.cfg.subjectID("output", req.PublicID))
+		stdout = io.MultiWriter(stdout, streamer.writer("stdout"))
+		stderr = io.MultiWriter(stderr, streamer.writer("stderr"))
+	}
+	stripANSI := r.cfg.StripANSI
+	if req.StripANSI != nil {
+		stripANSI = *req.StripANSI
+	}
+	if stripANSI {
+		stdout = newANSIStripper(stdout)
+		stderr = newANSIStripper(stderr)
+	}
+	if r.cfg.OutputRateLimit > 0 {
+		meter := newFloodMeter(r.cfg.OutputRateLimit, r.cfg.OutputRateWindow, func() { cancelJob(errOutputFlood) })
+		stdout = meter.writer(stdout)
+		stderr = meter.writer(stderr)
+	}
+	proc.stdout.attach(stdout)
+	proc.stderr.attach(stderr)
+	if warm != nil {
+		go warm.feed(req.Code)
+	}
+
+	job.setState(jobRunning)
+	heartbeats := r.startHeartbeats(req, slot, startTime, out)
+	runErr := cmd.Wait()
+	procEnd := time.Now()
+	if stdinFwd != nil {
+		stdinFwd.stop()
+	}
+	res.Heartbeats = heartbeats.finish()
+	if streamer != nil {
+		streamer.close()
+	}
+	term.markExited()
+	r.reaper.release(cmd.Process.Pid)
+	// Anything the script left behind in its process group dies with it.
+	_ = killProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+
+	endTime := time.Now()
+	duration := endTime.Sub(startTime)
+	log.Printf("[END] Job finished at: %s (duration: %v)", endTime.Format(time.RFC3339), duration)
+
+	// 5. Pack the result
+	res.Limits = r.jobLimits(timeout, heapMB)
+	res.StartedAt, res.FinishedAt = procStart.UTC(), procEnd.UTC()
+	res.DurationMs = procEnd.Sub(procStart).Milliseconds()
+	out.fill(&res, req.BinaryOutput)
+	if lines != nil {
+		lines.fill(&res)
+	}
+	res.Termination = term.outcome()
+	res.Warm = warm != nil
+	res.UserCPUMs = cmd.ProcessState.UserTime().Milliseconds()
+	res.SystemCPUMs = cmd.ProcessState.SystemTime().Milliseconds()
+	res.PeakRssBytes = peakRSS(cmd.ProcessState)
+	if cg != nil {
+		if peak, ok := cg.memoryPeak(); ok {
+			res.PeakRssBytes = peak
+		}
+	}
+	if quota != nil {
+		res.DiskUsageBytes = quota.usage()
+		res.DiskQuotaBytes = quota.limit
+	}
+	if value != nil {
+		res.Value, res.ValueError = readValue(value.wait(), workdir, r.cfg.MaxValueBytes)
+	}
+	if len(req.CollectArtifacts) > 0 {
+		res.Artifacts = collectArtifacts(workdir, req.CollectArtifacts, r.cfg.MaxArtifactBytes, r.cfg.MaxArtifactsTotalBytes)
+	}
+	res.ExitCode, res.ExitSignal = exitStatus(cmd.ProcessState)
+	if runErr != nil && res.ExitCode == 0 {
+		// The process exited cleanly but waiting on it failed (e.g. copying output).
+		res.ExitCode = 1
+	}
+	failure := jobFailure{cause: context.Cause(ctx), runErr: runErr, signal: res.ExitSignal, terminated: res.Termination != ""}
+	if runErr != nil {
+		failure.diskQuota = quota != nil && quota.exceeded()
+		failure.cpuLimit = r.cpuLimitHit(res.ExitSignal, cmd.ProcessState)
+		failure.cgroupOOM = cg != nil && cg.oomKilled()
+		failure.pidsLimit = cg != nil && cg.pidsLimitHit() || !r.rlimits.empty() && isThreadSpawnFailure(res.Stderr)
+		failure.v8OOM = isV8OutOfMemory(res.Stderr)
+		failure.kernelOOM = res.ExitSignal != nil && res.ExitSignal.Number == int(syscall.SIGKILL) && kernelOOMKills() > proc.oomKillsBefore
+	}
+	kind := failure.classify()
+	switch kind {
+	case failureCanceled:
+		log.Printf("[CANCEL] Job canceled: %s", req.PublicID)
+		res.Error = "canceled"
+		res.ErrorCode = errorCodeCanceled
+	case failureShutdown:
+		log.Printf("[SHUTDOWN] Job aborted by shutdown: %s", req.PublicID)
+		res.Error = "runner shutting down"
+		res.ErrorCode = errorCodeShutdown
+	case failureOutputFlood:
+		log.Printf("[FLOOD] Job exceeded output rate of %d bytes/s: %s", r.cfg.OutputRateLimit, req.PublicID)
+		res.Error = fmt.Sprintf("output rate limit exceeded (more than %d bytes/s over %v)", r.cfg.OutputRateLimit, r.cfg.OutputRateWindow)
+		res.ErrorCode = errorCodeOutputFlood
+	case failureDiskQuota:
+		log.Printf("[QUOTA] Job exceeded disk quota of %d bytes: %s", quota.limit, req.PublicID)
+		res.Error = fmt.Sprintf("disk quota exceeded (%d of %d bytes)", res.DiskUsageBytes, quota.limit)
+		res.ErrorCode = errorCodeDiskQuota
+	case failureIdleTimeout:
+		log.Printf("[TIMEOUT] Interactive job got no input for %v: %s", r.cfg.StdinIdleTimeout, req.PublicID)
+		res.Error = fmt.Sprintf("stdin idle timeout exceeded (no input for %v)", r.cfg.StdinIdleTimeout)
+		res.ErrorCode = errorCodeIdleTimeout
+	case failureTimeout:
+		log.Printf("[TIMEOUT] Job exceeded %v: %s", timeout, req.PublicID)
+		res.Error = "timeout exceeded"
+		res.ErrorCode = errorCodeTimeout
+		switch {
+		case deadlineLimit:
+			res.Error = fmt.Sprintf("timeout exceeded: killed at the request deadline (%v)", timeout.Round(time.Millisecond))
+		case runnerLimit:
+			res.Error = fmt.Sprintf("timeout exceeded: killed by runner limit (%v)", timeout)
+		}
+	case failureCPULimit:
+		log.Printf("[CPU] Job exceeded CPU time limit of %v: %s", r.cfg.CPULimit, req.PublicID)
+		res.Error = fmt.Sprintf("cpu time limit exceeded (%v of CPU, wall-clock timeout was %v)", r.cfg.CPULimit, timeout)
+		res.ErrorCode = errorCodeCPULimit
+	case failureCgroupOOM:
+		log.Printf("[OOM] Job exceeded cgroup memory limit of %d bytes: %s", r.cfg.MemoryLimit, req.PublicID)
+		res.Error = "memory limit exceeded"
+		res.ErrorCode = errorCodeOOM
+	case failureProcessLimit:
+		log.Printf("[PIDS] Job hit the process limit of %d: %s", r.cfg.PidsLimit, req.PublicID)
+		res.Error = fmt.Sprintf("process limit exceeded (max %d)", r.cfg.PidsLimit)
+		res.ErrorCode = errorCodeProcessLimit
+	case failureV8OOM:
+		log.Printf("[OOM] Job exceeded V8 heap limit of %d MB: %s", heapMB, req.PublicID)
+		res.Error = fmt.Sprintf("out of memory: JavaScript heap limit (%d MB) exceeded", heapMB)
+		res.ErrorCode = errorCodeOOM
+	case failureKernelOOM:
+		log.Printf("[OOM] Job was likely killed by the kernel OOM killer: %s", req.PublicID)
+		res.Error = "out of memory: killed by the kernel OOM killer"
+		res.ErrorCode = errorCodeOOM
+	case failureCrash:
+		log.Printf("[CRASH] deno crashed with %s: %s", res.ExitSignal.Name, req.PublicID)
+		res.Error = fmt.Sprintf("runtime crashed: %s", res.ExitSignal.Name)
+		res.ErrorCode = errorCodeCrashed
+	case failureRuntime:
+		res.Error = runErr.Error()
+		if res.ExitSignal != nil {
+			res.Error = fmt.Sprintf("killed by %s", res.ExitSignal.Name)
+		}
+		res.ErrorCode = errorCodeRuntime
+	}
+	res.retryable = failure.retryable(kind)
+	v8OOM := kind == failureV8OOM
+	markHit(&res, v8OOM)
+	checkLockfile(&res, req)
+	checkNpm(&res, req)
+	r.cfg.checkRemoteImport(&res, req)
+	switch jobMode(req) {
+	case modeTest:
+		r.fillTests(&res, req, workdir)
+	case modeCheck:
+		fillTypeCheck(&res, workdir)
+	case modeLint:
+		fillLint(&res, req, workdir)
+	case modeFmt:
+		fillFormat(&res, req)
+	case modeBench:
+		fillBench(&res, req)
+	}
+
+	return res
+}
+
+// failureKind is why a job did not succeed, as classify decides it.
+type failureKind int
+
+const (
+	failureNone failureKind = iota
+	failureCanceled
+	failureShutdown
+	failureOutputFlood
+	failureDiskQuota
+	failureIdleTimeout
+	failureTimeout
+	failureCPULimit
+	failureCgroupOOM
+	failureProcessLimit
+	failureV8OOM
+	failureKernelOOM
+	failureCrash
+	failureRuntime
+)
+
+// jobFailure is what is known of how a job's process ended. The fields
+// after runErr are only looked at when runErr is set.
+type jobFailure struct {
+	cause      error // the cause the job's context was canceled with
+	runErr     error // from cmd.Wait
+	signal     *ExitSignal
+	terminated bool // the runner stopped the process itself
+	diskQuota  bool // the working directory is over its quota
+	cpuLimit   bool // RLIMIT_CPU killed the process
+	cgroupOOM  bool
+	pidsLimit  bool // the cgroup's pids.max or RLIMIT_NPROC was hit
+	v8OOM      bool // stderr shows V8 ran out of heap
+	kernelOOM  bool // SIGKILLed while the kernel OOM killer fired
+}
+
+// classify picks the one reason to report. The runner's own reasons for
+// stopping the job come first, as they explain any failure that follows;
+// then the limits the process may have run into, most specific first; then
+// crashes; and last the script's own failure.
+func (f jobFailure) classify() failureKind {
+	failed := f.runErr != nil
+	switch {
+	case errors.Is(f.cause, errJobCanceled):
+		return failureCanceled
+	case errors.Is(f.cause, errRunnerShutdown):
+		return failureShutdown
+	case errors.Is(f.cause, errOutputFlood):
+		return failureOutputFlood
+	case errors.Is(f.cause, errDiskQuota) || failed && f.diskQuota:
+		return failureDiskQuota
+	case errors.Is(f.cause, errStdinIdle):
+		return failureIdleTimeout
+	case errors.Is(f.cause, context.DeadlineExceeded):
+		return failureTimeout
+	case failed && f.cpuLimit:
+		return failureCPULimit
+	case failed && f.cgroupOOM:
+		return failureCgroupOOM
+	case failed && f.pidsLimit:
+		return failureProcessLimit
+	case failed && f.v8OOM:
+		return failureV8OOM
+	case failed && f.kernelOOM:
+		// Nothing in the runner sent this SIGKILL and the kernel OOM killer
+		// fired while the job ran, so it is the likely culprit.
+		return failureKernelOOM
+	case failed && !f.terminated && isFaultSignal(f.signal):
+		// A fault signal means deno itself crashed; the script can't raise
+		// these on purpose without --allow-run or FFI, which are blocked.
+		return failureCrash
+	case failed:
+		return failureRuntime
+	}
+	return failureNone
+}
+
+// retryable reports whether a job that failed as kind may succeed if run
+// again, because the failure was the machine's rather than the script's.
+func (f jobFailure) retryable(kind failureKind) bool {
+	switch kind {
+	case failureKernelOOM, failureCrash:
+		return true
+	case failureRuntime:
+		return !f.terminated && isCrashSignal(f.signal)
+	}
+	return false
+}
+
+// exitStatus extracts the exit code and terminating signal from a finished process.
+func exitStatus(state *os.ProcessState) (int, *ExitSignal) {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		sig := ws.Signal()
+		return 128 + int(sig), &ExitSignal{Name: signalName(sig), Number: int(sig)}
+	}
+	return state.ExitCode(), nil
+}
+
+// isCrashSignal reports whether sig points at deno crashing or being killed
+// from outside, as opposed to the script exiting or signaling on purpose.
+func isCrashSignal(sig *ExitSignal) bool {
+	return isFaultSignal(sig) || sig != nil && syscall.Signal(sig.Number) == syscall.SIGKILL
+}
+
+// isFaultSignal reports whether sig is one the kernel or runtime raises when
+// the process itself faults.
+func isFaultSignal(sig *ExitSignal) bool {
+	if sig == nil {
+		return false
+	}
+	switch syscall.Signal(sig.Number) {
+	case syscall.SIGSEGV, syscall.SIGBUS, syscall.SIGILL, syscall.SIGFPE, syscall.SIGABRT, syscall.SIGTRAP:
+		return true
+	}
+	return false
+}
+
+// heapLimitMB returns the V8 heap limit for a request, clamped to the configured ceiling.
+func heapLimitMB(requestedMB int, cfg *Config) int {
+	if requestedMB <= 0 {
+		return cfg.V8HeapMB
+	}
+	return min(requestedMB, cfg.V8HeapCeilingMB)
+}
+
+// v8OOMMarkers are the messages V8 prints to stderr when it aborts because the
+// heap limit was reached.
+var v8OOMMarkers = []string{
+	"JavaScript heap out of memory",
+	"Fatal JavaScript out of memory",
+	"Reached heap limit",
+}
+
+func isV8OutOfMemory(stderr string) bool {
+	for _, marker := range v8OOMMarkers {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// cpuLimitHit reports whether the process was killed for exceeding RLIMIT_CPU:
+// either SIGXCPU at the soft limit, or SIGKILL at the hard limit once the
+// consumed CPU time has reached the configured budget.
+func (r *Runner) cpuLimitHit(sig *ExitSignal, state *os.ProcessState) bool {
+	if r.cfg.CPULimit <= 0 || sig == nil {
+		return false
+	}
+	switch sig := syscall.Signal(sig.Number); {
+	case isCPULimitSignal(sig):
+		return true
+	case sig == syscall.SIGKILL:
+		return state.UserTime()+state.SystemTime() >= r.cfg.CPULimit
+	}
+	return false
+}
+
+// isThreadSpawnFailure reports whether deno aborted because it could not create
+// a thread, which is how an exhausted RLIMIT_NPROC surfaces.
+func isThreadSpawnFailure(stderr string) bool {
+	return strings.Contains(stderr, "failed to spawn thread") ||
+		strings.Contains(stderr, "Resource temporarily unavailable")
+}
+
+// Script argument caps keep the deno command line well inside ARG_MAX.
+const (
+	maxScriptArgs     = 256
+	maxScriptArgBytes = 4096
+)
+
+// validateScriptArgs checks the request's script arguments against the caps.
+func validateScriptArgs(args []string) error {
+	if len(args) > maxScriptArgs {
+		return fmt.Errorf("too many arguments (%d, max %d)", len(args), maxScriptArgs)
+	}
+	for i, arg := range args {
+		if len(arg) > maxScriptArgBytes {
+			return fmt.Errorf("argument %d exceeds %d bytes", i, maxScriptArgBytes)
+		}
+		if strings.ContainsRune(arg, 0) {
+			return fmt.Errorf("argument %d contains a NUL byte", i)
+		}
+	}
+	return nil
+}
+
+// jobTimeout returns the execution deadline for a request, clamped to ceiling.
+// runnerLimit reports whether the deadline comes from the runner rather than
+// the value the client asked for.
+func jobTimeout(requestedMs int64, def, ceiling time.Duration) (timeout time.Duration, runnerLimit bool) {
+	if requestedMs <= 0 {
+		return def, true
+	}
+	if requestedMs > ceiling.Milliseconds() {
+		return ceiling, true
+	}
+	return time.Duration(requestedMs) * time.Millisecond, false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	exited := errors.New("exit status 1")
+	killed := &ExitSignal{Name: "SIGKILL", Number: int(syscall.SIGKILL)}
+	segv := &ExitSignal{Name: "SIGSEGV", Number: int(syscall.SIGSEGV)}
+	term := &ExitSignal{Name: "SIGTERM", Number: int(syscall.SIGTERM)}
+	// every sets every fact at once, to check the order they are weighed in.
+	every := jobFailure{runErr: exited, signal: killed, diskQuota: true, cpuLimit: true, cgroupOOM: true, pidsLimit: true, v8OOM: true, kernelOOM: true}
+	with := func(f jobFailure, cause error) jobFailure {
+		f.cause = cause
+		return f
+	}
+	tests := []struct {
+		name      string
+		f         jobFailure
+		want      failureKind
+		retryable bool
+	}{
+		{"success", jobFailure{}, failureNone, false},
+		{"success with limits hit", jobFailure{cpuLimit: true, cgroupOOM: true, v8OOM: true, signal: segv}, failureNone, false},
+		{"script failed", jobFailure{runErr: exited}, failureRuntime, false},
+		{"script killed itself", jobFailure{runErr: exited, signal: term}, failureRuntime, false},
+		{"killed from outside", jobFailure{runErr: exited, signal: killed}, failureRuntime, true},
+		{"killed by the runner", jobFailure{runErr: exited, signal: killed, terminated: true}, failureRuntime, false},
+
+		{"canceled", with(every, errJobCanceled), failureCanceled, false},
+		{"canceled without failing", jobFailure{cause: errJobCanceled}, failureCanceled, false},
+		{"canceled wrapped", jobFailure{cause: fmt.Errorf("by client: %w", errJobCanceled), runErr: exited}, failureCanceled, false},
+		{"shutdown", with(every, errRunnerShutdown), failureShutdown, false},
+		{"output flood", with(every, errOutputFlood), failureOutputFlood, false},
+		{"disk quota by cause", with(every, errDiskQuota), failureDiskQuota, false},
+		{"disk quota found after", every, failureDiskQuota, false},
+		{"disk quota without failure", jobFailure{diskQuota: true}, failureNone, false},
+		{"disk over stdin idle", with(every, errStdinIdle), failureDiskQuota, false},
+		{"stdin idle", jobFailure{cause: errStdinIdle, runErr: exited, signal: killed, cpuLimit: true}, failureIdleTimeout, false},
+		{"timeout", jobFailure{cause: context.DeadlineExceeded, runErr: exited, signal: killed, cpuLimit: true, cgroupOOM: true, kernelOOM: true}, failureTimeout, false},
+		{"timeout over crash", jobFailure{cause: context.DeadlineExceeded, runErr: exited, signal: segv}, failureTimeout, false},
+
+		{"cpu limit", jobFailure{runErr: exited, signal: killed, cpuLimit: true, cgroupOOM: true, pidsLimit: true, v8OOM: true, kernelOOM: true}, failureCPULimit, false},
+		{"cgroup oom", jobFailure{runErr: exited, signal: killed, cgroupOOM: true, pidsLimit: true, v8OOM: true, kernelOOM: true}, failureCgroupOOM, false},
+		{"pids", jobFailure{runErr: exited, pidsLimit: true, v8OOM: true, kernelOOM: true}, failureProcessLimit, false},
+		{"v8 oom", jobFailure{runErr: exited, signal: segv, v8OOM: true, kernelOOM: true}, failureV8OOM, false},
+		{"kernel oom", jobFailure{runErr: exited, signal: killed, kernelOOM: true}, failureKernelOOM, true},
+		{"kernel oom over crash", jobFailure{runErr: exited, signal: segv, kernelOOM: true}, failureKernelOOM, true},
+		{"crash", jobFailure{runErr: exited, signal: segv}, failureCrash, true},
+		{"fault after the runner stopped it", jobFailure{runErr: exited, signal: segv, terminated: true}, failureRuntime, false},
+		{"other cause", jobFailure{cause: errors.New("unrelated"), runErr: exited}, failureRuntime, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind := tt.f.classify()
+			if kind != tt.want {
+				t.Errorf("classify = %d, want %d", kind, tt.want)
+			}
+			if got := tt.f.retryable(kind); got != tt.retryable {
+				t.Errorf("retryable = %v, want %v", got, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	const def, ceiling = 30 * time.Second, 20 * time.Minute
+	tests := []struct {
+		requestedMs int64
+		want        time.Duration
+		runnerLimit bool
+	}{
+		{0, def, true},
+		{5000, 5 * time.Second, false},
+		// Past the old fixed five minutes, which the ceiling now replaces.
+		{(10 * time.Minute).Milliseconds(), 10 * time.Minute, false},
+		{ceiling.Milliseconds(), ceiling, false},
+		{ceiling.Milliseconds() + 1, ceiling, true},
+	}
+	for _, tt := range tests {
+		got, runnerLimit := jobTimeout(tt.requestedMs, def, ceiling)
+		if got != tt.want || runnerLimit != tt.runnerLimit {
+			t.Errorf("jobTimeout(%d) = %v, %v; want %v, %v", tt.requestedMs, got, runnerLimit, tt.want, tt.runnerLimit)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// floodMeter watches a job's combined output rate. Once the average over a
+// window of the configured length is above the limit, it calls trip (once)
+// and discards everything written afterwards, so a job printing in a tight
+// loop stops costing the runner CPU while it is being killed.
+type floodMeter struct {
+	mu      sync.Mutex
+	limit   int64 // bytes per second
+	window  time.Duration
+	start   time.Time
+	bytes   int64
+	tripped bool
+	trip    func()
+}
+
+func newFloodMeter(limit int64, window time.Duration, trip func()) *floodMeter {
+	return &floodMeter{limit: limit, window: window, start: time.Now(), trip: trip}
+}
+
+// writer returns w metered by m.
+func (m *floodMeter) writer(w io.Writer) io.Writer {
+	return floodWriter{m: m, w: w}
+}
+
+// add counts n bytes and reports whether output is still being accepted.
+func (m *floodMeter) add(n int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tripped {
+		return false
+	}
+	if now := time.Now(); now.Sub(m.start) >= m.window {
+		m.start, m.bytes = now, 0
+	}
+	m.bytes += int64(n)
+	// More than limit*window bytes in one window is over the limit on
+	// average however the rest of the window goes.
+	if m.bytes > m.limit*int64(m.window)/int64(time.Second) {
+		m.tripped = true
+		go m.trip()
+		return false
+	}
+	return true
+}
+
+type floodWriter struct {
+	m *floodMeter
+	w io.Writer
+}
+
+func (f floodWriter) Write(p []byte) (int, error) {
+	if !f.m.add(len(p)) {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fmtError matches the error deno fmt prints for code it can't parse, e.g.
+// "error: Expression expected at file:///.../$deno$stdin.ts:1:7".
+var fmtError = regexp.MustCompile(`^error: (.+) at \S+:(\d+):(\d+)$`)
+
+// fillFormat turns the output of deno fmt into res.Formatted, or with
+// fmtCheck into res.IsFormatted.
+func fillFormat(res *RunResult, req *RunRequest) {
+	switch {
+	case res.ErrorCode == "" && req.FmtCheck:
+		formatted := true
+		res.IsFormatted = &formatted
+		return
+	case res.ErrorCode == "" && res.Truncated:
+		res.Error = "formatted code exceeds the output cap"
+		res.ErrorCode = errorCodeFormat
+		return
+	case res.ErrorCode == "":
+		res.Formatted = res.Stdout
+		return
+	case res.ErrorCode != errorCodeRuntime || res.ExitSignal != nil:
+		return
+	}
+	for _, line := range strings.Split(stripANSI(res.Stderr), "\n") {
+		if m := fmtError.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{Message: m[1]}
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Column, _ = strconv.Atoi(m[3])
+			res.Diagnostics = append(res.Diagnostics, d)
+		}
+	}
+	switch {
+	case len(res.Diagnostics) > 0:
+		res.Error = "formatting failed: " + res.Diagnostics[0].Message
+		res.ErrorCode = errorCodeFormat
+	case req.FmtCheck:
+		formatted := false
+		res.IsFormatted = &formatted
+		res.Error = "code is not formatted"
+		res.ErrorCode = errorCodeNotFormatted
+	default:
+		res.ErrorCode = errorCodeFormat
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import "sync"
+
+// keyedGate limits how many jobs per key run at once. Jobs over the limit
+// wait in a per-key FIFO outside the worker pool's queue, so a key that is at
+// its limit never holds up jobs for other keys.
+//
+// A job may hold several of its key's slots (see Config.BenchWeight). One
+// heavier than the whole limit still runs, alone, so it can't wait forever.
+type keyedGate struct {
+	mu         sync.Mutex
+	limit      func(key string) int // <= 0 means unlimited
+	maxWaiting int
+	active     map[string]int
+	waiting    map[string][]gateWaiter
+}
+
+type gateWaiter struct {
+	job    *pendingJob
+	weight int
+}
+
+func newKeyedGate(limit func(key string) int, maxWaiting int) *keyedGate {
+	return &keyedGate{
+		limit:      limit,
+		maxWaiting: maxWaiting,
+		active:     make(map[string]int),
+		waiting:    make(map[string][]gateWaiter),
+	}
+}
+
+// fits reports whether weight more slots are free for key. Callers hold g.mu.
+func (g *keyedGate) fits(key string, weight int) bool {
+	limit := g.limit(key)
+	return limit <= 0 || g.active[key] == 0 || g.active[key]+weight <= limit
+}
+
+// admit reports whether job, holding weight slots, may proceed now. If not,
+// the job is parked until enough slots for its key free up; ok is false and
+// the caller must not dispatch it. full is set when the key's waiting list is
+// already at capacity.
+func (g *keyedGate) admit(key string, job *pendingJob, weight int) (ok, full bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// Waiting jobs keep their turn: a light job doesn't overtake a heavy one.
+	if len(g.waiting[key]) == 0 && g.fits(key, weight) {
+		g.active[key] += weight
+		return true, false
+	}
+	if len(g.waiting[key]) >= g.maxWaiting {
+		return false, true
+	}
+	g.waiting[key] = append(g.waiting[key], gateWaiter{job, weight})
+	return false, false
+}
+
+// release frees the weight slots held by a finished job. Jobs waiting for the
+// same key that now fit take the slots, in order, and are returned for
+// dispatch.
+func (g *keyedGate) release(key string, weight int) []*pendingJob {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active[key] -= weight
+	var next []*pendingJob
+	waiting := g.waiting[key]
+	for len(waiting) > 0 && g.fits(key, waiting[0].weight) {
+		g.active[key] += waiting[0].weight
+		next = append(next, waiting[0].job)
+		waiting = waiting[1:]
+	}
+	if len(waiting) == 0 {
+		delete(g.waiting, key)
+	} else {
+		g.waiting[key] = waiting
+	}
+	if g.active[key] <= 0 {
+		delete(g.active, key)
+	}
+	return next
+}
+
+// remove takes a parked job off key's waiting list, reporting whether it was
+// there. The job held no slots, so none are freed.
+func (g *keyedGate) remove(key string, job *pendingJob) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	waiting := g.waiting[key]
+	for i, w := range waiting {
+		if w.job != job {
+			continue
+		}
+		if len(waiting) == 1 {
+			delete(g.waiting, key)
+		} else {
+			g.waiting[key] = append(waiting[:i:i], waiting[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
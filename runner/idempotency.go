@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	maxIdempotencyKeyBytes = 256
+	// idempotencyKVTimeout bounds each read and write of the KV bucket, so a
+	// slow JetStream delays a job by at most this much.
+	idempotencyKVTimeout = 2 * time.Second
+)
+
+// idempotencyStore remembers the results of requests with an idempotency
+// key: in memory, and in a JetStream KV bucket when one is configured.
+type idempotencyStore struct {
+	mem *resultCache
+	kv  jetstream.KeyValue // nil unless RUNNER_IDEMPOTENCY_KV_BUCKET is set
+}
+
+func newIdempotencyStore(cfg *Config, nc *nats.Conn) (*idempotencyStore, error) {
+	s := &idempotencyStore{mem: newResultCache(cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries, replayable)}
+	if cfg.IdempotencyBucket == "" {
+		return s, nil
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s.kv, err = js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      cfg.IdempotencyBucket,
+		Description: "runner results by idempotency key",
+		TTL:         cfg.IdempotencyTTL,
+		History:     1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bucket %q: %w", cfg.IdempotencyBucket, err)
+	}
+	return s, nil
+}
+
+// idempotencyStoreKey scopes a request's idempotency key to its tenant, so
+// tenants can't replay each other's results. It is hashed to fit the
+// characters KV keys allow.
+func idempotencyStoreKey(tenant, key string) string {
+	sum := sha256.Sum256([]byte(tenant + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// replayable reports whether res is the outcome of running the request, and
+// so what a retry must get. Requests that were turned away or lost to the
+// runner before the code ran are left to run again.
+func replayable(res RunResult) bool {
+	switch res.ErrorCode {
+	case errorCodeBusy, errorCodeShutdown, errorCodeExpired, errorCodeSpawnFailed, errorCodeInternal:
+		return false
+	}
+	return true
+}
+
+// lookup returns the stored result for key, reading the KV bucket if it is
+// not in memory.
+func (s *idempotencyStore) lookup(key string) (RunResult, bool) {
+	if res, ok := s.mem.lookup(key); ok {
+		return res, true
+	}
+	if s.kv == nil {
+		return RunResult{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyKVTimeout)
+	defer cancel()
+	entry, err := s.kv.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			log.Printf("[WARN] Reading idempotency key from KV: %v", err)
+		}
+		return RunResult{}, false
+	}
+	var res RunResult
+	if err := json.Unmarshal(entry.Value(), &res); err != nil {
+		log.Printf("[WARN] Discarding bad idempotency KV entry: %v", err)
+		return RunResult{}, false
+	}
+	s.mem.store(key, res)
+	return res, true
+}
+
+// store records res under key. The KV write happens in the background so it
+// doesn't hold up the reply; until it lands, only this runner can replay it.
+func (s *idempotencyStore) store(key string, res RunResult) {
+	if !replayable(res) {
+		return
+	}
+	s.mem.store(key, res)
+	if s.kv == nil {
+		return
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		log.Printf("[WARN] Encoding result for idempotency KV: %v", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyKVTimeout)
+		defer cancel()
+		if _, err := s.kv.Put(ctx, key, data); err != nil {
+			log.Printf("[WARN] Writing idempotency key to KV: %v", err)
+		}
+	}()
+}
+
+// acceptIdempotent replays the stored result to a request whose key has run
+// before, attaches it to the run in progress if there is one, and otherwise
+// dispatches it as the key's first run.
+func (r *Runner) acceptIdempotent(job *pendingJob) {
+	req := &job.req
+	key := idempotencyStoreKey(job.tenant, req.IdempotencyKey)
+	if res, ok := r.idempotency.lookup(key); ok {
+		log.Printf("[IDEMPOTENCY] Replaying stored result to %s", req.PublicID)
+		res.Replayed = true
+		job.send(res)
+		return
+	}
+	flight := "idempotency\x00" + key
+	if r.flights.join(flight, job) {
+		log.Printf("[IDEMPOTENCY] %s attached to the in-flight run with the same key", req.PublicID)
+		job.acknowledge(submitWaiting)
+		return
+	}
+	job.idempotencyKey, job.flightKey = key, flight
+	// The previous run may have finished between the lookup and the join.
+	if res, ok := r.idempotency.lookup(key); ok {
+		log.Printf("[IDEMPOTENCY] Replaying stored result to %s", req.PublicID)
+		res.Replayed = true
+		r.active.Add(1) // reply counts the job as answered
+		r.reply(job, res)
+		return
+	}
+	r.dispatch(job)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// Reply headers naming the runner instance that answered.
+const (
+	runnerIDHeader      = "Runner-Id"
+	runnerVersionHeader = "Runner-Version"
+)
+
+// runnerVersion can be set at build time with
+// -ldflags "-X main.runnerVersion=v1.2.3"; otherwise it comes from the
+// module and VCS information embedded by the Go toolchain.
+var runnerVersion string
+
+// runnerID names this instance in results and replies. main sets it from
+// RUNNER_ID, or from the hostname plus a random suffix.
+var runnerID string
+
+func init() {
+	if runnerVersion == "" {
+		runnerVersion = buildVersion()
+	}
+}
+
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	var revision, dirty string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	switch {
+	case info.Main.Version != "" && info.Main.Version != "(devel)":
+		return info.Main.Version
+	case revision != "":
+		return revision[:min(len(revision), 12)] + dirty
+	}
+	return "dev"
+}
+
+func defaultRunnerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "runner"
+	}
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// stamp marks a result with the protocol version and this runner's identity,
+// and timestamps results of jobs that never ran.
+func (res *RunResult) stamp() {
+	if res.StartedAt.IsZero() {
+		now := time.Now().UTC()
+		res.StartedAt, res.FinishedAt = now, now
+	}
+	res.V = maxProtocolVersion
+	res.RunnerID = runnerID
+	res.RunnerVersion = runnerVersion
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// importMapFileName is where a request's import map is written in the
+// working directory.
+const importMapFileName = ".runner-import-map.json"
+
+// Registries behind the jsr: and npm: specifiers, which import map targets
+// may use when their host is allowlisted.
+var registryHosts = map[string]string{
+	"jsr": "jsr.io",
+	"npm": "registry.npmjs.org",
+}
+
+// importMap is the subset of the import map format deno accepts.
+type importMap struct {
+	Imports map[string]string            `json:"imports,omitempty"`
+	Scopes  map[string]map[string]string `json:"scopes,omitempty"`
+}
+
+// parseImportMap decodes raw and checks every target in it against the
+// import allowlist. It returns the remote hosts the map points at.
+func (c *Config) parseImportMap(raw json.RawMessage) ([]string, error) {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil, fmt.Errorf("must be an object")
+	}
+	var m importMap
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("must be an object of imports and scopes: %v", err)
+	}
+	seen := make(map[string]bool)
+	var hosts []string
+	check := func(specifier, target string) error {
+		host, err := c.importTargetHost(target)
+		if err != nil {
+			return fmt.Errorf("%q: %v", specifier, err)
+		}
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+		return nil
+	}
+	for specifier, target := range m.Imports {
+		if err := check(specifier, target); err != nil {
+			return nil, err
+		}
+	}
+	for _, scope := range m.Scopes {
+		for specifier, target := range scope {
+			if err := check(specifier, target); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// importTargetHost returns the host a mapped target is fetched from, or ""
+// for targets that are not remote: node builtins and files under the
+// working directory.
+func (c *Config) importTargetHost(target string) (string, error) {
+	if strings.HasPrefix(target, "./") {
+		if strings.Contains(target, "..") {
+			return "", fmt.Errorf("relative targets must stay in the working directory")
+		}
+		return "", nil
+	}
+	scheme, _, ok := strings.Cut(target, ":")
+	if !ok {
+		return "", fmt.Errorf("target %q must be a URL, a jsr:, npm: or node: specifier, or start with ./", target)
+	}
+	var host string
+	switch scheme {
+	case "node":
+		return "", nil
+	case "jsr", "npm":
+		host = registryHosts[scheme]
+	case "https", "http":
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid target URL %q", target)
+		}
+		host = u.Host
+	default:
+		return "", fmt.Errorf("%s: targets are not allowed", scheme)
+	}
+	if !c.importAllowed(host) {
+		return "", fmt.Errorf("%s is not in the import allowlist", host)
+	}
+	return host, nil
+}
+
+// importAllowed reports whether host is in the import allowlist.
+func (c *Config) importAllowed(host string) bool {
+	for _, h := range c.ImportAllowlist {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// withImportMap narrows the job's --allow-import permission for a request
+// with an import map to the stricter of the two sets: hosts the request
+// allowed that are also in the allowlist, or, when it asked for none, just
+// the hosts the map points at.
+func withImportMap(perms []string, allowlist, mapped []string) []string {
+	var requested map[string]bool // nil unless the request has --allow-import
+	out := make([]string, 0, len(perms)+1)
+	for _, perm := range perms {
+		name, value, _ := strings.Cut(perm, "=")
+		if name != "--allow-import" {
+			out = append(out, perm)
+			continue
+		}
+		if requested == nil {
+			requested = make(map[string]bool)
+		}
+		if value == "" {
+			// A bare --allow-import asks for everything.
+			for _, h := range allowlist {
+				requested[h] = true
+			}
+		}
+		for _, h := range strings.Split(value, ",") {
+			requested[h] = true
+		}
+	}
+	hosts := mapped
+	if requested != nil {
+		hosts = nil
+		for _, h := range allowlist {
+			if requested[h] {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	if len(hosts) > 0 {
+		out = append(out, "--allow-import="+strings.Join(hosts, ","))
+	}
+	return out
+}
+
+// mergeHosts returns the sorted union of two host lists.
+func mergeHosts(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var hosts []string
+	for _, h := range append(append([]string(nil), a...), b...) {
+		if !seen[h] {
+			seen[h] = true
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// importMapFlag returns the --import-map flag for req, if it has one.
+func importMapFlag(req *RunRequest, workdir string) []string {
+	if len(req.ImportMap) == 0 {
+		return nil
+	}
+	return []string{"--import-map=" + filepath.Join(workdir, importMapFileName)}
+}
+
+// writeImportMap writes a validated import map into dir.
+func writeImportMap(dir string, raw json.RawMessage) error {
+	f, err := os.OpenFile(filepath.Join(dir, importMapFileName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	// errJobCanceled is the context cause used when a client cancels a job.
+	errJobCanceled = errors.New("canceled")
+	// errRunnerShutdown is the context cause used when the runner is stopping.
+	errRunnerShutdown = errors.New("runner shutting down")
+	// errDiskQuota is the context cause used when a job outgrows its working directory quota.
+	errDiskQuota = errors.New("disk quota exceeded")
+	// errOutputFlood is the context cause used when a job writes output faster than the rate limit.
+	errOutputFlood = errors.New("output rate limit exceeded")
+	// errStdinIdle is the context cause used when an interactive job waits too long for input.
+	errStdinIdle = errors.New("stdin idle timeout")
+)
+
+// CancelRequest is the optional body of a message on runner.cancel.
+type CancelRequest struct {
+	PublicID string `json:"publicId"`
+}
+
+// CancelResult is the reply to a cancel message.
+type CancelResult struct {
+	PublicID string `json:"publicId"`
+	Status   string `json:"status"` // "canceled" or "not_found"
+	Error    string `json:"error,omitempty"`
+}
+
+// Job states reported by runner.tail.
+const (
+	jobStarting = "starting"
+	jobRunning  = "running"
+)
+
+// runningJob is an in-flight execution that can be canceled and tailed.
+type runningJob struct {
+	publicID string
+	cancel   context.CancelCauseFunc
+	started  time.Time
+	tail     *lineRing
+	state    atomic.Value // string
+}
+
+func (j *runningJob) setState(s string) { j.state.Store(s) }
+
+func (j *runningJob) getState() string {
+	s, _ := j.state.Load().(string)
+	return s
+}
+
+// jobRegistry tracks in-flight jobs by PublicID, and accepted jobs that are
+// still waiting at a gate or in the worker pool's queue.
+type jobRegistry struct {
+	mu     sync.Mutex
+	byID   map[string][]*runningJob
+	queued map[string][]*pendingJob
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{byID: make(map[string][]*runningJob), queued: make(map[string][]*pendingJob)}
+}
+
+// hold registers a job dispatch has accepted, so it can be canceled before a
+// worker picks it up.
+func (jr *jobRegistry) hold(job *pendingJob) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jr.queued[job.req.PublicID] = append(jr.queued[job.req.PublicID], job)
+}
+
+// take unregisters a held job and reports whether it was still held; false
+// means a cancel claimed it while it was queued.
+func (jr *jobRegistry) take(job *pendingJob) bool {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	id := job.req.PublicID
+	jobs := jr.queued[id]
+	for i, j := range jobs {
+		if j != job {
+			continue
+		}
+		if len(jobs) == 1 {
+			delete(jr.queued, id)
+		} else {
+			jr.queued[id] = append(jobs[:i:i], jobs[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// takeAll unregisters and returns every held job with the given PublicID.
+func (jr *jobRegistry) takeAll(publicID string) []*pendingJob {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jobs := jr.queued[publicID]
+	delete(jr.queued, publicID)
+	return jobs
+}
+
+func (jr *jobRegistry) add(publicID string, started time.Time, cancel context.CancelCauseFunc) *runningJob {
+	job := &runningJob{publicID: publicID, cancel: cancel, started: started, tail: newLineRing(tailLines)}
+	job.setState(jobStarting)
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jr.byID[publicID] = append(jr.byID[publicID], job)
+	return job
+}
+
+func (jr *jobRegistry) remove(job *runningJob) {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jobs := jr.byID[job.publicID]
+	for i, j := range jobs {
+		if j == job {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			break
+		}
+	}
+	if len(jobs) == 0 {
+		delete(jr.byID, job.publicID)
+	} else {
+		jr.byID[job.publicID] = jobs
+	}
+}
+
+// latest returns the most recently started in-flight job with the given
+// PublicID, or nil.
+func (jr *jobRegistry) latest(publicID string) *runningJob {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jobs := jr.byID[publicID]
+	if len(jobs) == 0 {
+		return nil
+	}
+	return jobs[len(jobs)-1]
+}
+
+// cancel aborts every in-flight job with the given PublicID and reports how many were found.
+func (jr *jobRegistry) cancel(publicID string) int {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	jobs := jr.byID[publicID]
+	for _, j := range jobs {
+		j.cancel(errJobCanceled)
+	}
+	return len(jobs)
+}
+
+// cancelAll aborts every in-flight job with the given cause and returns how many there were.
+func (jr *jobRegistry) cancelAll(cause error) int {
+	jr.mu.Lock()
+	defer jr.mu.Unlock()
+	n := 0
+	for _, jobs := range jr.byID {
+		for _, j := range jobs {
+			j.cancel(cause)
+			n++
+		}
+	}
+	return n
+}
+
+// waitIdle blocks until no jobs are in flight or the timeout elapses.
+func (jr *jobRegistry) waitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		jr.mu.Lock()
+		idle := len(jr.byID) == 0
+		jr.mu.Unlock()
+		if idle {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (r *Runner) handleCancel(m *nats.Msg) {
+	publicID := r.cfg.trimSubject(m.Subject, "cancel")
+	if m.Subject == r.cfg.subject("cancel") {
+		var req CancelRequest
+		if err := json.Unmarshal(m.Data, &req); err != nil {
+			log.Printf("Bad cancel data: %v", err)
+			r.respond(m, CancelResult{Status: "not_found", Error: "invalid cancel request"})
+			return
+		}
+		publicID = req.PublicID
+	}
+
+	if n := r.scheduler.cancel(publicID); n > 0 {
+		log.Printf("[CANCEL] Canceled %d scheduled job(s) for: %s", n, publicID)
+		r.respond(m, CancelResult{PublicID: publicID, Status: "canceled"})
+		return
+	}
+	if r.cancelJobs(publicID) == 0 {
+		log.Printf("[CANCEL] No in-flight job for: %s", publicID)
+		r.respond(m, CancelResult{PublicID: publicID, Status: "not_found", Error: "job not found or already completed"})
+		return
+	}
+	log.Printf("[CANCEL] Canceling job: %s", publicID)
+	r.respond(m, CancelResult{PublicID: publicID, Status: "canceled"})
+}
+
+// cancelJobs cancels every accepted job with the given PublicID, running or
+// not, and reports how many there were. Jobs waiting at a gate or in the
+// worker pool's queue are taken out and answered with a canceled result; one
+// caught between two of those stages is answered when a worker reaches it.
+func (r *Runner) cancelJobs(publicID string) int {
+	queued := r.jobs.takeAll(publicID)
+	for _, job := range queued {
+		switch {
+		case job.serialized && r.serial.remove(publicID, job):
+		case r.tenants.remove(job.tenant, job):
+			r.releaseSerial(job)
+		case r.pool.remove(job):
+			r.finish(job)
+		default:
+			continue // on its way to a worker; runPending turns it away
+		}
+		log.Printf("[CANCEL] Dropping queued job: %s", publicID)
+		r.reply(job, canceledResult())
+	}
+	return len(queued) + r.jobs.cancel(publicID)
+}
+
+// canceledResult is the result for a job canceled before it ran.
+func canceledResult() RunResult {
+	return RunResult{ExitCode: -1, Error: errJobCanceled.Error(), ErrorCode: errorCodeCanceled}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+)
+
+// gatedRunner returns a runner whose pool has no workers, so dispatched jobs
+// stay queued, and whose tenants may run one job at a time.
+func gatedRunner(t *testing.T) *Runner {
+	r := testRunner(t)
+	r.pool = newWorkerPool(0, 10, r.cfg.PriorityAging, equalWeights, r.runPending)
+	r.tenants = newKeyedGate(func(string) int { return 1 }, 10)
+	r.serial = newKeyedGate(func(string) int { return 1 }, 10)
+	return r
+}
+
+// dispatchTest dispatches a job that reports to the returned channel.
+func dispatchTest(r *Runner, id, tenant string, serialized bool) <-chan RunResult {
+	results := make(chan RunResult, 1)
+	job := testJob(id, tenant, priorityNormal)
+	job.serialized = serialized
+	job.done = func(res RunResult) { results <- res }
+	r.dispatch(job)
+	return results
+}
+
+func TestCancelQueuedJobs(t *testing.T) {
+	r := gatedRunner(t)
+	queued := dispatchTest(r, "queued", "t", false)      // in the pool's queue, holding t's slot
+	tenantGated := dispatchTest(r, "tenant", "t", false) // waiting for t's slot
+	serialQueued := dispatchTest(r, "serial", "", true)  // in the pool's queue, holding the PublicID
+	serialGated := dispatchTest(r, "serial", "", true)   // waiting for the PublicID
+	if r.pool.queued != 2 {
+		t.Fatalf("%d jobs in the pool's queue, want 2", r.pool.queued)
+	}
+
+	for _, tc := range []struct {
+		id      string
+		n       int
+		results []<-chan RunResult
+	}{
+		{"tenant", 1, []<-chan RunResult{tenantGated}},
+		{"queued", 1, []<-chan RunResult{queued}},
+		{"serial", 2, []<-chan RunResult{serialQueued, serialGated}},
+	} {
+		if n := r.cancelJobs(tc.id); n != tc.n {
+			t.Errorf("cancelJobs(%q) = %d, want %d", tc.id, n, tc.n)
+		}
+		for _, c := range tc.results {
+			select {
+			case res := <-c:
+				if res.ErrorCode != errorCodeCanceled || res.PublicID != tc.id {
+					t.Errorf("%s: result %+v", tc.id, res)
+				}
+			default:
+				t.Errorf("%s: no result", tc.id)
+			}
+		}
+	}
+	if r.pool.queued != 0 || len(r.tenants.active) != 0 || len(r.tenants.waiting) != 0 ||
+		len(r.serial.active) != 0 || len(r.serial.waiting) != 0 || len(r.jobs.queued) != 0 {
+		t.Errorf("left over: %d queued, tenants %v %v, serial %v %v, held %v",
+			r.pool.queued, r.tenants.active, r.tenants.waiting, r.serial.active, r.serial.waiting, r.jobs.queued)
+	}
+	if n := r.active.Load(); n != 0 {
+		t.Errorf("%d jobs still active", n)
+	}
+	if n := r.cancelJobs("queued"); n != 0 {
+		t.Errorf("second cancel found %d jobs", n)
+	}
+}
+
+// TestCancelDequeuedJob cancels a job a worker has taken from the queue but
+// not started, which the worker must then turn away.
+func TestCancelDequeuedJob(t *testing.T) {
+	r := gatedRunner(t)
+	results := dispatchTest(r, "job", "t", false)
+	job := r.pool.next()
+	if n := r.cancelJobs("job"); n != 1 {
+		t.Fatalf("cancelJobs = %d, want 1", n)
+	}
+	select {
+	case res := <-results:
+		t.Fatalf("answered before the worker saw it: %+v", res)
+	default:
+	}
+	r.runPending(0, job)
+	if res := <-results; res.ErrorCode != errorCodeCanceled {
+		t.Errorf("result %+v", res)
+	}
+	if len(r.tenants.active) != 0 || r.active.Load() != 0 {
+		t.Errorf("tenant slots %v, %d active", r.tenants.active, r.active.Load())
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+// languageExts maps RunRequest.Language to deno's --ext values. Without a
+// language deno works out the media type of the code itself.
+var languageExts = map[string]string{
+	"ts":         "ts",
+	"typescript": "ts",
+	"tsx":        "tsx",
+	"js":         "js",
+	"javascript": "js",
+	"jsx":        "jsx",
+}
+
+// extFlag returns the --ext flag for language, or nothing when it is unset.
+// The language has already been validated.
+func extFlag(language string) []string {
+	if language == "" {
+		return nil
+	}
+	return []string{"--ext=" + languageExts[language]}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "time"
+
+// Limits describes the constraints a job ran under and which of them it hit.
+// Zero-valued caps were not in effect.
+type Limits struct {
+	TimeoutMs       int64 `json:"timeoutMs"`
+	HeapMB          int   `json:"heapMb"`
+	MemoryBytes     int64 `json:"memoryBytes,omitempty"`
+	CPUMs           int64 `json:"cpuMs,omitempty"`
+	Processes       int   `json:"processes,omitempty"`
+	DiskQuotaBytes  int64 `json:"diskQuotaBytes,omitempty"`
+	OutputBytes     int64 `json:"outputBytes"`
+	OutputRateBytes int64 `json:"outputRateBytes,omitempty"`
+	MaxConcurrent   int   `json:"maxConcurrent"`
+	// QueuePosition is how many jobs were waiting, this one included, when
+	// it was queued; 0 means a worker was free.
+	QueuePosition int `json:"queuePosition"`
+	// Hit names the limits the job ran into: "timeout", "heap", "memory",
+	// "cpu", "processes", "disk", "output" (truncated) or "outputRate".
+	Hit []string `json:"hit,omitempty"`
+}
+
+// jobLimits returns the limits in effect for a job with the given timeout
+// and heap size.
+func (r *Runner) jobLimits(timeout time.Duration, heapMB int) *Limits {
+	l := &Limits{
+		TimeoutMs:       timeout.Milliseconds(),
+		HeapMB:          heapMB,
+		CPUMs:           r.cfg.CPULimit.Milliseconds(),
+		DiskQuotaBytes:  r.cfg.DiskQuota,
+		OutputBytes:     r.cfg.MaxOutputBytes,
+		OutputRateBytes: r.cfg.OutputRateLimit,
+		MaxConcurrent:   r.cfg.MaxConcurrent,
+	}
+	if r.cgroups != nil {
+		l.MemoryBytes = r.cfg.MemoryLimit
+		l.Processes = r.cfg.PidsLimit
+	} else if r.rlimits.NProc > 0 { // the RLIMIT_NPROC fallback
+		l.Processes = int(r.rlimits.NProc)
+	}
+	return l
+}
+
+// markHit records in res.Limits which limits the classified result ran into.
+func markHit(res *RunResult, v8OOM bool) {
+	l := res.Limits
+	switch res.ErrorCode {
+	case errorCodeTimeout:
+		l.Hit = append(l.Hit, "timeout")
+	case errorCodeOOM:
+		if v8OOM {
+			l.Hit = append(l.Hit, "heap")
+		} else {
+			l.Hit = append(l.Hit, "memory")
+		}
+	case errorCodeCPULimit:
+		l.Hit = append(l.Hit, "cpu")
+	case errorCodeProcessLimit:
+		l.Hit = append(l.Hit, "processes")
+	case errorCodeDiskQuota:
+		l.Hit = append(l.Hit, "disk")
+	case errorCodeOutputFlood:
+		l.Hit = append(l.Hit, "outputRate")
+	}
+	if res.Truncated {
+		l.Hit = append(l.Hit, "output")
+	}
+}
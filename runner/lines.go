@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxOutputLines caps how many timestamped lines a result carries.
+	maxOutputLines = 10000
+	// maxOutputLineBytes splits longer lines into several entries.
+	maxOutputLineBytes = 4 << 10
+)
+
+// OutputLine is one line of output with the time it was written, returned
+// in RunResult.Lines for requests with timestamps set.
+type OutputLine struct {
+	Time   time.Time `json:"t"`
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+}
+
+// lineRecorder splits stdout and stderr into timestamped lines. A line is
+// stamped when its first byte arrives.
+type lineRecorder struct {
+	mu      sync.Mutex
+	lines   []OutputLine
+	dropped int
+	partial map[string]*OutputLine
+}
+
+func newLineRecorder() *lineRecorder {
+	return &lineRecorder{partial: make(map[string]*OutputLine)}
+}
+
+func (lr *lineRecorder) writer(stream string) *lineRecorderWriter {
+	return &lineRecorderWriter{lr: lr, stream: stream}
+}
+
+type lineRecorderWriter struct {
+	lr     *lineRecorder
+	stream string
+}
+
+func (w *lineRecorderWriter) Write(p []byte) (int, error) {
+	lr := w.lr
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	now := time.Now()
+	buf := []byte(nil)
+	cur := lr.partial[w.stream]
+	if cur != nil {
+		buf = []byte(cur.Text)
+	} else {
+		cur = &OutputLine{Time: now, Stream: w.stream}
+	}
+	for _, c := range p {
+		if c == '\n' {
+			cur.Text = string(buf)
+			lr.add(*cur)
+			buf = buf[:0]
+			cur = &OutputLine{Time: now, Stream: w.stream}
+			continue
+		}
+		buf = append(buf, c)
+		if len(buf) >= maxOutputLineBytes {
+			n := runeBoundary(buf)
+			cur.Text = string(buf[:n])
+			lr.add(*cur)
+			buf = append([]byte(nil), buf[n:]...)
+			cur = &OutputLine{Time: now, Stream: w.stream}
+		}
+	}
+	if len(buf) > 0 {
+		cur.Text = string(buf)
+		lr.partial[w.stream] = cur
+	} else {
+		delete(lr.partial, w.stream)
+	}
+	return len(p), nil
+}
+
+func (lr *lineRecorder) add(l OutputLine) {
+	if len(lr.lines) >= maxOutputLines {
+		lr.dropped++
+		return
+	}
+	lr.lines = append(lr.lines, l)
+}
+
+// fill flushes partial lines and copies the recorded lines into the result.
+func (lr *lineRecorder) fill(res *RunResult) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	for _, stream := range []string{"stdout", "stderr"} {
+		if cur := lr.partial[stream]; cur != nil {
+			lr.add(*cur)
+			delete(lr.partial, stream)
+		}
+	}
+	for i := range lr.lines {
+		lr.lines[i].Text = strings.ToValidUTF8(lr.lines[i].Text, "\uFFFD")
+	}
+	res.Lines = lr.lines
+	res.LinesDropped = lr.dropped
+}
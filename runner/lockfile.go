@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lockfileName is where a request's lockfile is written in the working
+// directory.
+const lockfileName = "deno.lock"
+
+// lockfileViolations are the deno errors meaning a --frozen run needed
+// something the lockfile doesn't pin, or got content that doesn't match it.
+var lockfileViolations = []string{
+	"The lockfile is out of date",
+	"Integrity check failed",
+}
+
+// validateLockfile checks that contents are a JSON object, as deno.lock is.
+func validateLockfile(contents string) error {
+	var lock map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(contents), &lock); err != nil {
+		return fmt.Errorf("must be the JSON contents of a deno.lock: %v", err)
+	}
+	return nil
+}
+
+// lockfileFlags returns the flags that make deno run against req's
+// lockfile, refusing anything not pinned in it.
+func lockfileFlags(req *RunRequest, workdir string) []string {
+	if req.Lockfile == "" {
+		return nil
+	}
+	return []string{"--lock=" + filepath.Join(workdir, lockfileName), "--frozen"}
+}
+
+// writeLockfile writes req's lockfile into dir. It refuses to overwrite an
+// input file of the same name.
+func writeLockfile(dir, contents string) error {
+	f, err := os.OpenFile(filepath.Join(dir, lockfileName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(contents); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkLockfile reports a run that failed because of the lockfile as
+// LOCKFILE_VIOLATION, with deno's error as the message.
+func checkLockfile(res *RunResult, req *RunRequest) {
+	if req.Lockfile == "" || res.ErrorCode != errorCodeRuntime || res.ExitSignal != nil {
+		return
+	}
+	for _, line := range strings.Split(stripANSI(res.Stderr), "\n") {
+		for _, violation := range lockfileViolations {
+			if strings.Contains(line, violation) {
+				res.Error = strings.TrimPrefix(strings.TrimSpace(line), "error: ")
+				res.ErrorCode = errorCodeLockfile
+				return
+			}
+		}
+	}
+}
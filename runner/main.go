@@ -1,124 +1,917 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/nats-io/nats.go"
 )
 
+// shutdownGrace is how long the runner waits for killed jobs to send their
+// replies before exiting.
+const shutdownGrace = 5 * time.Second
+
+// defaultMaxTimeout is the upper bound on a single execution, regardless of
+// what the client asks for, unless RUNNER_MAX_TIMEOUT sets another.
+const defaultMaxTimeout = 5 * time.Minute
+
 type RunRequest struct {
+	// V is the protocol version the request is written against; zero means 1.
+	V           int      `json:"v,omitempty"`
 	PublicID    string   `json:"publicId"`
 	Code        string   `json:"code"`
 	Permissions []string `json:"permissions,omitempty"`
+	TimeoutMs   int64    `json:"timeoutMs,omitempty"`
+	// Mode is "run" (default); "eval", a quicker path for snippets of up to
+	// 4KB; "test", which runs Code with deno test and reports the results in
+	// RunResult.Tests; "check" or "lint", which only analyze it, returning
+	// RunResult.Diagnostics; "fmt", which returns it formatted in
+	// RunResult.Formatted; or "bench", which runs deno bench and reports
+	// RunResult.Benchmarks. FmtCheck makes fmt mode only report whether Code
+	// is formatted already, and Print makes eval mode print the value of
+	// Code as an expression, like deno eval --print.
+	Mode     string `json:"mode,omitempty"`
+	FmtCheck bool   `json:"fmtCheck,omitempty"`
+	Print    bool   `json:"print,omitempty"`
+	// Language tells deno how to parse Code: "ts", "tsx", "js" or "jsx"
+	// ("typescript" and "javascript" also work). Left out, deno guesses.
+	Language string `json:"language,omitempty"`
+	// UnstableFeatures are --unstable-* flags, such as "--unstable-kv", to
+	// run deno with. Only those the runner allows (RUNNER_UNSTABLE_FEATURES)
+	// are accepted.
+	UnstableFeatures []string `json:"unstableFeatures,omitempty"`
+	// ImportMap is an import map ({"imports": ..., "scopes": ...}) to run
+	// the code with. Its targets must be relative to the working directory,
+	// node: builtins, or on hosts in the runner's import allowlist
+	// (RUNNER_IMPORT_ALLOWLIST). The job may then import only from hosts
+	// both the map and any --allow-import permission allow.
+	ImportMap json.RawMessage `json:"importMap,omitempty"`
+	// Lockfile is the contents of a deno.lock to run the code against.
+	// deno then refuses to load anything the lockfile doesn't pin, failing
+	// with LOCKFILE_VIOLATION.
+	Lockfile string `json:"lockfile,omitempty"`
+	// DenoConfig is a deno.json to run with, for compiler options and the
+	// like. Tasks, keys the runner denies (RUNNER_DENO_CONFIG_DENYLIST),
+	// paths outside the working directory and unstable features the runner
+	// doesn't allow are dropped; imports are checked as for ImportMap.
+	DenoConfig json.RawMessage `json:"denoConfig,omitempty"`
+	// Npm enables npm: specifiers, for tenants the runner allows it
+	// (RUNNER_NPM_TENANTS); without it deno runs with --no-npm and importing
+	// one fails with NPM_DISABLED. NodeModulesDir is "auto" (the default),
+	// installing packages in the working directory, or "none", which uses
+	// deno's shared cache.
+	Npm            bool   `json:"npm,omitempty"`
+	NodeModulesDir string `json:"nodeModulesDir,omitempty"`
+	// RemoteImports: false loads modules only from the runner's shared
+	// cache; an import that isn't there fails with REMOTE_IMPORT_BLOCKED
+	// rather than being downloaded. RUNNER_NO_REMOTE applies it to all jobs.
+	RemoteImports *bool `json:"remoteImports,omitempty"`
+	// MaxHeapMB overrides the runner's default V8 heap limit, up to its ceiling.
+	MaxHeapMB int `json:"maxHeapMb,omitempty"`
+	// Tenant identifies who the job is billed to for concurrency quotas. When
+	// empty it is derived from the PublicID prefix.
+	Tenant string `json:"tenant,omitempty"`
+	// Metadata is opaque correlation data, such as a submission or user id,
+	// echoed unchanged in the RunResult and the job's events. Keys are up
+	// to 64 letters, digits, '.', '_' or '-'; 32 keys and 4KB at most.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Serialize makes the job wait for any other serialized job with the same
+	// PublicID to finish first.
+	Serialize bool `json:"serialize,omitempty"`
+	// Stdin, when set, is fed to the script's standard input; the code is then
+	// run from a file in the working directory instead of being piped in.
+	// StdinEncoding is "utf8" (default) or "base64".
+	Stdin         *string `json:"stdin,omitempty"`
+	StdinEncoding string  `json:"stdinEncoding,omitempty"`
+	// InteractiveStdin keeps stdin open and feeds it from messages on
+	// runner.stdin.<publicId> (a Runner-Stdin-Close header sends EOF). Output
+	// is streamed as with Stream.
+	InteractiveStdin bool `json:"interactiveStdin,omitempty"`
+	// Args are passed to the script verbatim as Deno.args.
+	Args []string `json:"args,omitempty"`
+	// Env is added to the script's otherwise clean environment; the runner
+	// grants --allow-env for exactly these keys.
+	Env map[string]string `json:"env,omitempty"`
+	// Stream publishes output on runner.output.<publicId> while the job runs;
+	// the final RunResult is still sent as the reply.
+	Stream bool `json:"stream,omitempty"`
+	// Timestamps adds RunResult.Lines: the output split into lines, each
+	// stamped with the time it was written.
+	Timestamps bool `json:"timestamps,omitempty"`
+	// BinaryOutput additionally returns stdout and stderr base64-encoded, for
+	// scripts whose output is not text.
+	BinaryOutput bool `json:"binaryOutput,omitempty"`
+	// StripANSI removes terminal escape sequences from captured and streamed
+	// output. It overrides RUNNER_STRIP_ANSI when set.
+	StripANSI *bool `json:"stripAnsi,omitempty"`
+	// ReplySubject makes the job asynchronous: the request is answered with
+	// a SubmitAck as soon as the job is queued, and the RunResult is
+	// published to ReplySubject when it completes. It must lie under one of
+	// the runner's allowed prefixes (RUNNER_REPLY_SUBJECT_PREFIXES).
+	//
+	// RunAt defers the job until the given time. The request is answered at
+	// once with a ScheduleAck and the RunResult is published to ReplySubject,
+	// which is required with RunAt. Scheduled jobs can be canceled like
+	// running ones; they are held in memory and lost if the runner restarts.
+	RunAt        *time.Time `json:"runAt,omitempty"`
+	ReplySubject string     `json:"replySubject,omitempty"`
+	// WebhookURL additionally has the RunResult POSTed there, signed, once
+	// the job completes; the outcome is published on
+	// runner.webhook.<publicId>. Its scheme and host must be in the
+	// runner's webhook allowlist (RUNNER_WEBHOOK_ALLOWLIST).
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	// Priority is "high", "normal" (default) or "low". Queued jobs are served
+	// highest first, with waiting jobs aged upwards so none starve.
+	Priority string `json:"priority,omitempty"`
+	// Deadline is when the job stops being worth running: an RFC3339 time or
+	// a number of milliseconds from receipt. A job still queued then is
+	// answered with EXPIRED instead of being run.
+	Deadline *requestDeadline `json:"deadline,omitempty"`
+	// Cacheable lets the runner answer from, and save to, its result cache.
+	// NoCache skips the lookup for one request but still refreshes the entry.
+	Cacheable bool `json:"cacheable,omitempty"`
+	NoCache   bool `json:"noCache,omitempty"`
+	// Coalesce lets an identical request that is already queued or running
+	// answer this one too.
+	Coalesce bool `json:"coalesce,omitempty"`
+	// IdempotencyKey makes retries of the request safe: the first request
+	// with a key runs, and later ones from the same tenant get its result,
+	// with Replayed set, for as long as the runner remembers it
+	// (RUNNER_IDEMPOTENCY_TTL). One that arrives while the first is still
+	// queued or running waits for it.
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// Files are written into the job's working directory before deno starts,
+	// keyed by relative path with base64-encoded contents. Scripts can read
+	// them with --allow-read=.
+	Files map[string]string `json:"files,omitempty"`
+	// Source fetches Code and Files from the object store instead, for
+	// requests too big to send whole; they are then left out.
+	Source *SourceRef `json:"source,omitempty"`
+	// sourced is set once Code and Files have been fetched from Source.
+	sourced bool
+	// ChunkedReply has the result sent as a chunked reply, in as many
+	// messages as it takes; see chunked.go.
+	ChunkedReply bool `json:"chunkedReply,omitempty"`
+	// Entrypoint names the file in Files to run instead of Code, which is
+	// then left out.
+	Entrypoint string `json:"entrypoint,omitempty"`
+	// Steps make the request a pipeline: each step's code runs in turn, in
+	// one working directory, with the step's own permissions and timeout and
+	// the request's other settings. Code, permissions and timeoutMs are then
+	// left out of the request itself. The pipeline stops at the first step
+	// that fails unless ContinueOnError is set; a canceled step always ends
+	// it. Only the last step may return a value.
+	Steps           []Step `json:"steps,omitempty"`
+	ContinueOnError bool   `json:"continueOnError,omitempty"`
+	// CollectArtifacts lists glob patterns, relative to the working directory,
+	// whose matches are returned in RunResult.Artifacts after the job exits.
+	CollectArtifacts []string `json:"collectArtifacts,omitempty"`
+	// ReturnValue lets the script return a JSON value in RunResult.Value by
+	// writing it to fd 3 or to $RUNNER_RESULT_FILE, instead of stdout.
+	ReturnValue bool `json:"returnValue,omitempty"`
 }
 
 type RunResult struct {
-	Output   string `json:"output"`
-	ExitCode int    `json:"exitCode"`
-	Error    string `json:"error,omitempty"`
+	// V is the protocol version of the result.
+	V int `json:"v"`
+	// RunnerID and RunnerVersion identify the runner instance and build
+	// that produced the result; they are also sent as reply headers.
+	RunnerID      string `json:"runnerId,omitempty"`
+	RunnerVersion string `json:"runnerVersion,omitempty"`
+	// PublicID and Metadata are the request's, unchanged, so results
+	// published to a reply subject can be matched to their requests.
+	PublicID string            `json:"publicId,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Output is stdout and stderr combined in the order they were written.
+	Output string `json:"output"`
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// OutputBytes is the total number of bytes the process wrote; when it
+	// exceeds the runner's output cap, Truncated is set and the middle of
+	// each stream is dropped.
+	OutputBytes int64 `json:"outputBytes"`
+	Truncated   bool  `json:"truncated,omitempty"`
+	// ContentEncoding is "gzip" when Output, Stdout and Stderr hold
+	// base64-encoded gzip rather than text; see compress.go.
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	// BinaryOutput holds the exact bytes of stdout and stderr for requests
+	// with binaryOutput set; the text fields above replace invalid UTF-8.
+	BinaryOutput *BinaryOutput `json:"binaryOutput,omitempty"`
+	// Lines is set for requests with timestamps. Partial lines at exit are
+	// kept and long lines are split; LinesDropped counts lines past the cap.
+	Lines        []OutputLine `json:"lines,omitempty"`
+	LinesDropped int          `json:"linesDropped,omitempty"`
+	// ExitCode is the process exit status. When the process was killed by a
+	// signal it is 128+signal (shell convention) and ExitSignal is set; -1 means
+	// deno could not be started at all.
+	ExitCode   int         `json:"exitCode"`
+	ExitSignal *ExitSignal `json:"exitSignal,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	// ErrorCode is a machine-readable classification of Error.
+	ErrorCode string `json:"errorCode,omitempty"`
+	// FieldErrors lists every invalid request field when ErrorCode is
+	// VALIDATION_FAILED because of the request's contents.
+	FieldErrors []FieldError `json:"fieldErrors,omitempty"`
+	// Attempts is how many times the job was run; more than one means earlier
+	// attempts failed for infrastructure reasons and were retried.
+	Attempts int `json:"attempts,omitempty"`
+	// Warm is set when the job ran on a pre-started deno process.
+	Warm bool `json:"warm,omitempty"`
+	// Cached is set when the result was served from the cache rather than run.
+	Cached bool `json:"cached,omitempty"`
+	// Coalesced is set when the result came from an identical request's run.
+	Coalesced bool `json:"coalesced,omitempty"`
+	// Replayed is set when the result is that of an earlier request with the
+	// same idempotency key rather than a new run.
+	Replayed bool `json:"replayed,omitempty"`
+	// Termination is set when the runner stopped the job (timeout, cancel,
+	// shutdown): "graceful" if it exited after SIGTERM, "forced" if it had to
+	// be SIGKILLed after the grace period.
+	Termination string `json:"termination,omitempty"`
+	// Limits echoes the constraints the job ran under and which it hit; it is
+	// unset for jobs that never started.
+	Limits *Limits `json:"limits,omitempty"`
+	// StartedAt and FinishedAt bound the process's run on the runner's
+	// clock. For jobs that never started, both are when the runner answered.
+	// DurationMs is how long the deno process ran, from spawn until it
+	// exited, and QueuedMs how long the job waited between being accepted
+	// and reaching a worker. The CPU times come from the process's rusage.
+	// All are 0 if deno never started.
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+	DurationMs  int64     `json:"durationMs"`
+	QueuedMs    int64     `json:"queuedMs"`
+	UserCPUMs   int64     `json:"userCpuMs"`
+	SystemCPUMs int64     `json:"systemCpuMs"`
+	// Heartbeats is how many progress events were published on
+	// runner.progress.<publicId> while the job ran.
+	Heartbeats int `json:"heartbeats,omitempty"`
+	// PeakRssBytes is the job's peak memory use: the cgroup's memory.peak when
+	// it ran in one, otherwise the deno process's maximum RSS.
+	PeakRssBytes int64 `json:"peakRssBytes,omitempty"`
+	// DiskUsageBytes is the size of the job's working directory when it
+	// finished, reported alongside the quota when one applies.
+	DiskUsageBytes int64 `json:"diskUsageBytes,omitempty"`
+	DiskQuotaBytes int64 `json:"diskQuotaBytes,omitempty"`
+	// Tests is the outcome of a test mode run. When tests fail ErrorCode is
+	// TESTS_FAILED.
+	Tests *TestReport `json:"tests,omitempty"`
+	// Diagnostics are the problems found by check and lint mode; ErrorCode
+	// is then TYPECHECK_FAILED or LINT_FAILED if there were any.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Formatted is the code as formatted by fmt mode. With fmtCheck,
+	// IsFormatted says whether it was formatted already; if not, ErrorCode
+	// is NOT_FORMATTED. Code that can't be parsed is FORMAT_FAILED, with the
+	// parse error in Diagnostics.
+	Formatted   string `json:"formatted,omitempty"`
+	IsFormatted *bool  `json:"isFormatted,omitempty"`
+	// Benchmarks are the measurements from a bench mode run.
+	Benchmarks []Benchmark `json:"benchmarks,omitempty"`
+	// Steps are the results of a pipeline's steps, up to the one it stopped
+	// at. The pipeline's own Error and ErrorCode are those of the first
+	// step that failed, and its output is only in the steps.
+	Steps []RunResult `json:"steps,omitempty"`
+	// Artifacts are the files matched by RunRequest.CollectArtifacts.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Value is the JSON the script returned, verbatim, for requests with
+	// returnValue. ValueError explains a value that couldn't be returned.
+	Value      json.RawMessage `json:"value,omitempty"`
+	ValueError string          `json:"valueError,omitempty"`
+	// FullResultBytes is set on a result that was too big to store whole, or
+	// to send whole in a reply: it is the full result's size, which was cut
+	// down to the end of its output and the fields describing the run.
+	FullResultBytes int64 `json:"fullResultBytes,omitempty"`
+	// Offloaded is set on a result too big to send in a reply: it points at
+	// the full result in the object store, and the reply is cut down like
+	// those too big to store, with FullResultBytes set.
+	Offloaded *OffloadedResult `json:"offloaded,omitempty"`
+
+	// retryable marks failures that were not the script's fault.
+	retryable bool
+}
+
+// Error codes reported in RunResult.ErrorCode. SPAWN_FAILED, RUNTIME_CRASH,
+// RUNNER_SHUTDOWN, BUSY and INTERNAL_ERROR point at the runner; the others at
+// the request or the script itself.
+const (
+	errorCodePermissionDenied   = "PERMISSION_DENIED"
+	errorCodeValidation         = "VALIDATION_FAILED"
+	errorCodeBadRequest         = "BAD_REQUEST"
+	errorCodeTimeout            = "TIMEOUT"
+	errorCodeIdleTimeout        = "IDLE_TIMEOUT"
+	errorCodeCPULimit           = "CPU_LIMIT_EXCEEDED"
+	errorCodeOOM                = "OOM"
+	errorCodeProcessLimit       = "PROCESS_LIMIT_EXCEEDED"
+	errorCodeDiskQuota          = "DISK_QUOTA_EXCEEDED"
+	errorCodeOutputFlood        = "OUTPUT_FLOOD"
+	errorCodeRuntime            = "RUNTIME_ERROR"
+	errorCodeTestsFailed        = "TESTS_FAILED"
+	errorCodeTypeCheck          = "TYPECHECK_FAILED"
+	errorCodeLint               = "LINT_FAILED"
+	errorCodeFormat             = "FORMAT_FAILED"
+	errorCodeNotFormatted       = "NOT_FORMATTED"
+	errorCodeLockfile           = "LOCKFILE_VIOLATION"
+	errorCodeNpmDisabled        = "NPM_DISABLED"
+	errorCodeRemoteBlocked      = "REMOTE_IMPORT_BLOCKED"
+	errorCodeCrashed            = "RUNTIME_CRASH"
+	errorCodeSpawnFailed        = "SPAWN_FAILED"
+	errorCodeCanceled           = "CANCELED"
+	errorCodeShutdown           = "RUNNER_SHUTDOWN"
+	errorCodeBusy               = "BUSY"
+	errorCodeExpired            = "EXPIRED"
+	errorCodeInternal           = "INTERNAL_ERROR"
+	errorCodeUnsupportedVersion = "UNSUPPORTED_VERSION"
+	errorCodeNotFound           = "NOT_FOUND" // no stored result for a runner.result query
+)
+
+// BinaryOutput carries raw process output in a JSON-safe encoding.
+type BinaryOutput struct {
+	Encoding string `json:"encoding"` // always "base64"
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+// ExitSignal describes the signal that terminated the process.
+type ExitSignal struct {
+	Name   string `json:"name"`
+	Number int    `json:"number"`
+}
+
+// Runner owns the NATS connection and the set of in-flight jobs.
+type Runner struct {
+	cfg     *Config
+	nc      *nats.Conn
+	jobs    *jobRegistry
+	cgroups *cgroupManager // nil when no cgroup limits are in effect
+	rlimits rlimits
+	sched   schedPriority
+	// netIsolation is set when jobs without network permissions can be
+	// started in their own network namespace.
+	netIsolation bool
+	reaper       *childReaper // nil unless the runner is PID 1
+	pool         *workerPool
+	tenants      *keyedGate
+	serial       *keyedGate // one job at a time per PublicID
+	cache        *resultCache
+	idempotency  *idempotencyStore
+	flights      *flightGroup
+	scheduler    *scheduler
+	warm         *warmPool    // nil unless RUNNER_WARM_POOL_SIZE is set
+	active       atomic.Int64 // jobs accepted by dispatch and not yet answered
+	// resultsPublished counts requests without a reply subject whose
+	// results went to the results subject.
+	resultsPublished atomic.Int64
+	webhooks         *webhookClient
+	draining         atomic.Bool  // set once shutdown has begun
+	results          *resultStore // nil unless RUNNER_RESULT_KV_BUCKET is set
+	offload          *offloader
+	conn             connTracker
+	subsMu           sync.Mutex
+	subs             []*subscription // long-lived subscriptions, checked after reconnects
 }
 
 func main() {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	runnerID = cfg.RunnerID
+	log.Printf("Runner %s (version %s)", runnerID, runnerVersion)
+	log.Printf("Default execution timeout: %v (bench mode %v, max %v)", cfg.DefaultTimeout, cfg.BenchTimeout, cfg.MaxTimeout)
+	log.Printf("Kill grace period: %v", cfg.KillGrace)
+	log.Printf("Request size limit: %d bytes (code %d bytes)", cfg.MaxRequestBytes, cfg.MaxCodeBytes)
+	log.Printf("Output cap: %d bytes per stream (%d tail)", cfg.MaxOutputBytes, cfg.OutputTailBytes)
+	if cfg.CompressThreshold > 0 {
+		log.Printf("Compressing results over %d bytes", cfg.CompressThreshold)
+	}
+	if cfg.OutputRateLimit > 0 {
+		log.Printf("Output rate limit: %d bytes/s over %v", cfg.OutputRateLimit, cfg.OutputRateWindow)
+	}
+	log.Printf("V8 heap limit: %d MB (ceiling %d MB)", cfg.V8HeapMB, cfg.V8HeapCeilingMB)
+	if len(cfg.UnstableFeatures) > 0 {
+		log.Printf("Unstable features allowed: %s", strings.Join(cfg.allowedUnstableFeatures(), " "))
+	}
+	if len(cfg.TenantPermissions) > 0 {
+		log.Printf("Permission ceilings for %d tenant entries", len(cfg.TenantPermissions))
+	}
+	if len(cfg.NpmTenants) > 0 {
+		log.Printf("npm: specifiers allowed for %d tenant entries", len(cfg.NpmTenants))
+	}
+	if len(cfg.ImportAllowlist) > 0 {
+		log.Printf("Import map allowlist: %s", strings.Join(cfg.ImportAllowlist, ","))
+	}
+
+	var cgroups *cgroupManager
+	limits := rlimits{CPUSeconds: uint64(cfg.CPULimit / time.Second)}
+	if cfg.CPULimit > 0 {
+		log.Printf("CPU time limit: %v per job", cfg.CPULimit)
+	}
+	if cfg.JobNice > 0 || cfg.JobIOClass != "" {
+		log.Printf("Job scheduling: nice %d, I/O class %q (priority %d)", cfg.JobNice, cfg.JobIOClass, cfg.JobIOPriority)
+	}
+	netIsolation := true
+	if err := checkNetIsolation(); err != nil {
+		if cfg.RequireNetIsolation {
+			log.Fatalf("Network isolation is required but unavailable: %v", err)
+		}
+		netIsolation = false
+		log.Printf("[WARN] Network isolation unavailable (%v): jobs without --allow-net rely on Deno's permission checks alone", err)
+	} else {
+		log.Printf("Jobs without network permissions run in their own network namespace")
+	}
+	log.Printf("Job working directories under: %s", cfg.WorkDir)
+	log.Printf("Heartbeat interval: %v", cfg.HeartbeatInterval)
+	if cfg.DiskQuota > 0 {
+		log.Printf("Disk quota: %d bytes per job", cfg.DiskQuota)
+	}
+	if cred := cfg.ExecCredential; cred != nil {
+		log.Printf("Jobs run as uid=%d gid=%d", cred.Uid, cred.Gid)
+	}
+	if cgLimits := cfg.cgroupLimits(); cgLimits != (cgroupLimits{}) {
+		if cgroups, err = setupCgroups(cfg.CgroupRoot, cgLimits); err != nil {
+			log.Printf("[WARN] cgroup limits unavailable, continuing without them: %v", err)
+			if cfg.PidsLimit > 0 {
+				log.Printf("Falling back to RLIMIT_NPROC=%d for the process limit", cfg.PidsLimit)
+				limits.NProc = uint64(cfg.PidsLimit)
+			}
+		} else {
+			log.Printf("cgroup limits per job: memory.max=%d pids.max=%d (under %s)", cgLimits.MemoryMax, cgLimits.PidsMax, cgroups.base)
+		}
+	}
+
 	// 1. Connect with RetryOnFailedConnect to handle startup race conditions
 	// Standard reconnect jitter applies (default 100ms / 1000ms for TLS)
-	natsURL := os.Getenv("NATS_URL")
-	if natsURL == "" {
-		natsURL = "127.0.0.1:4222"
-	}
-	log.Printf("Connecting to NATS at %s", natsURL)
+	log.Printf("Connecting to NATS at %s as %q", redactURLs(cfg.NatsURLs), cfg.NatsName)
 
-	nc, err := nats.Connect(natsURL, nats.RetryOnFailedConnect(true))
+	nc, err := nats.Connect(strings.Join(cfg.NatsURLs, ","), cfg.natsOptions()...)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer nc.Close()
 
-	log.Println("Runner ready. Listening on 'runner.execute'...")
+	r := &Runner{
+		cfg:          cfg,
+		nc:           nc,
+		jobs:         newJobRegistry(),
+		cgroups:      cgroups,
+		rlimits:      limits,
+		sched:        cfg.schedPriority(),
+		netIsolation: netIsolation,
+		reaper:       newChildReaper(),
+		cache:        newResultCache(cfg.CacheTTL, cfg.CacheMaxEntries, cfg.cacheable),
+		flights:      newFlightGroup(),
+		scheduler:    newScheduler(cfg.MaxScheduled),
+		webhooks:     newWebhookClient(cfg, nc),
+	}
+	r.watchConnection()
+	if cfg.NatsMaxReconnects < 0 {
+		log.Printf("Reconnecting to NATS without limit")
+	} else {
+		log.Printf("Reconnecting to NATS up to %d times", cfg.NatsMaxReconnects)
+	}
+	log.Printf("Result cache: up to %d entries for %v", cfg.CacheMaxEntries, cfg.CacheTTL)
+	log.Printf("Reply subjects allowed under: %s", strings.Join(cfg.ReplySubjectPrefixes, ", "))
+	log.Printf("Results of requests without a reply subject go to %s", cfg.ResultsSubject)
+	if len(cfg.WebhookAllowlist) > 0 {
+		log.Printf("Webhooks enabled for %d hosts, %d retries", len(cfg.WebhookAllowlist), cfg.WebhookRetries)
+	}
+	if r.idempotency, err = newIdempotencyStore(cfg, nc); err != nil {
+		log.Fatalf("Idempotency store: %v", err)
+	}
+	log.Printf("Idempotency keys: up to %d remembered for %v", cfg.IdempotencyMaxEntries, cfg.IdempotencyTTL)
+	if cfg.IdempotencyBucket != "" {
+		log.Printf("Idempotency keys shared through JetStream KV bucket %q", cfg.IdempotencyBucket)
+	}
+	if r.results, err = newResultStore(cfg, nc); err != nil {
+		log.Fatalf("Result store: %v", err)
+	}
+	if r.results != nil {
+		log.Printf("Results stored in JetStream KV bucket %q for %v", cfg.ResultBucket, cfg.ResultTTL)
+	}
+	if r.offload, err = newOffloader(cfg, nc); err != nil {
+		log.Fatalf("Result object store: %v", err)
+	}
+	if cfg.ResultObjectBucket != "" {
+		log.Printf("Results too big for a reply go to JetStream object store %q for %v", cfg.ResultObjectBucket, cfg.ResultObjectTTL)
+	}
+	if cfg.NoRemote {
+		log.Printf("Remote imports disabled: jobs load modules from the cache only")
+	}
+	r.precacheModules()
+	if cfg.WarmPoolSize > 0 {
+		if r.warm, err = newWarmPool(r, cfg.WarmPoolSize); err != nil {
+			log.Printf("[WARN] Warm pool unavailable, every job starts deno cold: %v", err)
+		} else {
+			log.Printf("Warm pool: %d pre-started deno processes for zero-permission jobs", cfg.WarmPoolSize)
+		}
+	}
+	r.pool = newWorkerPool(cfg.MaxConcurrent, cfg.MaxQueueDepth, cfg.PriorityAging, cfg.tenantWeight, r.runPending)
+	log.Printf("Worker pool: %d concurrent executions, up to %d queued", cfg.MaxConcurrent, cfg.MaxQueueDepth)
+	r.tenants = newKeyedGate(cfg.tenantLimit, cfg.TenantMaxQueued)
+	r.serial = newKeyedGate(func(string) int { return 1 }, cfg.SerializeMaxQueued)
+	if cfg.SerializeByID {
+		log.Printf("Serializing all jobs per PublicID, up to %d queued per ID", cfg.SerializeMaxQueued)
+	}
+	if cfg.TenantMaxConcurrent > 0 || len(cfg.TenantLimits) > 0 {
+		log.Printf("Tenant quotas: %d concurrent by default, overrides %v, up to %d queued per tenant",
+			cfg.TenantMaxConcurrent, cfg.TenantLimits, cfg.TenantMaxQueued)
+	}
+
+	cfg.logSubjects()
+	if cfg.Broadcast {
+		log.Printf("Runner ready. Listening on '%s' without a queue group: every runner gets every job", cfg.subject("execute"))
+	} else {
+		log.Printf("Runner ready. Listening on '%s' in queue group %q...", cfg.subject("execute"), cfg.QueueGroup)
+	}
 
 	// 2. Subscribe to requests
-	_, err = nc.Subscribe("runner.execute", func(m *nats.Msg) {
-		var req RunRequest
-		if err := json.Unmarshal(m.Data, &req); err != nil {
-			log.Printf("Bad data: %v", err)
-			return
+	execSub, err := r.subscribeShared(cfg.subject("execute"), r.handleExecute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Per-runtime jobs and batches; the bare subject means deno.
+	runtimeSub, err := r.subscribeShared(cfg.subjectID("execute", "*"), r.handleExecuteRuntime)
+	if err != nil {
+		log.Fatal(err)
+	}
+	intake := []*subscription{execSub, runtimeSub}
+	if cfg.TenantSubjects {
+		tenantSub, err := r.subscribeShared(cfg.subjectID("execute."+tenantToken, ">"), r.handleTenantExecute)
+		if err != nil {
+			log.Fatal(err)
 		}
+		intake = append(intake, tenantSub)
+	}
+	workCtx, stopWork := context.WithCancel(context.Background())
+	if cfg.WorkStream != "" {
+		cons, err := openWorkQueue(cfg, nc)
+		if err != nil {
+			log.Fatalf("Work queue: %v", err)
+		}
+		log.Printf("Taking jobs from JetStream stream %q (%s) as consumer %q, ack wait %v",
+			cfg.WorkStream, cfg.WorkSubject, cfg.WorkConsumer, cfg.WorkAckWait)
+		go r.consumeWork(workCtx, cons)
+	}
 
-		log.Printf("[REQ] Running code for: %s", req.PublicID)
-		startTime := time.Now()
-		log.Printf("[START] Job started at: %s", startTime.Format(time.RFC3339))
+	// Cancellation: either runner.cancel.<publicId> or runner.cancel with the ID in the body.
+	// Like the subjects below, every runner hears it, as only the one
+	// running a job can act on it.
+	if _, err := r.subscribe(cfg.subject("cancel"), "", r.handleCancel); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := r.subscribe(cfg.subjectID("cancel", ">"), "", r.handleCancel); err != nil {
+		log.Fatal(err)
+	}
 
-		// 3. Validate and sanitize permissions
-		validatedPerms, validationErr := validatePermissions(req.Permissions)
-		if validationErr != nil {
-			log.Printf("[ERROR] Permission validation failed: %v", validationErr)
-			res := RunResult{
-				Output:   "",
-				ExitCode: 1,
-				Error:    fmt.Sprintf("Permission validation failed: %v", validationErr),
-			}
-			data, _ := json.Marshal(res)
-			if err := m.Respond(data); err != nil {
-				log.Printf("Failed to respond: %v", err)
-			}
-			return
-		}
+	// Protocol versions and capabilities
+	if _, err := r.subscribe(cfg.subject("info"), "", r.handleInfo); err != nil {
+		log.Fatal(err)
+	}
+
+	// Stored results, which any runner can look up
+	if _, err := r.subscribeShared(cfg.subjectID("result", ">"), r.handleResult); err != nil {
+		log.Fatal(err)
+	}
 
-		// 4. Build Deno command with secure permissions
-		// Secure by default: if no permissions provided, script runs with zero I/O access
-		args := []string{"run"}
-		if len(validatedPerms) > 0 {
-			args = append(args, validatedPerms...)
+	// Recent output of an in-flight job
+	if _, err := r.subscribe(cfg.subjectID("tail", ">"), "", r.handleTail); err != nil {
+		log.Fatal(err)
+	}
+
+	// Runtime control of the backlog limit
+	if _, err := r.subscribe(cfg.subject("admin.queue"), "", r.handleQueueAdmin); err != nil {
+		log.Fatal(err)
+	}
+
+	// Keep the process alive until asked to stop, then drain
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	var sig os.Signal
+	for sig = range sigCh {
+		if sig != syscall.SIGHUP {
+			break
 		}
-		args = append(args, "--no-prompt", "-") // Ensure it never hangs for input
+		r.reloadTLS()
+	}
+	log.Printf("[SHUTDOWN] Received %v, no longer accepting jobs", sig)
+	stopWork()
+	aborted := r.shutdown(intake)
+	if !r.webhooks.wait(shutdownGrace) {
+		log.Printf("[SHUTDOWN] Exiting with webhook deliveries still in progress")
+	}
+	if !drainConn(nc, shutdownGrace) {
+		log.Printf("[SHUTDOWN] NATS connection did not drain in %v", shutdownGrace)
+	}
+	if aborted > 0 {
+		log.Printf("[SHUTDOWN] Exiting after aborting %d job(s)", aborted)
+		os.Exit(1)
+	}
+	log.Printf("[SHUTDOWN] Exiting cleanly")
+}
 
-		log.Printf("[PERMISSIONS] Using flags: %v", args)
-		cmd := exec.Command("deno", args...)
-		cmd.Stdin = bytes.NewBufferString(req.Code)
+// subscribeShared subscribes to a subject any one runner can handle, such as
+// those jobs are submitted on, in the runners' queue group unless
+// broadcasting.
+func (r *Runner) subscribeShared(subject string, handler nats.MsgHandler) (*subscription, error) {
+	if r.cfg.Broadcast {
+		return r.subscribe(subject, "", handler)
+	}
+	return r.subscribe(subject, r.cfg.QueueGroup, handler)
+}
 
-		var out bytes.Buffer
-		cmd.Stdout = &out
-		cmd.Stderr = &out
+func (r *Runner) handleExecute(m *nats.Msg) {
+	r.submit(m, nil)
+}
 
-		runErr := cmd.Run()
+// submit accepts a job requested in m, which work is set for when it comes
+// from the work queue.
+func (r *Runner) submit(m *nats.Msg, work *workItem) {
+	if err := r.cfg.checkRequestSize(len(m.Data)); err != nil {
+		log.Printf("[ERROR] Rejecting request: %v", err)
+		if m.Reply == "" {
+			m = r.publishToResults(m, "")
+		}
+		res := RunResult{ExitCode: 1, Error: err.Error(), ErrorCode: errorCodeValidation}
+		r.respond(m, res)
+		work.settle(res)
+		return
+	}
+	var req RunRequest
+	data, err := requestJSON(m, "RunRequest")
+	if err == nil {
+		err = decodeRequest(data, &req, r.cfg.strictDecoding(m.Header))
+	}
+	if err != nil {
+		log.Printf("Bad data: %v: %s", err, logPayload(m.Data))
+		if m.Reply != "" {
+			r.respond(m, badRequest(err))
+		}
+		work.deadLetter(fmt.Sprintf("undecodable request: %v", err), nil)
+		return
+	}
+	if m.Reply == "" && req.ReplySubject == "" {
+		m = r.publishToResults(m, req.PublicID)
+	}
+	if _, err := protocolVersion(req.V); err != nil {
+		log.Printf("[ERROR] Rejecting %s: %v", req.PublicID, err)
+		res := unsupportedVersion(err)
+		res.PublicID = req.PublicID
+		r.respond(m, res)
+		work.settle(res)
+		return
+	}
+	if err := r.applySubjectTenant(m, &req); err != nil {
+		log.Printf("[ERROR] Rejecting %s: %v", req.PublicID, err)
+		res := RunResult{PublicID: req.PublicID, ExitCode: 1, Error: err.Error(), ErrorCode: errorCodePermissionDenied}
+		r.respond(m, res)
+		work.settle(res)
+		return
+	}
+	if errs := r.cfg.validateRequest(&req); len(errs) > 0 {
+		log.Printf("[ERROR] Rejecting %s: %v", req.PublicID, errs)
+		res := errs.result()
+		res.PublicID = req.PublicID
+		r.respond(m, res)
+		work.settle(res)
+		return
+	}
 
-		endTime := time.Now()
-		duration := endTime.Sub(startTime)
-		log.Printf("[END] Job finished at: %s (duration: %v)", endTime.Format(time.RFC3339), duration)
+	priority, _ := parsePriority(req.Priority) // checked by validateRequest
+	job := &pendingJob{
+		msg:        m,
+		req:        req,
+		tenant:     r.cfg.tenantOf(&req),
+		serialized: req.Serialize || r.cfg.SerializeByID,
+		priority:   priority,
+		deadline:   r.cfg.jobDeadline(&req, m.Header, time.Now()),
+		runner:     r,
+	}
+	if req.WebhookURL != "" {
+		job.webhooks = r.webhooks
+	}
+	job.results = r.results
+	if req.RunAt != nil {
+		// Scheduled jobs wait in memory, so a work-queue job with a runAt
+		// is done with the queue once it is scheduled.
+		r.schedule(job)
+		work.settle(RunResult{})
+		return
+	}
+	job.work = work
+	if req.ReplySubject != "" {
+		deferReply(job)
+	}
+	r.accept(job)
+}
 
-		exitCode := 0
-		if runErr != nil {
-			exitCode = 1
+// accept takes a job that is due to run now through expiry, the result cache
+// and coalescing, then hands it to dispatch.
+func (r *Runner) accept(job *pendingJob) {
+	req := &job.req
+	if r.draining.Load() {
+		log.Printf("[SHUTDOWN] Turning away %s", req.PublicID)
+		job.send(shutdownResult())
+		return
+	}
+	if _, ok := r.deadlineLeft(job); !ok {
+		log.Printf("[EXPIRED] %s arrived after its deadline:%s", req.PublicID, r.deadlineFields(job))
+		job.send(expiredResult(job))
+		return
+	}
+	if req.IdempotencyKey != "" {
+		r.acceptIdempotent(job)
+		return
+	}
+	// Streaming and interactive jobs have subscribers waiting for live events,
+	// so they always run.
+	live := req.Stream || req.InteractiveStdin
+	if req.Cacheable && !live {
+		job.cacheKey = cacheKey(req)
+		if !req.NoCache {
+			if res, ok := r.cache.lookup(job.cacheKey); ok {
+				log.Printf("[CACHE] Serving cached result for: %s", req.PublicID)
+				res.Cached = true
+				job.send(res)
+				return
+			}
 		}
+	}
+	// Batch entries report to their batch rather than a requester, so
+	// they are not coalesced.
+	if req.Coalesce && !live && job.done == nil {
+		key := cacheKey(req)
+		if r.flights.join(key, job) {
+			log.Printf("[COALESCE] %s attached to an identical in-flight job", req.PublicID)
+			job.acknowledge(submitWaiting)
+			return
+		}
+		job.flightKey = key
+	}
+	r.dispatch(job)
+}
 
-		// 5. Pack the result
-		res := RunResult{
-			Output:   out.String(),
-			ExitCode: exitCode,
+// runPending executes a dequeued job on worker slot and replies to its requester.
+func (r *Runner) runPending(slot int, job *pendingJob) {
+	defer r.finish(job)
+	if !r.jobs.take(job) {
+		log.Printf("[CANCEL] %s was canceled while it was queued", job.req.PublicID)
+		r.reply(job, canceledResult())
+		return
+	}
+	queuedMs := time.Since(job.received).Milliseconds()
+	left, ok := r.deadlineLeft(job)
+	if !ok {
+		log.Printf("[EXPIRED] Skipping %s: deadline passed while it was queued:%s", job.req.PublicID, r.deadlineFields(job))
+		res := expiredResult(job)
+		res.QueuedMs = queuedMs
+		r.reply(job, res)
+		return
+	}
+	if !job.deadline.IsZero() {
+		log.Printf("[DEADLINE] Starting %s with %v left:%s", job.req.PublicID, left.Round(time.Millisecond), r.deadlineFields(job))
+	}
+	var res RunResult
+	for attempt := 1; ; attempt++ {
+		res = r.execute(&job.req, slot, job.deadline)
+		res.Attempts = attempt
+		res.QueuedMs = queuedMs
+		if res.Limits != nil {
+			res.Limits.QueuePosition = job.queuePosition
+		}
+		if !res.retryable || attempt > r.cfg.MaxRetries {
+			break
 		}
-		if runErr != nil {
-			res.Error = runErr.Error()
+		backoff := r.cfg.RetryBackoff << (attempt - 1)
+		if left, _ := r.deadlineLeft(job); !job.deadline.IsZero() && left < backoff {
+			log.Printf("[DEADLINE] Not retrying %s: its deadline is too close:%s", job.req.PublicID, r.deadlineFields(job))
+			break
 		}
+		log.Printf("[RETRY] %s failed for infrastructure reasons (%s), retrying in %v", job.req.PublicID, res.Error, backoff)
+		time.Sleep(backoff)
+	}
+
+	// 6. Reply instantly
+	r.reply(job, res)
+	log.Printf("[DONE] Sent reply for: %s (worker %d)%s", job.req.PublicID, slot, r.cfg.metadataLogFields(job.req.Metadata))
+}
+
+// reply sends a job's final result to its requester and to any identical
+// requests coalesced onto it, and records it in the cache if applicable.
+func (r *Runner) reply(job *pendingJob, res RunResult) {
+	defer r.active.Add(-1)
+	r.jobs.take(job) // if it never reached a worker
+	if job.cacheKey != "" {
+		r.cache.store(job.cacheKey, res)
+	}
+	if job.idempotencyKey != "" {
+		r.idempotency.store(job.idempotencyKey, res)
+	}
+	job.send(res)
+	if job.flightKey == "" {
+		return
+	}
+	followers := r.flights.done(job.flightKey)
+	if len(followers) == 0 {
+		return
+	}
+	if job.idempotencyKey != "" {
+		log.Printf("[IDEMPOTENCY] Replaying result of %s to %d retries that arrived while it ran", job.req.PublicID, len(followers))
+		res.Replayed = true
+	} else {
+		log.Printf("[COALESCE] Sharing result of %s with %d identical requests", job.req.PublicID, len(followers))
+		res.Coalesced = true
+	}
+	for _, f := range followers {
+		f.send(res)
+	}
+}
+
+// respond marshals v and sends it as the reply to m.
+func (r *Runner) respond(m *nats.Msg, v any) {
+	r.sendReply(m, r.buildReply(m, v, true))
+}
 
-		// 6. Reply instantly
-		data, _ := json.Marshal(res)
-		if err := m.Respond(data); err != nil {
-			log.Printf("Failed to respond: %v", err)
+// buildReply encodes v as the reply to m. A RunResult too big for a NATS
+// message is offloaded if offload is set.
+func (r *Runner) buildReply(m *nats.Msg, v any, offload bool) *nats.Msg {
+	compressed := false
+	var plain *RunResult // a RunResult as it was before compression
+	switch res := v.(type) {
+	case RunResult:
+		res.stamp()
+		orig := res
+		plain = &orig
+		compressed = res.compress(r.cfg.CompressThreshold)
+		v = res
+	case BatchResult:
+		res.V = maxProtocolVersion
+		res.RunnerID, res.RunnerVersion = runnerID, runnerVersion
+		for i := range res.Results {
+			res.Results[i].stamp()
+			if res.Results[i].compress(r.cfg.CompressThreshold) {
+				compressed = true
+			}
 		}
-		log.Printf("[DONE] Sent reply for: %s", req.PublicID)
-	})
+		v = res
+	}
+	data, contentType, err := encodeReply(m, v)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Failed to encode response: %v", err)
+		fallback := RunResult{ExitCode: -1, Error: "failed to encode result", ErrorCode: errorCodeInternal}
+		fallback.stamp()
+		data, contentType, _ = encodeReply(m, fallback)
+	}
+	if limit := r.offload.limit(); offload && plain != nil && limit > 0 && len(data) > limit {
+		if data2, contentType2, err := r.offload.reply(m, *plain, data, contentType); err == nil {
+			data, contentType, compressed = data2, contentType2, false
+		}
 	}
+	reply := &nats.Msg{Subject: m.Reply, Data: data, Header: nats.Header{}}
+	if contentType != "" {
+		reply.Header.Set(contentTypeHeader, contentType)
+	}
+	reply.Header.Set(runnerIDHeader, runnerID)
+	reply.Header.Set(runnerVersionHeader, runnerVersion)
+	if compressed {
+		reply.Header.Set(contentEncodingHeader, contentEncodingGzip)
+	}
+	return reply
+}
 
-	// Keep the process alive
-	select {}
+// sendReply sends reply to the requester of m. Jobs from the work queue
+// don't arrive on a subscription that could answer them, so their replies
+// are published on the runner's connection.
+func (r *Runner) sendReply(m, reply *nats.Msg) {
+	var err error
+	if m.Sub == nil {
+		err = r.nc.PublishMsg(reply)
+	} else {
+		err = m.RespondMsg(reply)
+	}
+	if err != nil {
+		log.Printf("Failed to respond: %v", err)
+	}
+}
+
+// needsNetwork reports whether validated permissions let the job use the
+// network, either directly or to fetch remote imports.
+func needsNetwork(perms []string) bool {
+	for _, perm := range perms {
+		name, _, _ := strings.Cut(perm, "=")
+		if name == "--allow-net" || name == "--allow-import" {
+			return true
+		}
+	}
+	return false
 }
 
 // validatePermissions validates and sanitizes Deno permission flags.
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Caps on RunRequest.Metadata.
+const (
+	maxMetadataKeys  = 32
+	maxMetadataBytes = 4 << 10 // keys and values together
+)
+
+var metadataKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// validateMetadata checks keys and size. Values are opaque to the runner.
+func validateMetadata(md map[string]string) error {
+	if len(md) > maxMetadataKeys {
+		return fmt.Errorf("has %d keys (max %d)", len(md), maxMetadataKeys)
+	}
+	size := 0
+	for _, k := range sortedKeys(md) {
+		if !metadataKey.MatchString(k) {
+			return fmt.Errorf("invalid key %q: must be up to 64 letters, digits, '.', '_' or '-'", k)
+		}
+		size += len(k) + len(md[k])
+	}
+	if size > maxMetadataBytes {
+		return fmt.Errorf("is %d bytes (max %d)", size, maxMetadataBytes)
+	}
+	return nil
+}
+
+// metadataLogFields renders the metadata keys on the log allowlist as
+// key=value pairs for a log line, with a leading space, or "" if none are
+// set.
+func (c *Config) metadataLogFields(md map[string]string) string {
+	var b strings.Builder
+	for _, k := range c.LogMetadataKeys {
+		if v, ok := md[k]; ok {
+			fmt.Fprintf(&b, " %s=%q", k, v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+)
+
+// Request modes pick the deno subcommand a job runs. Every mode goes through
+// the same permission validation, limits and output capture as run.
+const (
+	modeRun   = "run"
+	modeEval  = "eval"
+	modeTest  = "test"
+	modeCheck = "check"
+	modeLint  = "lint"
+	modeFmt   = "fmt"
+	modeBench = "bench"
+)
+
+// jobModes lists the valid modes. file is set for subcommands that can't
+// read the code from stdin, so it is written to the working directory;
+// static for those that only analyze the code and never run it, which take
+// no permissions.
+var jobModes = map[string]struct {
+	file   bool
+	static bool
+}{
+	modeRun:   {},
+	modeEval:  {},
+	modeTest:  {file: true},
+	modeCheck: {file: true, static: true},
+	modeLint:  {file: true, static: true},
+	modeFmt:   {static: true},
+	modeBench: {file: true},
+}
+
+// jobMode returns req's mode, run by default.
+func jobMode(req *RunRequest) string {
+	if req.Mode == "" {
+		return modeRun
+	}
+	return req.Mode
+}
+
+// denoArgs builds the deno command line for req, running script with the
+// validated permissions.
+func (c *Config) denoArgs(req *RunRequest, perms []string, heapMB int, script, workdir string) []string {
+	mode := jobMode(req)
+	switch mode {
+	case modeCheck:
+		args := []string{mode, fmt.Sprintf("--v8-flags=--max-old-space-size=%d", heapMB)}
+		args = append(args, c.moduleFlags(req, workdir)...)
+		return append(args, script)
+	case modeLint:
+		args := append([]string{mode, "--json"}, denoConfigFlag(req, workdir)...)
+		return append(args, script)
+	case modeFmt:
+		args := append([]string{mode}, denoConfigFlag(req, workdir)...)
+		if req.FmtCheck {
+			args = append(args, "--check")
+		}
+		args = append(args, extFlag(req.Language)...)
+		return append(args, script)
+	}
+	subcommand := mode
+	if mode == modeEval {
+		subcommand = modeRun // see evalScript
+	}
+	// Secure by default: if no permissions provided, script runs with zero I/O access
+	args := append([]string{subcommand}, perms...)
+	args = append(args, fmt.Sprintf("--v8-flags=--max-old-space-size=%d", heapMB))
+	args = append(args, "--no-prompt") // Ensure it never hangs for input
+	args = append(args, c.moduleFlags(req, workdir)...)
+	if mode != modeEval { // the data: URL carries the media type
+		args = append(args, extFlag(req.Language)...)
+	}
+	switch mode {
+	case modeTest:
+		args = append(args, "--junit-path="+filepath.Join(workdir, junitFileName))
+	case modeBench:
+		args = append(args, "--json")
+	}
+	args = append(args, script)
+	if (mode == modeTest || mode == modeBench) && len(req.Args) > 0 {
+		args = append(args, "--") // deno test and bench take script arguments after --
+	}
+	// Everything after the script is Deno.args, so flags here cannot widen permissions.
+	return append(args, req.Args...)
+}
+
+// evalMediaTypes are the data: URL media types for RunRequest.Language.
+var evalMediaTypes = map[string]string{
+	"":    "application/javascript",
+	"js":  "application/javascript",
+	"ts":  "application/typescript",
+	"jsx": "text/jsx",
+	"tsx": "text/tsx",
+}
+
+// evalScript returns the module eval mode runs: the code itself, passed on
+// the command line as a data: URL, so nothing is piped through stdin or
+// written to disk. deno eval would do the same but always has every
+// permission, so eval mode uses deno run to keep the requested ones. Like
+// deno eval, the code is JavaScript unless a language says otherwise.
+func evalScript(req *RunRequest) string {
+	code := req.Code
+	if req.Print {
+		code = "console.log(" + code + "\n)" // what deno eval --print runs
+	}
+	return "data:" + evalMediaTypes[languageExts[req.Language]] + ";base64," + base64.StdEncoding.EncodeToString([]byte(code))
+}
+
+// moduleFlags returns the flags for how req's code and its imports are
+// loaded: configuration, npm, remote imports, unstable features, import map
+// and lockfile.
+func (c *Config) moduleFlags(req *RunRequest, workdir string) []string {
+	flags := denoConfigFlag(req, workdir)
+	flags = append(flags, npmFlags(req)...)
+	flags = append(flags, c.remoteFlags(req)...)
+	flags = append(flags, unstableFlags(req.UnstableFeatures)...)
+	flags = append(flags, importMapFlag(req, workdir)...)
+	return append(flags, lockfileFlags(req, workdir)...)
+}
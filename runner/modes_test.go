@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDenoArgsExt checks the language's --ext flag comes after the
+// subcommand's other flags and before the script, where deno reads it as a
+// flag rather than as an argument to the script.
+func TestDenoArgsExt(t *testing.T) {
+	c := &Config{}
+	perms := []string{"--allow-net=example.com"}
+	const run = "run --allow-net=example.com --v8-flags=--max-old-space-size=64 --no-prompt --no-npm"
+	tests := []struct {
+		req    RunRequest
+		script string
+		want   string
+	}{
+		{RunRequest{Language: "tsx"}, "-", run + " --ext=tsx -"},
+		{RunRequest{Language: "typescript", Args: []string{"--ext=js", "-"}}, "-", run + " --ext=ts - --ext=js -"},
+		{RunRequest{}, "-", run + " -"},
+		{RunRequest{Language: "jsx", Mode: modeTest, Args: []string{"a"}}, "/w/main.jsx",
+			"test --allow-net=example.com --v8-flags=--max-old-space-size=64 --no-prompt --no-npm --ext=jsx --junit-path=/w/" + junitFileName + " /w/main.jsx -- a"},
+		{RunRequest{Language: "js", Mode: modeBench}, "/w/main.js",
+			"bench --allow-net=example.com --v8-flags=--max-old-space-size=64 --no-prompt --no-npm --ext=js --json /w/main.js"},
+		{RunRequest{Language: "tsx", Mode: modeFmt, FmtCheck: true}, "-", "fmt --check --ext=tsx -"},
+		// The data: URL's media type carries the language instead.
+		{RunRequest{Language: "tsx", Mode: modeEval}, "data:text/tsx;base64,", run + " data:text/tsx;base64,"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(c.denoArgs(&tt.req, perms, 64, tt.script, "/w"), " ")
+		if got != tt.want {
+			t.Errorf("mode %q language %q:\n got %s\nwant %s", tt.req.Mode, tt.req.Language, got, tt.want)
+		}
+	}
+}
+
+// TestLanguageArgv checks the flag reaches deno when the code comes from
+// stdin.
+func TestLanguageArgv(t *testing.T) {
+	fakeDeno(t, `echo "$@"; cat`)
+	r := testRunner(t)
+	res := r.executeIn(&RunRequest{PublicID: "tsx", Code: "const x = <b/>;", Language: "tsx", Args: []string{"arg"}}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	argv, code, _ := strings.Cut(res.Stdout, "\n")
+	if !strings.HasSuffix(argv, " --ext=tsx - arg") {
+		t.Errorf("argv %q, want it to end with --ext=tsx - arg", argv)
+	}
+	if code != "const x = <b/>;" {
+		t.Errorf("stdin %q", code)
+	}
+}
+
+// BenchmarkEvalMode and BenchmarkRunMode compare a one-liner passed on the
+// command line against the same code piped to deno run.
+func BenchmarkEvalMode(b *testing.B) {
+	benchDeno(b)
+	runBench(b, testRunner(b), RunRequest{PublicID: "eval", Code: "2 + 2", Mode: modeEval, Print: true}, nil)
+}
+
+func BenchmarkRunMode(b *testing.B) {
+	benchDeno(b)
+	runBench(b, testRunner(b), RunRequest{PublicID: "run", Code: "console.log(2 + 2)"}, nil)
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func hexBytes(parts ...any) []byte {
+	var b []byte
+	for _, p := range parts {
+		switch p := p.(type) {
+		case int:
+			b = append(b, byte(p))
+		case string:
+			b = append(b, p...)
+		case []byte:
+			b = append(b, p...)
+		}
+	}
+	return b
+}
+
+func TestMsgpackToJSON(t *testing.T) {
+	str32 := strings.Repeat("x", 32)
+	str256 := strings.Repeat("y", 256)
+	str64k := strings.Repeat("z", 65536)
+	arr16 := hexBytes(0xdc, 0x00, 0x10)
+	map16 := hexBytes(0xde, 0x00, 0x10)
+	for i := 0; i < 16; i++ {
+		arr16 = append(arr16, byte(i))
+		map16 = append(map16, 0xa1, 'a'+byte(i), byte(i))
+	}
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"nil", hexBytes(0xc0), `null`},
+		{"false", hexBytes(0xc2), `false`},
+		{"true", hexBytes(0xc3), `true`},
+		{"positive fixint", hexBytes(0x7f), `127`},
+		{"negative fixint", hexBytes(0xe0), `-32`},
+		{"uint8", hexBytes(0xcc, 0xff), `255`},
+		{"uint16", hexBytes(0xcd, 0xff, 0xff), `65535`},
+		{"uint32", hexBytes(0xce, 0xff, 0xff, 0xff, 0xff), `4294967295`},
+		{"uint64", hexBytes(0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff), `18446744073709551615`},
+		{"int8", hexBytes(0xd0, 0x80), `-128`},
+		{"int16", hexBytes(0xd1, 0x80, 0x00), `-32768`},
+		{"int32", hexBytes(0xd2, 0x80, 0x00, 0x00, 0x00), `-2147483648`},
+		{"int64", hexBytes(0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0), `-9223372036854775808`},
+		{"int8 positive", hexBytes(0xd0, 0x7f), `127`},
+		{"float32", hexBytes(0xca, 0x3f, 0xc0, 0x00, 0x00), `1.5`},
+		{"float64", hexBytes(0xcb, 0xc0, 0x04, 0, 0, 0, 0, 0, 0), `-2.5`},
+		{"fixstr", hexBytes(0xa2, "hi"), `"hi"`},
+		{"empty str", hexBytes(0xa0), `""`},
+		{"str8", hexBytes(0xd9, 32, str32), `"` + str32 + `"`},
+		{"str16", hexBytes(0xda, 0x01, 0x00, str256), `"` + str256 + `"`},
+		{"str32", hexBytes(0xdb, 0x00, 0x01, 0x00, 0x00, str64k), `"` + str64k + `"`},
+		{"bin8", hexBytes(0xc4, 3, "abc"), `"abc"`},
+		{"bin16", hexBytes(0xc5, 0x00, 0x02, "ab"), `"ab"`},
+		{"bin32", hexBytes(0xc6, 0, 0, 0, 1, "a"), `"a"`},
+		{"fixarray", hexBytes(0x93, 0x01, 0xa1, "x", 0xc0), `[1,"x",null]`},
+		{"empty array", hexBytes(0x90), `[]`},
+		{"array16", arr16, `[0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15]`},
+		{"array32", hexBytes(0xdd, 0, 0, 0, 2, 0xc3, 0xc2), `[true,false]`},
+		{"fixmap", hexBytes(0x82, 0xa1, "b", 0x02, 0xa1, "a", 0x91, 0x01), `{"a":[1],"b":2}`},
+		{"map32", hexBytes(0xdf, 0, 0, 0, 1, 0xa1, "k", 0xa1, "v"), `{"k":"v"}`},
+		{"timestamp32", hexBytes(0xd6, 0xff, 0x00, 0x00, 0x00, 0x3c), `"1970-01-01T00:01:00Z"`},
+		{"timestamp64", hexBytes(0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01), `"1970-01-01T00:00:01.000000001Z"`},
+		{"timestamp96", hexBytes(0xc7, 12, 0xff, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff), `"1969-12-31T23:59:59Z"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := msgpackToJSON(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %.80s, want %.80s", got, tt.want)
+			}
+		})
+	}
+	t.Run("map16", func(t *testing.T) {
+		got, err := msgpackToJSON(map16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]int
+		if err := json.Unmarshal(got, &m); err != nil || len(m) != 16 || m["p"] != 15 {
+			t.Errorf("got %s (%v)", got, err)
+		}
+	})
+}
+
+func TestMsgpackMalformed(t *testing.T) {
+	deep := bytes.Repeat([]byte{0x91}, msgpackMaxDepth+2)
+	deep = append(deep, 0xc0)
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"empty", nil, "unexpected end"},
+		{"trailing", hexBytes(0xc0, 0xc0), "trailing bytes"},
+		{"short uint16", hexBytes(0xcd, 0x01), "unexpected end"},
+		{"short str", hexBytes(0xa5, "ab"), "unexpected end"},
+		{"short str32 length", hexBytes(0xdb, 0xff, 0xff, 0xff, 0xff, "a"), "unexpected end"},
+		{"array longer than data", hexBytes(0xdd, 0xff, 0xff, 0xff, 0xff), "unexpected end"},
+		{"map longer than data", hexBytes(0x85, 0xa1, "a", 0x01), "unexpected end"},
+		{"integer key", hexBytes(0x81, 0x01, 0x02), "map key"},
+		{"never used", hexBytes(0xc1), "unknown type byte 0xc1"},
+		{"other extension", hexBytes(0xd4, 0x05, 0x00), "unsupported extension type 5"},
+		{"timestamp length", hexBytes(0xd5, 0xff, 0x00, 0x00), "timestamp of 2 bytes"},
+		{"short ext", hexBytes(0xc7, 12, 0xff, 0x00), "unexpected end"},
+		{"too deep", deep, "nesting too deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := msgpackToJSON(tt.in)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestMsgpackIntWidths checks integers use the smallest encoding and decode
+// back to themselves.
+func TestMsgpackIntWidths(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want byte
+	}{
+		{0, 0x00}, {127, 0x7f}, {128, 0xcc}, {255, 0xcc}, {256, 0xcd},
+		{65535, 0xcd}, {65536, 0xce}, {math.MaxUint32, 0xce}, {math.MaxUint32 + 1, 0xcf},
+		{math.MaxInt64, 0xcf}, {-1, 0xff}, {-32, 0xe0}, {-33, 0xd0}, {-128, 0xd0},
+		{-129, 0xd1}, {-32768, 0xd1}, {-32769, 0xd2}, {math.MinInt32, 0xd2},
+		{math.MinInt32 - 1, 0xd3}, {math.MinInt64, 0xd3},
+	}
+	for _, tt := range tests {
+		b := appendMsgpackInt(nil, tt.in)
+		if b[0] != tt.want {
+			t.Errorf("%d: type byte 0x%02x, want 0x%02x", tt.in, b[0], tt.want)
+		}
+		got, err := msgpackToJSON(b)
+		if err != nil {
+			t.Errorf("%d: %v", tt.in, err)
+			continue
+		}
+		if want, _ := json.Marshal(tt.in); string(got) != string(want) {
+			t.Errorf("%d: decoded %s", tt.in, got)
+		}
+	}
+}
+
+type msgpackInner struct {
+	N int `json:"n"`
+}
+
+type msgpackSample struct {
+	msgpackInner
+	Name    string            `json:"name"`
+	Skip    string            `json:"skip,omitempty"`
+	Hidden  string            `json:"-"`
+	Bytes   []byte            `json:"bytes"`
+	Float   float64           `json:"float"`
+	Neg     int8              `json:"neg"`
+	Big     uint64            `json:"big"`
+	Ptr     *bool             `json:"ptr"`
+	NilPtr  *bool             `json:"nilPtr"`
+	Long    string            `json:"long"`
+	List    []any             `json:"list"`
+	Many    []int             `json:"many"`
+	Map     map[string]int    `json:"map"`
+	NilMap  map[string]string `json:"nilMap"`
+	Raw     json.RawMessage   `json:"raw"`
+	NoRaw   json.RawMessage   `json:"noRaw"`
+	At      time.Time         `json:"at"`
+	Due     requestDeadline   `json:"due"`
+	private int
+}
+
+// TestMsgpackEncode checks a value encodes to the msgpack form of what
+// encoding/json would produce for it.
+func TestMsgpackEncode(t *testing.T) {
+	yes := true
+	many := make([]int, 70000)
+	for i := range many {
+		many[i] = i
+	}
+	v := msgpackSample{
+		msgpackInner: msgpackInner{N: 3},
+		Name:         "n",
+		Hidden:       "h",
+		Bytes:        []byte{0, 1, 2},
+		Float:        0.1,
+		Neg:          -100,
+		Big:          math.MaxUint64,
+		Ptr:          &yes,
+		Long:         strings.Repeat("é", 40000),
+		List:         []any{"a", 1.5, nil, map[string]any{"x": false}},
+		Many:         many,
+		Map:          map[string]int{"b": 2, "a": 1},
+		Raw:          json.RawMessage(`{"z":[1,2.5,"s",null,true],"big":12345678901}`),
+		At:           time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC),
+		Due:          requestDeadline{Relative: 1500 * time.Millisecond},
+		private:      1,
+	}
+	b, err := appendMsgpack(nil, reflect.ValueOf(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := msgpackToJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(v)
+	var g, w any
+	json.Unmarshal(got, &g)
+	json.Unmarshal(want, &w)
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got  %.300s\nwant %.300s", got, want)
+	}
+}
+
+func TestMsgpackUnsupported(t *testing.T) {
+	for _, v := range []any{map[int]string{1: "a"}, make(chan int), func() {}} {
+		if _, err := appendMsgpack(nil, reflect.ValueOf(v)); err == nil {
+			t.Errorf("%T encoded", v)
+		}
+	}
+}
+
+// jsonEquivalent reports whether two JSON documents hold the same values,
+// comparing numbers by value so 1 and 1.0, or -0 and 0, match.
+func jsonEquivalent(a, b []byte) bool {
+	decode := func(data []byte) (any, bool) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v any
+		return v, dec.Decode(&v) == nil
+	}
+	va, okA := decode(a)
+	vb, okB := decode(b)
+	return okA && okB && sameJSONValue(va, vb)
+}
+
+func sameJSONValue(a, b any) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, okA := new(big.Float).SetString(a.String())
+		fb, okB := new(big.Float).SetString(b.String())
+		return okA && okB && fa.Cmp(fb) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !sameJSONValue(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !sameJSONValue(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// checkMsgpackRoundTrip encodes v and checks the type byte and that it
+// decodes to what encoding/json makes of v.
+func checkMsgpackRoundTrip(t *testing.T, v any, typeByte byte) {
+	t.Helper()
+	b, err := appendMsgpack(nil, reflect.ValueOf(v))
+	if err != nil {
+		t.Fatalf("%T: %v", v, err)
+	}
+	if b[0] != typeByte {
+		t.Errorf("%T: type byte 0x%02x, want 0x%02x", v, b[0], typeByte)
+	}
+	got, err := msgpackToJSON(b)
+	if err != nil {
+		t.Fatalf("%T: %v", v, err)
+	}
+	want, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jsonEquivalent(got, want) {
+		t.Errorf("%T: got %.120s, want %.120s", v, got, want)
+	}
+}
+
+// TestMsgpackRoundTrip covers each kind the encoder handles.
+func TestMsgpackRoundTrip(t *testing.T) {
+	var nilDeadline *requestDeadline
+	var nilAny any
+	res := RunResult{PublicID: "a", ExitCode: -1, Value: json.RawMessage(`{"v":[1]}`)}
+	tests := []struct {
+		v        any
+		typeByte byte
+	}{
+		{false, 0xc2},
+		{true, 0xc3},
+		{int8(-5), 0xfb},
+		{int16(math.MinInt16), 0xd1},
+		{int32(math.MaxInt32), 0xce},
+		{int64(math.MinInt64), 0xd3},
+		{uint8(math.MaxUint8), 0xcc},
+		{uint16(math.MaxUint16), 0xcd},
+		{uint32(math.MaxUint32), 0xce},
+		{uint64(math.MaxUint64), 0xcf},
+		{uint(0), 0x00},
+		{float32(1.5), 0xca},
+		{math.MaxFloat64, 0xcb},
+		{-0.0, 0xcb},
+		{math.SmallestNonzeroFloat64, 0xcb},
+		{"", 0xa0},
+		{"naïve ☃", 0xaa},
+		{"\xff invalid utf-8", 0xaf},
+		{[]byte{}, 0xa0},
+		{[]byte(nil), 0xc0},
+		{[]string{}, 0x90},
+		{[]string(nil), 0xc0},
+		{[3]int{1, 2, 3}, 0x93},
+		{map[string]bool{}, 0x80},
+		{map[string]bool(nil), 0xc0},
+		{map[string]*int{"nil": nil}, 0x81},
+		{[]any{nilAny, 1, "a", []any{}}, 0x94},
+		{&msgpackInner{N: 7}, 0x81},
+		{(*msgpackInner)(nil), 0xc0},
+		{nilDeadline, 0xc0},
+		{requestDeadline{Relative: time.Second}, 0xcd},
+		{json.RawMessage(`18446744073709551615`), 0xcf},
+		{json.RawMessage(`-9223372036854775808`), 0xd3},
+		{json.RawMessage(`1e300`), 0xcb},
+		{json.RawMessage(` {"b":[1.25,{"c":null}],"a":"x"} `), 0x82},
+		{time.Date(1969, 12, 31, 23, 59, 59, 999, time.FixedZone("x", -3600)), 0xd9},
+		{res, 0x80 | byte(len(jsonFieldsOf(t, res)))},
+	}
+	for _, tt := range tests {
+		checkMsgpackRoundTrip(t, tt.v, tt.typeByte)
+	}
+}
+
+// jsonFieldsOf returns the keys encoding/json writes for v.
+func jsonFieldsOf(t *testing.T, v any) map[string]any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// TestMsgpackLengthBoundaries checks strings, arrays and maps on each side of
+// the lengths where the encoder switches to a wider header.
+func TestMsgpackLengthBoundaries(t *testing.T) {
+	strs := []struct {
+		n        int
+		typeByte byte
+	}{{31, 0xbf}, {32, 0xd9}, {255, 0xd9}, {256, 0xda}, {65535, 0xda}, {65536, 0xdb}}
+	for _, tt := range strs {
+		checkMsgpackRoundTrip(t, strings.Repeat("s", tt.n), tt.typeByte)
+	}
+	lists := []struct {
+		n              int
+		array, mapByte byte
+	}{{15, 0x9f, 0x8f}, {16, 0xdc, 0xde}, {65535, 0xdc, 0xde}, {65536, 0xdd, 0xdf}}
+	for _, tt := range lists {
+		checkMsgpackRoundTrip(t, make([]bool, tt.n), tt.array)
+		m := make(map[string]int, tt.n)
+		for i := 0; i < tt.n; i++ {
+			m[strconv.Itoa(i)] = i
+		}
+		checkMsgpackRoundTrip(t, m, tt.mapByte)
+	}
+}
+
+// TestMsgpackUintWidths is TestMsgpackIntWidths for unsigned values.
+func TestMsgpackUintWidths(t *testing.T) {
+	tests := []struct {
+		in   uint64
+		want byte
+	}{
+		{0x7f, 0x7f}, {0x80, 0xcc}, {0xff, 0xcc}, {0x100, 0xcd}, {0xffff, 0xcd}, {0x10000, 0xce},
+		{math.MaxUint32, 0xce}, {math.MaxUint32 + 1, 0xcf}, {math.MaxInt64 + 1, 0xcf}, {math.MaxUint64, 0xcf},
+	}
+	for _, tt := range tests {
+		checkMsgpackRoundTrip(t, tt.in, tt.want)
+	}
+}
+
+// FuzzMsgpackToJSON feeds arbitrary documents to the decoder. Whatever it
+// accepts must be valid JSON that survives being encoded back to msgpack
+// and decoded again.
+func FuzzMsgpackToJSON(f *testing.F) {
+	for _, seed := range [][]byte{
+		hexBytes(0xc0),
+		hexBytes(0x93, 0x01, 0xa1, "x", 0xc3),
+		hexBytes(0x82, 0xa1, "a", 0xcb, 0x80, 0, 0, 0, 0, 0, 0, 0, 0xa1, "b", 0xca, 0x3f, 0xc0, 0, 0),
+		hexBytes(0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+		hexBytes(0xd3, 0x80, 0, 0, 0, 0, 0, 0, 0),
+		hexBytes(0xc4, 2, 0xff, 0xfe),
+		hexBytes(0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01),
+		hexBytes(0xde, 0x00, 0x01, 0xa1, "k", 0xdc, 0x00, 0x01, 0xd0, 0x80),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		js, err := msgpackToJSON(data)
+		if err != nil {
+			return
+		}
+		if !json.Valid(js) {
+			t.Fatalf("invalid JSON %q", js)
+		}
+		b, err := appendMsgpackJSON(nil, js)
+		if err != nil {
+			t.Fatalf("re-encoding %s: %v", js, err)
+		}
+		again, err := msgpackToJSON(b)
+		if err != nil {
+			t.Fatalf("decoding re-encoded %s: %v", js, err)
+		}
+		if !jsonEquivalent(js, again) {
+			t.Fatalf("round trip changed %s to %s", js, again)
+		}
+	})
+}
+
+// FuzzMsgpackEncode checks that values built from arbitrary scalars encode
+// to the msgpack form of their encoding/json output.
+func FuzzMsgpackEncode(f *testing.F) {
+	f.Add(int64(0), uint64(0), 0.0, "", []byte(nil), false)
+	f.Add(int64(-33), uint64(256), -0.5, strings.Repeat("x", 32), []byte{0xff}, true)
+	f.Add(int64(math.MinInt64), uint64(math.MaxUint64), math.MaxFloat64, "\xff\xfe", []byte("bytes"), true)
+	f.Fuzz(func(t *testing.T, i int64, u uint64, fl float64, s string, bs []byte, flag bool) {
+		if math.IsNaN(fl) || math.IsInf(fl, 0) {
+			return // encoding/json refuses them
+		}
+		v := struct {
+			I    int64          `json:"i"`
+			U    uint64         `json:"u,omitempty"`
+			F    float64        `json:"f"`
+			S    string         `json:"s"`
+			B    []byte         `json:"b"`
+			Flag bool           `json:"flag,omitempty"`
+			List []any          `json:"list"`
+			Map  map[string]any `json:"map"`
+		}{i, u, fl, s, bs, flag, []any{i, s, nil}, map[string]any{s: u}}
+		b, err := appendMsgpack(nil, reflect.ValueOf(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := msgpackToJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := json.Marshal(v)
+		if !jsonEquivalent(got, want) {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	})
+}
+
+var benchResult = RunResult{
+	PublicID:   "job-1",
+	Stdout:     strings.Repeat("line of output\n", 64),
+	Stderr:     "warning\n",
+	DurationMs: 123,
+}
+
+func BenchmarkMsgpackEncode(b *testing.B) {
+	v := reflect.ValueOf(benchResult)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := appendMsgpack(nil, v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONEncode(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := json.Marshal(benchResult); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchRequest() RunRequest {
+	return RunRequest{
+		PublicID:    "job-1",
+		Code:        strings.Repeat("console.log('hello');\n", 32),
+		Permissions: []string{"--allow-net=example.com"},
+		Env:         map[string]string{"A": "1", "B": "2"},
+		TimeoutMs:   5000,
+	}
+}
+
+// BenchmarkMsgpackDecode decodes a request the way the runner does: to JSON,
+// then into the Go type.
+func BenchmarkMsgpackDecode(b *testing.B) {
+	data, err := appendMsgpack(nil, reflect.ValueOf(benchRequest()))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		js, err := msgpackToJSON(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var req RunRequest
+		if err := json.Unmarshal(js, &req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONDecode(b *testing.B) {
+	data, _ := json.Marshal(benchRequest())
+	b.ReportAllocs()
+	for b.Loop() {
+		var req RunRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// NATS credentials are taken from one source, the first set of: NATS_CREDS,
+// NATS_NKEY_SEED, NATS_TOKEN, then NATS_USER and NATS_PASSWORD. The others
+// are ignored, with a warning. Credentials in a NATS_URL entry take the
+// place of NATS_TOKEN or NATS_USER for that server, as nats.go has it; they
+// don't mix with NATS_CREDS or NATS_NKEY_SEED, whose key is still used.
+
+// natsAuthMode names the source natsAuthOptions authenticates with, or is
+// empty for none.
+func (c *Config) natsAuthMode() string {
+	switch {
+	case c.NatsCreds != "":
+		return "NATS_CREDS"
+	case c.NatsNkeySeed != "":
+		return "NATS_NKEY_SEED"
+	case c.NatsToken != "":
+		return "NATS_TOKEN"
+	case c.NatsUser != "":
+		return "NATS_USER"
+	}
+	return ""
+}
+
+// checkNatsAuth checks the NATS credentials at startup, so a missing or bad
+// creds file or seed fails there rather than on the first connect.
+func (c *Config) checkNatsAuth() error {
+	if c.NatsPassword != "" && c.NatsUser == "" {
+		return fmt.Errorf("NATS_PASSWORD needs NATS_USER")
+	}
+	mode := c.natsAuthMode()
+	var ignored []string
+	for _, s := range []struct {
+		name string
+		set  bool
+	}{
+		{"NATS_CREDS", c.NatsCreds != ""},
+		{"NATS_NKEY_SEED", c.NatsNkeySeed != ""},
+		{"NATS_TOKEN", c.NatsToken != ""},
+		{"NATS_USER", c.NatsUser != ""},
+	} {
+		if s.set && s.name != mode {
+			ignored = append(ignored, s.name)
+		}
+	}
+	if len(ignored) > 0 {
+		log.Printf("[WARN] NATS auth uses %s; ignoring %s", mode, strings.Join(ignored, ", "))
+	}
+	switch mode {
+	case "NATS_CREDS":
+		data, err := os.ReadFile(c.NatsCreds)
+		if err != nil {
+			return fmt.Errorf("NATS_CREDS: %w", err)
+		}
+		defer wipeBytes(data)
+		if _, err := nkeys.ParseDecoratedJWT(data); err != nil {
+			return fmt.Errorf("NATS_CREDS %q: no user JWT in it: %v", c.NatsCreds, err)
+		}
+		kp, err := nkeys.ParseDecoratedNKey(data)
+		if err != nil {
+			return fmt.Errorf("NATS_CREDS %q: no user seed in it: %v", c.NatsCreds, err)
+		}
+		kp.Wipe()
+	case "NATS_NKEY_SEED":
+		if _, err := nkeyPublicKey(c.NatsNkeySeed); err != nil {
+			return fmt.Errorf("NATS_NKEY_SEED: %v", err)
+		}
+	}
+	return nil
+}
+
+// nkeyPublicKey returns the public key of a user seed.
+func nkeyPublicKey(seed string) (string, error) {
+	kp, err := nkeys.FromSeed([]byte(seed))
+	if err != nil {
+		return "", fmt.Errorf("not a valid seed")
+	}
+	defer kp.Wipe()
+	pub, err := kp.PublicKey()
+	if err != nil || !nkeys.IsValidPublicUserKey(pub) {
+		return "", fmt.Errorf("not a user seed")
+	}
+	return pub, nil
+}
+
+// natsAuthOptions returns the options that authenticate the connection. The
+// creds file is read on every connect, so a JWT refreshed on disk is picked
+// up on the next reconnect. Seeds, tokens and passwords are never logged.
+func (c *Config) natsAuthOptions() []nats.Option {
+	switch c.natsAuthMode() {
+	case "NATS_CREDS":
+		log.Printf("NATS auth: credentials file %s", c.NatsCreds)
+		return []nats.Option{nats.UserCredentials(c.NatsCreds)}
+	case "NATS_NKEY_SEED":
+		pub, _ := nkeyPublicKey(c.NatsNkeySeed) // checked by checkNatsAuth
+		log.Printf("NATS auth: nkey %s", pub)
+		seed := c.NatsNkeySeed
+		return []nats.Option{nats.Nkey(pub, func(nonce []byte) ([]byte, error) {
+			kp, err := nkeys.FromSeed([]byte(seed))
+			if err != nil {
+				return nil, err
+			}
+			defer kp.Wipe()
+			return kp.Sign(nonce)
+		})}
+	case "NATS_TOKEN":
+		log.Printf("NATS auth: token")
+		return []nats.Option{nats.Token(c.NatsToken)}
+	case "NATS_USER":
+		log.Printf("NATS auth: user %s", c.NatsUser)
+		return []nats.Option{nats.UserInfo(c.NatsUser, c.NatsPassword)}
+	}
+	return nil
+}
+
+// wipeBytes overwrites key material once it has been used.
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 'x'
+	}
+}
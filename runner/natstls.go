@@ -0,0 +1,142 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsTLS holds the certificates the NATS connection's TLS uses, loaded from
+// NATS_TLS_CA, NATS_TLS_CERT and NATS_TLS_KEY at startup and again on SIGHUP.
+// Each handshake takes the ones loaded last.
+type natsTLS struct {
+	caFile, certFile, keyFile string
+
+	mu    sync.Mutex
+	roots *x509.CertPool   // nil without a CA bundle
+	cert  *tls.Certificate // nil without a client certificate
+}
+
+// tlsVersions are the values NATS_TLS_MIN_VERSION accepts.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// checkNatsTLS reads the TLS settings and loads the certificates they name,
+// so a missing or bad file fails at startup.
+func (c *Config) checkNatsTLS() error {
+	t := &natsTLS{caFile: os.Getenv("NATS_TLS_CA"), certFile: os.Getenv("NATS_TLS_CERT"), keyFile: os.Getenv("NATS_TLS_KEY")}
+	if (t.certFile == "") != (t.keyFile == "") {
+		return fmt.Errorf("NATS_TLS_CERT and NATS_TLS_KEY must be set together")
+	}
+	var err error
+	if c.NatsTLSInsecure, err = envBool("NATS_TLS_INSECURE", false); err != nil {
+		return err
+	}
+	if v := os.Getenv("NATS_TLS_MIN_VERSION"); v != "" {
+		var ok bool
+		if c.NatsTLSMinVersion, ok = tlsVersions[v]; !ok {
+			return fmt.Errorf("invalid NATS_TLS_MIN_VERSION %q: must be 1.2 or 1.3", v)
+		}
+	}
+	if t.caFile == "" && t.certFile == "" {
+		return nil
+	}
+	if err := t.load(); err != nil {
+		return err
+	}
+	c.natsTLS = t
+	return nil
+}
+
+// load reads the CA bundle and client certificate, replacing the ones in use
+// only if both load.
+func (t *natsTLS) load() error {
+	var roots *x509.CertPool
+	if t.caFile != "" {
+		pem, err := os.ReadFile(t.caFile)
+		if err != nil {
+			return fmt.Errorf("NATS_TLS_CA: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("NATS_TLS_CA %q: no PEM certificates in it", t.caFile)
+		}
+	}
+	var cert *tls.Certificate
+	if t.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+		if err != nil {
+			return fmt.Errorf("NATS_TLS_CERT/NATS_TLS_KEY: %w", err)
+		}
+		if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return fmt.Errorf("NATS_TLS_CERT %q: %w", t.certFile, err)
+		}
+		cert = &pair
+	}
+	t.mu.Lock()
+	t.roots, t.cert = roots, cert
+	t.mu.Unlock()
+	if cert != nil {
+		log.Printf("NATS TLS client certificate %q, valid until %s", cert.Leaf.Subject.CommonName, cert.Leaf.NotAfter.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// natsTLSOptions returns the options that set up TLS, if any is configured.
+// A tls:// URL turns TLS on by itself, with the system's CAs.
+func (c *Config) natsTLSOptions() []nats.Option {
+	if c.natsTLS == nil && !c.NatsTLSInsecure && c.NatsTLSMinVersion == 0 {
+		return nil
+	}
+	if c.NatsTLSInsecure {
+		log.Printf("[WARN] NATS_TLS_INSECURE is set: the NATS server's certificate is NOT verified, so the connection can be intercepted")
+	}
+	minVersion := max(c.NatsTLSMinVersion, tls.VersionTLS12)
+	opts := []nats.Option{nats.Secure(&tls.Config{MinVersion: minVersion, InsecureSkipVerify: c.NatsTLSInsecure})}
+	t := c.natsTLS
+	if t == nil {
+		return opts
+	}
+	var certCB nats.TLSCertHandler
+	var rootsCB nats.RootCAsHandler
+	if t.certFile != "" {
+		certCB = func() (tls.Certificate, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return *t.cert, nil
+		}
+	}
+	if t.caFile != "" {
+		rootsCB = func() (*x509.CertPool, error) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return t.roots, nil
+		}
+	}
+	return append(opts, nats.ClientTLSConfig(certCB, rootsCB))
+}
+
+// reloadTLS loads the NATS TLS certificates again, on SIGHUP, and reconnects
+// so the connection uses them. If they don't load, the old ones stay.
+func (r *Runner) reloadTLS() {
+	t := r.cfg.natsTLS
+	if t == nil {
+		log.Printf("[TLS] SIGHUP: no NATS TLS certificates configured to reload")
+		return
+	}
+	if err := t.load(); err != nil {
+		log.Printf("[TLS] Reload failed, keeping the current certificates: %v", err)
+		return
+	}
+	log.Printf("[TLS] Reloaded NATS TLS certificates; reconnecting to use them")
+	if err := r.nc.ForceReconnect(); err != nil {
+		log.Printf("[TLS] Reconnect failed: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOfflineJobLoopback checks a job without network permissions runs in
+// a namespace of its own whose only interface, lo, is up.
+func TestOfflineJobLoopback(t *testing.T) {
+	if err := checkNetIsolation(); err != nil {
+		t.Skipf("cannot create network namespaces: %v", err)
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("no ip command to list interfaces with")
+	}
+	fakeDeno(t, `ip -o link show`)
+	r := testRunner(t)
+	r.netIsolation = true
+	res := r.executeIn(&RunRequest{PublicID: "offline", Code: "Deno.serve(() => new Response())"}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s), stderr %q", res.ErrorCode, res.Error, res.Stderr)
+	}
+	links := strings.Split(strings.TrimSpace(res.Stdout), "\n")
+	if len(links) != 1 || !strings.HasPrefix(links[0], "1: lo: <LOOPBACK,UP,LOWER_UP>") {
+		t.Errorf("job's interfaces:\n%s\nwant only lo, up", res.Stdout)
+	}
+
+	// Jobs allowed the network stay in the runner's namespace.
+	res = r.executeIn(&RunRequest{PublicID: "online", Code: "fetch(url)", Permissions: []string{"--allow-net"}}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	host, err := exec.Command("ip", "-o", "link", "show").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stdout != string(host) {
+		t.Errorf("online job's interfaces:\n%s\nwant the runner's:\n%s", res.Stdout, host)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func checkNetIsolation() error {
+	return errors.New("network namespaces require Linux")
+}
+
+func startIsolated(cmd *exec.Cmd, start func(*exec.Cmd) error) error { return start(cmd) }
@@ -0,0 +1,65 @@
+package main
+
+import "strings"
+
+// npmDisabledErrors are how deno reports an npm: specifier under --no-npm.
+var npmDisabledErrors = []string{
+	"--no-npm is specified",
+	"npm specifiers are not allowed",
+}
+
+// npmAllowed reports whether tenant's requests may use npm: specifiers.
+func (c *Config) npmAllowed(tenant string) bool {
+	return c.NpmTenants["*"] || c.NpmTenants[tenant]
+}
+
+// validateNpm checks a request's npm settings against the tenant policy.
+func (c *Config) validateNpm(req *RunRequest, errs *validationErrors) {
+	switch {
+	case !req.Npm:
+		if req.NodeModulesDir != "" {
+			errs.add("nodeModulesDir", "requires npm")
+		}
+		return
+	case req.Mode == modeLint || req.Mode == modeFmt:
+		errs.add("npm", "is not supported in %s mode", req.Mode)
+	case !c.npmAllowed(c.tenantOf(req)):
+		errs.add("npm", "is not enabled for tenant %q", c.tenantOf(req))
+	}
+	switch req.NodeModulesDir {
+	case "", "auto", "none":
+	default:
+		errs.add("nodeModulesDir", "must be auto or none")
+	}
+}
+
+// npmFlags returns the flags for req's npm policy: --no-npm unless it may
+// use npm, and where packages go if it may. With auto, the default, they are
+// installed under node_modules in the working directory, so they count
+// against the disk quota and go away with the job.
+func npmFlags(req *RunRequest) []string {
+	if !req.Npm {
+		return []string{"--no-npm"}
+	}
+	dir := req.NodeModulesDir
+	if dir == "" {
+		dir = "auto"
+	}
+	return []string{"--node-modules-dir=" + dir}
+}
+
+// checkNpm reports a run that failed on an npm: specifier while npm was
+// disabled as NPM_DISABLED.
+func checkNpm(res *RunResult, req *RunRequest) {
+	if req.Npm || res.ErrorCode != errorCodeRuntime || res.ExitSignal != nil {
+		return
+	}
+	stderr := stripANSI(res.Stderr)
+	for _, msg := range npmDisabledErrors {
+		if strings.Contains(stderr, msg) {
+			res.Error = "npm: specifiers are disabled; set npm on the request if its tenant allows them"
+			res.ErrorCode = errorCodeNpmDisabled
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// offloadTimeout bounds the upload of one result to the object store.
+const offloadTimeout = 30 * time.Second
+
+// offloadHeadroom is left under the server's max_payload for the reply's
+// headers when the offload threshold is derived from it.
+const offloadHeadroom = 1 << 10
+
+// offloader decides which replies are too big for a NATS message and
+// uploads those results to the object store when there is one.
+type offloader struct {
+	nc *nats.Conn
+	// threshold is RUNNER_RESULT_OFFLOAD_BYTES; zero derives it from the
+	// server's max_payload.
+	threshold int
+	store     jetstream.ObjectStore // nil unless RUNNER_RESULT_OBJECT_BUCKET is set
+	bucket    string
+}
+
+// OffloadedResult points at the full result of a reply too big for a NATS
+// message, in the JetStream object store. The reply is the result cut down
+// to the end of its output, with FullResultBytes set and this attached.
+type OffloadedResult struct {
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	// Digest is the object's "SHA-256=<base64url>" digest, as the object
+	// store reports it.
+	Digest string `json:"digest"`
+	// ContentType is the object's encoding, as the reply's Content-Type
+	// header would have had it; empty for JSON.
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// newOffloader creates or updates the object store bucket oversized results
+// are uploaded to, if one is configured.
+func newOffloader(cfg *Config, nc *nats.Conn) (*offloader, error) {
+	o := &offloader{nc: nc, threshold: int(cfg.ResultOffloadBytes)}
+	if cfg.ResultObjectBucket == "" {
+		return o, nil
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	o.store, err = js.CreateOrUpdateObjectStore(ctx, jetstream.ObjectStoreConfig{
+		Bucket:      cfg.ResultObjectBucket,
+		Description: "runner results too big for a reply",
+		TTL:         cfg.ResultObjectTTL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bucket %q: %w", cfg.ResultObjectBucket, err)
+	}
+	o.bucket = cfg.ResultObjectBucket
+	return o, nil
+}
+
+// limit returns the largest reply that is sent as is, or zero while it isn't
+// known. It is zero on a nil offloader.
+func (o *offloader) limit() int {
+	if o == nil {
+		return 0
+	}
+	if o.threshold > 0 {
+		return o.threshold
+	}
+	if mp := int(o.nc.MaxPayload()); mp > offloadHeadroom {
+		return mp - offloadHeadroom
+	}
+	return 0
+}
+
+// reply uploads data, the encoded reply res would have been, to the object
+// store and returns the encoded reply pointing at it. Without an object
+// store, or if the upload fails, the reply is only the cut-down result.
+func (o *offloader) reply(m *nats.Msg, res RunResult, data []byte, contentType string) ([]byte, string, error) {
+	preview := storedPreview(res, len(data))
+	if o.store == nil {
+		log.Printf("[OFFLOAD] Result of %s is %d bytes, more than a reply can hold; sending it cut down (set RUNNER_RESULT_OBJECT_BUCKET to keep it whole)", res.PublicID, len(data))
+		return encodeReply(m, preview)
+	}
+	name := fmt.Sprintf("%s/%d", res.PublicID, time.Now().UnixNano())
+	meta := jetstream.ObjectMeta{Name: name, Description: "result of " + res.PublicID}
+	if contentType != "" {
+		meta.Headers = nats.Header{contentTypeHeader: []string{contentType}}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), offloadTimeout)
+	defer cancel()
+	info, err := o.store.Put(ctx, meta, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("[OFFLOAD] Failed to upload result of %s, sending it cut down: %v", res.PublicID, err)
+		return encodeReply(m, preview)
+	}
+	log.Printf("[OFFLOAD] Uploaded %d-byte result of %s to %s/%s", len(data), res.PublicID, o.bucket, name)
+	preview.Offloaded = &OffloadedResult{Bucket: o.bucket, Name: name, Size: int64(info.Size), Digest: info.Digest, ContentType: contentType}
+	return encodeReply(m, preview)
+}
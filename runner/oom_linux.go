@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// kernelOOMKills returns the system-wide count of OOM killer invocations from
+// /proc/vmstat, or 0 if it cannot be read.
+func kernelOOMKills() int64 {
+	data, err := os.ReadFile("/proc/vmstat")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package main
+
+// kernelOOMKills is only tracked on Linux.
+func kernelOOMKills() int64 { return 0 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// outputCapture collects stdout and stderr separately while also keeping the
+// combined, interleaved stream. exec.Cmd copies each pipe on its own goroutine,
+// so writes are serialized with a mutex.
+type outputCapture struct {
+	mu       sync.Mutex
+	combined *cappedBuffer
+	stdout   *cappedBuffer
+	stderr   *cappedBuffer
+}
+
+func newOutputCapture(maxBytes, tailBytes int64) *outputCapture {
+	return &outputCapture{
+		combined: newCappedBuffer(maxBytes, tailBytes),
+		stdout:   newCappedBuffer(maxBytes, tailBytes),
+		stderr:   newCappedBuffer(maxBytes, tailBytes),
+	}
+}
+
+// streamWriter is the io.Writer handed to exec.Cmd for a single stream.
+type streamWriter struct {
+	c   *outputCapture
+	buf *cappedBuffer
+}
+
+func (w streamWriter) Write(p []byte) (int, error) {
+	w.c.mu.Lock()
+	defer w.c.mu.Unlock()
+	w.c.combined.Write(p)
+	w.buf.Write(p)
+	return len(p), nil
+}
+
+func (c *outputCapture) Stdout() io.Writer { return streamWriter{c: c, buf: c.stdout} }
+func (c *outputCapture) Stderr() io.Writer { return streamWriter{c: c, buf: c.stderr} }
+
+// bytes returns how many bytes the process has written so far.
+func (c *outputCapture) bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.combined.total
+}
+
+// fill copies the captured streams into the result. Invalid UTF-8 is
+// replaced with U+FFFD in the text fields; with binary set, stdout and stderr
+// are also returned byte-for-byte as base64.
+func (c *outputCapture) fill(res *RunResult, binary bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res.Output = strings.ToValidUTF8(c.combined.String(), "\uFFFD")
+	res.Stdout = strings.ToValidUTF8(c.stdout.String(), "\uFFFD")
+	res.Stderr = strings.ToValidUTF8(c.stderr.String(), "\uFFFD")
+	if binary {
+		res.BinaryOutput = &BinaryOutput{
+			Encoding: "base64",
+			Stdout:   base64.StdEncoding.EncodeToString([]byte(c.stdout.String())),
+			Stderr:   base64.StdEncoding.EncodeToString([]byte(c.stderr.String())),
+		}
+	}
+	res.OutputBytes = c.combined.total
+	res.Truncated = c.combined.truncated()
+}
+
+// cappedBuffer keeps the first bytes of a stream and a rolling window of the
+// last bytes, discarding the middle once the limit is reached. total counts
+// every byte written, including discarded ones.
+type cappedBuffer struct {
+	head    []byte
+	tail    []byte
+	headMax int
+	tailMax int
+	total   int64
+}
+
+func newCappedBuffer(maxBytes, tailBytes int64) *cappedBuffer {
+	return &cappedBuffer{headMax: int(maxBytes - tailBytes), tailMax: int(tailBytes)}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.total += int64(len(p))
+	rest := p
+	if room := b.headMax - len(b.head); room > 0 {
+		n := min(room, len(rest))
+		b.head = append(b.head, rest[:n]...)
+		rest = rest[n:]
+	}
+	if len(rest) > 0 && b.tailMax > 0 {
+		b.tail = append(b.tail, rest...)
+		if len(b.tail) > 2*b.tailMax {
+			b.tail = append([]byte(nil), b.tail[len(b.tail)-b.tailMax:]...)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) tailBytes() []byte {
+	if len(b.tail) > b.tailMax {
+		return b.tail[len(b.tail)-b.tailMax:]
+	}
+	return b.tail
+}
+
+// dropped returns how many bytes were discarded from the middle of the stream.
+func (b *cappedBuffer) dropped() int64 {
+	return b.total - int64(len(b.head)) - int64(len(b.tailBytes()))
+}
+
+func (b *cappedBuffer) truncated() bool { return b.dropped() > 0 }
+
+func (b *cappedBuffer) String() string {
+	if n := b.dropped(); n > 0 {
+		return fmt.Sprintf("%s\n... [%d bytes truncated] ...\n%s", b.head, n, b.tailBytes())
+	}
+	return string(b.head) + string(b.tailBytes())
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// binaryScript writes a null byte, a stray continuation byte, an invalid
+// lead byte and a multibyte sequence cut short to each stream, across
+// several writes and lines.
+const binaryScript = `printf 'a\000b\n'
+printf 'c\200d\377e\n'
+printf 'euro \342\202' >&2
+printf '\n'
+printf 'f\342'`
+
+var (
+	binaryStdout = "a\x00b\nc\x80d\xffe\n\nf\xe2"
+	binaryStderr = "euro \xe2\x82"
+)
+
+func TestBinaryOutputText(t *testing.T) {
+	fakeDeno(t, binaryScript)
+	r := testRunner(t)
+	res := r.executeIn(&RunRequest{PublicID: "binary", Code: "Deno.stdout.write(bytes)"}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	if want := "a\x00b\nc�d�e\n\nf�"; res.Stdout != want {
+		t.Errorf("stdout %q, want %q", res.Stdout, want)
+	}
+	// A truncated sequence is replaced as a whole.
+	if want := "euro �"; res.Stderr != want {
+		t.Errorf("stderr %q, want %q", res.Stderr, want)
+	}
+	if res.BinaryOutput != nil {
+		t.Errorf("binaryOutput set without being asked for: %+v", res.BinaryOutput)
+	}
+	if res.OutputBytes != int64(len(binaryStdout)+len(binaryStderr)) {
+		t.Errorf("outputBytes %d, want %d", res.OutputBytes, len(binaryStdout)+len(binaryStderr))
+	}
+	checkValidJSON(t, res)
+}
+
+func TestBinaryOutputBase64(t *testing.T) {
+	fakeDeno(t, binaryScript)
+	r := testRunner(t)
+	res := r.executeIn(&RunRequest{PublicID: "binary", Code: "Deno.stdout.write(bytes)", BinaryOutput: true}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	if res.BinaryOutput == nil || res.BinaryOutput.Encoding != "base64" {
+		t.Fatalf("binaryOutput = %+v", res.BinaryOutput)
+	}
+	for _, s := range []struct{ name, encoded, want string }{
+		{"stdout", res.BinaryOutput.Stdout, binaryStdout},
+		{"stderr", res.BinaryOutput.Stderr, binaryStderr},
+	} {
+		got, err := base64.StdEncoding.DecodeString(s.encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if string(got) != s.want {
+			t.Errorf("%s decodes to %q, want %q", s.name, got, s.want)
+		}
+	}
+	if !utf8.ValidString(res.Stdout) || !utf8.ValidString(res.Stderr) || !utf8.ValidString(res.Output) {
+		t.Errorf("text fields not valid UTF-8 alongside binaryOutput: %q %q", res.Stdout, res.Stderr)
+	}
+	checkValidJSON(t, res)
+}
+
+func TestBinaryOutputLines(t *testing.T) {
+	fakeDeno(t, binaryScript)
+	r := testRunner(t)
+	res := r.executeIn(&RunRequest{PublicID: "binary", Code: "Deno.stdout.write(bytes)", Timestamps: true}, 0, time.Time{}, nil)
+	var got []string
+	for _, l := range res.Lines {
+		got = append(got, l.Stream+":"+l.Text)
+	}
+	want := []string{"stdout:a\x00b", "stdout:c�d�e", "stdout:", "stdout:f�", "stderr:euro �"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines %q, want %q", got, want)
+	}
+	checkValidJSON(t, res)
+}
+
+// TestLineRecorderSplitsOnRunes checks a long line is split between runes,
+// so a multibyte character straddling the split survives intact.
+func TestLineRecorderSplitsOnRunes(t *testing.T) {
+	lr := newLineRecorder()
+	w := lr.writer("stdout")
+	long := strings.Repeat("x", maxOutputLineBytes-1) + "€tail"
+	// One byte at a time, as a pipe may deliver it.
+	for i := 0; i < len(long); i++ {
+		w.Write([]byte{long[i]})
+	}
+	var res RunResult
+	lr.fill(&res)
+	if len(res.Lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(res.Lines))
+	}
+	if first := res.Lines[0].Text; first != strings.Repeat("x", maxOutputLineBytes-1) {
+		t.Errorf("first line is %d bytes ending %q", len(first), first[len(first)-4:])
+	}
+	if second := res.Lines[1].Text; second != "€tail" {
+		t.Errorf("second line %q, want %q", second, "€tail")
+	}
+}
+
+// checkValidJSON checks res encodes to valid UTF-8 and decodes back to the
+// same text. encoding/json would quietly replace invalid bytes itself, so
+// the round trip is what shows the result had none.
+func checkValidJSON(t *testing.T, res RunResult) {
+	t.Helper()
+	data, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.Valid(data) {
+		t.Fatalf("result JSON is not valid UTF-8: %q", data)
+	}
+	var back RunResult
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Stdout != res.Stdout || back.Stderr != res.Stderr {
+		t.Errorf("stdout %q / stderr %q after a JSON round trip, want %q / %q", back.Stdout, back.Stderr, res.Stdout, res.Stderr)
+	}
+}
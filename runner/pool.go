@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// errBusy is returned by submit when the backlog is full.
+var errBusy = errors.New("runner busy, retry later")
+
+// Job priorities, lowest first; they index workerPool.queues.
+const (
+	priorityLow = iota
+	priorityNormal
+	priorityHigh
+	numPriorities
+)
+
+var priorityNames = [numPriorities]string{"low", "normal", "high"}
+
+// parsePriority maps RunRequest.Priority to a level; "" means normal.
+func parsePriority(s string) (int, error) {
+	if s == "" {
+		return priorityNormal, nil
+	}
+	for level, name := range priorityNames {
+		if s == name {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid priority %q: want high, normal or low", s)
+}
+
+// pendingJob is a decoded request waiting for a free worker.
+type pendingJob struct {
+	msg            *nats.Msg
+	req            RunRequest
+	tenant         string
+	serialized     bool
+	priority       int
+	received       time.Time                // when dispatch accepted it
+	enqueued       time.Time                // when it entered the worker pool's queue
+	queuePosition  int                      // jobs waiting for a worker, this one included, when it was queued
+	deadline       time.Time                // zero if the job never expires
+	ack            atomic.Pointer[nats.Msg] // request still owed a SubmitAck
+	runner         *Runner                  // sends replies over NATS
+	webhooks       *webhookClient           // set when the request has a webhook
+	cacheKey       string                   // set for cacheable requests
+	idempotencyKey string                   // store key for requests with an idempotency key
+	flightKey      string                   // set when identical requests may coalesce onto this one
+	done           func(RunResult)          // set for batch entries, which don't reply over NATS
+	work           *workItem                // set for jobs from the work queue
+	results        *resultStore             // set when results are kept for runner.result queries
+}
+
+// send delivers a job's result, carrying the job's PublicID and metadata, to
+// whoever is waiting for it and to its webhook, acknowledging the request
+// first if it is owed a SubmitAck.
+func (j *pendingJob) send(res RunResult) {
+	j.acknowledge(submitCompleted)
+	res.PublicID = j.req.PublicID
+	res.Metadata = j.req.Metadata
+	if j.work != nil {
+		if redeliverable(res) && j.work.retry(res) {
+			return // another runner will answer it
+		}
+		defer j.work.settle(res) // once the result is out
+	}
+	if j.webhooks != nil {
+		j.webhooks.deliver(&j.req, res)
+	}
+	j.results.store(res)
+	if j.done != nil {
+		j.done(res)
+		return
+	}
+	if j.req.ChunkedReply {
+		j.runner.respondChunked(j.msg, res)
+		return
+	}
+	j.runner.respond(j.msg, res)
+}
+
+// workerPool runs jobs on a fixed number of worker goroutines, with a bounded
+// backlog in front of them. The backlog is split by tenant, and tenants with
+// queued work take turns in weighted round-robin order, each getting as many
+// consecutive slots as its weight, so a flood from one tenant delays another
+// tenant's job by at most one round. Within a tenant there is one FIFO per
+// priority; higher priorities are served first, but a job gains one level for
+// every aging interval it waits so low-priority work is never starved.
+type workerPool struct {
+	mu            sync.Mutex
+	cond          *sync.Cond
+	tenants       map[string]*tenantQueue
+	ring          []*tenantQueue // tenants with queued jobs, in serving order
+	turn          int            // index in ring of the tenant being served
+	credit        int            // slots left in the current tenant's turn
+	weight        func(tenant string) int
+	queued        int
+	idle          int
+	maxQueueDepth int
+	aging         time.Duration
+	closed        bool
+}
+
+// tenantQueue is one tenant's share of the backlog.
+type tenantQueue struct {
+	name   string
+	queues [numPriorities][]*pendingJob
+	n      int
+}
+
+// newWorkerPool starts size workers that call run for each submitted job.
+// The slot passed to run identifies the worker (0..size-1) for logging, and
+// weight gives each tenant's share of the workers when jobs are queued.
+func newWorkerPool(size, maxQueueDepth int, aging time.Duration, weight func(string) int, run func(slot int, job *pendingJob)) *workerPool {
+	p := &workerPool{maxQueueDepth: maxQueueDepth, aging: aging, weight: weight, tenants: make(map[string]*tenantQueue)}
+	p.cond = sync.NewCond(&p.mu)
+	for slot := 0; slot < size; slot++ {
+		go func() {
+			for {
+				run(slot, p.next())
+			}
+		}()
+	}
+	return p
+}
+
+// free returns the number of idle workers no queued job is waiting for.
+func (p *workerPool) free() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return max(p.idle-p.queued, 0)
+}
+
+// next blocks until a job is available and removes it from the queue.
+func (p *workerPool) next() *pendingJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.idle++
+	for p.queued == 0 {
+		p.cond.Wait()
+	}
+	p.idle--
+
+	tq := p.nextTenant()
+	// Pick the queue whose head has the best priority after aging.
+	now := time.Now()
+	best, bestScore := -1, 0.0
+	for level := numPriorities - 1; level >= 0; level-- {
+		q := tq.queues[level]
+		if len(q) == 0 {
+			continue
+		}
+		score := float64(level) + float64(now.Sub(q[0].enqueued))/float64(p.aging)
+		if best < 0 || score > bestScore {
+			best, bestScore = level, score
+		}
+	}
+	job := tq.queues[best][0]
+	tq.queues[best][0] = nil
+	tq.queues[best] = tq.queues[best][1:]
+	tq.n--
+	p.queued--
+	if tq.n == 0 {
+		p.dropTenant(tq)
+	}
+	if job.priority < priorityHigh && now.Sub(job.enqueued) >= p.aging {
+		log.Printf("[QUEUE] %s (%s) dispatched after aging for %v", job.req.PublicID, priorityNames[job.priority], now.Sub(job.enqueued).Round(time.Millisecond))
+	}
+	return job
+}
+
+// nextTenant returns the tenant whose turn it is, moving on to the next one
+// in the ring once the current tenant has used up its weight; p.mu must be
+// held and at least one job queued.
+func (p *workerPool) nextTenant() *tenantQueue {
+	if p.credit <= 0 {
+		p.turn++
+		if p.turn >= len(p.ring) {
+			p.turn = 0
+		}
+		p.credit = max(p.weight(p.ring[p.turn].name), 1)
+	}
+	p.credit--
+	return p.ring[p.turn]
+}
+
+// dropTenant removes a tenant with no queued jobs from the ring; p.mu must
+// be held.
+func (p *workerPool) dropTenant(tq *tenantQueue) {
+	delete(p.tenants, tq.name)
+	for i, t := range p.ring {
+		if t != tq {
+			continue
+		}
+		p.ring = append(p.ring[:i], p.ring[i+1:]...)
+		switch {
+		case i < p.turn:
+			p.turn--
+		case i == p.turn:
+			// Its turn is over; the tenant that moved into slot i is next.
+			p.turn--
+			p.credit = 0
+		}
+		return
+	}
+}
+
+// remove takes a queued job out of the backlog, reporting whether it was
+// there.
+func (p *workerPool) remove(job *pendingJob) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tq := p.tenants[job.tenant]
+	if tq == nil {
+		return false
+	}
+	q := tq.queues[job.priority]
+	for i, j := range q {
+		if j != job {
+			continue
+		}
+		tq.queues[job.priority] = append(q[:i:i], q[i+1:]...)
+		tq.n--
+		p.queued--
+		if tq.n == 0 {
+			p.dropTenant(tq)
+		}
+		return true
+	}
+	return false
+}
+
+// depths returns the number of queued jobs per priority; p.mu must be held.
+func (p *workerPool) depths() map[string]int {
+	d := make(map[string]int, numPriorities)
+	for _, name := range priorityNames {
+		d[name] = 0
+	}
+	for _, tq := range p.ring {
+		for level, q := range tq.queues {
+			d[priorityNames[level]] += len(q)
+		}
+	}
+	return d
+}
+
+// tenantDepths returns the number of queued jobs per tenant; p.mu must be
+// held. Jobs with no tenant are listed under "".
+func (p *workerPool) tenantDepths() map[string]int {
+	d := make(map[string]int, len(p.ring))
+	for _, tq := range p.ring {
+		d[tq.name] = tq.n
+	}
+	return d
+}
+
+// submit queues a job for the next free worker. It fails with errBusy instead
+// of queueing when every worker is busy and the backlog is at its limit,
+// unless force is set for a job that was already accepted earlier.
+func (p *workerPool) submit(job *pendingJob, force bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errPoolClosed
+	}
+	waiting := p.queued - p.idle
+	if waiting >= p.maxQueueDepth && !force {
+		log.Printf("[BUSY] Rejecting %s: %d job(s) waiting (max %d)", job.req.PublicID, waiting, p.maxQueueDepth)
+		return errBusy
+	}
+	job.enqueued = time.Now()
+	job.queuePosition = max(waiting+1, 0)
+	tq := p.tenants[job.tenant]
+	if tq == nil {
+		tq = &tenantQueue{name: job.tenant}
+		p.tenants[job.tenant] = tq
+		p.ring = append(p.ring, tq)
+	}
+	tq.queues[job.priority] = append(tq.queues[job.priority], job)
+	tq.n++
+	p.queued++
+	p.cond.Signal()
+	if waiting+1 > 0 {
+		d := p.depths()
+		log.Printf("[QUEUE] %s (%s) waiting for a worker (depth %d/%d: high=%d normal=%d low=%d)",
+			job.req.PublicID, priorityNames[job.priority], waiting+1, p.maxQueueDepth, d["high"], d["normal"], d["low"])
+	}
+	return nil
+}
+
+// QueueSettings is the request and reply body of runner.admin.queue.
+type QueueSettings struct {
+	MaxQueueDepth *int `json:"maxQueueDepth,omitempty"`
+	QueueDepth    int  `json:"queueDepth"`
+	// ByPriority breaks the queued jobs down by priority (replies only).
+	ByPriority map[string]int `json:"byPriority,omitempty"`
+	// ByTenant breaks the queued jobs down by tenant (replies only).
+	ByTenant map[string]int `json:"byTenant,omitempty"`
+}
+
+// handleQueueAdmin reports the backlog and, if the body sets maxQueueDepth,
+// changes the rejection threshold at runtime.
+func (r *Runner) handleQueueAdmin(m *nats.Msg) {
+	var req QueueSettings
+	if len(m.Data) > 0 {
+		if err := json.Unmarshal(m.Data, &req); err != nil || req.MaxQueueDepth != nil && *req.MaxQueueDepth < 0 {
+			log.Printf("Bad queue admin data: %s", m.Data)
+			r.respond(m, map[string]string{"error": "invalid queue settings"})
+			return
+		}
+	}
+
+	p := r.pool
+	p.mu.Lock()
+	if req.MaxQueueDepth != nil {
+		log.Printf("[QUEUE] Max queue depth changed from %d to %d", p.maxQueueDepth, *req.MaxQueueDepth)
+		p.maxQueueDepth = *req.MaxQueueDepth
+	}
+	maxDepth := p.maxQueueDepth
+	depth := max(p.queued-p.idle, 0)
+	byPriority := p.depths()
+	byTenant := p.tenantDepths()
+	p.mu.Unlock()
+
+	r.respond(m, QueueSettings{MaxQueueDepth: &maxDepth, QueueDepth: depth, ByPriority: byPriority, ByTenant: byTenant})
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testJob(id, tenant string, priority int) *pendingJob {
+	job := &pendingJob{tenant: tenant, priority: priority}
+	job.req.PublicID = id
+	return job
+}
+
+func equalWeights(string) int { return 1 }
+
+// waitFree waits until n workers are idle with nothing queued for them.
+func waitFree(t *testing.T, p *workerPool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.free() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d workers free, want %d", p.free(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestWorkerPoolSpawnsWorkers checks the pool starts one worker per slot,
+// each with its own slot number, and queues jobs beyond them.
+func TestWorkerPoolSpawnsWorkers(t *testing.T) {
+	const size = 4
+	started := make(chan int, size+1)
+	release := make(chan struct{})
+	p := newWorkerPool(size, 10, time.Minute, equalWeights, func(slot int, job *pendingJob) {
+		started <- slot
+		<-release
+	})
+	waitFree(t, p, size)
+
+	for i := 0; i < size+1; i++ {
+		if err := p.submit(testJob(fmt.Sprint(i), "", priorityNormal), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var slots []int
+	for i := 0; i < size; i++ {
+		select {
+		case slot := <-started:
+			slots = append(slots, slot)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d workers started a job", i, size)
+		}
+	}
+	sort.Ints(slots)
+	for i, slot := range slots {
+		if slot != i {
+			t.Fatalf("slots %v, want 0..%d each once", slots, size-1)
+		}
+	}
+	select {
+	case slot := <-started:
+		t.Fatalf("job %d started on slot %d with every worker busy", size, slot)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if p.free() != 0 {
+		t.Errorf("free = %d with every worker busy", p.free())
+	}
+
+	release <- struct{}{} // one worker finishes and takes the queued job
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued job never started")
+	}
+	close(release)
+	waitFree(t, p, size)
+}
+
+// TestWorkerPoolBacklogLimit checks jobs past the backlog are rejected
+// unless forced.
+func TestWorkerPoolBacklogLimit(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	p := newWorkerPool(1, 2, time.Minute, equalWeights, func(int, *pendingJob) { <-release })
+	waitFree(t, p, 1)
+	for i := 0; i < 3; i++ { // one running, two waiting
+		if err := p.submit(testJob(fmt.Sprint(i), "", priorityNormal), false); err != nil {
+			t.Fatalf("job %d: %v", i, err)
+		}
+	}
+	if err := p.submit(testJob("over", "", priorityNormal), false); !errors.Is(err, errBusy) {
+		t.Errorf("submit past the backlog = %v, want errBusy", err)
+	}
+	if err := p.submit(testJob("forced", "", priorityNormal), true); err != nil {
+		t.Errorf("forced submit = %v", err)
+	}
+}
+
+// TestWorkerPoolRunsConcurrently checks size jobs run at the same time: each
+// waits for all the others to start before it finishes.
+func TestWorkerPoolRunsConcurrently(t *testing.T) {
+	const size = 8
+	var (
+		mu           sync.Mutex
+		running, top int
+		starts, ends []time.Time
+	)
+	var all sync.WaitGroup
+	all.Add(size)
+	done := make(chan struct{}, size)
+	p := newWorkerPool(size, size, time.Minute, equalWeights, func(slot int, job *pendingJob) {
+		mu.Lock()
+		running++
+		top = max(top, running)
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		all.Done()
+		all.Wait()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		ends = append(ends, time.Now())
+		mu.Unlock()
+		done <- struct{}{}
+	})
+	for i := 0; i < size; i++ {
+		if err := p.submit(testJob(fmt.Sprint(i), "", priorityNormal), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < size; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d jobs finished; the rest never ran alongside them", i, size)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if top != size {
+		t.Errorf("at most %d jobs ran at once, want %d", top, size)
+	}
+	latestStart, earliestEnd := starts[0], ends[0]
+	for _, s := range starts {
+		if s.After(latestStart) {
+			latestStart = s
+		}
+	}
+	for _, e := range ends {
+		if e.Before(earliestEnd) {
+			earliestEnd = e
+		}
+	}
+	if !latestStart.Before(earliestEnd) {
+		t.Errorf("last job started at %v, after the first finished at %v", latestStart, earliestEnd)
+	}
+}
+
+// dispatchOrder takes every queued job from a pool without workers and
+// returns their IDs in the order workers would have got them.
+func dispatchOrder(p *workerPool) []string {
+	var ids []string
+	for {
+		p.mu.Lock()
+		queued := p.queued
+		p.mu.Unlock()
+		if queued == 0 {
+			return ids
+		}
+		ids = append(ids, p.next().req.PublicID)
+	}
+}
+
+func TestDispatchTenantFairness(t *testing.T) {
+	p := newWorkerPool(0, 10000, time.Hour, equalWeights, nil)
+	for i := 0; i < 1000; i++ {
+		if err := p.submit(testJob(fmt.Sprintf("heavy-%d", i), "heavy", priorityNormal), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two of the flood go out before the light tenant's jobs arrive.
+	first := []string{p.next().req.PublicID, p.next().req.PublicID}
+	for i := 0; i < 3; i++ {
+		if err := p.submit(testJob(fmt.Sprintf("light-%d", i), "light", priorityNormal), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order := append(first, dispatchOrder(p)...)
+	if len(order) != 1003 {
+		t.Fatalf("dispatched %d jobs, want 1003", len(order))
+	}
+	// From then on the tenants alternate until the light one runs out.
+	want := []string{"heavy-0", "heavy-1", "light-0", "heavy-2", "light-1", "heavy-3", "light-2", "heavy-4", "heavy-5"}
+	for i, id := range want {
+		if order[i] != id {
+			t.Fatalf("order starts %v, want %v", order[:len(want)], want)
+		}
+	}
+}
+
+func TestDispatchTenantWeights(t *testing.T) {
+	weights := map[string]int{"big": 3}
+	p := newWorkerPool(0, 100, time.Hour, func(tenant string) int { return weights[tenant] }, nil)
+	for i := 0; i < 6; i++ {
+		p.submit(testJob(fmt.Sprintf("big-%d", i), "big", priorityNormal), false)
+		p.submit(testJob(fmt.Sprintf("small-%d", i), "small", priorityNormal), false)
+	}
+	got := strings.Join(dispatchOrder(p), " ")
+	// small has no weight set, which counts as 1, so big gets three jobs to
+	// each of small's while both have work queued.
+	want := "small-0 big-0 big-1 big-2 small-1 big-3 big-4 big-5 small-2 small-3 small-4 small-5"
+	if got != want {
+		t.Errorf("order\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDispatchPriorityAging(t *testing.T) {
+	const aging = time.Minute
+	p := newWorkerPool(0, 100, aging, equalWeights, nil)
+	low := testJob("low", "", priorityLow)
+	p.submit(low, false)
+	for i := 0; i < 3; i++ {
+		p.submit(testJob(fmt.Sprintf("high-%d", i), "", priorityHigh), false)
+	}
+	if got := p.next().req.PublicID; got != "high-0" {
+		t.Fatalf("fresh low-priority job went before high: got %s", got)
+	}
+
+	// Waiting two aging intervals lifts it to high; a tie goes to the job
+	// that waited longer.
+	p.mu.Lock()
+	low.enqueued = low.enqueued.Add(-2*aging - time.Second)
+	p.mu.Unlock()
+	logs := captureLog(t)
+	if got := p.next().req.PublicID; got != "low" {
+		t.Fatalf("aged low-priority job not dispatched: got %s", got)
+	}
+	if !strings.Contains(logs.String(), "low (low) dispatched after aging") {
+		t.Errorf("no aging log: %s", logs)
+	}
+	if got := strings.Join(dispatchOrder(p), " "); got != "high-1 high-2" {
+		t.Errorf("rest dispatched as %s", got)
+	}
+}
+
+// TestDispatchNormalAgesPastHigh checks one interval is enough for normal
+// priority to catch up with fresh high-priority work.
+func TestDispatchNormalAgesPastHigh(t *testing.T) {
+	const aging = time.Minute
+	p := newWorkerPool(0, 100, aging, equalWeights, nil)
+	normal := testJob("normal", "", priorityNormal)
+	p.submit(normal, false)
+	p.submit(testJob("high", "", priorityHigh), false)
+	p.mu.Lock()
+	normal.enqueued = normal.enqueued.Add(-aging - time.Second)
+	p.mu.Unlock()
+	if got := strings.Join(dispatchOrder(p), " "); got != "normal high" {
+		t.Errorf("order %s, want normal high", got)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// waitDelay bounds how long Wait keeps reading output after the process group
+// has been killed, in case something outside the group still holds the pipes.
+const waitDelay = 2 * time.Second
+
+// groupTerminator stops a job's process group when its context is done:
+// SIGTERM first so scripts can flush and exit, then SIGKILL once the grace
+// period runs out.
+type groupTerminator struct {
+	cmd    *exec.Cmd
+	grace  time.Duration
+	exited chan struct{}
+
+	signaled atomic.Bool
+	forced   atomic.Bool
+}
+
+// terminateGroupOnCancel wires a groupTerminator into cmd. Call its
+// markExited method once cmd.Wait returns.
+func terminateGroupOnCancel(cmd *exec.Cmd, grace time.Duration) *groupTerminator {
+	t := &groupTerminator{cmd: cmd, grace: grace, exited: make(chan struct{})}
+	newProcessGroup(cmd.SysProcAttr)
+	cmd.Cancel = t.terminate
+	// Must outlast the grace period, or exec would kill just the deno parent first.
+	cmd.WaitDelay = grace + waitDelay
+	return t
+}
+
+func (t *groupTerminator) terminate() error {
+	pid := t.cmd.Process.Pid
+	t.signaled.Store(true)
+	if t.grace <= 0 {
+		t.forced.Store(true)
+		return killProcessGroup(pid, syscall.SIGKILL)
+	}
+	if err := killProcessGroup(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	go func() {
+		timer := time.NewTimer(t.grace)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			t.forced.Store(true)
+			_ = killProcessGroup(pid, syscall.SIGKILL)
+		case <-t.exited:
+		}
+	}()
+	return nil
+}
+
+func (t *groupTerminator) markExited() { close(t.exited) }
+
+// outcome describes how the runner stopped the job: "" if it was never asked
+// to, "graceful" if the script exited within the grace period, "forced" if it
+// had to be SIGKILLed.
+func (t *groupTerminator) outcome() string {
+	switch {
+	case !t.signaled.Load():
+		return ""
+	case t.forced.Load():
+		return "forced"
+	default:
+		return "graceful"
+	}
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// newProcessGroup starts the process attr describes in a process group of
+// its own, whose ID is its PID.
+func newProcessGroup(attr *syscall.SysProcAttr) {
+	attr.Setpgid = true
+}
+
+// killProcessGroup signals every process in the child's process group.
+// Jobs are started with Setpgid, so the group ID equals the deno PID.
+func killProcessGroup(pid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return nil // group already gone
+	}
+	return err
+}
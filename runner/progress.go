@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Heartbeat is published on runner.progress.<publicId> every heartbeat
+// interval while a job's process is running. Seq starts at 1 and has no gaps,
+// so a subscriber can tell when it missed one.
+type Heartbeat struct {
+	PublicID    string `json:"publicId"`
+	Seq         int    `json:"seq"`
+	ElapsedMs   int64  `json:"elapsedMs"`
+	OutputBytes int64  `json:"outputBytes"`
+	Worker      int    `json:"worker"`
+	// Metadata is the request's metadata, unchanged.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// heartbeater publishes heartbeats for one job until stopped.
+type heartbeater struct {
+	stop chan struct{}
+	done chan struct{}
+	sent int
+}
+
+func (r *Runner) startHeartbeats(req *RunRequest, slot int, started time.Time, out *outputCapture) *heartbeater {
+	h := &heartbeater{stop: make(chan struct{}), done: make(chan struct{})}
+	publicID := req.PublicID
+	subject := r.cfg.subjectID("progress", publicID)
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(r.cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+				h.sent++
+				data, _ := json.Marshal(Heartbeat{
+					PublicID:    publicID,
+					Seq:         h.sent,
+					ElapsedMs:   time.Since(started).Milliseconds(),
+					OutputBytes: out.bytes(),
+					Worker:      slot,
+					Metadata:    req.Metadata,
+				})
+				if err := r.nc.Publish(subject, data); err != nil {
+					log.Printf("[PROGRESS] Failed to publish heartbeat for %s: %v", publicID, err)
+				}
+			}
+		}
+	}()
+	return h
+}
+
+// finish stops the heartbeats and returns how many were sent.
+func (h *heartbeater) finish() int {
+	close(h.stop)
+	<-h.done
+	return h.sent
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	runnerpb "runner/proto"
+)
+
+// The protobuf encoding uses the messages generated from proto/runner.proto
+// into runner/proto. A Go type is encoded as the message of the same name,
+// through its JSON form: the proto3 JSON mapping of runner.proto is the
+// runner's JSON protocol, except that Deadline is a time or a number of
+// milliseconds in JSON and a message in protobuf, and int64s are numbers
+// rather than strings. proto_test.go checks the Go types and the messages
+// agree field by field.
+
+// protoPackage is runner.proto's package, which message names are under.
+const protoPackage = "runner.v1"
+
+// protoRoots are the Go types with a protobuf encoding.
+var protoRoots = []any{RunRequest{}, RunResult{}, ScheduleAck{}, SubmitAck{}, OutputChunk{}, Heartbeat{}, WebhookEvent{}}
+
+// protoMessageType returns the generated message type of the named Go type.
+func protoMessageType(name string) (protoreflect.MessageType, bool) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(protoPackage + "." + name))
+	return mt, err == nil
+}
+
+// encodeProto encodes v if its type has a message in runner.proto.
+func encodeProto(v any) ([]byte, bool, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Struct {
+		return nil, false, nil
+	}
+	mt, ok := protoMessageType(rv.Type().Name())
+	if !ok {
+		return nil, false, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, true, err
+	}
+	var obj map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, true, err
+	}
+	protoJSONDeadlines(obj, mt.Descriptor())
+	if data, err = json.Marshal(obj); err != nil {
+		return nil, true, err
+	}
+	msg := mt.New().Interface()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, msg); err != nil {
+		return nil, true, err
+	}
+	data, err = proto.Marshal(msg)
+	return data, true, err
+}
+
+// protoJSONDeadlines rewrites the JSON form of Deadline fields in obj, a
+// message md, into the proto3 JSON form of the Deadline message.
+func protoJSONDeadlines(obj map[string]any, md protoreflect.MessageDescriptor) {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		v, ok := obj[fd.JSONName()]
+		if !ok || fd.Kind() != protoreflect.MessageKind || fd.IsMap() {
+			continue
+		}
+		sub := fd.Message()
+		switch {
+		case sub.Name() == "Deadline":
+			switch d := v.(type) {
+			case string:
+				obj[fd.JSONName()] = map[string]any{"at": d}
+			case json.Number:
+				obj[fd.JSONName()] = map[string]any{"relativeMs": d}
+			}
+		case sub.FullName().Parent() != protoPackage:
+		case fd.IsList():
+			list, _ := v.([]any)
+			for _, e := range list {
+				if o, ok := e.(map[string]any); ok {
+					protoJSONDeadlines(o, sub)
+				}
+			}
+		default:
+			if o, ok := v.(map[string]any); ok {
+				protoJSONDeadlines(o, sub)
+			}
+		}
+	}
+}
+
+// protoToJSON converts a protobuf-encoded message of the named type to the
+// equivalent JSON document.
+func protoToJSON(data []byte, message string) ([]byte, error) {
+	mt, ok := protoMessageType(message)
+	if !ok {
+		return nil, fmt.Errorf("%s has no protobuf encoding", message)
+	}
+	msg := mt.New().Interface()
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("malformed protobuf: %w", err)
+	}
+	return json.Marshal(protoMessageJSON(msg.ProtoReflect()))
+}
+
+// protoMessageJSON returns m's set fields keyed by their JSON names.
+func protoMessageJSON(m protoreflect.Message) map[string]any {
+	obj := map[string]any{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := make([]any, v.List().Len())
+			for i := range list {
+				list[i] = protoValueJSON(fd, v.List().Get(i))
+			}
+			obj[fd.JSONName()] = list
+		case fd.IsMap():
+			entries := map[string]any{}
+			v.Map().Range(func(k protoreflect.MapKey, e protoreflect.Value) bool {
+				entries[k.String()] = protoValueJSON(fd.MapValue(), e)
+				return true
+			})
+			obj[fd.JSONName()] = entries
+		default:
+			obj[fd.JSONName()] = protoValueJSON(fd, v)
+		}
+		return true
+	})
+	return obj
+}
+
+// protoValueJSON returns the JSON form of one value of field fd.
+func protoValueJSON(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	if fd.Kind() != protoreflect.MessageKind {
+		return v.Interface()
+	}
+	switch m := v.Message().Interface().(type) {
+	case *timestamppb.Timestamp:
+		return m.AsTime().Format(time.RFC3339Nano)
+	case *structpb.Value:
+		return m.AsInterface()
+	case *runnerpb.Deadline:
+		if m.At != nil {
+			return m.At.AsTime().Format(time.RFC3339Nano)
+		}
+		return m.RelativeMs
+	}
+	return protoMessageJSON(v.Message())
+}
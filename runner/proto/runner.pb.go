@@ -0,0 +1,2665 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: runner.proto
+
+package runnerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RunRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	V                int32                  `protobuf:"varint,1,opt,name=v,proto3" json:"v,omitempty"`
+	PublicId         string                 `protobuf:"bytes,2,opt,name=public_id,json=publicId,proto3" json:"public_id,omitempty"`
+	Code             string                 `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+	Permissions      []string               `protobuf:"bytes,4,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	TimeoutMs        int64                  `protobuf:"varint,5,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	MaxHeapMb        int32                  `protobuf:"varint,6,opt,name=max_heap_mb,json=maxHeapMb,proto3" json:"max_heap_mb,omitempty"`
+	Tenant           string                 `protobuf:"bytes,7,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	Serialize        bool                   `protobuf:"varint,8,opt,name=serialize,proto3" json:"serialize,omitempty"`
+	Stdin            *string                `protobuf:"bytes,9,opt,name=stdin,proto3,oneof" json:"stdin,omitempty"`
+	StdinEncoding    string                 `protobuf:"bytes,10,opt,name=stdin_encoding,json=stdinEncoding,proto3" json:"stdin_encoding,omitempty"`
+	InteractiveStdin bool                   `protobuf:"varint,11,opt,name=interactive_stdin,json=interactiveStdin,proto3" json:"interactive_stdin,omitempty"`
+	Args             []string               `protobuf:"bytes,12,rep,name=args,proto3" json:"args,omitempty"`
+	Env              map[string]string      `protobuf:"bytes,13,rep,name=env,proto3" json:"env,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Stream           bool                   `protobuf:"varint,14,opt,name=stream,proto3" json:"stream,omitempty"`
+	Timestamps       bool                   `protobuf:"varint,15,opt,name=timestamps,proto3" json:"timestamps,omitempty"`
+	BinaryOutput     bool                   `protobuf:"varint,16,opt,name=binary_output,json=binaryOutput,proto3" json:"binary_output,omitempty"`
+	StripAnsi        *bool                  `protobuf:"varint,17,opt,name=strip_ansi,json=stripAnsi,proto3,oneof" json:"strip_ansi,omitempty"`
+	RunAt            *timestamppb.Timestamp `protobuf:"bytes,18,opt,name=run_at,json=runAt,proto3" json:"run_at,omitempty"`
+	ReplySubject     string                 `protobuf:"bytes,19,opt,name=reply_subject,json=replySubject,proto3" json:"reply_subject,omitempty"`
+	Priority         string                 `protobuf:"bytes,20,opt,name=priority,proto3" json:"priority,omitempty"`
+	Deadline         *Deadline              `protobuf:"bytes,21,opt,name=deadline,proto3" json:"deadline,omitempty"`
+	Cacheable        bool                   `protobuf:"varint,22,opt,name=cacheable,proto3" json:"cacheable,omitempty"`
+	NoCache          bool                   `protobuf:"varint,23,opt,name=no_cache,json=noCache,proto3" json:"no_cache,omitempty"`
+	Coalesce         bool                   `protobuf:"varint,24,opt,name=coalesce,proto3" json:"coalesce,omitempty"`
+	// Contents are base64, as in JSON.
+	Files            map[string]string `protobuf:"bytes,25,rep,name=files,proto3" json:"files,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	CollectArtifacts []string          `protobuf:"bytes,26,rep,name=collect_artifacts,json=collectArtifacts,proto3" json:"collect_artifacts,omitempty"`
+	ReturnValue      bool              `protobuf:"varint,27,opt,name=return_value,json=returnValue,proto3" json:"return_value,omitempty"`
+	Language         string            `protobuf:"bytes,28,opt,name=language,proto3" json:"language,omitempty"`
+	Mode             string            `protobuf:"bytes,29,opt,name=mode,proto3" json:"mode,omitempty"`
+	FmtCheck         bool              `protobuf:"varint,30,opt,name=fmt_check,json=fmtCheck,proto3" json:"fmt_check,omitempty"`
+	Print            bool              `protobuf:"varint,31,opt,name=print,proto3" json:"print,omitempty"`
+	UnstableFeatures []string          `protobuf:"bytes,32,rep,name=unstable_features,json=unstableFeatures,proto3" json:"unstable_features,omitempty"`
+	ImportMap        *structpb.Value   `protobuf:"bytes,33,opt,name=import_map,json=importMap,proto3" json:"import_map,omitempty"`
+	Lockfile         string            `protobuf:"bytes,34,opt,name=lockfile,proto3" json:"lockfile,omitempty"`
+	DenoConfig       *structpb.Value   `protobuf:"bytes,35,opt,name=deno_config,json=denoConfig,proto3" json:"deno_config,omitempty"`
+	Entrypoint       string            `protobuf:"bytes,36,opt,name=entrypoint,proto3" json:"entrypoint,omitempty"`
+	Npm              bool              `protobuf:"varint,37,opt,name=npm,proto3" json:"npm,omitempty"`
+	NodeModulesDir   string            `protobuf:"bytes,38,opt,name=node_modules_dir,json=nodeModulesDir,proto3" json:"node_modules_dir,omitempty"`
+	RemoteImports    *bool             `protobuf:"varint,39,opt,name=remote_imports,json=remoteImports,proto3,oneof" json:"remote_imports,omitempty"`
+	Metadata         map[string]string `protobuf:"bytes,40,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	IdempotencyKey   string            `protobuf:"bytes,41,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	WebhookUrl       string            `protobuf:"bytes,42,opt,name=webhook_url,json=webhookUrl,proto3" json:"webhook_url,omitempty"`
+	Steps            []*Step           `protobuf:"bytes,43,rep,name=steps,proto3" json:"steps,omitempty"`
+	ContinueOnError  bool              `protobuf:"varint,44,opt,name=continue_on_error,json=continueOnError,proto3" json:"continue_on_error,omitempty"`
+	Source           *SourceRef        `protobuf:"bytes,45,opt,name=source,proto3" json:"source,omitempty"`
+	ChunkedReply     bool              `protobuf:"varint,46,opt,name=chunked_reply,json=chunkedReply,proto3" json:"chunked_reply,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *RunRequest) Reset() {
+	*x = RunRequest{}
+	mi := &file_runner_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunRequest) ProtoMessage() {}
+
+func (x *RunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunRequest.ProtoReflect.Descriptor instead.
+func (*RunRequest) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *RunRequest) GetV() int32 {
+	if x != nil {
+		return x.V
+	}
+	return 0
+}
+
+func (x *RunRequest) GetPublicId() string {
+	if x != nil {
+		return x.PublicId
+	}
+	return ""
+}
+
+func (x *RunRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *RunRequest) GetPermissions() []string {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *RunRequest) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *RunRequest) GetMaxHeapMb() int32 {
+	if x != nil {
+		return x.MaxHeapMb
+	}
+	return 0
+}
+
+func (x *RunRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+func (x *RunRequest) GetSerialize() bool {
+	if x != nil {
+		return x.Serialize
+	}
+	return false
+}
+
+func (x *RunRequest) GetStdin() string {
+	if x != nil && x.Stdin != nil {
+		return *x.Stdin
+	}
+	return ""
+}
+
+func (x *RunRequest) GetStdinEncoding() string {
+	if x != nil {
+		return x.StdinEncoding
+	}
+	return ""
+}
+
+func (x *RunRequest) GetInteractiveStdin() bool {
+	if x != nil {
+		return x.InteractiveStdin
+	}
+	return false
+}
+
+func (x *RunRequest) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *RunRequest) GetEnv() map[string]string {
+	if x != nil {
+		return x.Env
+	}
+	return nil
+}
+
+func (x *RunRequest) GetStream() bool {
+	if x != nil {
+		return x.Stream
+	}
+	return false
+}
+
+func (x *RunRequest) GetTimestamps() bool {
+	if x != nil {
+		return x.Timestamps
+	}
+	return false
+}
+
+func (x *RunRequest) GetBinaryOutput() bool {
+	if x != nil {
+		return x.BinaryOutput
+	}
+	return false
+}
+
+func (x *RunRequest) GetStripAnsi() bool {
+	if x != nil && x.StripAnsi != nil {
+		return *x.StripAnsi
+	}
+	return false
+}
+
+func (x *RunRequest) GetRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RunAt
+	}
+	return nil
+}
+
+func (x *RunRequest) GetReplySubject() string {
+	if x != nil {
+		return x.ReplySubject
+	}
+	return ""
+}
+
+func (x *RunRequest) GetPriority() string {
+	if x != nil {
+		return x.Priority
+	}
+	return ""
+}
+
+func (x *RunRequest) GetDeadline() *Deadline {
+	if x != nil {
+		return x.Deadline
+	}
+	return nil
+}
+
+func (x *RunRequest) GetCacheable() bool {
+	if x != nil {
+		return x.Cacheable
+	}
+	return false
+}
+
+func (x *RunRequest) GetNoCache() bool {
+	if x != nil {
+		return x.NoCache
+	}
+	return false
+}
+
+func (x *RunRequest) GetCoalesce() bool {
+	if x != nil {
+		return x.Coalesce
+	}
+	return false
+}
+
+func (x *RunRequest) GetFiles() map[string]string {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *RunRequest) GetCollectArtifacts() []string {
+	if x != nil {
+		return x.CollectArtifacts
+	}
+	return nil
+}
+
+func (x *RunRequest) GetReturnValue() bool {
+	if x != nil {
+		return x.ReturnValue
+	}
+	return false
+}
+
+func (x *RunRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+func (x *RunRequest) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *RunRequest) GetFmtCheck() bool {
+	if x != nil {
+		return x.FmtCheck
+	}
+	return false
+}
+
+func (x *RunRequest) GetPrint() bool {
+	if x != nil {
+		return x.Print
+	}
+	return false
+}
+
+func (x *RunRequest) GetUnstableFeatures() []string {
+	if x != nil {
+		return x.UnstableFeatures
+	}
+	return nil
+}
+
+func (x *RunRequest) GetImportMap() *structpb.Value {
+	if x != nil {
+		return x.ImportMap
+	}
+	return nil
+}
+
+func (x *RunRequest) GetLockfile() string {
+	if x != nil {
+		return x.Lockfile
+	}
+	return ""
+}
+
+func (x *RunRequest) GetDenoConfig() *structpb.Value {
+	if x != nil {
+		return x.DenoConfig
+	}
+	return nil
+}
+
+func (x *RunRequest) GetEntrypoint() string {
+	if x != nil {
+		return x.Entrypoint
+	}
+	return ""
+}
+
+func (x *RunRequest) GetNpm() bool {
+	if x != nil {
+		return x.Npm
+	}
+	return false
+}
+
+func (x *RunRequest) GetNodeModulesDir() string {
+	if x != nil {
+		return x.NodeModulesDir
+	}
+	return ""
+}
+
+func (x *RunRequest) GetRemoteImports() bool {
+	if x != nil && x.RemoteImports != nil {
+		return *x.RemoteImports
+	}
+	return false
+}
+
+func (x *RunRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *RunRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *RunRequest) GetWebhookUrl() string {
+	if x != nil {
+		return x.WebhookUrl
+	}
+	return ""
+}
+
+func (x *RunRequest) GetSteps() []*Step {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *RunRequest) GetContinueOnError() bool {
+	if x != nil {
+		return x.ContinueOnError
+	}
+	return false
+}
+
+func (x *RunRequest) GetSource() *SourceRef {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *RunRequest) GetChunkedReply() bool {
+	if x != nil {
+		return x.ChunkedReply
+	}
+	return false
+}
+
+// Code and files in the object store, as a JSON SourceBundle.
+type SourceRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bucket        string                 `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Digest        string                 `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SourceRef) Reset() {
+	*x = SourceRef{}
+	mi := &file_runner_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SourceRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SourceRef) ProtoMessage() {}
+
+func (x *SourceRef) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SourceRef.ProtoReflect.Descriptor instead.
+func (*SourceRef) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SourceRef) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *SourceRef) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SourceRef) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+type Step struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Code          string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Permissions   []string               `protobuf:"bytes,3,rep,name=permissions,proto3" json:"permissions,omitempty"`
+	TimeoutMs     int64                  `protobuf:"varint,4,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Step) Reset() {
+	*x = Step{}
+	mi := &file_runner_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Step) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Step) ProtoMessage() {}
+
+func (x *Step) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Step.ProtoReflect.Descriptor instead.
+func (*Step) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Step) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Step) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Step) GetPermissions() []string {
+	if x != nil {
+		return x.Permissions
+	}
+	return nil
+}
+
+func (x *Step) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+// Deadline sets one of its fields: an absolute time, or milliseconds from
+// when the runner received the request.
+type Deadline struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	At            *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=at,proto3" json:"at,omitempty"`
+	RelativeMs    int64                  `protobuf:"varint,2,opt,name=relative_ms,json=relativeMs,proto3" json:"relative_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Deadline) Reset() {
+	*x = Deadline{}
+	mi := &file_runner_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Deadline) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Deadline) ProtoMessage() {}
+
+func (x *Deadline) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Deadline.ProtoReflect.Descriptor instead.
+func (*Deadline) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Deadline) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *Deadline) GetRelativeMs() int64 {
+	if x != nil {
+		return x.RelativeMs
+	}
+	return 0
+}
+
+type RunResult struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	V               int32                  `protobuf:"varint,1,opt,name=v,proto3" json:"v,omitempty"`
+	RunnerId        string                 `protobuf:"bytes,2,opt,name=runner_id,json=runnerId,proto3" json:"runner_id,omitempty"`
+	RunnerVersion   string                 `protobuf:"bytes,3,opt,name=runner_version,json=runnerVersion,proto3" json:"runner_version,omitempty"`
+	Output          string                 `protobuf:"bytes,4,opt,name=output,proto3" json:"output,omitempty"`
+	Stdout          string                 `protobuf:"bytes,5,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr          string                 `protobuf:"bytes,6,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	OutputBytes     int64                  `protobuf:"varint,7,opt,name=output_bytes,json=outputBytes,proto3" json:"output_bytes,omitempty"`
+	Truncated       bool                   `protobuf:"varint,8,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	ContentEncoding string                 `protobuf:"bytes,9,opt,name=content_encoding,json=contentEncoding,proto3" json:"content_encoding,omitempty"`
+	BinaryOutput    *BinaryOutput          `protobuf:"bytes,10,opt,name=binary_output,json=binaryOutput,proto3" json:"binary_output,omitempty"`
+	Lines           []*OutputLine          `protobuf:"bytes,11,rep,name=lines,proto3" json:"lines,omitempty"`
+	LinesDropped    int32                  `protobuf:"varint,12,opt,name=lines_dropped,json=linesDropped,proto3" json:"lines_dropped,omitempty"`
+	ExitCode        int32                  `protobuf:"varint,13,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	ExitSignal      *ExitSignal            `protobuf:"bytes,14,opt,name=exit_signal,json=exitSignal,proto3" json:"exit_signal,omitempty"`
+	Error           string                 `protobuf:"bytes,15,opt,name=error,proto3" json:"error,omitempty"`
+	ErrorCode       string                 `protobuf:"bytes,16,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	FieldErrors     []*FieldError          `protobuf:"bytes,17,rep,name=field_errors,json=fieldErrors,proto3" json:"field_errors,omitempty"`
+	Attempts        int32                  `protobuf:"varint,18,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	Warm            bool                   `protobuf:"varint,19,opt,name=warm,proto3" json:"warm,omitempty"`
+	Cached          bool                   `protobuf:"varint,20,opt,name=cached,proto3" json:"cached,omitempty"`
+	Coalesced       bool                   `protobuf:"varint,21,opt,name=coalesced,proto3" json:"coalesced,omitempty"`
+	Termination     string                 `protobuf:"bytes,22,opt,name=termination,proto3" json:"termination,omitempty"`
+	Limits          *Limits                `protobuf:"bytes,23,opt,name=limits,proto3" json:"limits,omitempty"`
+	StartedAt       *timestamppb.Timestamp `protobuf:"bytes,24,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt      *timestamppb.Timestamp `protobuf:"bytes,25,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	DurationMs      int64                  `protobuf:"varint,26,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	QueuedMs        int64                  `protobuf:"varint,27,opt,name=queued_ms,json=queuedMs,proto3" json:"queued_ms,omitempty"`
+	UserCpuMs       int64                  `protobuf:"varint,28,opt,name=user_cpu_ms,json=userCpuMs,proto3" json:"user_cpu_ms,omitempty"`
+	SystemCpuMs     int64                  `protobuf:"varint,29,opt,name=system_cpu_ms,json=systemCpuMs,proto3" json:"system_cpu_ms,omitempty"`
+	Heartbeats      int32                  `protobuf:"varint,30,opt,name=heartbeats,proto3" json:"heartbeats,omitempty"`
+	PeakRssBytes    int64                  `protobuf:"varint,31,opt,name=peak_rss_bytes,json=peakRssBytes,proto3" json:"peak_rss_bytes,omitempty"`
+	DiskUsageBytes  int64                  `protobuf:"varint,32,opt,name=disk_usage_bytes,json=diskUsageBytes,proto3" json:"disk_usage_bytes,omitempty"`
+	DiskQuotaBytes  int64                  `protobuf:"varint,33,opt,name=disk_quota_bytes,json=diskQuotaBytes,proto3" json:"disk_quota_bytes,omitempty"`
+	Artifacts       []*Artifact            `protobuf:"bytes,34,rep,name=artifacts,proto3" json:"artifacts,omitempty"`
+	Value           *structpb.Value        `protobuf:"bytes,35,opt,name=value,proto3" json:"value,omitempty"`
+	ValueError      string                 `protobuf:"bytes,36,opt,name=value_error,json=valueError,proto3" json:"value_error,omitempty"`
+	Tests           *TestReport            `protobuf:"bytes,37,opt,name=tests,proto3" json:"tests,omitempty"`
+	Diagnostics     []*Diagnostic          `protobuf:"bytes,38,rep,name=diagnostics,proto3" json:"diagnostics,omitempty"`
+	Formatted       string                 `protobuf:"bytes,39,opt,name=formatted,proto3" json:"formatted,omitempty"`
+	IsFormatted     *bool                  `protobuf:"varint,40,opt,name=is_formatted,json=isFormatted,proto3,oneof" json:"is_formatted,omitempty"`
+	Benchmarks      []*Benchmark           `protobuf:"bytes,41,rep,name=benchmarks,proto3" json:"benchmarks,omitempty"`
+	Metadata        map[string]string      `protobuf:"bytes,42,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Replayed        bool                   `protobuf:"varint,43,opt,name=replayed,proto3" json:"replayed,omitempty"`
+	PublicId        string                 `protobuf:"bytes,44,opt,name=public_id,json=publicId,proto3" json:"public_id,omitempty"`
+	Steps           []*RunResult           `protobuf:"bytes,45,rep,name=steps,proto3" json:"steps,omitempty"`
+	FullResultBytes int64                  `protobuf:"varint,46,opt,name=full_result_bytes,json=fullResultBytes,proto3" json:"full_result_bytes,omitempty"`
+	Offloaded       *OffloadedResult       `protobuf:"bytes,47,opt,name=offloaded,proto3" json:"offloaded,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *RunResult) Reset() {
+	*x = RunResult{}
+	mi := &file_runner_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunResult) ProtoMessage() {}
+
+func (x *RunResult) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunResult.ProtoReflect.Descriptor instead.
+func (*RunResult) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RunResult) GetV() int32 {
+	if x != nil {
+		return x.V
+	}
+	return 0
+}
+
+func (x *RunResult) GetRunnerId() string {
+	if x != nil {
+		return x.RunnerId
+	}
+	return ""
+}
+
+func (x *RunResult) GetRunnerVersion() string {
+	if x != nil {
+		return x.RunnerVersion
+	}
+	return ""
+}
+
+func (x *RunResult) GetOutput() string {
+	if x != nil {
+		return x.Output
+	}
+	return ""
+}
+
+func (x *RunResult) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *RunResult) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+func (x *RunResult) GetOutputBytes() int64 {
+	if x != nil {
+		return x.OutputBytes
+	}
+	return 0
+}
+
+func (x *RunResult) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *RunResult) GetContentEncoding() string {
+	if x != nil {
+		return x.ContentEncoding
+	}
+	return ""
+}
+
+func (x *RunResult) GetBinaryOutput() *BinaryOutput {
+	if x != nil {
+		return x.BinaryOutput
+	}
+	return nil
+}
+
+func (x *RunResult) GetLines() []*OutputLine {
+	if x != nil {
+		return x.Lines
+	}
+	return nil
+}
+
+func (x *RunResult) GetLinesDropped() int32 {
+	if x != nil {
+		return x.LinesDropped
+	}
+	return 0
+}
+
+func (x *RunResult) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *RunResult) GetExitSignal() *ExitSignal {
+	if x != nil {
+		return x.ExitSignal
+	}
+	return nil
+}
+
+func (x *RunResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *RunResult) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *RunResult) GetFieldErrors() []*FieldError {
+	if x != nil {
+		return x.FieldErrors
+	}
+	return nil
+}
+
+func (x *RunResult) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *RunResult) GetWarm() bool {
+	if x != nil {
+		return x.Warm
+	}
+	return false
+}
+
+func (x *RunResult) GetCached() bool {
+	if x != nil {
+		return x.Cached
+	}
+	return false
+}
+
+func (x *RunResult) GetCoalesced() bool {
+	if x != nil {
+		return x.Coalesced
+	}
+	return false
+}
+
+func (x *RunResult) GetTermination() string {
+	if x != nil {
+		return x.Termination
+	}
+	return ""
+}
+
+func (x *RunResult) GetLimits() *Limits {
+	if x != nil {
+		return x.Limits
+	}
+	return nil
+}
+
+func (x *RunResult) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *RunResult) GetFinishedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return nil
+}
+
+func (x *RunResult) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *RunResult) GetQueuedMs() int64 {
+	if x != nil {
+		return x.QueuedMs
+	}
+	return 0
+}
+
+func (x *RunResult) GetUserCpuMs() int64 {
+	if x != nil {
+		return x.UserCpuMs
+	}
+	return 0
+}
+
+func (x *RunResult) GetSystemCpuMs() int64 {
+	if x != nil {
+		return x.SystemCpuMs
+	}
+	return 0
+}
+
+func (x *RunResult) GetHeartbeats() int32 {
+	if x != nil {
+		return x.Heartbeats
+	}
+	return 0
+}
+
+func (x *RunResult) GetPeakRssBytes() int64 {
+	if x != nil {
+		return x.PeakRssBytes
+	}
+	return 0
+}
+
+func (x *RunResult) GetDiskUsageBytes() int64 {
+	if x != nil {
+		return x.DiskUsageBytes
+	}
+	return 0
+}
+
+func (x *RunResult) GetDiskQuotaBytes() int64 {
+	if x != nil {
+		return x.DiskQuotaBytes
+	}
+	return 0
+}
+
+func (x *RunResult) GetArtifacts() []*Artifact {
+	if x != nil {
+		return x.Artifacts
+	}
+	return nil
+}
+
+func (x *RunResult) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *RunResult) GetValueError() string {
+	if x != nil {
+		return x.ValueError
+	}
+	return ""
+}
+
+func (x *RunResult) GetTests() *TestReport {
+	if x != nil {
+		return x.Tests
+	}
+	return nil
+}
+
+func (x *RunResult) GetDiagnostics() []*Diagnostic {
+	if x != nil {
+		return x.Diagnostics
+	}
+	return nil
+}
+
+func (x *RunResult) GetFormatted() string {
+	if x != nil {
+		return x.Formatted
+	}
+	return ""
+}
+
+func (x *RunResult) GetIsFormatted() bool {
+	if x != nil && x.IsFormatted != nil {
+		return *x.IsFormatted
+	}
+	return false
+}
+
+func (x *RunResult) GetBenchmarks() []*Benchmark {
+	if x != nil {
+		return x.Benchmarks
+	}
+	return nil
+}
+
+func (x *RunResult) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *RunResult) GetReplayed() bool {
+	if x != nil {
+		return x.Replayed
+	}
+	return false
+}
+
+func (x *RunResult) GetPublicId() string {
+	if x != nil {
+		return x.PublicId
+	}
+	return ""
+}
+
+func (x *RunResult) GetSteps() []*RunResult {
+	if x != nil {
+		return x.Steps
+	}
+	return nil
+}
+
+func (x *RunResult) GetFullResultBytes() int64 {
+	if x != nil {
+		return x.FullResultBytes
+	}
+	return 0
+}
+
+func (x *RunResult) GetOffloaded() *OffloadedResult {
+	if x != nil {
+		return x.Offloaded
+	}
+	return nil
+}
+
+// Where the full result of a reply too big for a NATS message is.
+type OffloadedResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bucket        string                 `protobuf:"bytes,1,opt,name=bucket,proto3" json:"bucket,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	Digest        string                 `protobuf:"bytes,4,opt,name=digest,proto3" json:"digest,omitempty"`
+	ContentType   string                 `protobuf:"bytes,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OffloadedResult) Reset() {
+	*x = OffloadedResult{}
+	mi := &file_runner_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OffloadedResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OffloadedResult) ProtoMessage() {}
+
+func (x *OffloadedResult) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OffloadedResult.ProtoReflect.Descriptor instead.
+func (*OffloadedResult) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *OffloadedResult) GetBucket() string {
+	if x != nil {
+		return x.Bucket
+	}
+	return ""
+}
+
+func (x *OffloadedResult) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *OffloadedResult) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *OffloadedResult) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
+func (x *OffloadedResult) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+// Benchmark times are nanoseconds per iteration.
+type Benchmark struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Group         string                 `protobuf:"bytes,2,opt,name=group,proto3" json:"group,omitempty"`
+	N             int64                  `protobuf:"varint,3,opt,name=n,proto3" json:"n,omitempty"`
+	AvgNs         float64                `protobuf:"fixed64,4,opt,name=avg_ns,json=avgNs,proto3" json:"avg_ns,omitempty"`
+	MinNs         float64                `protobuf:"fixed64,5,opt,name=min_ns,json=minNs,proto3" json:"min_ns,omitempty"`
+	MaxNs         float64                `protobuf:"fixed64,6,opt,name=max_ns,json=maxNs,proto3" json:"max_ns,omitempty"`
+	P75Ns         float64                `protobuf:"fixed64,7,opt,name=p75_ns,json=p75Ns,proto3" json:"p75_ns,omitempty"`
+	P99Ns         float64                `protobuf:"fixed64,8,opt,name=p99_ns,json=p99Ns,proto3" json:"p99_ns,omitempty"`
+	Error         string                 `protobuf:"bytes,9,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Benchmark) Reset() {
+	*x = Benchmark{}
+	mi := &file_runner_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Benchmark) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Benchmark) ProtoMessage() {}
+
+func (x *Benchmark) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Benchmark.ProtoReflect.Descriptor instead.
+func (*Benchmark) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Benchmark) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Benchmark) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *Benchmark) GetN() int64 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+func (x *Benchmark) GetAvgNs() float64 {
+	if x != nil {
+		return x.AvgNs
+	}
+	return 0
+}
+
+func (x *Benchmark) GetMinNs() float64 {
+	if x != nil {
+		return x.MinNs
+	}
+	return 0
+}
+
+func (x *Benchmark) GetMaxNs() float64 {
+	if x != nil {
+		return x.MaxNs
+	}
+	return 0
+}
+
+func (x *Benchmark) GetP75Ns() float64 {
+	if x != nil {
+		return x.P75Ns
+	}
+	return 0
+}
+
+func (x *Benchmark) GetP99Ns() float64 {
+	if x != nil {
+		return x.P99Ns
+	}
+	return 0
+}
+
+func (x *Benchmark) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type Diagnostic struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	File          string                 `protobuf:"bytes,1,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	Column        int32                  `protobuf:"varint,3,opt,name=column,proto3" json:"column,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	Code          string                 `protobuf:"bytes,5,opt,name=code,proto3" json:"code,omitempty"`
+	EndLine       int32                  `protobuf:"varint,6,opt,name=end_line,json=endLine,proto3" json:"end_line,omitempty"`
+	EndColumn     int32                  `protobuf:"varint,7,opt,name=end_column,json=endColumn,proto3" json:"end_column,omitempty"`
+	Hint          string                 `protobuf:"bytes,8,opt,name=hint,proto3" json:"hint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Diagnostic) Reset() {
+	*x = Diagnostic{}
+	mi := &file_runner_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Diagnostic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Diagnostic) ProtoMessage() {}
+
+func (x *Diagnostic) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Diagnostic.ProtoReflect.Descriptor instead.
+func (*Diagnostic) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Diagnostic) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetEndLine() int32 {
+	if x != nil {
+		return x.EndLine
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetEndColumn() int32 {
+	if x != nil {
+		return x.EndColumn
+	}
+	return 0
+}
+
+func (x *Diagnostic) GetHint() string {
+	if x != nil {
+		return x.Hint
+	}
+	return ""
+}
+
+type TestReport struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int32                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	Passed        int32                  `protobuf:"varint,2,opt,name=passed,proto3" json:"passed,omitempty"`
+	Failed        int32                  `protobuf:"varint,3,opt,name=failed,proto3" json:"failed,omitempty"`
+	Ignored       int32                  `protobuf:"varint,4,opt,name=ignored,proto3" json:"ignored,omitempty"`
+	Failures      []*TestFailure         `protobuf:"bytes,5,rep,name=failures,proto3" json:"failures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TestReport) Reset() {
+	*x = TestReport{}
+	mi := &file_runner_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TestReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestReport) ProtoMessage() {}
+
+func (x *TestReport) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestReport.ProtoReflect.Descriptor instead.
+func (*TestReport) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TestReport) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *TestReport) GetPassed() int32 {
+	if x != nil {
+		return x.Passed
+	}
+	return 0
+}
+
+func (x *TestReport) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+func (x *TestReport) GetIgnored() int32 {
+	if x != nil {
+		return x.Ignored
+	}
+	return 0
+}
+
+func (x *TestReport) GetFailures() []*TestFailure {
+	if x != nil {
+		return x.Failures
+	}
+	return nil
+}
+
+type TestFailure struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	File          string                 `protobuf:"bytes,2,opt,name=file,proto3" json:"file,omitempty"`
+	Line          int32                  `protobuf:"varint,3,opt,name=line,proto3" json:"line,omitempty"`
+	Message       string                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TestFailure) Reset() {
+	*x = TestFailure{}
+	mi := &file_runner_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TestFailure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TestFailure) ProtoMessage() {}
+
+func (x *TestFailure) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TestFailure.ProtoReflect.Descriptor instead.
+func (*TestFailure) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TestFailure) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TestFailure) GetFile() string {
+	if x != nil {
+		return x.File
+	}
+	return ""
+}
+
+func (x *TestFailure) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *TestFailure) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type BinaryOutput struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Encoding      string                 `protobuf:"bytes,1,opt,name=encoding,proto3" json:"encoding,omitempty"`
+	Stdout        string                 `protobuf:"bytes,2,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr        string                 `protobuf:"bytes,3,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BinaryOutput) Reset() {
+	*x = BinaryOutput{}
+	mi := &file_runner_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BinaryOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BinaryOutput) ProtoMessage() {}
+
+func (x *BinaryOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BinaryOutput.ProtoReflect.Descriptor instead.
+func (*BinaryOutput) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *BinaryOutput) GetEncoding() string {
+	if x != nil {
+		return x.Encoding
+	}
+	return ""
+}
+
+func (x *BinaryOutput) GetStdout() string {
+	if x != nil {
+		return x.Stdout
+	}
+	return ""
+}
+
+func (x *BinaryOutput) GetStderr() string {
+	if x != nil {
+		return x.Stderr
+	}
+	return ""
+}
+
+type OutputLine struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	T             *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=t,proto3" json:"t,omitempty"`
+	Stream        string                 `protobuf:"bytes,2,opt,name=stream,proto3" json:"stream,omitempty"`
+	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputLine) Reset() {
+	*x = OutputLine{}
+	mi := &file_runner_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputLine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputLine) ProtoMessage() {}
+
+func (x *OutputLine) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputLine.ProtoReflect.Descriptor instead.
+func (*OutputLine) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *OutputLine) GetT() *timestamppb.Timestamp {
+	if x != nil {
+		return x.T
+	}
+	return nil
+}
+
+func (x *OutputLine) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *OutputLine) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type ExitSignal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Number        int32                  `protobuf:"varint,2,opt,name=number,proto3" json:"number,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExitSignal) Reset() {
+	*x = ExitSignal{}
+	mi := &file_runner_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExitSignal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExitSignal) ProtoMessage() {}
+
+func (x *ExitSignal) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExitSignal.ProtoReflect.Descriptor instead.
+func (*ExitSignal) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ExitSignal) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ExitSignal) GetNumber() int32 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+type FieldError struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FieldError) Reset() {
+	*x = FieldError{}
+	mi := &file_runner_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FieldError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldError) ProtoMessage() {}
+
+func (x *FieldError) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldError.ProtoReflect.Descriptor instead.
+func (*FieldError) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FieldError) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *FieldError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type Limits struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	TimeoutMs       int64                  `protobuf:"varint,1,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	HeapMb          int32                  `protobuf:"varint,2,opt,name=heap_mb,json=heapMb,proto3" json:"heap_mb,omitempty"`
+	MemoryBytes     int64                  `protobuf:"varint,3,opt,name=memory_bytes,json=memoryBytes,proto3" json:"memory_bytes,omitempty"`
+	CpuMs           int64                  `protobuf:"varint,4,opt,name=cpu_ms,json=cpuMs,proto3" json:"cpu_ms,omitempty"`
+	Processes       int32                  `protobuf:"varint,5,opt,name=processes,proto3" json:"processes,omitempty"`
+	DiskQuotaBytes  int64                  `protobuf:"varint,6,opt,name=disk_quota_bytes,json=diskQuotaBytes,proto3" json:"disk_quota_bytes,omitempty"`
+	OutputBytes     int64                  `protobuf:"varint,7,opt,name=output_bytes,json=outputBytes,proto3" json:"output_bytes,omitempty"`
+	OutputRateBytes int64                  `protobuf:"varint,8,opt,name=output_rate_bytes,json=outputRateBytes,proto3" json:"output_rate_bytes,omitempty"`
+	MaxConcurrent   int32                  `protobuf:"varint,9,opt,name=max_concurrent,json=maxConcurrent,proto3" json:"max_concurrent,omitempty"`
+	QueuePosition   int32                  `protobuf:"varint,10,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`
+	Hit             []string               `protobuf:"bytes,11,rep,name=hit,proto3" json:"hit,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Limits) Reset() {
+	*x = Limits{}
+	mi := &file_runner_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Limits) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Limits) ProtoMessage() {}
+
+func (x *Limits) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Limits.ProtoReflect.Descriptor instead.
+func (*Limits) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Limits) GetTimeoutMs() int64 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+func (x *Limits) GetHeapMb() int32 {
+	if x != nil {
+		return x.HeapMb
+	}
+	return 0
+}
+
+func (x *Limits) GetMemoryBytes() int64 {
+	if x != nil {
+		return x.MemoryBytes
+	}
+	return 0
+}
+
+func (x *Limits) GetCpuMs() int64 {
+	if x != nil {
+		return x.CpuMs
+	}
+	return 0
+}
+
+func (x *Limits) GetProcesses() int32 {
+	if x != nil {
+		return x.Processes
+	}
+	return 0
+}
+
+func (x *Limits) GetDiskQuotaBytes() int64 {
+	if x != nil {
+		return x.DiskQuotaBytes
+	}
+	return 0
+}
+
+func (x *Limits) GetOutputBytes() int64 {
+	if x != nil {
+		return x.OutputBytes
+	}
+	return 0
+}
+
+func (x *Limits) GetOutputRateBytes() int64 {
+	if x != nil {
+		return x.OutputRateBytes
+	}
+	return 0
+}
+
+func (x *Limits) GetMaxConcurrent() int32 {
+	if x != nil {
+		return x.MaxConcurrent
+	}
+	return 0
+}
+
+func (x *Limits) GetQueuePosition() int32 {
+	if x != nil {
+		return x.QueuePosition
+	}
+	return 0
+}
+
+func (x *Limits) GetHit() []string {
+	if x != nil {
+		return x.Hit
+	}
+	return nil
+}
+
+type Artifact struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Skipped       string                 `protobuf:"bytes,4,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Artifact) Reset() {
+	*x = Artifact{}
+	mi := &file_runner_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Artifact) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Artifact) ProtoMessage() {}
+
+func (x *Artifact) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Artifact.ProtoReflect.Descriptor instead.
+func (*Artifact) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Artifact) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Artifact) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Artifact) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *Artifact) GetSkipped() string {
+	if x != nil {
+		return x.Skipped
+	}
+	return ""
+}
+
+// The reply to a request with runAt set.
+type ScheduleAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PublicId      string                 `protobuf:"bytes,1,opt,name=public_id,json=publicId,proto3" json:"public_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	RunAt         *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=run_at,json=runAt,proto3" json:"run_at,omitempty"`
+	ReplySubject  string                 `protobuf:"bytes,4,opt,name=reply_subject,json=replySubject,proto3" json:"reply_subject,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScheduleAck) Reset() {
+	*x = ScheduleAck{}
+	mi := &file_runner_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScheduleAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScheduleAck) ProtoMessage() {}
+
+func (x *ScheduleAck) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScheduleAck.ProtoReflect.Descriptor instead.
+func (*ScheduleAck) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ScheduleAck) GetPublicId() string {
+	if x != nil {
+		return x.PublicId
+	}
+	return ""
+}
+
+func (x *ScheduleAck) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ScheduleAck) GetRunAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RunAt
+	}
+	return nil
+}
+
+func (x *ScheduleAck) GetReplySubject() string {
+	if x != nil {
+		return x.ReplySubject
+	}
+	return ""
+}
+
+func (x *ScheduleAck) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+// The reply to a request with replySubject and no runAt.
+type SubmitAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PublicId      string                 `protobuf:"bytes,1,opt,name=public_id,json=publicId,proto3" json:"public_id,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	ReplySubject  string                 `protobuf:"bytes,3,opt,name=reply_subject,json=replySubject,proto3" json:"reply_subject,omitempty"`
+	QueuePosition int32                  `protobuf:"varint,4,opt,name=queue_position,json=queuePosition,proto3" json:"queue_position,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,5,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitAck) Reset() {
+	*x = SubmitAck{}
+	mi := &file_runner_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitAck) ProtoMessage() {}
+
+func (x *SubmitAck) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitAck.ProtoReflect.Descriptor instead.
+func (*SubmitAck) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *SubmitAck) GetPublicId() string {
+	if x != nil {
+		return x.PublicId
+	}
+	return ""
+}
+
+func (x *SubmitAck) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *SubmitAck) GetReplySubject() string {
+	if x != nil {
+		return x.ReplySubject
+	}
+	return ""
+}
+
+func (x *SubmitAck) GetQueuePosition() int32 {
+	if x != nil {
+		return x.QueuePosition
+	}
+	return 0
+}
+
+func (x *SubmitAck) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type OutputChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stream        string                 `protobuf:"bytes,1,opt,name=stream,proto3" json:"stream,omitempty"`
+	Seq           uint64                 `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	T             *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=t,proto3" json:"t,omitempty"`
+	Data          string                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Eof           bool                   `protobuf:"varint,5,opt,name=eof,proto3" json:"eof,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputChunk) Reset() {
+	*x = OutputChunk{}
+	mi := &file_runner_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputChunk) ProtoMessage() {}
+
+func (x *OutputChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputChunk.ProtoReflect.Descriptor instead.
+func (*OutputChunk) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *OutputChunk) GetStream() string {
+	if x != nil {
+		return x.Stream
+	}
+	return ""
+}
+
+func (x *OutputChunk) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *OutputChunk) GetT() *timestamppb.Timestamp {
+	if x != nil {
+		return x.T
+	}
+	return nil
+}
+
+func (x *OutputChunk) GetData() string {
+	if x != nil {
+		return x.Data
+	}
+	return ""
+}
+
+func (x *OutputChunk) GetEof() bool {
+	if x != nil {
+		return x.Eof
+	}
+	return false
+}
+
+type WebhookEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PublicId      string                 `protobuf:"bytes,1,opt,name=public_id,json=publicId,proto3" json:"public_id,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Status        string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Attempts      int32                  `protobuf:"varint,4,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	StatusCode    int32                  `protobuf:"varint,5,opt,name=status_code,json=statusCode,proto3" json:"status_code,omitempty"`
+	Error         string                 `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WebhookEvent) Reset() {
+	*x = WebhookEvent{}
+	mi := &file_runner_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebhookEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebhookEvent) ProtoMessage() {}
+
+func (x *WebhookEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebhookEvent.ProtoReflect.Descriptor instead.
+func (*WebhookEvent) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *WebhookEvent) GetPublicId() string {
+	if x != nil {
+		return x.PublicId
+	}
+	return ""
+}
+
+func (x *WebhookEvent) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *WebhookEvent) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *WebhookEvent) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+func (x *WebhookEvent) GetStatusCode() int32 {
+	if x != nil {
+		return x.StatusCode
+	}
+	return 0
+}
+
+func (x *WebhookEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *WebhookEvent) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type Heartbeat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PublicId      string                 `protobuf:"bytes,1,opt,name=public_id,json=publicId,proto3" json:"public_id,omitempty"`
+	Seq           int32                  `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`
+	ElapsedMs     int64                  `protobuf:"varint,3,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	OutputBytes   int64                  `protobuf:"varint,4,opt,name=output_bytes,json=outputBytes,proto3" json:"output_bytes,omitempty"`
+	Worker        int32                  `protobuf:"varint,5,opt,name=worker,proto3" json:"worker,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
+	mi := &file_runner_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Heartbeat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Heartbeat) ProtoMessage() {}
+
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
+	mi := &file_runner_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
+	return file_runner_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Heartbeat) GetPublicId() string {
+	if x != nil {
+		return x.PublicId
+	}
+	return ""
+}
+
+func (x *Heartbeat) GetSeq() int32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetOutputBytes() int64 {
+	if x != nil {
+		return x.OutputBytes
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetWorker() int32 {
+	if x != nil {
+		return x.Worker
+	}
+	return 0
+}
+
+func (x *Heartbeat) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+var File_runner_proto protoreflect.FileDescriptor
+
+const file_runner_proto_rawDesc = "" +
+	"\n" +
+	"\frunner.proto\x12\trunner.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xca\x0e\n" +
+	"\n" +
+	"RunRequest\x12\f\n" +
+	"\x01v\x18\x01 \x01(\x05R\x01v\x12\x1b\n" +
+	"\tpublic_id\x18\x02 \x01(\tR\bpublicId\x12\x12\n" +
+	"\x04code\x18\x03 \x01(\tR\x04code\x12 \n" +
+	"\vpermissions\x18\x04 \x03(\tR\vpermissions\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x05 \x01(\x03R\ttimeoutMs\x12\x1e\n" +
+	"\vmax_heap_mb\x18\x06 \x01(\x05R\tmaxHeapMb\x12\x16\n" +
+	"\x06tenant\x18\a \x01(\tR\x06tenant\x12\x1c\n" +
+	"\tserialize\x18\b \x01(\bR\tserialize\x12\x19\n" +
+	"\x05stdin\x18\t \x01(\tH\x00R\x05stdin\x88\x01\x01\x12%\n" +
+	"\x0estdin_encoding\x18\n" +
+	" \x01(\tR\rstdinEncoding\x12+\n" +
+	"\x11interactive_stdin\x18\v \x01(\bR\x10interactiveStdin\x12\x12\n" +
+	"\x04args\x18\f \x03(\tR\x04args\x120\n" +
+	"\x03env\x18\r \x03(\v2\x1e.runner.v1.RunRequest.EnvEntryR\x03env\x12\x16\n" +
+	"\x06stream\x18\x0e \x01(\bR\x06stream\x12\x1e\n" +
+	"\n" +
+	"timestamps\x18\x0f \x01(\bR\n" +
+	"timestamps\x12#\n" +
+	"\rbinary_output\x18\x10 \x01(\bR\fbinaryOutput\x12\"\n" +
+	"\n" +
+	"strip_ansi\x18\x11 \x01(\bH\x01R\tstripAnsi\x88\x01\x01\x121\n" +
+	"\x06run_at\x18\x12 \x01(\v2\x1a.google.protobuf.TimestampR\x05runAt\x12#\n" +
+	"\rreply_subject\x18\x13 \x01(\tR\freplySubject\x12\x1a\n" +
+	"\bpriority\x18\x14 \x01(\tR\bpriority\x12/\n" +
+	"\bdeadline\x18\x15 \x01(\v2\x13.runner.v1.DeadlineR\bdeadline\x12\x1c\n" +
+	"\tcacheable\x18\x16 \x01(\bR\tcacheable\x12\x19\n" +
+	"\bno_cache\x18\x17 \x01(\bR\anoCache\x12\x1a\n" +
+	"\bcoalesce\x18\x18 \x01(\bR\bcoalesce\x126\n" +
+	"\x05files\x18\x19 \x03(\v2 .runner.v1.RunRequest.FilesEntryR\x05files\x12+\n" +
+	"\x11collect_artifacts\x18\x1a \x03(\tR\x10collectArtifacts\x12!\n" +
+	"\freturn_value\x18\x1b \x01(\bR\vreturnValue\x12\x1a\n" +
+	"\blanguage\x18\x1c \x01(\tR\blanguage\x12\x12\n" +
+	"\x04mode\x18\x1d \x01(\tR\x04mode\x12\x1b\n" +
+	"\tfmt_check\x18\x1e \x01(\bR\bfmtCheck\x12\x14\n" +
+	"\x05print\x18\x1f \x01(\bR\x05print\x12+\n" +
+	"\x11unstable_features\x18  \x03(\tR\x10unstableFeatures\x125\n" +
+	"\n" +
+	"import_map\x18! \x01(\v2\x16.google.protobuf.ValueR\timportMap\x12\x1a\n" +
+	"\blockfile\x18\" \x01(\tR\blockfile\x127\n" +
+	"\vdeno_config\x18# \x01(\v2\x16.google.protobuf.ValueR\n" +
+	"denoConfig\x12\x1e\n" +
+	"\n" +
+	"entrypoint\x18$ \x01(\tR\n" +
+	"entrypoint\x12\x10\n" +
+	"\x03npm\x18% \x01(\bR\x03npm\x12(\n" +
+	"\x10node_modules_dir\x18& \x01(\tR\x0enodeModulesDir\x12*\n" +
+	"\x0eremote_imports\x18' \x01(\bH\x02R\rremoteImports\x88\x01\x01\x12?\n" +
+	"\bmetadata\x18( \x03(\v2#.runner.v1.RunRequest.MetadataEntryR\bmetadata\x12'\n" +
+	"\x0fidempotency_key\x18) \x01(\tR\x0eidempotencyKey\x12\x1f\n" +
+	"\vwebhook_url\x18* \x01(\tR\n" +
+	"webhookUrl\x12%\n" +
+	"\x05steps\x18+ \x03(\v2\x0f.runner.v1.StepR\x05steps\x12*\n" +
+	"\x11continue_on_error\x18, \x01(\bR\x0fcontinueOnError\x12,\n" +
+	"\x06source\x18- \x01(\v2\x14.runner.v1.SourceRefR\x06source\x12#\n" +
+	"\rchunked_reply\x18. \x01(\bR\fchunkedReply\x1a6\n" +
+	"\bEnvEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a8\n" +
+	"\n" +
+	"FilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06_stdinB\r\n" +
+	"\v_strip_ansiB\x11\n" +
+	"\x0f_remote_imports\"O\n" +
+	"\tSourceRef\x12\x16\n" +
+	"\x06bucket\x18\x01 \x01(\tR\x06bucket\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06digest\x18\x03 \x01(\tR\x06digest\"o\n" +
+	"\x04Step\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12 \n" +
+	"\vpermissions\x18\x03 \x03(\tR\vpermissions\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x04 \x01(\x03R\ttimeoutMs\"W\n" +
+	"\bDeadline\x12*\n" +
+	"\x02at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12\x1f\n" +
+	"\vrelative_ms\x18\x02 \x01(\x03R\n" +
+	"relativeMs\"\xe9\x0e\n" +
+	"\tRunResult\x12\f\n" +
+	"\x01v\x18\x01 \x01(\x05R\x01v\x12\x1b\n" +
+	"\trunner_id\x18\x02 \x01(\tR\brunnerId\x12%\n" +
+	"\x0erunner_version\x18\x03 \x01(\tR\rrunnerVersion\x12\x16\n" +
+	"\x06output\x18\x04 \x01(\tR\x06output\x12\x16\n" +
+	"\x06stdout\x18\x05 \x01(\tR\x06stdout\x12\x16\n" +
+	"\x06stderr\x18\x06 \x01(\tR\x06stderr\x12!\n" +
+	"\foutput_bytes\x18\a \x01(\x03R\voutputBytes\x12\x1c\n" +
+	"\ttruncated\x18\b \x01(\bR\ttruncated\x12)\n" +
+	"\x10content_encoding\x18\t \x01(\tR\x0fcontentEncoding\x12<\n" +
+	"\rbinary_output\x18\n" +
+	" \x01(\v2\x17.runner.v1.BinaryOutputR\fbinaryOutput\x12+\n" +
+	"\x05lines\x18\v \x03(\v2\x15.runner.v1.OutputLineR\x05lines\x12#\n" +
+	"\rlines_dropped\x18\f \x01(\x05R\flinesDropped\x12\x1b\n" +
+	"\texit_code\x18\r \x01(\x05R\bexitCode\x126\n" +
+	"\vexit_signal\x18\x0e \x01(\v2\x15.runner.v1.ExitSignalR\n" +
+	"exitSignal\x12\x14\n" +
+	"\x05error\x18\x0f \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\x10 \x01(\tR\terrorCode\x128\n" +
+	"\ffield_errors\x18\x11 \x03(\v2\x15.runner.v1.FieldErrorR\vfieldErrors\x12\x1a\n" +
+	"\battempts\x18\x12 \x01(\x05R\battempts\x12\x12\n" +
+	"\x04warm\x18\x13 \x01(\bR\x04warm\x12\x16\n" +
+	"\x06cached\x18\x14 \x01(\bR\x06cached\x12\x1c\n" +
+	"\tcoalesced\x18\x15 \x01(\bR\tcoalesced\x12 \n" +
+	"\vtermination\x18\x16 \x01(\tR\vtermination\x12)\n" +
+	"\x06limits\x18\x17 \x01(\v2\x11.runner.v1.LimitsR\x06limits\x129\n" +
+	"\n" +
+	"started_at\x18\x18 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12;\n" +
+	"\vfinished_at\x18\x19 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"finishedAt\x12\x1f\n" +
+	"\vduration_ms\x18\x1a \x01(\x03R\n" +
+	"durationMs\x12\x1b\n" +
+	"\tqueued_ms\x18\x1b \x01(\x03R\bqueuedMs\x12\x1e\n" +
+	"\vuser_cpu_ms\x18\x1c \x01(\x03R\tuserCpuMs\x12\"\n" +
+	"\rsystem_cpu_ms\x18\x1d \x01(\x03R\vsystemCpuMs\x12\x1e\n" +
+	"\n" +
+	"heartbeats\x18\x1e \x01(\x05R\n" +
+	"heartbeats\x12$\n" +
+	"\x0epeak_rss_bytes\x18\x1f \x01(\x03R\fpeakRssBytes\x12(\n" +
+	"\x10disk_usage_bytes\x18  \x01(\x03R\x0ediskUsageBytes\x12(\n" +
+	"\x10disk_quota_bytes\x18! \x01(\x03R\x0ediskQuotaBytes\x121\n" +
+	"\tartifacts\x18\" \x03(\v2\x13.runner.v1.ArtifactR\tartifacts\x12,\n" +
+	"\x05value\x18# \x01(\v2\x16.google.protobuf.ValueR\x05value\x12\x1f\n" +
+	"\vvalue_error\x18$ \x01(\tR\n" +
+	"valueError\x12+\n" +
+	"\x05tests\x18% \x01(\v2\x15.runner.v1.TestReportR\x05tests\x127\n" +
+	"\vdiagnostics\x18& \x03(\v2\x15.runner.v1.DiagnosticR\vdiagnostics\x12\x1c\n" +
+	"\tformatted\x18' \x01(\tR\tformatted\x12&\n" +
+	"\fis_formatted\x18( \x01(\bH\x00R\visFormatted\x88\x01\x01\x124\n" +
+	"\n" +
+	"benchmarks\x18) \x03(\v2\x14.runner.v1.BenchmarkR\n" +
+	"benchmarks\x12>\n" +
+	"\bmetadata\x18* \x03(\v2\".runner.v1.RunResult.MetadataEntryR\bmetadata\x12\x1a\n" +
+	"\breplayed\x18+ \x01(\bR\breplayed\x12\x1b\n" +
+	"\tpublic_id\x18, \x01(\tR\bpublicId\x12*\n" +
+	"\x05steps\x18- \x03(\v2\x14.runner.v1.RunResultR\x05steps\x12*\n" +
+	"\x11full_result_bytes\x18. \x01(\x03R\x0ffullResultBytes\x128\n" +
+	"\toffloaded\x18/ \x01(\v2\x1a.runner.v1.OffloadedResultR\toffloaded\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x0f\n" +
+	"\r_is_formatted\"\x8c\x01\n" +
+	"\x0fOffloadedResult\x12\x16\n" +
+	"\x06bucket\x18\x01 \x01(\tR\x06bucket\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06digest\x18\x04 \x01(\tR\x06digest\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\tR\vcontentType\"\xcc\x01\n" +
+	"\tBenchmark\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05group\x18\x02 \x01(\tR\x05group\x12\f\n" +
+	"\x01n\x18\x03 \x01(\x03R\x01n\x12\x15\n" +
+	"\x06avg_ns\x18\x04 \x01(\x01R\x05avgNs\x12\x15\n" +
+	"\x06min_ns\x18\x05 \x01(\x01R\x05minNs\x12\x15\n" +
+	"\x06max_ns\x18\x06 \x01(\x01R\x05maxNs\x12\x15\n" +
+	"\x06p75_ns\x18\a \x01(\x01R\x05p75Ns\x12\x15\n" +
+	"\x06p99_ns\x18\b \x01(\x01R\x05p99Ns\x12\x14\n" +
+	"\x05error\x18\t \x01(\tR\x05error\"\xc8\x01\n" +
+	"\n" +
+	"Diagnostic\x12\x12\n" +
+	"\x04file\x18\x01 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06column\x18\x03 \x01(\x05R\x06column\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\x12\x12\n" +
+	"\x04code\x18\x05 \x01(\tR\x04code\x12\x19\n" +
+	"\bend_line\x18\x06 \x01(\x05R\aendLine\x12\x1d\n" +
+	"\n" +
+	"end_column\x18\a \x01(\x05R\tendColumn\x12\x12\n" +
+	"\x04hint\x18\b \x01(\tR\x04hint\"\xa0\x01\n" +
+	"\n" +
+	"TestReport\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x05R\x05total\x12\x16\n" +
+	"\x06passed\x18\x02 \x01(\x05R\x06passed\x12\x16\n" +
+	"\x06failed\x18\x03 \x01(\x05R\x06failed\x12\x18\n" +
+	"\aignored\x18\x04 \x01(\x05R\aignored\x122\n" +
+	"\bfailures\x18\x05 \x03(\v2\x16.runner.v1.TestFailureR\bfailures\"c\n" +
+	"\vTestFailure\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04file\x18\x02 \x01(\tR\x04file\x12\x12\n" +
+	"\x04line\x18\x03 \x01(\x05R\x04line\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessage\"Z\n" +
+	"\fBinaryOutput\x12\x1a\n" +
+	"\bencoding\x18\x01 \x01(\tR\bencoding\x12\x16\n" +
+	"\x06stdout\x18\x02 \x01(\tR\x06stdout\x12\x16\n" +
+	"\x06stderr\x18\x03 \x01(\tR\x06stderr\"b\n" +
+	"\n" +
+	"OutputLine\x12(\n" +
+	"\x01t\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x01t\x12\x16\n" +
+	"\x06stream\x18\x02 \x01(\tR\x06stream\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\"8\n" +
+	"\n" +
+	"ExitSignal\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06number\x18\x02 \x01(\x05R\x06number\"<\n" +
+	"\n" +
+	"FieldError\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xf1\x02\n" +
+	"\x06Limits\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x01 \x01(\x03R\ttimeoutMs\x12\x17\n" +
+	"\aheap_mb\x18\x02 \x01(\x05R\x06heapMb\x12!\n" +
+	"\fmemory_bytes\x18\x03 \x01(\x03R\vmemoryBytes\x12\x15\n" +
+	"\x06cpu_ms\x18\x04 \x01(\x03R\x05cpuMs\x12\x1c\n" +
+	"\tprocesses\x18\x05 \x01(\x05R\tprocesses\x12(\n" +
+	"\x10disk_quota_bytes\x18\x06 \x01(\x03R\x0ediskQuotaBytes\x12!\n" +
+	"\foutput_bytes\x18\a \x01(\x03R\voutputBytes\x12*\n" +
+	"\x11output_rate_bytes\x18\b \x01(\x03R\x0foutputRateBytes\x12%\n" +
+	"\x0emax_concurrent\x18\t \x01(\x05R\rmaxConcurrent\x12%\n" +
+	"\x0equeue_position\x18\n" +
+	" \x01(\x05R\rqueuePosition\x12\x10\n" +
+	"\x03hit\x18\v \x03(\tR\x03hit\"f\n" +
+	"\bArtifact\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12\x18\n" +
+	"\askipped\x18\x04 \x01(\tR\askipped\"\x99\x02\n" +
+	"\vScheduleAck\x12\x1b\n" +
+	"\tpublic_id\x18\x01 \x01(\tR\bpublicId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x121\n" +
+	"\x06run_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x05runAt\x12#\n" +
+	"\rreply_subject\x18\x04 \x01(\tR\freplySubject\x12@\n" +
+	"\bmetadata\x18\x05 \x03(\v2$.runner.v1.ScheduleAck.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x89\x02\n" +
+	"\tSubmitAck\x12\x1b\n" +
+	"\tpublic_id\x18\x01 \x01(\tR\bpublicId\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12#\n" +
+	"\rreply_subject\x18\x03 \x01(\tR\freplySubject\x12%\n" +
+	"\x0equeue_position\x18\x04 \x01(\x05R\rqueuePosition\x12>\n" +
+	"\bmetadata\x18\x05 \x03(\v2\".runner.v1.SubmitAck.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x87\x01\n" +
+	"\vOutputChunk\x12\x16\n" +
+	"\x06stream\x18\x01 \x01(\tR\x06stream\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x04R\x03seq\x12(\n" +
+	"\x01t\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x01t\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\tR\x04data\x12\x10\n" +
+	"\x03eof\x18\x05 \x01(\bR\x03eof\"\xa8\x02\n" +
+	"\fWebhookEvent\x12\x1b\n" +
+	"\tpublic_id\x18\x01 \x01(\tR\bpublicId\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x1a\n" +
+	"\battempts\x18\x04 \x01(\x05R\battempts\x12\x1f\n" +
+	"\vstatus_code\x18\x05 \x01(\x05R\n" +
+	"statusCode\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12A\n" +
+	"\bmetadata\x18\a \x03(\v2%.runner.v1.WebhookEvent.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x91\x02\n" +
+	"\tHeartbeat\x12\x1b\n" +
+	"\tpublic_id\x18\x01 \x01(\tR\bpublicId\x12\x10\n" +
+	"\x03seq\x18\x02 \x01(\x05R\x03seq\x12\x1d\n" +
+	"\n" +
+	"elapsed_ms\x18\x03 \x01(\x03R\telapsedMs\x12!\n" +
+	"\foutput_bytes\x18\x04 \x01(\x03R\voutputBytes\x12\x16\n" +
+	"\x06worker\x18\x05 \x01(\x05R\x06worker\x12>\n" +
+	"\bmetadata\x18\x06 \x03(\v2\".runner.v1.Heartbeat.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\x17Z\x15runner/proto;runnerpbb\x06proto3"
+
+var (
+	file_runner_proto_rawDescOnce sync.Once
+	file_runner_proto_rawDescData []byte
+)
+
+func file_runner_proto_rawDescGZIP() []byte {
+	file_runner_proto_rawDescOnce.Do(func() {
+		file_runner_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_runner_proto_rawDesc), len(file_runner_proto_rawDesc)))
+	})
+	return file_runner_proto_rawDescData
+}
+
+var file_runner_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
+var file_runner_proto_goTypes = []any{
+	(*RunRequest)(nil),            // 0: runner.v1.RunRequest
+	(*SourceRef)(nil),             // 1: runner.v1.SourceRef
+	(*Step)(nil),                  // 2: runner.v1.Step
+	(*Deadline)(nil),              // 3: runner.v1.Deadline
+	(*RunResult)(nil),             // 4: runner.v1.RunResult
+	(*OffloadedResult)(nil),       // 5: runner.v1.OffloadedResult
+	(*Benchmark)(nil),             // 6: runner.v1.Benchmark
+	(*Diagnostic)(nil),            // 7: runner.v1.Diagnostic
+	(*TestReport)(nil),            // 8: runner.v1.TestReport
+	(*TestFailure)(nil),           // 9: runner.v1.TestFailure
+	(*BinaryOutput)(nil),          // 10: runner.v1.BinaryOutput
+	(*OutputLine)(nil),            // 11: runner.v1.OutputLine
+	(*ExitSignal)(nil),            // 12: runner.v1.ExitSignal
+	(*FieldError)(nil),            // 13: runner.v1.FieldError
+	(*Limits)(nil),                // 14: runner.v1.Limits
+	(*Artifact)(nil),              // 15: runner.v1.Artifact
+	(*ScheduleAck)(nil),           // 16: runner.v1.ScheduleAck
+	(*SubmitAck)(nil),             // 17: runner.v1.SubmitAck
+	(*OutputChunk)(nil),           // 18: runner.v1.OutputChunk
+	(*WebhookEvent)(nil),          // 19: runner.v1.WebhookEvent
+	(*Heartbeat)(nil),             // 20: runner.v1.Heartbeat
+	nil,                           // 21: runner.v1.RunRequest.EnvEntry
+	nil,                           // 22: runner.v1.RunRequest.FilesEntry
+	nil,                           // 23: runner.v1.RunRequest.MetadataEntry
+	nil,                           // 24: runner.v1.RunResult.MetadataEntry
+	nil,                           // 25: runner.v1.ScheduleAck.MetadataEntry
+	nil,                           // 26: runner.v1.SubmitAck.MetadataEntry
+	nil,                           // 27: runner.v1.WebhookEvent.MetadataEntry
+	nil,                           // 28: runner.v1.Heartbeat.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 29: google.protobuf.Timestamp
+	(*structpb.Value)(nil),        // 30: google.protobuf.Value
+}
+var file_runner_proto_depIdxs = []int32{
+	21, // 0: runner.v1.RunRequest.env:type_name -> runner.v1.RunRequest.EnvEntry
+	29, // 1: runner.v1.RunRequest.run_at:type_name -> google.protobuf.Timestamp
+	3,  // 2: runner.v1.RunRequest.deadline:type_name -> runner.v1.Deadline
+	22, // 3: runner.v1.RunRequest.files:type_name -> runner.v1.RunRequest.FilesEntry
+	30, // 4: runner.v1.RunRequest.import_map:type_name -> google.protobuf.Value
+	30, // 5: runner.v1.RunRequest.deno_config:type_name -> google.protobuf.Value
+	23, // 6: runner.v1.RunRequest.metadata:type_name -> runner.v1.RunRequest.MetadataEntry
+	2,  // 7: runner.v1.RunRequest.steps:type_name -> runner.v1.Step
+	1,  // 8: runner.v1.RunRequest.source:type_name -> runner.v1.SourceRef
+	29, // 9: runner.v1.Deadline.at:type_name -> google.protobuf.Timestamp
+	10, // 10: runner.v1.RunResult.binary_output:type_name -> runner.v1.BinaryOutput
+	11, // 11: runner.v1.RunResult.lines:type_name -> runner.v1.OutputLine
+	12, // 12: runner.v1.RunResult.exit_signal:type_name -> runner.v1.ExitSignal
+	13, // 13: runner.v1.RunResult.field_errors:type_name -> runner.v1.FieldError
+	14, // 14: runner.v1.RunResult.limits:type_name -> runner.v1.Limits
+	29, // 15: runner.v1.RunResult.started_at:type_name -> google.protobuf.Timestamp
+	29, // 16: runner.v1.RunResult.finished_at:type_name -> google.protobuf.Timestamp
+	15, // 17: runner.v1.RunResult.artifacts:type_name -> runner.v1.Artifact
+	30, // 18: runner.v1.RunResult.value:type_name -> google.protobuf.Value
+	8,  // 19: runner.v1.RunResult.tests:type_name -> runner.v1.TestReport
+	7,  // 20: runner.v1.RunResult.diagnostics:type_name -> runner.v1.Diagnostic
+	6,  // 21: runner.v1.RunResult.benchmarks:type_name -> runner.v1.Benchmark
+	24, // 22: runner.v1.RunResult.metadata:type_name -> runner.v1.RunResult.MetadataEntry
+	4,  // 23: runner.v1.RunResult.steps:type_name -> runner.v1.RunResult
+	5,  // 24: runner.v1.RunResult.offloaded:type_name -> runner.v1.OffloadedResult
+	9,  // 25: runner.v1.TestReport.failures:type_name -> runner.v1.TestFailure
+	29, // 26: runner.v1.OutputLine.t:type_name -> google.protobuf.Timestamp
+	29, // 27: runner.v1.ScheduleAck.run_at:type_name -> google.protobuf.Timestamp
+	25, // 28: runner.v1.ScheduleAck.metadata:type_name -> runner.v1.ScheduleAck.MetadataEntry
+	26, // 29: runner.v1.SubmitAck.metadata:type_name -> runner.v1.SubmitAck.MetadataEntry
+	29, // 30: runner.v1.OutputChunk.t:type_name -> google.protobuf.Timestamp
+	27, // 31: runner.v1.WebhookEvent.metadata:type_name -> runner.v1.WebhookEvent.MetadataEntry
+	28, // 32: runner.v1.Heartbeat.metadata:type_name -> runner.v1.Heartbeat.MetadataEntry
+	33, // [33:33] is the sub-list for method output_type
+	33, // [33:33] is the sub-list for method input_type
+	33, // [33:33] is the sub-list for extension type_name
+	33, // [33:33] is the sub-list for extension extendee
+	0,  // [0:33] is the sub-list for field type_name
+}
+
+func init() { file_runner_proto_init() }
+func file_runner_proto_init() {
+	if File_runner_proto != nil {
+		return
+	}
+	file_runner_proto_msgTypes[0].OneofWrappers = []any{}
+	file_runner_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_runner_proto_rawDesc), len(file_runner_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   29,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_runner_proto_goTypes,
+		DependencyIndexes: file_runner_proto_depIdxs,
+		MessageInfos:      file_runner_proto_msgTypes,
+	}.Build()
+	File_runner_proto = out.File
+	file_runner_proto_goTypes = nil
+	file_runner_proto_depIdxs = nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	runnerpb "runner/proto"
+)
+
+var deadlineType = reflect.TypeOf(requestDeadline{})
+
+// TestProtoMatchesGoTypes checks every field of the protobuf roots, and of
+// the structs they hold, has a field of the same JSON name in the message of
+// the same name, and the other way round.
+func TestProtoMatchesGoTypes(t *testing.T) {
+	seen := map[reflect.Type]bool{}
+	var check func(gt reflect.Type, md protoreflect.MessageDescriptor)
+	check = func(gt reflect.Type, md protoreflect.MessageDescriptor) {
+		if seen[gt] {
+			return
+		}
+		seen[gt] = true
+		inGo := map[string]bool{}
+		for _, f := range jsonFields(gt) {
+			inGo[f.name] = true
+			fd := md.Fields().ByJSONName(f.name)
+			if fd == nil {
+				t.Errorf("%s.%s is not in runner.proto", md.Name(), f.name)
+				continue
+			}
+			ft := gt.FieldByIndex(f.index).Type
+			for ft.Kind() == reflect.Pointer || (ft.Kind() == reflect.Slice && ft != rawMessageType) {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType && ft != deadlineType && fd.Message() != nil {
+				check(ft, fd.Message())
+			}
+		}
+		for i := 0; i < md.Fields().Len(); i++ {
+			if name := md.Fields().Get(i).JSONName(); !inGo[name] {
+				t.Errorf("%s.%s has no Go field", md.Name(), name)
+			}
+		}
+	}
+	for _, root := range protoRoots {
+		gt := reflect.TypeOf(root)
+		mt, ok := protoMessageType(gt.Name())
+		if !ok {
+			t.Errorf("no message %s in runner.proto", gt.Name())
+			continue
+		}
+		check(gt, mt.Descriptor())
+	}
+}
+
+var fillTime = time.Date(2026, 3, 4, 5, 6, 7, 890123456, time.UTC)
+
+// fill sets every encoded field reachable from v to a non-zero value.
+func fill(v reflect.Value, depth int) {
+	switch {
+	case v.Type() == timeType:
+		v.Set(reflect.ValueOf(fillTime))
+		return
+	case v.Type() == deadlineType:
+		v.Set(reflect.ValueOf(requestDeadline{At: fillTime}))
+		return
+	case v.Type() == rawMessageType:
+		v.Set(reflect.ValueOf(json.RawMessage(`{"a":[1,"x",true,null],"b":{"c":2.5}}`)))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("s")
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(7)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(7)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1.5)
+	case reflect.Pointer:
+		if depth == 0 {
+			return
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), depth)
+	case reflect.Slice:
+		if depth == 0 {
+			return
+		}
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0), depth-1)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		k, e := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fill(k, depth)
+		fill(e, depth)
+		m.SetMapIndex(k, e)
+		v.Set(m)
+	case reflect.Struct:
+		for _, f := range jsonFields(v.Type()) {
+			fill(v.FieldByIndex(f.index), depth)
+		}
+	}
+}
+
+// TestProtoRoundTrip checks each protobuf root decodes to the same value
+// from its JSON encoding as from its protobuf encoding.
+func TestProtoRoundTrip(t *testing.T) {
+	for _, root := range protoRoots {
+		gt := reflect.TypeOf(root)
+		t.Run(gt.Name(), func(t *testing.T) {
+			orig := reflect.New(gt)
+			fill(orig.Elem(), 2)
+			checkProtoRoundTrip(t, orig.Elem().Interface())
+		})
+	}
+	t.Run("RelativeDeadline", func(t *testing.T) {
+		checkProtoRoundTrip(t, RunRequest{PublicID: "p", Deadline: &requestDeadline{Relative: 1500 * time.Millisecond}})
+	})
+	t.Run("FalseOptionals", func(t *testing.T) {
+		off := false
+		checkProtoRoundTrip(t, RunRequest{PublicID: "p", StripANSI: &off, RemoteImports: &off})
+	})
+}
+
+func checkProtoRoundTrip(t *testing.T, v any) {
+	t.Helper()
+	gt := reflect.TypeOf(v)
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb, ok, err := encodeProto(v)
+	if !ok || err != nil {
+		t.Fatalf("encodeProto: ok=%v err=%v", ok, err)
+	}
+	protoJSON, err := protoToJSON(pb, gt.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, fromProto := reflect.New(gt), reflect.New(gt)
+	if err := json.Unmarshal(jsonData, fromJSON.Interface()); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(protoJSON, fromProto.Interface()); err != nil {
+		t.Fatalf("decoding %s: %v", protoJSON, err)
+	}
+	again, _ := json.Marshal(fromProto.Interface())
+	if !reflect.DeepEqual(fromJSON.Elem().Interface(), fromProto.Elem().Interface()) {
+		t.Errorf("decoded differently:\nfrom JSON  %s\nfrom proto %s", jsonData, again)
+	}
+}
+
+// TestProtoGeneratedRequest decodes a request built with the generated types.
+func TestProtoGeneratedRequest(t *testing.T) {
+	data, err := proto.Marshal(&runnerpb.RunRequest{
+		PublicId:    "p1",
+		Code:        "console.log(1)",
+		Permissions: []string{"--allow-net"},
+		TimeoutMs:   1 << 40,
+		Env:         map[string]string{"A": "b"},
+		RunAt:       timestamppb.New(fillTime),
+		Deadline:    &runnerpb.Deadline{RelativeMs: 2500},
+		Steps:       []*runnerpb.Step{{Name: "one", Code: "1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := protoToJSON(data, "RunRequest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req RunRequest
+	if err := json.Unmarshal(js, &req); err != nil {
+		t.Fatalf("decoding %s: %v", js, err)
+	}
+	switch {
+	case req.PublicID != "p1" || req.Code != "console.log(1)" || len(req.Permissions) != 1:
+		t.Errorf("scalar fields: %+v", req)
+	case req.TimeoutMs != 1<<40:
+		t.Errorf("timeoutMs = %d", req.TimeoutMs)
+	case req.Env["A"] != "b":
+		t.Errorf("env = %v", req.Env)
+	case req.RunAt == nil || !req.RunAt.Equal(fillTime):
+		t.Errorf("runAt = %v", req.RunAt)
+	case req.Deadline == nil || req.Deadline.Relative != 2500*time.Millisecond:
+		t.Errorf("deadline = %+v", req.Deadline)
+	case len(req.Steps) != 1 || req.Steps[0].Name != "one":
+		t.Errorf("steps = %+v", req.Steps)
+	}
+}
+
+func TestProtoMalformed(t *testing.T) {
+	if _, err := protoToJSON([]byte{0x0a, 0x05, 'a'}, "RunRequest"); err == nil {
+		t.Error("truncated message decoded")
+	}
+	if _, err := protoToJSON(nil, "NoSuchMessage"); err == nil {
+		t.Error("unknown message decoded")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// diskQuotaInterval is how often the working directory is measured when the
+// quota can't be enforced by a size-limited tmpfs.
+const diskQuotaInterval = 250 * time.Millisecond
+
+// diskQuota enforces a byte limit on a job's working directory. When the
+// runner is privileged enough, the directory is a tmpfs mount of exactly that
+// size and the kernel refuses writes past it; otherwise the directory is
+// measured periodically and the job is killed once it goes over.
+type diskQuota struct {
+	dir   string
+	limit int64
+	tmpfs bool
+}
+
+func newDiskQuota(dir string, limit int64, cred *jobCredential) *diskQuota {
+	q := &diskQuota{dir: dir, limit: limit}
+	if err := mountQuotaTmpfs(dir, limit, cred); err != nil {
+		log.Printf("[QUOTA] tmpfs unavailable for %s, polling usage instead: %v", dir, err)
+	} else {
+		q.tmpfs = true
+	}
+	return q
+}
+
+// watch polls usage until ctx is done, calling onExceeded once if the quota is breached.
+func (q *diskQuota) watch(ctx context.Context, onExceeded func()) {
+	if q.tmpfs {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(diskQuotaInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if q.usage() > q.limit {
+					onExceeded()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// exceeded reports whether the job used up its quota.
+func (q *diskQuota) exceeded() bool {
+	if q.tmpfs {
+		return tmpfsFull(q.dir)
+	}
+	return q.usage() > q.limit
+}
+
+// usage returns the total size of regular files in the working directory.
+func (q *diskQuota) usage() int64 {
+	var total int64
+	_ = filepath.WalkDir(q.dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // files can disappear while the job runs
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// release unmounts the quota tmpfs, if any. Call it before removing the directory.
+func (q *diskQuota) release() {
+	if q.tmpfs {
+		if err := unmountQuotaTmpfs(q.dir); err != nil {
+			log.Printf("[QUOTA] Failed to unmount %s: %v", q.dir, err)
+		}
+	}
+}
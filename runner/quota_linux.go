@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountQuotaTmpfs mounts a tmpfs of the given size over dir. It needs
+// CAP_SYS_ADMIN, so it fails in unprivileged containers.
+func mountQuotaTmpfs(dir string, size int64, cred *jobCredential) error {
+	opts := fmt.Sprintf("size=%d,mode=0700", size)
+	if cred != nil {
+		opts += fmt.Sprintf(",uid=%d,gid=%d", cred.Uid, cred.Gid)
+	}
+	return unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, opts)
+}
+
+func unmountQuotaTmpfs(dir string) error {
+	return unix.Unmount(dir, unix.MNT_DETACH)
+}
+
+// tmpfsFull reports whether the file system at dir has no space left.
+func tmpfsFull(dir string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return false
+	}
+	return st.Bavail == 0
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func mountQuotaTmpfs(string, int64, *jobCredential) error {
+	return errors.New("tmpfs quotas require Linux")
+}
+
+func unmountQuotaTmpfs(string) error { return nil }
+
+func tmpfsFull(string) bool { return false }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// childReaper collects orphaned processes when the runner is PID 1 in a
+// container without an init system. Killed deno children can leave
+// grandchildren behind; those get reparented to PID 1 and would otherwise stay
+// zombies forever.
+//
+// It must never reap a process that an exec.Cmd is still waiting on, or
+// cmd.Wait would lose the exit status. Jobs therefore start their process while
+// holding startMu for reading and register the PID before releasing it; a reap
+// pass holds startMu exclusively and skips every registered PID.
+type childReaper struct {
+	startMu sync.RWMutex
+
+	mu      sync.Mutex
+	tracked map[int]struct{}
+}
+
+// newChildReaper starts the reaper loop if this process is PID 1 and returns
+// nil otherwise, in which case starting commands falls through to cmd.Start.
+func newChildReaper() *childReaper {
+	if os.Getpid() != 1 {
+		return nil
+	}
+	cr := &childReaper{tracked: make(map[int]struct{})}
+	go cr.loop()
+	log.Println("Running as PID 1, reaping orphaned child processes")
+	return cr
+}
+
+// start launches cmd and registers its PID so reap passes leave it to cmd.Wait.
+func (cr *childReaper) start(cmd *exec.Cmd) error {
+	if cr == nil {
+		return cmd.Start()
+	}
+	cr.startMu.RLock()
+	defer cr.startMu.RUnlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cr.mu.Lock()
+	cr.tracked[cmd.Process.Pid] = struct{}{}
+	cr.mu.Unlock()
+	return nil
+}
+
+// release forgets a PID once cmd.Wait has collected it.
+func (cr *childReaper) release(pid int) {
+	if cr == nil {
+		return
+	}
+	cr.mu.Lock()
+	delete(cr.tracked, pid)
+	cr.mu.Unlock()
+}
+
+func (cr *childReaper) reap() {
+	cr.startMu.Lock()
+	defer cr.startMu.Unlock()
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	for _, pid := range zombieChildren() {
+		if _, ok := cr.tracked[pid]; ok {
+			continue
+		}
+		if err := collectZombie(pid); err == nil {
+			log.Printf("[REAP] Collected orphaned process %d", pid)
+		}
+	}
+}
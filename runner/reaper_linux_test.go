@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// subreaper makes the test process adopt orphaned descendants, as PID 1
+// would, for the rest of the test, and returns a reaper that is not running
+// its loop.
+func subreaper(t *testing.T) *childReaper {
+	t.Helper()
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		t.Skipf("cannot become a subreaper: %v", err)
+	}
+	t.Cleanup(func() { unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 0, 0, 0, 0) })
+	return &childReaper{tracked: make(map[int]struct{})}
+}
+
+// waitZombie waits until pid is an unreaped child of the test process.
+func waitZombie(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Contains(zombieChildren(), pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d never became a zombie child", pid)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReapOrphanedGrandchild(t *testing.T) {
+	cr := subreaper(t)
+	// The shell exits at once, leaving its background child to be
+	// reparented to the test process.
+	out, err := exec.Command("sh", "-c", "(sleep 0.1) & echo $!").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitZombie(t, pid)
+	cr.reap()
+	if slices.Contains(zombieChildren(), pid) {
+		t.Errorf("orphan %d was not reaped", pid)
+	}
+}
+
+// TestReapSkipsStarted checks a reap pass leaves processes started through
+// the reaper to cmd.Wait, which then still gets their exit status.
+func TestReapSkipsStarted(t *testing.T) {
+	cr := subreaper(t)
+	cmd := exec.Command("sh", "-c", "exit 7")
+	if err := cr.start(cmd); err != nil {
+		t.Fatal(err)
+	}
+	waitZombie(t, cmd.Process.Pid)
+	cr.reap()
+	err := cmd.Wait()
+	cr.release(cmd.Process.Pid)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 7 {
+		t.Errorf("Wait = %v, want exit status 7", err)
+	}
+}
+
+// TestPrecacheThroughReaper checks module precaching survives reap passes
+// running as it does, and leaves no zombies behind.
+func TestPrecacheThroughReaper(t *testing.T) {
+	cr := subreaper(t)
+	fakeDeno(t, `(sleep 0.05) &
+sleep 0.1
+echo "cached $*"`)
+	logs := captureLog(t)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				cr.reap()
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	r := &Runner{cfg: &Config{PrecacheModules: []string{"jsr:@std/assert"}}, reaper: cr}
+	for i := 0; i < 5; i++ {
+		r.precacheModules()
+	}
+	close(stop)
+	wg.Wait()
+	if got := strings.Count(logs.String(), "Precached 1 modules"); got != 5 {
+		t.Errorf("%d of 5 precache runs succeeded; log:\n%s", got, logs)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cr.reap()
+	if z := zombieChildren(); len(z) > 0 {
+		t.Errorf("zombies left: %v", z)
+	}
+}
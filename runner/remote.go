@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// precacheTimeout bounds the startup download of RUNNER_PRECACHE_MODULES.
+const precacheTimeout = 5 * time.Minute
+
+// remoteBlockedErrors are how deno reports an import it may not fetch under
+// --no-remote and --cached-only.
+var remoteBlockedErrors = []string{
+	"--no-remote is specified",
+	"--cached-only is specified",
+}
+
+// remoteImportsDisabled reports whether req must run from the module cache
+// alone, because it asked to or the runner forces it.
+func (c *Config) remoteImportsDisabled(req *RunRequest) bool {
+	return c.NoRemote || (req.RemoteImports != nil && !*req.RemoteImports)
+}
+
+// remoteFlags returns the flags that keep deno from fetching any module:
+// remote URLs are refused and npm and jsr packages must already be cached.
+func (c *Config) remoteFlags(req *RunRequest) []string {
+	if !c.remoteImportsDisabled(req) {
+		return nil
+	}
+	return []string{"--no-remote", "--cached-only"}
+}
+
+// checkRemoteImport reports a run that failed on an import deno wasn't
+// allowed to fetch as REMOTE_IMPORT_BLOCKED, with deno's error as the
+// message.
+func (c *Config) checkRemoteImport(res *RunResult, req *RunRequest) {
+	if !c.remoteImportsDisabled(req) || res.ErrorCode != errorCodeRuntime || res.ExitSignal != nil {
+		return
+	}
+	for _, line := range strings.Split(stripANSI(res.Stderr), "\n") {
+		for _, msg := range remoteBlockedErrors {
+			if strings.Contains(line, msg) {
+				res.Error = strings.TrimPrefix(strings.TrimSpace(line), "error: ")
+				res.ErrorCode = errorCodeRemoteBlocked
+				return
+			}
+		}
+	}
+}
+
+// precacheModules downloads cfg.PrecacheModules into the shared module
+// cache, so jobs without remote imports can still use them. It runs once at
+// startup, as the job user so the cache stays readable to jobs; a failure
+// is logged and jobs importing the missing modules fail as blocked. Like
+// jobs, deno is started through the reaper, which would otherwise collect
+// it before cmd.Wait can when the runner is PID 1.
+func (r *Runner) precacheModules() {
+	cfg := r.cfg
+	if len(cfg.PrecacheModules) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), precacheTimeout)
+	defer cancel()
+	dir := os.TempDir()
+	args := append([]string{"cache", "--allow-import"}, cfg.PrecacheModules...)
+	cmd := exec.CommandContext(ctx, "deno", args...)
+	cmd.Dir = dir
+	cmd.Env = jobEnv(dir, nil)
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	setCredential(cmd.SysProcAttr, cfg.ExecCredential)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	start := time.Now()
+	err := r.reaper.start(cmd)
+	if err == nil {
+		err = cmd.Wait()
+		r.reaper.release(cmd.Process.Pid)
+	}
+	if err != nil {
+		log.Printf("[WARN] Failed to precache modules: %v\n%s", err, out.Bytes())
+		return
+	}
+	log.Printf("Precached %d modules in %v", len(cfg.PrecacheModules), time.Since(start).Round(time.Millisecond))
+}
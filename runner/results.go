@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// resultKVTimeout bounds each read and write of the results bucket.
+	resultKVTimeout = 2 * time.Second
+	// storedOutputTail is how much of each output stream a result too big
+	// for the bucket keeps.
+	storedOutputTail = 4 << 10
+)
+
+// resultStore keeps jobs' results in a JetStream KV bucket by PublicID, for
+// callers that missed the reply to look up on runner.result.<publicId>.
+type resultStore struct {
+	kv       jetstream.KeyValue
+	maxBytes int
+}
+
+// newResultStore opens the results bucket, or returns nil if none is
+// configured.
+func newResultStore(cfg *Config, nc *nats.Conn) (*resultStore, error) {
+	if cfg.ResultBucket == "" {
+		return nil, nil
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	kv, err := js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+		Bucket:      cfg.ResultBucket,
+		Description: "runner results by PublicID",
+		TTL:         cfg.ResultTTL,
+		History:     1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("bucket %q: %w", cfg.ResultBucket, err)
+	}
+	return &resultStore{kv: kv, maxBytes: int(cfg.ResultMaxBytes)}, nil
+}
+
+// resultStoreKey encodes a PublicID, which may hold characters KV keys
+// don't allow, as a key.
+func resultStoreKey(publicID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(publicID))
+}
+
+// store records res, which carries its PublicID, in the background. A result
+// over the size limit is stored cut down, with FullResultBytes set.
+func (s *resultStore) store(res RunResult) {
+	if s == nil {
+		return
+	}
+	res.stamp()
+	data, err := json.Marshal(res)
+	if err == nil && len(data) > s.maxBytes {
+		full := len(data)
+		data, err = json.Marshal(storedPreview(res, full))
+		if err == nil && len(data) > s.maxBytes {
+			log.Printf("[RESULTS] Not storing result of %s: %d bytes even cut down (max %d)", res.PublicID, len(data), s.maxBytes)
+			return
+		}
+	}
+	if err != nil {
+		log.Printf("[RESULTS] Failed to encode result of %s: %v", res.PublicID, err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), resultKVTimeout)
+		defer cancel()
+		if _, err := s.kv.Put(ctx, resultStoreKey(res.PublicID), data); err != nil {
+			log.Printf("[RESULTS] Failed to store result of %s: %v", res.PublicID, err)
+		}
+	}()
+}
+
+// storedPreview cuts res down to the end of its output and the fields
+// describing the run.
+func storedPreview(res RunResult, fullBytes int) RunResult {
+	res.Output, res.Stdout, res.Stderr = lastBytes(res.Output), lastBytes(res.Stdout), lastBytes(res.Stderr)
+	res.BinaryOutput, res.Lines, res.Steps, res.Artifacts = nil, nil, nil, nil
+	res.Value, res.Formatted, res.Diagnostics, res.Benchmarks, res.Tests = nil, "", nil, nil, nil
+	res.FullResultBytes = int64(fullBytes)
+	return res
+}
+
+// lastBytes returns the end of s, at most storedOutputTail bytes, starting
+// on a UTF-8 boundary.
+func lastBytes(s string) string {
+	if len(s) <= storedOutputTail {
+		return s
+	}
+	s = s[len(s)-storedOutputTail:]
+	return strings.ToValidUTF8(s, "")
+}
+
+// handleResult answers runner.result.<publicId> with the stored result of
+// the latest job with that PublicID.
+func (r *Runner) handleResult(m *nats.Msg) {
+	publicID := r.cfg.trimSubject(m.Subject, "result")
+	notFound := RunResult{PublicID: publicID, ExitCode: -1, ErrorCode: errorCodeNotFound}
+	if r.results == nil {
+		notFound.Error = "results are not stored on this runner"
+		r.respond(m, notFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resultKVTimeout)
+	defer cancel()
+	entry, err := r.results.kv.Get(ctx, resultStoreKey(publicID))
+	if err != nil {
+		if !errors.Is(err, jetstream.ErrKeyNotFound) {
+			log.Printf("[RESULTS] Reading result of %s: %v", publicID, err)
+		}
+		notFound.Error = "no stored result for this PublicID"
+		r.respond(m, notFound)
+		return
+	}
+	var res RunResult
+	if err := json.Unmarshal(entry.Value(), &res); err != nil {
+		log.Printf("[RESULTS] Discarding bad stored result of %s: %v", publicID, err)
+		notFound.Error = "no stored result for this PublicID"
+		r.respond(m, notFound)
+		return
+	}
+	r.respond(m, res)
+}
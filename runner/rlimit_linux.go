@@ -0,0 +1,51 @@
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// rlimits are per-process resource limits applied to the child right after it starts.
+//
+// os/exec has no hook between fork and exec, so they are set with prlimit
+// once cmd.Start has returned, and deno runs unlimited until then. The CPU
+// time it uses in that window still counts, since RLIMIT_CPU is measured
+// from the start of the process, but threads and processes it creates
+// before the NPROC limit lands aren't refused. The window closes long
+// before deno has loaded the job's code, so only deno's own startup
+// threads can slip through; where that matters, prefer the cgroup
+// pids.max, which holds the child from the moment it is cloned.
+type rlimits struct {
+	// NProc caps the number of processes/threads for the child's real UID.
+	// The kernel does not enforce it for root, so it only helps when jobs run
+	// as a dedicated user.
+	NProc uint64
+	// CPUSeconds caps CPU time across all threads. The kernel sends SIGXCPU at
+	// the soft limit and SIGKILL one second later.
+	CPUSeconds uint64
+}
+
+func (l rlimits) empty() bool { return l.NProc == 0 && l.CPUSeconds == 0 }
+
+// apply sets the limits on an already-started process; see rlimits for the
+// window before it is called.
+func (l rlimits) apply(pid int) error {
+	if l.NProc > 0 {
+		lim := unix.Rlimit{Cur: l.NProc, Max: l.NProc}
+		if err := unix.Prlimit(pid, unix.RLIMIT_NPROC, &lim, nil); err != nil {
+			return err
+		}
+	}
+	if l.CPUSeconds > 0 {
+		lim := unix.Rlimit{Cur: l.CPUSeconds, Max: l.CPUSeconds + 1}
+		if err := unix.Prlimit(pid, unix.RLIMIT_CPU, &lim, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCPULimitSignal reports whether sig is the one the kernel sends at the
+// CPUSeconds soft limit.
+func isCPULimitSignal(sig syscall.Signal) bool { return sig == unix.SIGXCPU }
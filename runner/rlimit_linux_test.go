@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// workerBomb stands in for deno running a script that starts workers until
+// it can't, failing the way deno does when a thread can't be spawned.
+const workerBomb = `sleep 0.2
+i=0
+while [ $i -lt 50 ]; do
+	if ! (sleep 5 >/dev/null 2>&1 &) 2>/dev/null; then
+		echo "error: Uncaught Error: failed to spawn thread: Resource temporarily unavailable (os error 11)" >&2
+		exit 1
+	fi
+	i=$((i+1))
+done
+echo "started $i workers"`
+
+// asJobUser makes r run jobs as nobody, as the kernel doesn't enforce
+// RLIMIT_NPROC for root.
+func asJobUser(t *testing.T, r *Runner) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("running jobs as another user needs root")
+	}
+	r.cfg.ExecCredential = &syscall.Credential{Uid: 65534, Gid: 65534}
+}
+
+func TestProcessLimitRlimit(t *testing.T) {
+	fakeDeno(t, workerBomb)
+	r := testRunner(t)
+	asJobUser(t, r)
+	r.cfg.PidsLimit = 8
+	r.rlimits.NProc = 8
+	logs := captureLog(t)
+	res := r.executeIn(&RunRequest{PublicID: "bomb", Code: "for (;;) new Worker(url)"}, 0, time.Time{}, nil)
+	if strings.Contains(logs.String(), "Failed to apply rlimits") {
+		// Limiting another user's process takes CAP_SYS_RESOURCE.
+		t.Skipf("runner cannot set the job's limits: %s", logs)
+	}
+	if res.ErrorCode != errorCodeProcessLimit {
+		t.Fatalf("errorCode = %q (%s), want %s; stdout %q", res.ErrorCode, res.Error, errorCodeProcessLimit, res.Stdout)
+	}
+	if !strings.Contains(res.Error, "max 8") || res.Limits.Processes != 8 || !slices.Contains(res.Limits.Hit, "processes") {
+		t.Errorf("error %q, limits %+v", res.Error, res.Limits)
+	}
+}
+
+// TestProcessLimitUnlimited checks the same script runs to the end without
+// a limit, so the test above fails for the limit's sake.
+func TestProcessLimitUnlimited(t *testing.T) {
+	fakeDeno(t, workerBomb)
+	r := testRunner(t)
+	asJobUser(t, r)
+	res := r.executeIn(&RunRequest{PublicID: "bomb", Code: "for (;;) new Worker(url)"}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" || !strings.Contains(res.Stdout, "started 50 workers") {
+		t.Fatalf("errorCode = %q (%s); stdout %q stderr %q", res.ErrorCode, res.Error, res.Stdout, res.Stderr)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+type rlimits struct {
+	NProc      uint64
+	CPUSeconds uint64
+}
+
+func (l rlimits) empty() bool { return l.NProc == 0 && l.CPUSeconds == 0 }
+
+func (l rlimits) apply(int) error {
+	return errors.New("setting limits on a running process requires Linux")
+}
+
+func isCPULimitSignal(syscall.Signal) bool { return false }
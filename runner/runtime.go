@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/nats-io/nats.go"
+)
+
+// runtimes are the runtimes a job can be sent to on
+// <prefix>.execute.<runtime>. Deno is the only one so far, and the
+// executor runs it for every job, including those on the bare
+// <prefix>.execute subject and from the work queue; a new runtime is
+// registered here along with its executor.
+var runtimes = map[string]bool{
+	"deno": true,
+}
+
+// batchToken is the <prefix>.execute.* token batches are sent on, so no
+// runtime can be called that.
+const batchToken = "batch"
+
+// runtimeNames lists the registered runtimes, for errors.
+func runtimeNames() []string {
+	names := make([]string, 0, len(runtimes))
+	for name := range runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleExecuteRuntime takes jobs on <prefix>.execute.<runtime>, and
+// batches on <prefix>.execute.batch: the wildcard subscription would take
+// them from a separate one in the same queue group.
+func (r *Runner) handleExecuteRuntime(m *nats.Msg) {
+	token := r.cfg.trimSubject(m.Subject, "execute")
+	if token == batchToken {
+		r.handleBatch(m)
+		return
+	}
+	if !runtimes[token] {
+		log.Printf("[ERROR] Rejecting request on %s: no runtime %q", m.Subject, token)
+		if m.Reply == "" {
+			m = r.publishToResults(m, "")
+		}
+		r.respond(m, RunResult{ExitCode: 1, Error: fmt.Sprintf("unknown runtime %q; runtimes are %v", token, runtimeNames()), ErrorCode: errorCodeValidation})
+		return
+	}
+	r.submit(m, nil)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// peakRSS is only reported on Linux and macOS, whose units are known.
+func peakRSS(*os.ProcessState) int64 { return 0 }
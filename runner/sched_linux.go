@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// I/O scheduling classes for ioprio_set(2).
+const (
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+
+	ioprioWhoPgrp    = 2
+	ioprioClassShift = 13
+)
+
+// schedPriority lowers the CPU and I/O priority of a job so it can't starve
+// the runner or a co-located NATS server.
+type schedPriority struct {
+	Nice    int // 0 leaves the inherited nice value
+	IOClass int // 0 leaves the inherited I/O class
+	IOLevel int // 0 (highest) to 7, for the best-effort class
+}
+
+func (s schedPriority) empty() bool { return s.Nice == 0 && s.IOClass == 0 }
+
+// apply sets the priorities on every thread of an already-started job. Jobs
+// run in their own process group, so addressing the group reaches threads
+// the child has already created; later ones inherit the values.
+func (s schedPriority) apply(pid int) error {
+	if s.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PGRP, pid, s.Nice); err != nil {
+			return fmt.Errorf("setpriority: %w", err)
+		}
+	}
+	if s.IOClass != 0 {
+		prio := s.IOClass<<ioprioClassShift | s.IOLevel
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoPgrp, uintptr(pid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("ioprio_set: %w", errno)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+const (
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+)
+
+type schedPriority struct {
+	Nice    int
+	IOClass int
+	IOLevel int
+}
+
+func (s schedPriority) empty() bool { return s.Nice == 0 && s.IOClass == 0 }
+
+func (s schedPriority) apply(int) error {
+	return errors.New("lowering the priority of a running process requires Linux")
+}
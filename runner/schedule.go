@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ScheduleAck is the immediate reply to a request with runAt.
+type ScheduleAck struct {
+	PublicID     string    `json:"publicId"`
+	Status       string    `json:"status"` // "scheduled"
+	RunAt        time.Time `json:"runAt"`
+	ReplySubject string    `json:"replySubject"`
+	// Metadata is the request's metadata, unchanged.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// scheduler holds runAt jobs until they are due.
+type scheduler struct {
+	mu   sync.Mutex
+	max  int
+	byID map[string][]*scheduledJob
+	n    int
+}
+
+type scheduledJob struct {
+	job   *pendingJob
+	timer *time.Timer
+}
+
+func newScheduler(max int) *scheduler {
+	return &scheduler{max: max, byID: make(map[string][]*scheduledJob)}
+}
+
+// schedule validates a runAt job, acknowledges it and arms its timer. The
+// job's reply is redirected to its ReplySubject, since the original request
+// will have timed out by the time it runs.
+func (r *Runner) schedule(job *pendingJob) {
+	req := &job.req
+	ack := ScheduleAck{PublicID: req.PublicID, Status: "scheduled", RunAt: *req.RunAt, ReplySubject: req.ReplySubject, Metadata: req.Metadata}
+	deferred := *job.msg
+	deferred.Reply = req.ReplySubject
+	orig := job.msg
+	job.msg = &deferred
+
+	s := r.scheduler
+	s.mu.Lock()
+	if s.n >= s.max {
+		s.mu.Unlock()
+		log.Printf("[SCHEDULE] Rejecting %s: %d jobs already scheduled", req.PublicID, s.max)
+		r.respond(orig, RunResult{ExitCode: -1, Error: fmt.Sprintf("too many scheduled jobs (max %d)", s.max), ErrorCode: errorCodeBusy})
+		return
+	}
+	sj := &scheduledJob{job: job}
+	sj.timer = time.AfterFunc(time.Until(*req.RunAt), func() {
+		if s.remove(sj) {
+			log.Printf("[SCHEDULE] Running %s scheduled for %s", req.PublicID, req.RunAt.Format(time.RFC3339))
+			job.deadline = r.cfg.jobDeadline(req, job.msg.Header, time.Now())
+			r.accept(job)
+		}
+	})
+	s.byID[req.PublicID] = append(s.byID[req.PublicID], sj)
+	s.n++
+	s.mu.Unlock()
+
+	log.Printf("[SCHEDULE] %s scheduled for %s, result to %s", req.PublicID, req.RunAt.Format(time.RFC3339), req.ReplySubject)
+	r.respond(orig, ack)
+}
+
+// remove unregisters sj and reports whether it was still pending.
+func (s *scheduler) remove(sj *scheduledJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := sj.job.req.PublicID
+	jobs := s.byID[id]
+	for i, j := range jobs {
+		if j == sj {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			if len(jobs) == 0 {
+				delete(s.byID, id)
+			} else {
+				s.byID[id] = jobs
+			}
+			s.n--
+			return true
+		}
+	}
+	return false
+}
+
+// cancel drops every scheduled job with publicID, publishing a canceled
+// result to each one's reply subject, and reports how many there were.
+func (s *scheduler) cancel(publicID string) int {
+	s.mu.Lock()
+	jobs := s.byID[publicID]
+	delete(s.byID, publicID)
+	s.n -= len(jobs)
+	s.mu.Unlock()
+	for _, sj := range jobs {
+		sj.timer.Stop()
+		sj.job.send(canceledResult())
+	}
+	return len(jobs)
+}
+
+// cancelAll drops every scheduled job, e.g. at shutdown, telling each
+// reply subject why.
+func (s *scheduler) cancelAll(cause error) {
+	s.mu.Lock()
+	all := s.byID
+	s.byID = make(map[string][]*scheduledJob)
+	s.n = 0
+	s.mu.Unlock()
+	for _, jobs := range all {
+		for _, sj := range jobs {
+			sj.timer.Stop()
+			sj.job.send(RunResult{ExitCode: -1, Error: cause.Error(), ErrorCode: errorCodeShutdown})
+		}
+	}
+}
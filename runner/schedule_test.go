@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// parkScheduled adds a job to s that won't come due during the test and
+// returns the result it is eventually sent.
+func parkScheduled(s *scheduler, publicID string) <-chan RunResult {
+	results := make(chan RunResult, 1)
+	job := &pendingJob{
+		req:  RunRequest{PublicID: publicID, Metadata: map[string]string{"trace": publicID}},
+		done: func(res RunResult) { results <- res },
+	}
+	sj := &scheduledJob{job: job, timer: time.AfterFunc(time.Hour, func() {})}
+	s.mu.Lock()
+	s.byID[publicID] = append(s.byID[publicID], sj)
+	s.n++
+	s.mu.Unlock()
+	return results
+}
+
+func TestSchedulerCancelSendsResult(t *testing.T) {
+	s := newScheduler(10)
+	a := parkScheduled(s, "a")
+	b := parkScheduled(s, "b")
+	if n := s.cancel("a"); n != 1 {
+		t.Fatalf("cancel = %d, want 1", n)
+	}
+	res := <-a
+	if res.ErrorCode != errorCodeCanceled || res.PublicID != "a" || res.Metadata["trace"] != "a" {
+		t.Errorf("canceled result %+v", res)
+	}
+	select {
+	case res := <-b:
+		t.Fatalf("b was sent %+v", res)
+	default:
+	}
+
+	s.cancelAll(errors.New("runner shutting down"))
+	res = <-b
+	if res.ErrorCode != errorCodeShutdown || res.PublicID != "b" || res.Metadata["trace"] != "b" {
+		t.Errorf("shutdown result %+v", res)
+	}
+	if s.n != 0 {
+		t.Errorf("%d jobs still scheduled", s.n)
+	}
+}
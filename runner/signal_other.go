@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "syscall"
+
+func signalName(sig syscall.Signal) string {
+	return sig.String()
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// signalName returns the conventional name of sig, such as "SIGKILL".
+func signalName(sig syscall.Signal) string {
+	return unix.SignalName(sig)
+}
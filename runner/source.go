@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// sourceFetchTimeout bounds the download of a request's source object.
+const sourceFetchTimeout = time.Minute
+
+// SourceRef points at a request's code in the JetStream object store, for
+// code and files too big to send inline. The object holds a JSON
+// SourceBundle. The runner only reads it: objects are the caller's to
+// clean up.
+type SourceRef struct {
+	Bucket string `json:"bucket"`
+	Name   string `json:"name"`
+	// Digest is the object's "SHA-256=<base64url>" digest, as the object
+	// store reports it; the download must match it.
+	Digest string `json:"digest"`
+}
+
+// SourceBundle is the content of a source object: what the request would
+// otherwise carry in code and files, encoded the same way.
+type SourceBundle struct {
+	Code  string            `json:"code"`
+	Files map[string]string `json:"files,omitempty"`
+}
+
+// validateSource checks a request's source reference. The bundle itself is
+// checked once it has been downloaded.
+func (c *Config) validateSource(req *RunRequest, errs *validationErrors) {
+	ref := req.Source
+	if ref == nil {
+		return
+	}
+	if req.Code != "" || len(req.Files) > 0 {
+		errs.add("source", "can't be combined with code or files: send them inline or by reference, not both")
+	}
+	if len(req.Steps) > 0 {
+		errs.add("source", "can't be combined with steps")
+	}
+	if ref.Bucket == "" {
+		errs.add("source.bucket", "is required")
+	}
+	if ref.Name == "" {
+		errs.add("source.name", "is required")
+	}
+	if _, err := parseDigest(ref.Digest); err != nil {
+		errs.add("source.digest", "%v", err)
+	}
+}
+
+// parseDigest decodes a "SHA-256=<base64url>" digest, padded or not.
+func parseDigest(s string) ([]byte, error) {
+	enc, ok := strings.CutPrefix(s, "SHA-256=")
+	if !ok {
+		return nil, fmt.Errorf("must be SHA-256= followed by the base64url digest")
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(enc, "="))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("is not a valid SHA-256 digest")
+	}
+	return sum, nil
+}
+
+// fetchSource downloads req's source object and returns the request with its
+// code and files filled in from it. The bundle is capped at MaxSourceBytes
+// as a whole rather than by the limits on inline code and files.
+func (r *Runner) fetchSource(req *RunRequest) (RunRequest, error) {
+	ref := req.Source
+	want, _ := parseDigest(ref.Digest) // checked by validateRequest
+	js, err := jetstream.New(r.nc)
+	if err != nil {
+		return RunRequest{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sourceFetchTimeout)
+	defer cancel()
+	store, err := js.ObjectStore(ctx, ref.Bucket)
+	if err != nil {
+		return RunRequest{}, fmt.Errorf("bucket %q: %w", ref.Bucket, err)
+	}
+	info, err := store.GetInfo(ctx, ref.Name)
+	if err != nil {
+		return RunRequest{}, fmt.Errorf("object %q: %w", ref.Name, err)
+	}
+	if int64(info.Size) > r.cfg.MaxSourceBytes {
+		return RunRequest{}, fmt.Errorf("object is %d bytes (max %d)", info.Size, r.cfg.MaxSourceBytes)
+	}
+	obj, err := store.Get(ctx, ref.Name)
+	if err != nil {
+		return RunRequest{}, fmt.Errorf("object %q: %w", ref.Name, err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(io.LimitReader(obj, r.cfg.MaxSourceBytes+1))
+	if err != nil {
+		return RunRequest{}, fmt.Errorf("reading object %q: %w", ref.Name, err)
+	}
+	if int64(len(data)) > r.cfg.MaxSourceBytes {
+		return RunRequest{}, fmt.Errorf("object is over %d bytes", r.cfg.MaxSourceBytes)
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], want) {
+		return RunRequest{}, fmt.Errorf("object %q does not match its digest", ref.Name)
+	}
+	var bundle SourceBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return RunRequest{}, fmt.Errorf("object %q is not a source bundle: %v", ref.Name, err)
+	}
+	resolved := *req
+	resolved.Code, resolved.Files, resolved.Source = bundle.Code, bundle.Files, nil
+	resolved.sourced = true
+	switch {
+	case resolved.Entrypoint != "":
+		if !hasFile(resolved.Files, resolved.Entrypoint) {
+			return RunRequest{}, fmt.Errorf("entrypoint must name one of the bundle's files")
+		}
+	case strings.TrimSpace(resolved.Code) == "":
+		return RunRequest{}, fmt.Errorf("the bundle has no code")
+	}
+	log.Printf("[SOURCE] Fetched %d-byte source of %s from %s/%s", len(data), req.PublicID, ref.Bucket, ref.Name)
+	return resolved, nil
+}
+
+// sourceFailed is the result of a job whose source couldn't be fetched:
+// a validation failure unless the object store couldn't be reached.
+func sourceFailed(err error) RunResult {
+	code := errorCodeValidation
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, jetstream.ErrJetStreamNotEnabled) {
+		code = errorCodeInternal
+	}
+	return RunResult{ExitCode: 1, Error: fmt.Sprintf("source: %v", err), ErrorCode: code}
+}
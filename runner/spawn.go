@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// jobProcess is a started deno process together with the resources that live
+// and die with it. Its output goes to switchWriters so the destination can be
+// attached after start, which is what lets warm processes be spawned before
+// the job they will run is known.
+type jobProcess struct {
+	cmd    *exec.Cmd
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	term   *groupTerminator
+	cg     *jobCgroup // nil when running without cgroup limits
+	stdin  io.WriteCloser
+	stdout *switchWriter
+	stderr *switchWriter
+
+	oomKillsBefore int64
+}
+
+// spawn starts deno with args in workdir. The code or data for stdin comes
+// from stdin, or, when stdin is nil, is written later through proc.stdin.
+// offline jobs are cut off from the network when the runner can do so, and
+// extraFiles are passed on as fd 3 onwards.
+// Canceling proc.ctx (with a cause) stops the process group.
+func (r *Runner) spawn(args []string, workdir string, env []string, stdin io.Reader, offline bool, extraFiles []*os.File) (*jobProcess, error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cmd := exec.CommandContext(ctx, "deno", args...)
+	cmd.Dir = workdir
+	cmd.Env = env
+	proc := &jobProcess{
+		cmd:    cmd,
+		ctx:    ctx,
+		cancel: cancel,
+		stdout: &switchWriter{},
+		stderr: &switchWriter{},
+	}
+	if stdin != nil {
+		cmd.Stdin = stdin
+	} else {
+		pipe, err := cmd.StdinPipe()
+		if err != nil {
+			cancel(nil)
+			return nil, err
+		}
+		proc.stdin = pipe
+	}
+	cmd.ExtraFiles = extraFiles
+	cmd.Stdout = proc.stdout
+	cmd.Stderr = proc.stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	setCredential(cmd.SysProcAttr, r.cfg.ExecCredential)
+	proc.term = terminateGroupOnCancel(cmd, r.cfg.KillGrace)
+
+	if r.cgroups != nil {
+		cg, err := r.cgroups.create(r.cfg.cgroupLimits())
+		if err != nil {
+			log.Printf("[WARN] Failed to create job cgroup, running without cgroup limits: %v", err)
+		} else {
+			proc.cg = cg
+			cg.apply(cmd.SysProcAttr)
+		}
+	}
+
+	proc.oomKillsBefore = kernelOOMKills()
+	start := r.reaper.start
+	if offline && r.netIsolation {
+		start = func(cmd *exec.Cmd) error { return startIsolated(cmd, r.reaper.start) }
+	}
+	if err := start(cmd); err != nil {
+		proc.cleanup()
+		return nil, err
+	}
+	if !r.rlimits.empty() {
+		if err := r.rlimits.apply(cmd.Process.Pid); err != nil {
+			log.Printf("[WARN] Failed to apply rlimits to pid %d: %v", cmd.Process.Pid, err)
+		}
+	}
+	if !r.sched.empty() {
+		if err := r.sched.apply(cmd.Process.Pid); err != nil {
+			log.Printf("[WARN] Failed to lower priority of pid %d: %v", cmd.Process.Pid, err)
+		}
+	}
+	return proc, nil
+}
+
+// cleanup releases the process's cgroup and context. Call it after Wait.
+func (p *jobProcess) cleanup() {
+	if p.cg != nil {
+		p.cg.cleanup()
+	}
+	p.cancel(nil)
+}
+
+// switchWriter holds output until a destination is attached, then forwards
+// everything to it.
+type switchWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending strings.Builder
+}
+
+func (s *switchWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return s.pending.Write(p)
+	}
+	return s.w.Write(p)
+}
+
+// attach sets the destination and flushes anything written before it.
+func (s *switchWriter) attach(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+	if s.pending.Len() > 0 {
+		_, _ = io.WriteString(w, s.pending.String())
+		s.pending.Reset()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// spawnWorker starts the fake deno through r.spawn. It backgrounds a worker
+// with its output detached, writes the worker's pid to a file and waits for
+// it, and spawnWorker returns the started process and the worker's pid.
+func spawnWorker(t *testing.T, r *Runner, worker string) (*jobProcess, int) {
+	t.Helper()
+	pidFile := filepath.Join(t.TempDir(), "worker.pid")
+	fakeDeno(t, `(`+worker+`) </dev/null >/dev/null 2>&1 &
+echo $! > "$WORKER_PID.tmp" && mv "$WORKER_PID.tmp" "$WORKER_PID"
+wait`)
+	env := append(os.Environ(), "WORKER_PID="+pidFile)
+	proc, err := r.spawn(nil, t.TempDir(), env, strings.NewReader(""), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(proc.cleanup)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(pidFile)
+		if err == nil {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return proc, pid
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker never started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// procStat returns the fields of /proc/<pid>/stat after the command name,
+// so field n of proc(5) is at index n-3.
+func procStat(t *testing.T, pid int) []string {
+	t.Helper()
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+	return strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+}
+
+func TestTimeoutKillsProcessGroup(t *testing.T) {
+	cr := subreaper(t)
+	pidFile := filepath.Join(t.TempDir(), "worker.pid")
+	// The worker shrugs off SIGTERM and is detached from the job's output,
+	// so nothing but a SIGKILL to the whole group stops it.
+	fakeDeno(t, `(trap "" TERM; while :; do sleep 1; done) </dev/null >/dev/null 2>&1 &
+echo $! > `+pidFile+`
+wait`)
+	r := testRunner(t)
+	r.cfg.KillGrace = 100 * time.Millisecond
+	res := r.executeIn(&RunRequest{PublicID: "detached", Code: "new Worker(url)", TimeoutMs: 300}, 0, time.Time{}, nil)
+	if res.ErrorCode != errorCodeTimeout {
+		t.Errorf("errorCode = %q (%s), want %s", res.ErrorCode, res.Error, errorCodeTimeout)
+	}
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker was orphaned to the test process, so once it is a zombie
+	// it has died.
+	waitZombie(t, worker)
+	cr.reap()
+	if err := syscall.Kill(worker, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("worker %d still exists (kill: %v)", worker, err)
+	}
+}
+
+func TestSpawnLowersPriority(t *testing.T) {
+	r := testRunner(t)
+	r.sched = schedPriority{Nice: 7, IOClass: ioClassIdle}
+	proc, worker := spawnWorker(t, r, `while :; do sleep 1; done`)
+	defer func() {
+		proc.cancel(errors.New("test done"))
+		proc.cmd.Wait()
+	}()
+
+	// The worker may fork before or after the priority is set, and it must
+	// end up lowered either way.
+	for _, pid := range []int{proc.cmd.Process.Pid, worker} {
+		if nice := procStat(t, pid)[19-3]; nice != "7" {
+			t.Errorf("pid %d has nice %s, want 7", pid, nice)
+		}
+		prio, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, 1, uintptr(pid), 0)
+		if errno != 0 {
+			t.Fatalf("ioprio_get: %v", errno)
+		}
+		if class := int(prio) >> ioprioClassShift; class != ioClassIdle {
+			t.Errorf("pid %d has I/O class %d, want %d (idle)", pid, class, ioClassIdle)
+		}
+	}
+	if nice := procStat(t, os.Getpid())[19-3]; nice != "0" {
+		t.Errorf("runner's own nice changed to %s", nice)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// stdinCloseHeader marks a runner.stdin.<publicId> message as end of input;
+// any payload it carries is written first.
+const stdinCloseHeader = "Runner-Stdin-Close"
+
+// StdinAck is the reply to a runner.stdin message that asked for one.
+type StdinAck struct {
+	Status string `json:"status"` // "ok", "closed" or "error"
+	Error  string `json:"error,omitempty"`
+}
+
+// stdinForwarder feeds messages from runner.stdin.<publicId> into an
+// interactive job's stdin pipe. If no message arrives within the idle
+// timeout before input is closed, onIdle is called so the job can be stopped.
+type stdinForwarder struct {
+	r       *Runner
+	mu      sync.Mutex
+	pipe    io.WriteCloser
+	sub     *nats.Subscription
+	idle    *time.Timer
+	timeout time.Duration
+	closed  bool
+}
+
+func (r *Runner) forwardStdin(publicID string, pipe io.WriteCloser, initial []byte, onIdle func()) (*stdinForwarder, error) {
+	f := &stdinForwarder{r: r, pipe: pipe, timeout: r.cfg.StdinIdleTimeout}
+	f.idle = time.AfterFunc(f.timeout, onIdle)
+	if len(initial) > 0 {
+		if _, err := pipe.Write(initial); err != nil {
+			f.idle.Stop()
+			return nil, err
+		}
+	}
+	sub, err := r.nc.Subscribe(r.cfg.subjectID("stdin", publicID), f.handle)
+	if err != nil {
+		f.idle.Stop()
+		return nil, err
+	}
+	f.sub = sub
+	return f, nil
+}
+
+func (f *stdinForwarder) handle(m *nats.Msg) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		f.ack(m, StdinAck{Status: "closed", Error: "stdin already closed"})
+		return
+	}
+	f.idle.Stop()
+	if len(m.Data) > 0 {
+		if _, err := f.pipe.Write(m.Data); err != nil {
+			f.closed = true
+			f.ack(m, StdinAck{Status: "error", Error: err.Error()})
+			return
+		}
+	}
+	if m.Header.Get(stdinCloseHeader) != "" {
+		f.closed = true
+		f.pipe.Close()
+		f.ack(m, StdinAck{Status: "closed"})
+		return
+	}
+	f.idle.Reset(f.timeout)
+	f.ack(m, StdinAck{Status: "ok"})
+}
+
+func (f *stdinForwarder) ack(m *nats.Msg, a StdinAck) {
+	if m.Reply != "" {
+		f.r.respond(m, a)
+	}
+}
+
+// stop unsubscribes and disarms the idle timer once the process has exited.
+func (f *stdinForwarder) stop() {
+	if err := f.sub.Unsubscribe(); err != nil {
+		log.Printf("[STDIN] Failed to unsubscribe %s: %v", f.sub.Subject, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.idle.Stop()
+	f.closed = true
+}
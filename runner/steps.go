@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxSteps caps the length of a pipeline.
+const maxSteps = 16
+
+// Step is one script of a pipeline. Steps run one after another in the
+// same working directory, so a step can read what earlier ones wrote.
+type Step struct {
+	Name        string   `json:"name,omitempty"`
+	Code        string   `json:"code"`
+	Permissions []string `json:"permissions,omitempty"`
+	TimeoutMs   int64    `json:"timeoutMs,omitempty"`
+}
+
+// jobDir is the working directory a pipeline's steps share.
+type jobDir struct {
+	path     string
+	quota    *diskQuota
+	prepared bool // the first step has written the request's config files
+}
+
+// removeConfigFiles removes the configuration files an earlier step ran
+// with, or whatever it left in their place, so they can be written again.
+func (d *jobDir) removeConfigFiles(req *RunRequest) error {
+	var names []string
+	if len(req.ImportMap) > 0 {
+		names = append(names, importMapFileName)
+	}
+	if len(req.DenoConfig) > 0 {
+		names = append(names, denoConfigName)
+	}
+	if req.Lockfile != "" {
+		names = append(names, lockfileName)
+	}
+	for _, name := range names {
+		if err := os.RemoveAll(filepath.Join(d.path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSteps checks a pipeline request. The request-wide settings apply
+// to every step, except those each step sets for itself.
+func (c *Config) validateSteps(req *RunRequest, errs *validationErrors) {
+	if len(req.Steps) == 0 {
+		if req.ContinueOnError {
+			errs.add("continueOnError", "is only valid with steps")
+		}
+		return
+	}
+	switch {
+	case len(req.Steps) > maxSteps:
+		errs.add("steps", "has %d steps (max %d)", len(req.Steps), maxSteps)
+	case jobMode(req) != modeRun:
+		errs.add("steps", "are only supported in run mode")
+	case req.Entrypoint != "":
+		errs.add("steps", "can't be combined with entrypoint")
+	case req.Stdin != nil || req.InteractiveStdin:
+		errs.add("steps", "can't be combined with stdin")
+	case len(req.Permissions) > 0 || req.TimeoutMs != 0:
+		errs.add("steps", "set their own permissions and timeoutMs")
+	}
+	for i, step := range req.Steps {
+		field := fmt.Sprintf("steps[%d]", i)
+		switch {
+		case step.Code == "":
+			errs.add(field+".code", "is required")
+		case int64(len(step.Code)) > c.MaxCodeBytes:
+			errs.add(field+".code", "is %d bytes (max %d)", len(step.Code), c.MaxCodeBytes)
+		}
+		if step.TimeoutMs < 0 {
+			errs.add(field+".timeoutMs", "must not be negative")
+		}
+	}
+}
+
+// stepRequest is the request step i of req runs as.
+func stepRequest(req *RunRequest, i int) RunRequest {
+	step := req.Steps[i]
+	s := *req
+	s.Code, s.Permissions, s.TimeoutMs = step.Code, step.Permissions, step.TimeoutMs
+	s.Steps, s.ContinueOnError = nil, false
+	// Files are written once for the pipeline and artifacts collected once
+	// it is over; only the last step can return a value.
+	s.Files, s.CollectArtifacts = nil, nil
+	s.ReturnValue = req.ReturnValue && i == len(req.Steps)-1
+	return s
+}
+
+// executeSteps runs a pipeline. Every step's permissions are checked before
+// the first one starts, so a pipeline doesn't fail halfway for a reason
+// known up front. The result sums the steps' resource use and reports the
+// first failing step's error; the steps' own results, output included, are
+// in Steps.
+func (r *Runner) executeSteps(req *RunRequest, slot int, deadline time.Time) RunResult {
+	tenant := r.cfg.tenantOf(req)
+	for i, step := range req.Steps {
+		perms, err := validatePermissions(step.Permissions)
+		if err == nil {
+			err = r.cfg.checkPermissionCeiling(tenant, perms)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Permission validation failed for %s step %d: %v", req.PublicID, i, err)
+			return RunResult{ExitCode: 1, Error: fmt.Sprintf("Permission validation failed: step %d: %v", i, err), ErrorCode: errorCodePermissionDenied}
+		}
+	}
+
+	path, err := createWorkdir(r.cfg.WorkDir, r.cfg.ExecCredential)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create working directory: %v", err)
+		return RunResult{ExitCode: -1, Error: fmt.Sprintf("failed to prepare working directory: %v", err), ErrorCode: errorCodeInternal}
+	}
+	defer os.RemoveAll(path)
+	dir := &jobDir{path: path}
+	if r.cfg.DiskQuota > 0 {
+		dir.quota = newDiskQuota(path, r.cfg.DiskQuota, r.cfg.ExecCredential)
+		defer dir.quota.release()
+	}
+	if len(req.Files) > 0 {
+		if err := writeInputFiles(path, req.Files, r.cfg.inputFilesLimit(req), r.cfg.ExecCredential); err != nil {
+			log.Printf("[ERROR] Invalid input files for %s: %v", req.PublicID, err)
+			return RunResult{ExitCode: 1, Error: fmt.Sprintf("invalid input files: %v", err), ErrorCode: errorCodeValidation}
+		}
+	}
+
+	var res RunResult
+	for i := range req.Steps {
+		step := stepRequest(req, i)
+		log.Printf("[STEP] Running step %d/%d of %s", i+1, len(req.Steps), req.PublicID)
+		sr := r.executeIn(&step, slot, deadline, dir)
+		if i == 0 {
+			res.StartedAt = sr.StartedAt
+		}
+		res.FinishedAt = sr.FinishedAt
+		res.DurationMs += sr.DurationMs
+		res.UserCPUMs += sr.UserCPUMs
+		res.SystemCPUMs += sr.SystemCPUMs
+		res.OutputBytes += sr.OutputBytes
+		res.Heartbeats += sr.Heartbeats
+		res.PeakRssBytes = max(res.PeakRssBytes, sr.PeakRssBytes)
+		res.Limits = sr.Limits
+		res.Value, res.ValueError = sr.Value, sr.ValueError
+		res.DiskUsageBytes, res.DiskQuotaBytes = sr.DiskUsageBytes, sr.DiskQuotaBytes
+		res.Steps = append(res.Steps, sr)
+		if sr.ErrorCode == "" {
+			continue
+		}
+		if res.ErrorCode == "" {
+			res.ExitCode, res.ExitSignal, res.ErrorCode = sr.ExitCode, sr.ExitSignal, sr.ErrorCode
+			res.Error = fmt.Sprintf("step %d failed: %s", i, sr.Error)
+			// Retrying is only safe if nothing has run yet.
+			res.retryable = sr.retryable && i == 0
+		}
+		stopped := sr.ErrorCode == errorCodeCanceled || sr.ErrorCode == errorCodeShutdown || sr.ErrorCode == errorCodeExpired
+		if !req.ContinueOnError || stopped {
+			if i < len(req.Steps)-1 {
+				log.Printf("[STEP] %s stopping after step %d: %s", req.PublicID, i+1, sr.ErrorCode)
+			}
+			break
+		}
+	}
+	if len(req.CollectArtifacts) > 0 {
+		res.Artifacts = collectArtifacts(path, req.CollectArtifacts, r.cfg.MaxArtifactBytes, r.cfg.MaxArtifactsTotalBytes)
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStepsSourcedFiles checks a pipeline's files from a source object are
+// held to the source's limit, as a single job's are, not the inline one.
+func TestStepsSourcedFiles(t *testing.T) {
+	fakeDeno(t, `wc -c < data.bin`)
+	r := testRunner(t)
+	r.cfg.MaxInputFilesBytes = 1 << 10
+	r.cfg.MaxSourceBytes = 1 << 20
+	files := map[string]string{"data.bin": base64.StdEncoding.EncodeToString(make([]byte, 4<<10))}
+	steps := []Step{{Code: "read()"}, {Code: "readAgain()"}}
+
+	for _, sourced := range []bool{false, true} {
+		req := &RunRequest{PublicID: "pipeline", Steps: steps, Files: files, sourced: sourced}
+		res := r.executeSteps(req, 0, time.Time{})
+		if !sourced {
+			if res.ErrorCode != errorCodeValidation || !strings.Contains(res.Error, "invalid input files") {
+				t.Errorf("inline files over the limit: errorCode = %q (%s)", res.ErrorCode, res.Error)
+			}
+			continue
+		}
+		if res.ErrorCode != "" {
+			t.Fatalf("sourced files: errorCode = %q (%s)", res.ErrorCode, res.Error)
+		}
+		if len(res.Steps) != 2 || strings.TrimSpace(res.Steps[1].Stdout) != "4096" {
+			t.Errorf("steps %+v, want both to see the 4096-byte file", res.Steps)
+		}
+	}
+}
+
+// TestStepsConfigFilesRewritten checks each step runs with the request's
+// configuration files, whatever the step before it did to them.
+func TestStepsConfigFilesRewritten(t *testing.T) {
+	fakeDeno(t, `cat deno.json .runner-import-map.json deno.lock
+echo '{"tampered": true}' > deno.json
+rm .runner-import-map.json && mkdir .runner-import-map.json
+ln -sf /etc/passwd deno.lock`)
+	r := testRunner(t)
+	req := &RunRequest{
+		PublicID:   "pipeline",
+		Steps:      []Step{{Code: "first()"}, {Code: "second()"}},
+		DenoConfig: json.RawMessage(`{"compilerOptions":{"strict":true}}`),
+		ImportMap:  json.RawMessage(`{"imports":{}}`),
+		Lockfile:   `{"version":"4"}`,
+	}
+	res := r.executeSteps(req, 0, time.Time{})
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	first, second := res.Steps[0].Stdout, res.Steps[1].Stdout
+	if !strings.Contains(first, `{"version":"4"}`) || second != first {
+		t.Errorf("first step saw %q, second %q", first, second)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// streamChunkBytes is the most output buffered before a chunk is published
+	// even without a newline.
+	streamChunkBytes = 4 << 10
+	// streamFlushInterval bounds how long a partial line waits to be published.
+	streamFlushInterval = 100 * time.Millisecond
+)
+
+// OutputChunk is one piece of live output published on
+// runner.output.<publicId> for requests with stream set. Seq increases by one
+// per event across both streams, so subscribers can restore the original
+// interleaving. The last event has EOF set and no data.
+type OutputChunk struct {
+	Stream string    `json:"stream,omitempty"`
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"t"`
+	Data   string    `json:"data,omitempty"`
+	EOF    bool      `json:"eof,omitempty"`
+}
+
+// outputStreamer publishes a job's output as it is produced. Output is
+// line-buffered: complete lines go out immediately, partial lines after
+// streamFlushInterval or once streamChunkBytes accumulate.
+type outputStreamer struct {
+	nc      *nats.Conn
+	subject string
+
+	mu     sync.Mutex
+	seq    uint64
+	bufs   map[string]*bytes.Buffer
+	failed bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newOutputStreamer(nc *nats.Conn, subject string) *outputStreamer {
+	s := &outputStreamer{
+		nc:      nc,
+		subject: subject,
+		bufs:    map[string]*bytes.Buffer{"stdout": {}, "stderr": {}},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.flushLoop()
+	return s
+}
+
+// writer returns the io.Writer for one stream ("stdout" or "stderr").
+func (s *outputStreamer) writer(stream string) io.Writer {
+	return streamChunkWriter{s: s, stream: stream}
+}
+
+type streamChunkWriter struct {
+	s      *outputStreamer
+	stream string
+}
+
+func (w streamChunkWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	buf := w.s.bufs[w.stream]
+	buf.Write(p)
+	for {
+		data := buf.Bytes()
+		n := bytes.LastIndexByte(data, '\n') + 1
+		if n > streamChunkBytes || n == 0 && len(data) >= streamChunkBytes {
+			n = runeBoundary(data[:streamChunkBytes])
+		}
+		if n == 0 {
+			return len(p), nil
+		}
+		w.s.publish(w.stream, buf.Next(n))
+	}
+}
+
+// close publishes whatever is still buffered followed by the EOF event.
+func (s *outputStreamer) close() {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stream := range []string{"stdout", "stderr"} {
+		if buf := s.bufs[stream]; buf.Len() > 0 {
+			s.publish(stream, buf.Next(buf.Len()))
+		}
+	}
+	s.emit(OutputChunk{EOF: true})
+}
+
+func (s *outputStreamer) flushLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(streamFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			for _, stream := range []string{"stdout", "stderr"} {
+				buf := s.bufs[stream]
+				if n := runeBoundary(buf.Bytes()); n > 0 {
+					s.publish(stream, buf.Next(n))
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// publish sends one chunk; s.mu must be held.
+func (s *outputStreamer) publish(stream string, data []byte) {
+	s.emit(OutputChunk{Stream: stream, Data: string(data)})
+}
+
+func (s *outputStreamer) emit(chunk OutputChunk) {
+	s.seq++
+	chunk.Seq = s.seq
+	chunk.Time = time.Now()
+	data, _ := json.Marshal(chunk)
+	if err := s.nc.Publish(s.subject, data); err != nil && !s.failed {
+		s.failed = true
+		log.Printf("[STREAM] Failed to publish output on %s: %v", s.subject, err)
+	}
+}
+
+// runeBoundary returns the length of the longest prefix of data that does not
+// end inside a multi-byte UTF-8 sequence, so chunks never split a character.
+func runeBoundary(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if utf8.FullRune(data[i:]) {
+				return len(data)
+			}
+			return i
+		}
+	}
+	return len(data)
+}
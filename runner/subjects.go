@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+)
+
+// subject returns the subject name under the runner's subject prefix, such
+// as "runner.execute" for "execute".
+func (c *Config) subject(name string) string {
+	return c.SubjectPrefix + "." + name
+}
+
+// subjectID returns the per-job subject name.<publicId> under the prefix.
+func (c *Config) subjectID(name, publicID string) string {
+	return c.subject(name) + "." + publicID
+}
+
+// trimSubject returns what follows <prefix>.name. in subject.
+func (c *Config) trimSubject(subject, name string) string {
+	return strings.TrimPrefix(subject, c.subject(name)+".")
+}
+
+// subjects lists the subjects the runner uses, for the startup log and
+// runner.info. Per-job subjects end in <publicId>.
+func (c *Config) subjects() map[string]string {
+	s := map[string]string{
+		"execute":  c.subject("execute"),
+		"batch":    c.subject("execute." + batchToken),
+		"runtime":  c.subjectID("execute", "<runtime>"),
+		"cancel":   c.subject("cancel"),
+		"info":     c.subject("info"),
+		"result":   c.subjectID("result", "<publicId>"),
+		"tail":     c.subjectID("tail", "<publicId>"),
+		"admin":    c.subject("admin.queue"),
+		"progress": c.subjectID("progress", "<publicId>"),
+		"output":   c.subjectID("output", "<publicId>"),
+		"stdin":    c.subjectID("stdin", "<publicId>"),
+		"webhook":  c.subjectID("webhook", "<publicId>"),
+		"results":  c.ResultsSubject,
+	}
+	if c.TenantSubjects {
+		s["tenant"] = c.subjectID("execute."+tenantToken, "<tenant>[.<runtime>|.batch]")
+	}
+	if c.WorkStream != "" {
+		s["work"] = c.WorkSubject
+		s["deadLetters"] = c.DeadLetterSubject
+	}
+	return s
+}
+
+// logSubjects logs the subjects the runner uses.
+func (c *Config) logSubjects() {
+	subjects := c.subjects()
+	names := make([]string, 0, len(subjects))
+	for name := range subjects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]string, len(names))
+	for i, name := range names {
+		list[i] = name + "=" + subjects[name]
+	}
+	log.Printf("Subjects under %q: %s", c.SubjectPrefix, strings.Join(list, " "))
+}
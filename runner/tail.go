@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// tailLines is how many recent lines of output each running job keeps.
+	tailLines = 200
+	// tailLineBytes caps a single buffered line; longer lines are cut.
+	tailLineBytes = 4 << 10
+)
+
+// TailRequest is the optional body of a message on runner.tail.<publicId>.
+type TailRequest struct {
+	Lines int `json:"lines,omitempty"` // defaults to 100, at most tailLines
+}
+
+// TailResult is the reply to a tail request.
+type TailResult struct {
+	PublicID  string   `json:"publicId"`
+	Status    string   `json:"status"` // "ok" or "not_found"
+	State     string   `json:"state,omitempty"`
+	ElapsedMs int64    `json:"elapsedMs,omitempty"`
+	Lines     []string `json:"lines,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// lineRing keeps the last lines written to a job's stdout and stderr.
+// Partial lines are tracked per stream so the two never mix mid-line.
+type lineRing struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial map[string][]byte
+}
+
+func newLineRing(n int) *lineRing {
+	return &lineRing{lines: make([]string, n), partial: make(map[string][]byte)}
+}
+
+func (lr *lineRing) writer(stream string) *lineRingWriter {
+	return &lineRingWriter{lr: lr, stream: stream}
+}
+
+type lineRingWriter struct {
+	lr     *lineRing
+	stream string
+}
+
+func (w *lineRingWriter) Write(p []byte) (int, error) {
+	lr := w.lr
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	buf := lr.partial[w.stream]
+	for _, c := range p {
+		if c == '\n' {
+			lr.push(string(buf))
+			buf = buf[:0]
+			continue
+		}
+		if len(buf) < tailLineBytes {
+			buf = append(buf, c)
+		}
+	}
+	lr.partial[w.stream] = buf
+	return len(p), nil
+}
+
+func (lr *lineRing) push(line string) {
+	lr.lines[lr.next] = line
+	lr.next = (lr.next + 1) % len(lr.lines)
+	if lr.next == 0 {
+		lr.full = true
+	}
+}
+
+// last returns up to n of the most recent lines, oldest first, followed by
+// any line still being written.
+func (lr *lineRing) last(n int) []string {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	var out []string
+	if lr.full {
+		out = append(out, lr.lines[lr.next:]...)
+	}
+	out = append(out, lr.lines[:lr.next]...)
+	for _, stream := range []string{"stdout", "stderr"} {
+		if p := lr.partial[stream]; len(p) > 0 {
+			out = append(out, string(p))
+		}
+	}
+	if len(out) > n {
+		out = out[len(out)-n:]
+	}
+	return out
+}
+
+func (r *Runner) handleTail(m *nats.Msg) {
+	publicID := r.cfg.trimSubject(m.Subject, "tail")
+	req := TailRequest{Lines: 100}
+	if len(m.Data) > 0 {
+		if err := json.Unmarshal(m.Data, &req); err != nil {
+			r.respond(m, TailResult{PublicID: publicID, Status: "not_found", Error: "invalid tail request"})
+			return
+		}
+	}
+	if req.Lines <= 0 || req.Lines > tailLines {
+		req.Lines = tailLines
+	}
+
+	job := r.jobs.latest(publicID)
+	if job == nil {
+		log.Printf("[TAIL] No in-flight job for: %s", publicID)
+		r.respond(m, TailResult{PublicID: publicID, Status: "not_found", Error: "job not found or already completed"})
+		return
+	}
+	r.respond(m, TailResult{
+		PublicID:  publicID,
+		Status:    "ok",
+		State:     job.getState(),
+		ElapsedMs: time.Since(job.started).Milliseconds(),
+		Lines:     job.tail.last(req.Lines),
+	})
+}
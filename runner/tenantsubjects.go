@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Tenant subjects, with RUNNER_TENANT_SUBJECTS set, take jobs on
+// <prefix>.execute.tenant.<tenant> and <prefix>.execute.tenant.<tenant>.<runtime>,
+// so NATS account permissions can say which tenant a client may submit as:
+// publish rights on runner.execute.tenant.a and runner.execute.tenant.a.>
+// only. Batches go to <prefix>.execute.tenant.<tenant>.batch. The
+// subject's tenant is authoritative: it replaces RunRequest.Tenant, and that
+// of every batch entry, for quotas, permission ceilings and logs. A payload
+// that claims another tenant, in Tenant or its PublicID prefix, is logged,
+// and rejected with RUNNER_TENANT_SUBJECT_STRICT.
+//
+// Jobs on any other subject, the bare execute, runtime and batch subjects and
+// the work queue, may not claim a tenant at all, so a client can't choose its
+// tenant by sending there. They still run as no tenant, so the NATS
+// permissions of tenants' clients must not allow publishing on them.
+const tenantToken = "tenant"
+
+// subjectTenant returns the tenant and runtime tokens of a tenant subject.
+func (c *Config) subjectTenant(subject string) (tenant, runtime string, ok bool) {
+	if !c.TenantSubjects {
+		return "", "", false
+	}
+	rest, ok := strings.CutPrefix(subject, c.subject("execute."+tenantToken)+".")
+	if !ok {
+		return "", "", false
+	}
+	tenant, runtime, _ = strings.Cut(rest, ".")
+	return tenant, runtime, true
+}
+
+// handleTenantExecute takes jobs on tenant subjects.
+func (r *Runner) handleTenantExecute(m *nats.Msg) {
+	_, runtime, _ := r.cfg.subjectTenant(m.Subject)
+	if runtime == batchToken {
+		r.handleBatch(m)
+		return
+	}
+	if runtime != "" && !runtimes[runtime] {
+		log.Printf("[ERROR] Rejecting request on %s: no runtime %q", m.Subject, runtime)
+		if m.Reply == "" {
+			m = r.publishToResults(m, "")
+		}
+		r.respond(m, RunResult{ExitCode: 1, Error: fmt.Sprintf("unknown runtime %q; runtimes are %v", runtime, runtimeNames()), ErrorCode: errorCodeValidation})
+		return
+	}
+	r.submit(m, nil)
+}
+
+// applySubjectTenant makes the tenant of the subject req came on, if it is
+// a tenant subject, req's tenant. It returns an error if the payload claims
+// another one and mismatches are rejected, or if req claims a tenant on a
+// subject that is not a tenant's.
+func (r *Runner) applySubjectTenant(m *nats.Msg, req *RunRequest) error {
+	if !r.cfg.TenantSubjects {
+		return nil
+	}
+	claimed := r.cfg.tenantOf(req)
+	tenant, _, ok := r.cfg.subjectTenant(m.Subject)
+	if !ok {
+		if claimed != "" {
+			log.Printf("[TENANT] %s claims tenant %q on %s, which is not a tenant subject", req.PublicID, claimed, m.Subject)
+			return fmt.Errorf("jobs of tenant %q must be sent on %s", claimed, r.cfg.subjectID("execute."+tenantToken, claimed))
+		}
+		return nil
+	}
+	if claimed != "" && claimed != tenant {
+		log.Printf("[TENANT] %s came on the subject of tenant %q but claims tenant %q", req.PublicID, tenant, claimed)
+		if r.cfg.TenantSubjectStrict {
+			return fmt.Errorf("tenant %q does not match the subject's tenant %q", claimed, tenant)
+		}
+	}
+	req.Tenant = tenant
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func tenantRunner(t *testing.T, strict bool) *Runner {
+	t.Helper()
+	r := testRunner(t)
+	r.cfg.SubjectPrefix = "runner"
+	r.cfg.TenantSubjects = true
+	r.cfg.TenantSubjectStrict = strict
+	return r
+}
+
+func TestApplySubjectTenant(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		subject string
+		req     RunRequest
+		tenant  string // req.Tenant afterwards
+		err     string
+	}{
+		{name: "subject tenant", subject: "runner.execute.tenant.a", req: RunRequest{PublicID: "job"}, tenant: "a"},
+		{name: "with runtime", subject: "runner.execute.tenant.a.deno", req: RunRequest{PublicID: "job"}, tenant: "a"},
+		{name: "batch", subject: "runner.execute.tenant.a.batch", req: RunRequest{PublicID: "b/0"}, tenant: "a"},
+		{name: "same claim", subject: "runner.execute.tenant.a", req: RunRequest{PublicID: "a:job", Tenant: "a"}, tenant: "a"},
+		{name: "other claim logged", subject: "runner.execute.tenant.a", req: RunRequest{PublicID: "job", Tenant: "b"}, tenant: "a"},
+		{name: "other claim strict", strict: true, subject: "runner.execute.tenant.a", req: RunRequest{PublicID: "job", Tenant: "b"}, err: `tenant "b" does not match the subject's tenant "a"`},
+		{name: "other prefix strict", strict: true, subject: "runner.execute.tenant.a", req: RunRequest{PublicID: "b:job"}, err: `tenant "b" does not match`},
+		{name: "bare untenanted", subject: "runner.execute", req: RunRequest{PublicID: "job"}},
+		{name: "bare claim", subject: "runner.execute", req: RunRequest{PublicID: "job", Tenant: "a"}, err: `jobs of tenant "a" must be sent on runner.execute.tenant.a`},
+		{name: "bare prefix", subject: "runner.execute", req: RunRequest{PublicID: "a:job"}, err: `jobs of tenant "a" must be sent on runner.execute.tenant.a`},
+		{name: "batch claim", subject: "runner.execute.batch", req: RunRequest{PublicID: "b/0", Tenant: "a"}, err: "must be sent on"},
+		{name: "runtime claim", subject: "runner.execute.deno", req: RunRequest{PublicID: "job", Tenant: "a"}, err: "must be sent on"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tenantRunner(t, tt.strict)
+			captureLog(t)
+			req := tt.req
+			err := r.applySubjectTenant(&nats.Msg{Subject: tt.subject}, &req)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Tenant != tt.tenant {
+				t.Errorf("tenant %q, want %q", req.Tenant, tt.tenant)
+			}
+		})
+	}
+}
+
+// TestApplySubjectTenantOff checks payload tenants are trusted as before
+// when tenant subjects are off.
+func TestApplySubjectTenantOff(t *testing.T) {
+	r := testRunner(t)
+	req := RunRequest{PublicID: "a:job", Tenant: "b"}
+	if err := r.applySubjectTenant(&nats.Msg{Subject: "runner.execute"}, &req); err != nil || req.Tenant != "b" {
+		t.Errorf("err = %v, tenant %q", err, req.Tenant)
+	}
+}
+
+// TestTenantBatchEntries checks each entry of a batch is held to the tenant
+// of the subject the batch came on.
+func TestTenantBatchEntries(t *testing.T) {
+	tests := []struct {
+		subject string
+		entry   RunRequest
+		err     string
+	}{
+		{"runner.execute.tenant.a.batch", RunRequest{PublicID: "b/0", Code: "1", Tenant: "b"}, `tenant "b" does not match the subject's tenant "a"`},
+		{"runner.execute.batch", RunRequest{PublicID: "b/0", Code: "1", Tenant: "a"}, `jobs of tenant "a" must be sent on runner.execute.tenant.a`},
+	}
+	for _, tt := range tests {
+		r := tenantRunner(t, true)
+		captureLog(t)
+		var got RunResult
+		r.acceptEntry(&nats.Msg{Subject: tt.subject}, &BatchEntry{ID: "0", RunRequest: tt.entry}, time.Now().Add(time.Minute), func(res RunResult) { got = res })
+		if got.ErrorCode != errorCodePermissionDenied || got.Error != tt.err {
+			t.Errorf("%s: entry result %q (%s), want %s %q", tt.subject, got.ErrorCode, got.Error, errorCodePermissionDenied, tt.err)
+		}
+	}
+}
+
+// TestTenantBatchSubject checks batches on a tenant's subject are taken as
+// batches rather than turned away as an unknown runtime.
+func TestTenantBatchSubject(t *testing.T) {
+	r := tenantRunner(t, false)
+	logs := captureLog(t)
+	r.handleTenantExecute(&nats.Msg{Subject: "runner.execute.tenant.a.batch", Data: []byte("{")})
+	if !strings.Contains(logs.String(), "Bad batch data") || strings.Contains(logs.String(), "no runtime") {
+		t.Errorf("batch not handled as one: %s", logs)
+	}
+}
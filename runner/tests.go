@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// junitFileName is where test mode has deno write its JUnit report.
+const junitFileName = ".runner-junit.xml"
+
+// Caps on what is read back from a test report.
+const (
+	maxJUnitBytes          = 4 << 20
+	maxTestFailures        = 100
+	maxTestFailureMsgBytes = 4 << 10
+)
+
+// TestReport summarizes a test mode run.
+type TestReport struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Ignored int `json:"ignored,omitempty"`
+	// Failures lists the failed tests, up to 100; nested steps are named
+	// "test > step".
+	Failures []TestFailure `json:"failures,omitempty"`
+}
+
+type TestFailure struct {
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// junitSuites is the part of deno's JUnit report the runner reads.
+type junitSuites struct {
+	Suites []struct {
+		Cases []struct {
+			Name      string `xml:"name,attr"`
+			ClassName string `xml:"classname,attr"`
+			Line      int    `xml:"line,attr"`
+			Failure   *struct {
+				Message string `xml:"message,attr"`
+				Text    string `xml:",chardata"`
+			} `xml:"failure"`
+			Error *struct {
+				Message string `xml:"message,attr"`
+				Text    string `xml:",chardata"`
+			} `xml:"error"`
+			Skipped *struct{} `xml:"skipped"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+// readTestReport parses the JUnit report deno left in workdir. A missing
+// report means deno never got as far as running tests.
+func readTestReport(workdir string) (*TestReport, error) {
+	path := filepath.Join(workdir, junitFileName)
+	// The script can write to its working directory, so only a regular file
+	// counts, as for the result file.
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("test report is not a regular file")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var suites junitSuites
+	if err := xml.NewDecoder(io.LimitReader(f, maxJUnitBytes)).Decode(&suites); err != nil {
+		return nil, fmt.Errorf("invalid test report: %w", err)
+	}
+
+	report := &TestReport{}
+	for _, suite := range suites.Suites {
+		for _, tc := range suite.Cases {
+			report.Total++
+			failure := tc.Failure
+			if failure == nil {
+				failure = tc.Error
+			}
+			switch {
+			case failure != nil:
+				report.Failed++
+				if len(report.Failures) == maxTestFailures {
+					continue
+				}
+				msg := failure.Message
+				if msg == "" {
+					msg = strings.TrimSpace(failure.Text)
+				}
+				if len(msg) > maxTestFailureMsgBytes {
+					msg = strings.ToValidUTF8(msg[:maxTestFailureMsgBytes], "") + "..."
+				}
+				report.Failures = append(report.Failures, TestFailure{
+					Name:    tc.Name,
+					File:    strings.TrimPrefix(tc.ClassName, "./"),
+					Line:    tc.Line,
+					Message: msg,
+				})
+			case tc.Skipped != nil:
+				report.Ignored++
+			default:
+				report.Passed++
+			}
+		}
+	}
+	return report, nil
+}
+
+// fillTests adds the test report to res. A plain non-zero exit with failed
+// tests is reported as TESTS_FAILED rather than a runtime error.
+func (r *Runner) fillTests(res *RunResult, req *RunRequest, workdir string) {
+	report, err := readTestReport(workdir)
+	if err != nil {
+		log.Printf("[TEST] Failed to read test report for %s: %v", req.PublicID, err)
+		return
+	}
+	res.Tests = report
+	if report != nil && report.Failed > 0 && res.ErrorCode == errorCodeRuntime && res.ExitSignal == nil {
+		res.Error = fmt.Sprintf("%d of %d tests failed", report.Failed, report.Total)
+		res.ErrorCode = errorCodeTestsFailed
+	}
+}
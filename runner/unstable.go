@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// unstableFlag matches one specific unstable feature flag. The bare
+// --unstable, which enabled everything, is deliberately not matched.
+var unstableFlag = regexp.MustCompile(`^--unstable-[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// envUnstableFeatures parses a comma-separated allowlist of unstable
+// feature flags, such as "--unstable-kv,--unstable-cron". The --unstable-
+// prefix may be left out.
+func envUnstableFeatures(name string) (map[string]bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	allowed := make(map[string]bool)
+	for _, entry := range strings.Split(v, ",") {
+		flag := strings.TrimSpace(entry)
+		if flag == "" {
+			continue
+		}
+		if !strings.HasPrefix(flag, "--") {
+			flag = "--unstable-" + flag
+		}
+		if !unstableFlag.MatchString(flag) {
+			return nil, fmt.Errorf("invalid %s entry %q: want a specific --unstable-* flag", name, entry)
+		}
+		allowed[flag] = true
+	}
+	return allowed, nil
+}
+
+// validateUnstableFeatures checks requested flags against the allowlist.
+func (c *Config) validateUnstableFeatures(features []string) error {
+	for _, flag := range features {
+		switch {
+		case flag == "--unstable":
+			return fmt.Errorf("--unstable is not allowed; name the specific --unstable-* features")
+		case !unstableFlag.MatchString(flag):
+			return fmt.Errorf("%q is not an --unstable-* flag", flag)
+		case !c.UnstableFeatures[flag]:
+			return fmt.Errorf("%s is not enabled on this runner", flag)
+		}
+	}
+	return nil
+}
+
+// unstableFlags returns the validated features, deduplicated, in a stable
+// order.
+func unstableFlags(features []string) []string {
+	if len(features) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(features))
+	var flags []string
+	for _, flag := range features {
+		if !seen[flag] {
+			seen[flag] = true
+			flags = append(flags, flag)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// allowedUnstableFeatures lists the allowlist for the startup log.
+func (c *Config) allowedUnstableFeatures() []string {
+	flags := make([]string, 0, len(c.UnstableFeatures))
+	for flag := range c.UnstableFeatures {
+		flags = append(flags, flag)
+	}
+	sort.Strings(flags)
+	return flags
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	maxPublicIDBytes = 256
+	maxTenantBytes   = 128
+)
+
+// FieldError describes one invalid field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationErrors collects every problem found in a request.
+type validationErrors []FieldError
+
+func (v *validationErrors) add(field, format string, args ...any) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v validationErrors) Error() string {
+	parts := make([]string, len(v))
+	for i, fe := range v {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// result is the VALIDATION_FAILED reply listing every invalid field.
+func (v validationErrors) result() RunResult {
+	return RunResult{ExitCode: 1, Error: v.Error(), ErrorCode: errorCodeValidation, FieldErrors: v}
+}
+
+// validateRequest checks the contents of a decoded request before it is
+// queued. It reports all invalid fields at once; permission flags are
+// checked separately when the job starts.
+func (c *Config) validateRequest(req *RunRequest) validationErrors {
+	var errs validationErrors
+	switch {
+	case req.PublicID == "":
+		errs.add("publicId", "is required")
+	case len(req.PublicID) > maxPublicIDBytes:
+		errs.add("publicId", "is %d bytes (max %d)", len(req.PublicID), maxPublicIDBytes)
+	case strings.ContainsAny(req.PublicID, " \t\r\n*>"):
+		errs.add("publicId", "must not contain whitespace, '*' or '>'")
+	}
+	switch {
+	case len(req.Steps) > 0:
+		if req.Code != "" {
+			errs.add("code", "must be left out when steps are set")
+		}
+	case req.Source != nil:
+		// checked by validateSource
+	case req.Entrypoint != "":
+		if req.Code != "" {
+			errs.add("code", "must be left out when entrypoint is set")
+		}
+	case strings.TrimSpace(req.Code) == "":
+		errs.add("code", "is required")
+	case int64(len(req.Code)) > c.MaxCodeBytes:
+		errs.add("code", "is %d bytes (max %d)", len(req.Code), c.MaxCodeBytes)
+	case req.Mode == modeEval && int64(len(req.Code)) > c.MaxEvalBytes:
+		errs.add("code", "is %d bytes (max %d in eval mode)", len(req.Code), c.MaxEvalBytes)
+	}
+	if _, ok := jobModes[req.Mode]; req.Mode != "" && !ok {
+		errs.add("mode", "must be run, eval, test, check, lint, fmt or bench")
+	}
+	if req.Entrypoint != "" {
+		switch {
+		case req.Mode == modeEval || req.Mode == modeFmt:
+			errs.add("entrypoint", "is not supported in %s mode", req.Mode)
+		case !filepath.IsLocal(req.Entrypoint):
+			errs.add("entrypoint", "must be relative and inside the working directory")
+		case req.Source == nil && !hasFile(req.Files, req.Entrypoint):
+			errs.add("entrypoint", "must name one of files")
+		}
+	}
+	if req.FmtCheck && req.Mode != modeFmt {
+		errs.add("fmtCheck", "is only valid in fmt mode")
+	}
+	if req.Print && req.Mode != modeEval {
+		errs.add("print", "is only valid in eval mode")
+	}
+	if req.Mode == modeFmt && (req.Stdin != nil || req.InteractiveStdin) {
+		errs.add("stdin", "is not supported in fmt mode")
+	}
+	if jobModes[req.Mode].static && len(req.Permissions) > 0 {
+		errs.add("permissions", "are not allowed in %s mode, which doesn't run the code", req.Mode)
+	}
+	if len(req.UnstableFeatures) > 0 && (req.Mode == modeLint || req.Mode == modeFmt) {
+		errs.add("unstableFeatures", "are not supported in %s mode", req.Mode)
+	} else if err := c.validateUnstableFeatures(req.UnstableFeatures); err != nil {
+		errs.add("unstableFeatures", "%v", err)
+	}
+	if len(req.ImportMap) > 0 {
+		if req.Mode == modeLint || req.Mode == modeFmt {
+			errs.add("importMap", "is not supported in %s mode", req.Mode)
+		} else if _, err := c.parseImportMap(req.ImportMap); err != nil {
+			errs.add("importMap", "%v", err)
+		}
+	}
+	if req.Lockfile != "" {
+		if req.Mode == modeLint || req.Mode == modeFmt {
+			errs.add("lockfile", "is not supported in %s mode", req.Mode)
+		} else if err := validateLockfile(req.Lockfile); err != nil {
+			errs.add("lockfile", "%v", err)
+		}
+	}
+	c.validateNpm(req, &errs)
+	c.validateSteps(req, &errs)
+	c.validateSource(req, &errs)
+	if len(req.DenoConfig) > 0 {
+		if _, err := c.sanitizeDenoConfig(req.DenoConfig); err != nil {
+			errs.add("denoConfig", "%v", err)
+		}
+	}
+	if _, ok := languageExts[req.Language]; req.Language != "" && !ok {
+		errs.add("language", "must be ts, tsx, js or jsx")
+	}
+	if req.TimeoutMs < 0 {
+		errs.add("timeoutMs", "must not be negative")
+	}
+	if req.MaxHeapMB < 0 {
+		errs.add("maxHeapMb", "must not be negative")
+	}
+	if len(req.Tenant) > maxTenantBytes {
+		errs.add("tenant", "is %d bytes (max %d)", len(req.Tenant), maxTenantBytes)
+	}
+	if _, err := parsePriority(req.Priority); err != nil {
+		errs.add("priority", "must be high, normal or low")
+	}
+	switch req.StdinEncoding {
+	case "", "utf8", "base64":
+	default:
+		errs.add("stdinEncoding", "must be utf8 or base64")
+	}
+	if err := validateEnv(req.Env); err != nil {
+		errs.add("env", "%v", err)
+	}
+	if err := validateMetadata(req.Metadata); err != nil {
+		errs.add("metadata", "%v", err)
+	}
+	if len(req.IdempotencyKey) > maxIdempotencyKeyBytes {
+		errs.add("idempotencyKey", "is %d bytes (max %d)", len(req.IdempotencyKey), maxIdempotencyKeyBytes)
+	}
+	if err := validateScriptArgs(req.Args); err != nil {
+		errs.add("args", "%v", err)
+	}
+	if req.WebhookURL != "" {
+		if err := c.validateWebhookURL(req.WebhookURL); err != nil {
+			errs.add("webhookUrl", "%v", err)
+		}
+	}
+	if req.RunAt != nil && req.ReplySubject == "" {
+		errs.add("replySubject", "is required with runAt")
+	} else if req.ReplySubject != "" {
+		if err := c.validateReplySubject(req.ReplySubject); err != nil {
+			errs.add("replySubject", "%v", err)
+		}
+	}
+	return errs
+}
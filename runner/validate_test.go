@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRequest(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxCodeBytes, cfg.MaxEvalBytes = 100, 10
+	cfg.UnstableFeatures = map[string]bool{"--unstable-kv": true}
+	cfg.NpmTenants = map[string]bool{"npm-ok": true}
+	cfg.WebhookAllowlist = []*url.URL{{Scheme: "https", Host: "hooks.example.com"}}
+	cfg.ReplySubjectPrefixes = []string{"replies"}
+
+	str := func(s string) *string { return &s }
+	runAt := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		mutate func(*RunRequest)
+		want   []string // "field: message substring", in order
+	}{
+		{"valid", func(*RunRequest) {}, nil},
+		{"valid eval", func(r *RunRequest) { r.Mode, r.Code, r.Print = modeEval, "1+1", true }, nil},
+		{"valid entrypoint", func(r *RunRequest) { r.Code, r.Entrypoint, r.Files = "", "main.ts", map[string]string{"main.ts": "1"} }, nil},
+		{"valid steps", func(r *RunRequest) { r.Code, r.Steps = "", []Step{{Code: "1"}, {Code: "2"}} }, nil},
+
+		{"publicId missing", func(r *RunRequest) { r.PublicID = "" }, []string{"publicId: is required"}},
+		{"publicId too long", func(r *RunRequest) { r.PublicID = strings.Repeat("a", maxPublicIDBytes+1) }, []string{"publicId: is 257 bytes"}},
+		{"publicId wildcard", func(r *RunRequest) { r.PublicID = "a.>" }, []string{"publicId: must not contain"}},
+		{"publicId space", func(r *RunRequest) { r.PublicID = "a b" }, []string{"publicId: must not contain"}},
+
+		{"code missing", func(r *RunRequest) { r.Code = " \n" }, []string{"code: is required"}},
+		{"code too long", func(r *RunRequest) { r.Code = strings.Repeat("x", 101) }, []string{"code: is 101 bytes (max 100)"}},
+		{"code too long for eval", func(r *RunRequest) { r.Mode, r.Code = modeEval, strings.Repeat("x", 11) }, []string{"code: is 11 bytes (max 10 in eval mode)"}},
+		{"code with steps", func(r *RunRequest) { r.Steps = []Step{{Code: "1"}} }, []string{"code: must be left out when steps"}},
+		{"code with entrypoint", func(r *RunRequest) { r.Entrypoint, r.Files = "m.ts", map[string]string{"m.ts": "1"} }, []string{"code: must be left out when entrypoint"}},
+
+		{"mode", func(r *RunRequest) { r.Mode = "compile" }, []string{"mode: must be run"}},
+		{"entrypoint in eval", func(r *RunRequest) { r.Code, r.Mode, r.Entrypoint = "", modeEval, "m.ts" }, []string{"entrypoint: is not supported in eval mode"}},
+		{"entrypoint outside", func(r *RunRequest) { r.Code, r.Entrypoint = "", "../m.ts" }, []string{"entrypoint: must be relative"}},
+		{"entrypoint not a file", func(r *RunRequest) { r.Code, r.Entrypoint = "", "m.ts" }, []string{"entrypoint: must name one of files"}},
+		{"fmtCheck", func(r *RunRequest) { r.FmtCheck = true }, []string{"fmtCheck: is only valid in fmt mode"}},
+		{"print", func(r *RunRequest) { r.Print = true }, []string{"print: is only valid in eval mode"}},
+		{"stdin in fmt", func(r *RunRequest) { r.Mode, r.Stdin = modeFmt, str("x") }, []string{"stdin: is not supported in fmt mode"}},
+		{"interactive stdin in fmt", func(r *RunRequest) { r.Mode, r.InteractiveStdin = modeFmt, true }, []string{"stdin: is not supported in fmt mode"}},
+		{"permissions in check", func(r *RunRequest) { r.Mode, r.Permissions = modeCheck, []string{"--allow-net"} }, []string{"permissions: are not allowed in check mode"}},
+
+		{"unstable in lint", func(r *RunRequest) { r.Mode, r.UnstableFeatures = modeLint, []string{"--unstable-kv"} }, []string{"unstableFeatures: are not supported in lint mode"}},
+		{"unstable bare", func(r *RunRequest) { r.UnstableFeatures = []string{"--unstable"} }, []string{"unstableFeatures: --unstable is not allowed"}},
+		{"unstable not a flag", func(r *RunRequest) { r.UnstableFeatures = []string{"kv"} }, []string{`unstableFeatures: "kv" is not an --unstable-* flag`}},
+		{"unstable not enabled", func(r *RunRequest) { r.UnstableFeatures = []string{"--unstable-ffi"} }, []string{"unstableFeatures: --unstable-ffi is not enabled"}},
+		{"importMap in fmt", func(r *RunRequest) { r.Mode, r.ImportMap = modeFmt, json.RawMessage(`{}`) }, []string{"importMap: is not supported in fmt mode"}},
+		{"importMap null", func(r *RunRequest) { r.ImportMap = json.RawMessage(`null`) }, []string{"importMap: must be an object"}},
+		{"importMap unknown key", func(r *RunRequest) { r.ImportMap = json.RawMessage(`{"x":1}`) }, []string{"importMap: must be an object of imports and scopes"}},
+		{"lockfile in lint", func(r *RunRequest) { r.Mode, r.Lockfile = modeLint, "{}" }, []string{"lockfile: is not supported in lint mode"}},
+		{"lockfile not JSON", func(r *RunRequest) { r.Lockfile = "[" }, []string{"lockfile: must be the JSON contents"}},
+
+		{"nodeModulesDir without npm", func(r *RunRequest) { r.NodeModulesDir = "auto" }, []string{"nodeModulesDir: requires npm"}},
+		{"npm in fmt", func(r *RunRequest) { r.Mode, r.Npm, r.Tenant = modeFmt, true, "npm-ok" }, []string{"npm: is not supported in fmt mode"}},
+		{"npm not enabled", func(r *RunRequest) { r.Npm, r.Tenant = true, "other" }, []string{`npm: is not enabled for tenant "other"`}},
+		{"nodeModulesDir value", func(r *RunRequest) { r.Npm, r.Tenant, r.NodeModulesDir = true, "npm-ok", "global" }, []string{"nodeModulesDir: must be auto or none"}},
+
+		{"continueOnError without steps", func(r *RunRequest) { r.ContinueOnError = true }, []string{"continueOnError: is only valid with steps"}},
+		{"too many steps", func(r *RunRequest) {
+			r.Code, r.Steps = "", make([]Step, maxSteps+1)
+			for i := range r.Steps {
+				r.Steps[i].Code = "1"
+			}
+		}, []string{"steps: has 17 steps (max 16)"}},
+		{"steps outside run mode", func(r *RunRequest) { r.Code, r.Mode, r.Steps = "", modeTest, []Step{{Code: "1"}} }, []string{"steps: are only supported in run mode"}},
+		{"steps with entrypoint", func(r *RunRequest) {
+			r.Code, r.Entrypoint, r.Files, r.Steps = "", "m.ts", map[string]string{"m.ts": "1"}, []Step{{Code: "1"}}
+		}, []string{"steps: can't be combined with entrypoint"}},
+		{"steps with stdin", func(r *RunRequest) { r.Code, r.Stdin, r.Steps = "", str(""), []Step{{Code: "1"}} }, []string{"steps: can't be combined with stdin"}},
+		{"steps with permissions", func(r *RunRequest) { r.Code, r.TimeoutMs, r.Steps = "", 5, []Step{{Code: "1"}} }, []string{"steps: set their own permissions"}},
+		{"step code", func(r *RunRequest) {
+			r.Code, r.Steps = "", []Step{{Code: "1"}, {}, {Code: strings.Repeat("x", 101)}}
+		}, []string{"steps[1].code: is required", "steps[2].code: is 101 bytes (max 100)"}},
+		{"step timeout", func(r *RunRequest) { r.Code, r.Steps = "", []Step{{Code: "1", TimeoutMs: -1}} }, []string{"steps[0].timeoutMs: must not be negative"}},
+
+		{"source with code", func(r *RunRequest) { r.Source = validSource() }, []string{"source: can't be combined with code or files"}},
+		{"source with steps", func(r *RunRequest) {
+			r.Code, r.Source, r.Steps = "", validSource(), []Step{{Code: "1"}}
+		}, []string{"source: can't be combined with steps"}},
+		{"source fields", func(r *RunRequest) { r.Code, r.Source = "", &SourceRef{Digest: "md5=x"} }, []string{"source.bucket: is required", "source.name: is required", "source.digest: must be SHA-256="}},
+		{"source digest", func(r *RunRequest) { r.Code, r.Source = "", &SourceRef{Bucket: "b", Name: "n", Digest: "SHA-256=abc"} }, []string{"source.digest: is not a valid SHA-256 digest"}},
+
+		{"denoConfig", func(r *RunRequest) { r.DenoConfig = json.RawMessage(`[]`) }, []string{"denoConfig: must be a JSON object"}},
+		{"language", func(r *RunRequest) { r.Language = "py" }, []string{"language: must be ts, tsx, js or jsx"}},
+		{"timeoutMs", func(r *RunRequest) { r.TimeoutMs = -1 }, []string{"timeoutMs: must not be negative"}},
+		{"maxHeapMb", func(r *RunRequest) { r.MaxHeapMB = -1 }, []string{"maxHeapMb: must not be negative"}},
+		{"tenant", func(r *RunRequest) { r.Tenant = strings.Repeat("t", maxTenantBytes+1) }, []string{"tenant: is 129 bytes (max 128)"}},
+		{"priority", func(r *RunRequest) { r.Priority = "urgent" }, []string{"priority: must be high, normal or low"}},
+		{"stdinEncoding", func(r *RunRequest) { r.StdinEncoding = "hex" }, []string{"stdinEncoding: must be utf8 or base64"}},
+		{"env name", func(r *RunRequest) { r.Env = map[string]string{"1A": "x"} }, []string{`env: invalid variable name: "1A"`}},
+		{"env reserved", func(r *RunRequest) { r.Env = map[string]string{"NATS_URL": "x"} }, []string{"env: reserved variable: NATS_URL"}},
+		{"env NUL", func(r *RunRequest) { r.Env = map[string]string{"A": "x\x00"} }, []string{"env: value of A contains a NUL byte"}},
+		{"metadata key", func(r *RunRequest) { r.Metadata = map[string]string{"-x": "1"} }, []string{`metadata: invalid key "-x"`}},
+		{"metadata size", func(r *RunRequest) { r.Metadata = map[string]string{"k": strings.Repeat("v", maxMetadataBytes)} }, []string{"metadata: is 4097 bytes"}},
+		{"idempotencyKey", func(r *RunRequest) { r.IdempotencyKey = strings.Repeat("k", maxIdempotencyKeyBytes+1) }, []string{"idempotencyKey: is 257 bytes (max 256)"}},
+		{"args count", func(r *RunRequest) { r.Args = make([]string, maxScriptArgs+1) }, []string{"args: too many arguments"}},
+		{"args NUL", func(r *RunRequest) { r.Args = []string{"a", "b\x00"} }, []string{"args: argument 1 contains a NUL byte"}},
+		{"webhook credentials", func(r *RunRequest) { r.WebhookURL = "https://u:p@hooks.example.com/x" }, []string{"webhookUrl: must be an absolute http(s) URL"}},
+		{"webhook host", func(r *RunRequest) { r.WebhookURL = "https://evil.example.com/x" }, []string{"webhookUrl: https://evil.example.com is not in the webhook allowlist"}},
+		{"runAt without replySubject", func(r *RunRequest) { r.RunAt = &runAt }, []string{"replySubject: is required with runAt"}},
+		{"replySubject wildcard", func(r *RunRequest) { r.ReplySubject = "replies.*" }, []string{"replySubject: "}},
+		{"replySubject prefix", func(r *RunRequest) { r.ReplySubject = "runner.execute" }, []string{"replySubject: must start with one of replies"}},
+
+		{"several", func(r *RunRequest) {
+			r.PublicID, r.Code, r.Mode, r.TimeoutMs, r.Priority = "", "", "compile", -5, "urgent"
+			r.Env = map[string]string{"DENO_DIR": "/"}
+		}, []string{
+			"publicId: is required", "code: is required", "mode: must be run",
+			"timeoutMs: must not be negative", "priority: must be high", "env: reserved variable",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := RunRequest{PublicID: "job-1", Code: "console.log(1)"}
+			tt.mutate(&req)
+			errs := cfg.validateRequest(&req)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d: %v", len(errs), len(tt.want), errs)
+			}
+			for i, want := range tt.want {
+				field, msg, _ := strings.Cut(want, ": ")
+				if errs[i].Field != field || !strings.Contains(errs[i].Message, msg) {
+					t.Errorf("error %d = %s: %s, want %s", i, errs[i].Field, errs[i].Message, want)
+				}
+			}
+			if len(errs) > 0 {
+				res := errs.result()
+				if res.ErrorCode != errorCodeValidation || len(res.FieldErrors) != len(errs) || !strings.HasPrefix(res.Error, "invalid request: ") {
+					t.Errorf("result: %+v", res)
+				}
+			}
+		})
+	}
+}
+
+func validSource() *SourceRef {
+	return &SourceRef{Bucket: "b", Name: "n", Digest: "SHA-256=" + strings.Repeat("A", 43)}
+}
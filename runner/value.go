@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scripts with returnValue set can hand back a JSON value without going
+// through stdout: by writing it to file descriptor 3, or to the file named
+// by $RUNNER_RESULT_FILE in the working directory. Whatever arrives on fd 3
+// wins; the file is only read if nothing did. Writing neither leaves
+// RunResult.Value unset.
+const (
+	valueFD       = 3
+	valueFileName = ".runner-result.json"
+	valueDevPath  = "/dev/fd/3"
+)
+
+// valuePipe collects what a job writes to fd 3.
+type valuePipe struct {
+	r, w *os.File
+	done chan []byte
+}
+
+// newValuePipe creates the pipe; w goes to the child as fd 3.
+func newValuePipe(limit int64) (*valuePipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &valuePipe{r: r, w: w, done: make(chan []byte, 1)}
+	go func() {
+		data, _ := io.ReadAll(io.LimitReader(r, limit+1))
+		_, _ = io.Copy(io.Discard, r)
+		p.done <- data
+	}()
+	return p, nil
+}
+
+// started closes the parent's copy of the write end once the child has it.
+func (p *valuePipe) started() { p.w.Close() }
+
+// wait returns what was written to fd 3. The job's process group is dead by
+// now, so the pipe is about to hit EOF; the timeout only guards against a
+// straggler that escaped it.
+func (p *valuePipe) wait() []byte {
+	defer p.r.Close()
+	select {
+	case data := <-p.done:
+		return data
+	case <-time.After(time.Second):
+		return nil
+	}
+}
+
+// valueEnv tells the script where it may put its value.
+func valueEnv(workdir string) []string {
+	return []string{
+		"RUNNER_RESULT_FD=" + strconv.Itoa(valueFD),
+		"RUNNER_RESULT_FILE=" + filepath.Join(workdir, valueFileName),
+	}
+}
+
+// withValueScope grants write access to the result file and fd 3, merging
+// with an existing --allow-write list.
+func withValueScope(perms []string, workdir string) []string {
+	paths := valueDevPath + "," + filepath.Join(workdir, valueFileName)
+	for i, perm := range perms {
+		switch {
+		case perm == "--allow-write":
+			return perms
+		case strings.HasPrefix(perm, "--allow-write="):
+			perms[i] = perm + "," + paths
+			return perms
+		}
+	}
+	return append(perms, "--allow-write="+paths)
+}
+
+// readValue returns the job's value from what arrived on fd 3 or, failing
+// that, the result file, with a reason when it can't be returned.
+func readValue(fromFD []byte, workdir string, limit int64) (json.RawMessage, string) {
+	data := bytes.TrimSpace(fromFD)
+	if len(fromFD) == 0 {
+		// The script controls the working directory, so only a regular
+		// file counts; a symlink could point anywhere the runner can read.
+		path := filepath.Join(workdir, valueFileName)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, ""
+		}
+		if !info.Mode().IsRegular() {
+			return nil, "result file is not a regular file"
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Sprintf("failed to read result file: %v", err)
+		}
+		defer f.Close()
+		if fromFD, err = io.ReadAll(io.LimitReader(f, limit+1)); err != nil {
+			return nil, fmt.Sprintf("failed to read result file: %v", err)
+		}
+		data = bytes.TrimSpace(fromFD)
+	}
+	switch {
+	case int64(len(fromFD)) > limit:
+		return nil, fmt.Sprintf("value exceeds %d bytes", limit)
+	case len(data) == 0:
+		return nil, ""
+	case !json.Valid(data):
+		return nil, "value is not valid JSON"
+	}
+	return json.RawMessage(data), ""
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReturnValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		value  string // RunResult.Value, "" for unset
+		err    string // RunResult.ValueError
+	}{
+		{name: "fd", script: `printf '{"a": 1}\n' >&3`, value: `{"a": 1}`},
+		{name: "file", script: `printf '[1, 2]' > "$RUNNER_RESULT_FILE"`, value: `[1, 2]`},
+		{name: "fd wins", script: `printf '"fd"' >&3; printf '"file"' > "$RUNNER_RESULT_FILE"`, value: `"fd"`},
+		{name: "nothing", script: `echo output only`},
+		{name: "empty file", script: `: > "$RUNNER_RESULT_FILE"`},
+		{name: "invalid fd", script: `printf '{oops' >&3`, err: "value is not valid JSON"},
+		{name: "invalid file", script: `printf 'undefined' > "$RUNNER_RESULT_FILE"`, err: "value is not valid JSON"},
+		{name: "oversized fd", script: `printf '"%s"' 0123456789012345678901234567890123456789 >&3`, err: "value exceeds 32 bytes"},
+		{name: "oversized file", script: `printf '"%s"' 0123456789012345678901234567890123456789 > "$RUNNER_RESULT_FILE"`, err: "value exceeds 32 bytes"},
+		{name: "symlinked file", script: `ln -s /etc/passwd "$RUNNER_RESULT_FILE"`, err: "result file is not a regular file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeDeno(t, tt.script)
+			r := testRunner(t)
+			r.cfg.MaxValueBytes = 32
+			res := r.executeIn(&RunRequest{PublicID: "value", Code: "Deno.exit()", ReturnValue: true}, 0, time.Time{}, nil)
+			if res.ErrorCode != "" {
+				t.Fatalf("errorCode = %q (%s), stderr %q", res.ErrorCode, res.Error, res.Stderr)
+			}
+			if string(res.Value) != tt.value || res.ValueError != tt.err {
+				t.Errorf("value %s, valueError %q; want %s, %q", res.Value, res.ValueError, tt.value, tt.err)
+			}
+		})
+	}
+}
+
+// TestReturnValueOff checks jobs that don't ask for a value get no fd 3 and
+// no result file location, and a file they write anyway is ignored.
+func TestReturnValueOff(t *testing.T) {
+	fakeDeno(t, `echo "fd=${RUNNER_RESULT_FD:-} file=${RUNNER_RESULT_FILE:-}"
+{ printf 1 >&3; } 2>/dev/null && echo "fd 3 open"
+printf 1 > .runner-result.json`)
+	r := testRunner(t)
+	res := r.executeIn(&RunRequest{PublicID: "novalue", Code: "Deno.exit()"}, 0, time.Time{}, nil)
+	if res.ErrorCode != "" {
+		t.Fatalf("errorCode = %q (%s)", res.ErrorCode, res.Error)
+	}
+	if strings.TrimSpace(res.Stdout) != "fd= file=" || res.Value != nil {
+		t.Errorf("stdout %q, value %s", res.Stdout, res.Value)
+	}
+}
+
+func TestWithValueScope(t *testing.T) {
+	const paths = "/dev/fd/3,/w/.runner-result.json"
+	tests := []struct{ perms, want []string }{
+		{nil, []string{"--allow-write=" + paths}},
+		{[]string{"--allow-net"}, []string{"--allow-net", "--allow-write=" + paths}},
+		{[]string{"--allow-write=/w/out"}, []string{"--allow-write=/w/out," + paths}},
+		{[]string{"--allow-write"}, []string{"--allow-write"}},
+	}
+	for _, tt := range tests {
+		got := withValueScope(append([]string(nil), tt.perms...), "/w")
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("withValueScope(%q) = %q, want %q", tt.perms, got, tt.want)
+		}
+	}
+}
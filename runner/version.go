@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Protocol versions of the RunRequest/RunResult schema this runner speaks.
+// Requests without a version are treated as minProtocolVersion.
+const (
+	minProtocolVersion = 1
+	maxProtocolVersion = 1
+)
+
+// protocolVersion returns the version a request asked for, or an error
+// naming the supported range when the runner doesn't know it.
+func protocolVersion(v int) (int, error) {
+	if v == 0 {
+		return minProtocolVersion, nil
+	}
+	if v < minProtocolVersion || v > maxProtocolVersion {
+		return 0, fmt.Errorf("unsupported protocol version %d (supported: %d-%d)", v, minProtocolVersion, maxProtocolVersion)
+	}
+	return v, nil
+}
+
+func unsupportedVersion(err error) RunResult {
+	return RunResult{ExitCode: 1, Error: err.Error(), ErrorCode: errorCodeUnsupportedVersion}
+}
+
+// InfoResult is the reply on runner.info.
+type InfoResult struct {
+	MinVersion    int    `json:"minVersion"`
+	MaxVersion    int    `json:"maxVersion"`
+	MaxConcurrent int    `json:"maxConcurrent"`
+	RunnerID      string `json:"runnerId"`
+	RunnerVersion string `json:"runnerVersion"`
+	// ResultsSubject is where results of requests without a reply subject
+	// go, and ResultsPublished how many have gone there since startup.
+	ResultsSubject   string `json:"resultsSubject"`
+	ResultsPublished int64  `json:"resultsPublished"`
+	// Connection is the state of the runner's NATS connection.
+	Connection ConnectionInfo `json:"connection"`
+	// Subjects are the subjects the runner uses, by purpose, all under
+	// SubjectPrefix.
+	SubjectPrefix string            `json:"subjectPrefix"`
+	Subjects      map[string]string `json:"subjects"`
+}
+
+func (r *Runner) handleInfo(m *nats.Msg) {
+	r.respond(m, InfoResult{
+		MinVersion:       minProtocolVersion,
+		MaxVersion:       maxProtocolVersion,
+		MaxConcurrent:    r.cfg.MaxConcurrent,
+		RunnerID:         runnerID,
+		RunnerVersion:    runnerVersion,
+		ResultsSubject:   r.cfg.ResultsSubject,
+		ResultsPublished: r.resultsPublished.Load(),
+		Connection:       r.connectionInfo(),
+		SubjectPrefix:    r.cfg.SubjectPrefix,
+		Subjects:         r.cfg.subjects(),
+	})
+}
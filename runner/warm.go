@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"syscall"
+)
+
+// warmBootstrap is the main module of a warm deno process. It waits for the
+// job's code on stdin and imports it, so V8 start-up and runtime
+// initialization happen before the job arrives instead of on its clock.
+const warmBootstrap = `const code = await new Response(Deno.stdin.readable).text();
+await import("data:application/typescript," + encodeURIComponent(code));
+`
+
+// warmIncompatible matches code that would behave differently as the data:
+// module warmBootstrap imports than as the main module of a cold run:
+// import.meta and Deno.mainModule would name the data: URL or the bootstrap,
+// and relative specifiers would resolve against the data: URL instead of
+// the working directory. Dynamic imports are left out too, since their
+// specifiers may be computed. It errs on the side of a cold start, matching
+// in comments and strings too.
+var warmIncompatible = regexp.MustCompile(`import\s*\.\s*meta|Deno\s*\.\s*mainModule|\bimport\s*\(|\b(?:from|import)\s*["'](?:\.{0,2}/|file:)`)
+
+// warmPool keeps deno processes started ahead of time for jobs that need
+// nothing beyond the defaults. Permission flags are fixed when deno starts,
+// so only zero-permission jobs with the default heap, no env, args, files
+// or stdin qualify, and only if their code can't tell it was imported by
+// the bootstrap; everything else takes the normal path.
+type warmPool struct {
+	r         *Runner
+	size      int
+	bootstrap string
+
+	mu       sync.Mutex
+	idle     []*warmProcess
+	filling  bool
+	draining bool
+}
+
+// warmProcess is an idle deno process waiting for code, with the working
+// directory it will run in.
+type warmProcess struct {
+	proc    *jobProcess
+	workdir string
+	quota   *diskQuota
+}
+
+// newWarmPool writes the bootstrap module and starts filling the pool.
+func newWarmPool(r *Runner, size int) (*warmPool, error) {
+	path := filepath.Join(r.cfg.WorkDir, fmt.Sprintf("runner-warm-%d.js", os.Getpid()))
+	if err := os.WriteFile(path, []byte(warmBootstrap), 0o644); err != nil {
+		return nil, err
+	}
+	p := &warmPool{r: r, size: size, bootstrap: path}
+	p.refill()
+	return p, nil
+}
+
+// eligible reports whether req can run on a warm process.
+func (p *warmPool) eligible(req *RunRequest) bool {
+	return len(req.Permissions) == 0 && len(req.Env) == 0 && len(req.Args) == 0 && req.Language == "" && jobMode(req) == modeRun &&
+		len(req.UnstableFeatures) == 0 && len(req.ImportMap) == 0 && req.Lockfile == "" && len(req.DenoConfig) == 0 && !req.Npm && (req.RemoteImports == nil || *req.RemoteImports) &&
+		len(req.Files) == 0 && req.Stdin == nil && !req.InteractiveStdin && !req.ReturnValue &&
+		heapLimitMB(req.MaxHeapMB, p.r.cfg) == p.r.cfg.V8HeapMB && !warmIncompatible.MatchString(req.Code)
+}
+
+// take hands out an idle warm process for req, or nil if req does not
+// qualify or none is ready. The pool is topped up in the background. It is
+// safe to call on a nil pool.
+func (p *warmPool) take(req *RunRequest) *warmProcess {
+	if p == nil || !p.eligible(req) {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.idle) > 0 {
+		w := p.idle[0]
+		p.idle = p.idle[1:]
+		go p.refill()
+		// Signal 0 checks the process is still there and has not crashed while idle.
+		if err := w.proc.cmd.Process.Signal(syscall.Signal(0)); err == nil {
+			return w
+		}
+		go p.discard(w)
+	}
+	return nil
+}
+
+// refill starts warm processes until the pool is full. Only one refill runs
+// at a time.
+func (p *warmPool) refill() {
+	p.mu.Lock()
+	if p.filling || p.draining {
+		p.mu.Unlock()
+		return
+	}
+	p.filling = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.filling = false
+		p.mu.Unlock()
+	}()
+
+	for {
+		p.mu.Lock()
+		full := len(p.idle) >= p.size || p.draining
+		p.mu.Unlock()
+		if full {
+			return
+		}
+		w, err := p.start()
+		if err != nil {
+			log.Printf("[WARM] Failed to start a warm deno process: %v", err)
+			return
+		}
+		p.mu.Lock()
+		if p.draining {
+			p.mu.Unlock()
+			p.discard(w)
+			return
+		}
+		p.idle = append(p.idle, w)
+		p.mu.Unlock()
+	}
+}
+
+func (p *warmPool) start() (*warmProcess, error) {
+	cfg := p.r.cfg
+	workdir, err := createWorkdir(cfg.WorkDir, cfg.ExecCredential)
+	if err != nil {
+		return nil, err
+	}
+	var quota *diskQuota
+	if cfg.DiskQuota > 0 {
+		quota = newDiskQuota(workdir, cfg.DiskQuota, cfg.ExecCredential)
+	}
+	args := []string{"run", fmt.Sprintf("--v8-flags=--max-old-space-size=%d", cfg.V8HeapMB), "--no-prompt", "--no-npm"}
+	args = append(args, cfg.remoteFlags(&RunRequest{})...)
+	args = append(args, p.bootstrap)
+	proc, err := p.r.spawn(args, workdir, jobEnv(workdir, nil), nil, true, nil)
+	if err != nil {
+		if quota != nil {
+			quota.release()
+		}
+		os.RemoveAll(workdir)
+		return nil, err
+	}
+	return &warmProcess{proc: proc, workdir: workdir, quota: quota}, nil
+}
+
+// feed writes the job's code to the process and closes its stdin.
+func (w *warmProcess) feed(code string) {
+	if _, err := io.WriteString(w.proc.stdin, code); err != nil {
+		log.Printf("[WARM] Failed to hand code to pid %d: %v", w.proc.cmd.Process.Pid, err)
+	}
+	w.proc.stdin.Close()
+}
+
+// discard kills an unused warm process and removes its resources.
+func (p *warmPool) discard(w *warmProcess) {
+	w.proc.cancel(errRunnerShutdown)
+	_ = w.proc.cmd.Wait()
+	w.proc.term.markExited()
+	p.r.reaper.release(w.proc.cmd.Process.Pid)
+	_ = killProcessGroup(w.proc.cmd.Process.Pid, syscall.SIGKILL)
+	w.proc.cleanup()
+	if w.quota != nil {
+		w.quota.release()
+	}
+	os.RemoveAll(w.workdir)
+}
+
+// close stops refilling and discards all idle warm processes.
+func (p *warmPool) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.draining = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	for _, w := range idle {
+		p.discard(w)
+	}
+	os.Remove(p.bootstrap)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchStartup is how long benchDeno's stand-in takes to start, in place of
+// V8 start-up and runtime initialization.
+const benchStartup = "0.1"
+
+// benchDeno runs the benchmark against the installed deno, or, without one,
+// a script that takes benchStartup seconds to start, then reads stdin and
+// prints 4.
+func benchDeno(b *testing.B) {
+	b.Helper()
+	if _, err := exec.LookPath("deno"); err == nil {
+		return
+	}
+	fakeDeno(b, "sleep "+benchStartup+"\ncat >/dev/null\necho 4")
+}
+
+// runBench runs req b.N times, failing on the first job that doesn't print 4
+// or, with a warm pool, doesn't run warm.
+// between, if set, runs with the timer stopped before each job.
+func runBench(b *testing.B, r *Runner, req RunRequest, between func()) {
+	b.Helper()
+	captureLog(b)
+	for b.Loop() {
+		if between != nil {
+			b.StopTimer()
+			between()
+			b.StartTimer()
+		}
+		req := req
+		res := r.executeIn(&req, 0, time.Time{}, nil)
+		if res.ErrorCode != "" || strings.TrimSpace(res.Stdout) != "4" || res.Warm != (r.warm != nil) {
+			b.Fatalf("errorCode = %q (%s), warm %v, stdout %q, stderr %q", res.ErrorCode, res.Error, res.Warm, res.Stdout, res.Stderr)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Milliseconds())/float64(b.N), "ms/job")
+}
+
+// BenchmarkColdStart and BenchmarkWarmStart compare the latency of a
+// trivial job spawning deno for it against one handed to a warm process.
+func BenchmarkColdStart(b *testing.B) {
+	benchDeno(b)
+	runBench(b, testRunner(b), RunRequest{PublicID: "cold", Code: "console.log(2 + 2)"}, nil)
+}
+
+func BenchmarkWarmStart(b *testing.B) {
+	benchDeno(b)
+	r := testRunner(b)
+	pool, err := newWarmPool(r, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	r.warm = pool
+	b.Cleanup(pool.close)
+	// Each job waits for the refill after the last, so it always finds a
+	// process that has finished starting, as jobs arriving slower than the
+	// pool refills do.
+	waitWarm := func() {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			pool.mu.Lock()
+			ready := len(pool.idle) > 0 && !pool.filling
+			pool.mu.Unlock()
+			if ready {
+				time.Sleep(200 * time.Millisecond) // past the stand-in's start-up
+				return
+			}
+			if time.Now().After(deadline) {
+				b.Fatal("warm pool never refilled")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	runBench(b, r, RunRequest{PublicID: "warm", Code: "console.log(2 + 2)"}, waitWarm)
+}
+
+func TestWarmEligibleCode(t *testing.T) {
+	p := &warmPool{r: testRunner(t)}
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{`console.log(2 + 2)`, true},
+		{`import { assert } from "https://deno.land/std/assert/mod.ts";`, true},
+		{`import "jsr:@std/fmt";`, true},
+		{`const important = 1; console.log(important)`, true},
+		{`console.log(import.meta.url)`, false},
+		{`const { main } = import . meta;`, false},
+		{`if (import.meta.main) run()`, false},
+		{`console.log(Deno.mainModule)`, false},
+		{`import { f } from "./lib.ts";`, false},
+		{`import {f} from'../lib.ts'`, false},
+		{`export * from "/abs/mod.ts";`, false},
+		{`import "./side-effect.ts";`, false},
+		{`import data from "file:///etc/data.json" with { type: "json" };`, false},
+		{`const m = await import("https://example.com/mod.ts");`, false},
+		{`const m = await import(name);`, false},
+	}
+	for _, tt := range tests {
+		if got := p.eligible(&RunRequest{Code: tt.code}); got != tt.want {
+			t.Errorf("eligible(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
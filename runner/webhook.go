@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Headers on webhook requests. The signature is the hex HMAC-SHA256, keyed
+// with RUNNER_WEBHOOK_SECRET, of the timestamp header's value, a '.', and
+// the body; receivers should also reject stale timestamps.
+const (
+	webhookSignatureHeader = "Runner-Signature"
+	webhookTimestampHeader = "Runner-Timestamp"
+)
+
+// WebhookEvent is published on runner.webhook.<publicId> once a job's
+// webhook delivery has succeeded or been given up on.
+type WebhookEvent struct {
+	PublicID   string `json:"publicId"`
+	URL        string `json:"url"`
+	Status     string `json:"status"` // "delivered" or "failed"
+	Attempts   int    `json:"attempts"`
+	StatusCode int    `json:"statusCode,omitempty"` // of the last attempt
+	Error      string `json:"error,omitempty"`
+	// Metadata is the request's metadata, unchanged.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// webhookClient posts results to RunRequest.WebhookURL. Deliveries run on
+// their own goroutines, so a slow receiver never holds up a worker.
+type webhookClient struct {
+	cfg      *Config
+	nc       *nats.Conn
+	http     *http.Client
+	inFlight sync.WaitGroup
+}
+
+func newWebhookClient(cfg *Config, nc *nats.Conn) *webhookClient {
+	return &webhookClient{
+		cfg: cfg,
+		nc:  nc,
+		http: &http.Client{
+			Timeout: cfg.WebhookTimeout,
+			// A redirect could lead anywhere, outside the allowlist included.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// validateWebhookURL checks that raw is an absolute URL whose scheme and
+// host are in the webhook allowlist.
+func (c *Config) validateWebhookURL(raw string) error {
+	if len(c.WebhookAllowlist) == 0 {
+		return fmt.Errorf("webhooks are not enabled on this runner")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return fmt.Errorf("must be an absolute http(s) URL without credentials")
+	}
+	for _, allowed := range c.WebhookAllowlist {
+		if strings.EqualFold(u.Scheme, allowed.Scheme) && strings.EqualFold(u.Host, allowed.Host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s://%s is not in the webhook allowlist", u.Scheme, u.Host)
+}
+
+// deliver posts res to the job's webhook in the background.
+func (w *webhookClient) deliver(req *RunRequest, res RunResult) {
+	res.stamp()
+	body, err := json.Marshal(res)
+	if err != nil {
+		log.Printf("[WEBHOOK] Failed to encode result of %s: %v", req.PublicID, err)
+		return
+	}
+	event := WebhookEvent{PublicID: req.PublicID, URL: req.WebhookURL, Metadata: req.Metadata}
+	w.inFlight.Add(1)
+	go func() {
+		defer w.inFlight.Done()
+		w.post(&event, body)
+		if event.Status == "delivered" {
+			log.Printf("[WEBHOOK] Delivered result of %s to %s (attempt %d)", req.PublicID, req.WebhookURL, event.Attempts)
+		} else {
+			log.Printf("[WEBHOOK] Giving up on %s after %d attempts: %s", req.PublicID, event.Attempts, event.Error)
+		}
+		data, _ := json.Marshal(event)
+		if err := w.nc.Publish(w.cfg.subjectID("webhook", req.PublicID), data); err != nil {
+			log.Printf("[WEBHOOK] Failed to publish delivery event for %s: %v", req.PublicID, err)
+		}
+	}()
+}
+
+// post sends body until the receiver accepts it or the retries run out,
+// waiting WebhookBackoff, doubled each time, in between. Client errors other
+// than 408 and 429 are not retried.
+func (w *webhookClient) post(event *WebhookEvent, body []byte) {
+	backoff := w.cfg.WebhookBackoff
+	for {
+		event.Attempts++
+		code, err := w.attempt(event.URL, body)
+		event.StatusCode = code
+		if err == nil {
+			event.Status, event.Error = "delivered", ""
+			return
+		}
+		event.Status, event.Error = "failed", err.Error()
+		permanent := code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+		if permanent || event.Attempts > w.cfg.WebhookRetries {
+			return
+		}
+		log.Printf("[WEBHOOK] Attempt %d for %s failed (%v), retrying in %v", event.Attempts, event.PublicID, err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// attempt makes one signed POST and returns the response status.
+func (w *webhookClient) attempt(target string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, ts)
+	req.Header.Set(webhookSignatureHeader, "sha256="+w.sign(ts, body))
+	req.Header.Set(runnerIDHeader, runnerID)
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (w *webhookClient) sign(ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.cfg.WebhookSecret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// wait blocks until deliveries in progress finish or the timeout elapses.
+func (w *webhookClient) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		w.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// envWebhookAllowlist reads a comma-separated list of scheme://host entries;
+// a bare host means https.
+func envWebhookAllowlist(name string) ([]*url.URL, error) {
+	var allowlist []*url.URL
+	for _, entry := range envList(name) {
+		if !strings.Contains(entry, "://") {
+			entry = "https://" + entry
+		}
+		u, err := url.Parse(entry)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("%s: invalid entry %q: must be a host or scheme://host", name, entry)
+		}
+		allowlist = append(allowlist, u)
+	}
+	return allowlist, nil
+}
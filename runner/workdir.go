@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// createWorkdir makes a fresh scratch directory for one job under base. When
+// jobs run as a dedicated user the directory is handed over to that user.
+func createWorkdir(base string, cred *jobCredential) (string, error) {
+	dir, err := os.MkdirTemp(base, "job-")
+	if err != nil {
+		return "", err
+	}
+	if cred != nil {
+		if err := os.Chown(dir, int(cred.Uid), int(cred.Gid)); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// pathScopedFlags are the permission flags whose values are file system paths.
+var pathScopedFlags = map[string]bool{
+	"--allow-read":  true,
+	"--allow-write": true,
+	"--deny-read":   true,
+	"--deny-write":  true,
+}
+
+// resolvePermissionPaths rewrites relative paths in read/write permission
+// flags so they are scoped to the job's working directory rather than
+// whatever deno would resolve them against.
+func resolvePermissionPaths(perms []string, dir string) []string {
+	resolved := make([]string, 0, len(perms))
+	for _, perm := range perms {
+		flag, value, ok := strings.Cut(perm, "=")
+		if !ok || !pathScopedFlags[flag] {
+			resolved = append(resolved, perm)
+			continue
+		}
+		paths := strings.Split(value, ",")
+		for i, p := range paths {
+			if !filepath.IsAbs(p) {
+				paths[i] = filepath.Join(dir, p)
+			}
+		}
+		resolved = append(resolved, flag+"="+strings.Join(paths, ","))
+	}
+	return resolved
+}
+
+// scriptFileBase names the file the code is written to when it can't go on
+// stdin; the extension follows the request's language, TypeScript by default.
+const scriptFileBase = "__main__"
+
+// writeScriptFile writes code into dir as the job's main module and returns
+// its path. It refuses to overwrite an input file of the same name.
+func writeScriptFile(dir, code, ext string) (string, error) {
+	if ext == "" {
+		ext = "ts"
+	}
+	path := filepath.Join(dir, scriptFileBase+"."+ext)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(code); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// decodeStdin returns the request's stdin payload as bytes. encoding is
+// "base64" or empty/"utf8" for plain text.
+func decodeStdin(data, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "utf8":
+		return []byte(data), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(data)
+	default:
+		return nil, fmt.Errorf("unsupported stdinEncoding %q", encoding)
+	}
+}
+
+// inputFilesLimit returns the cap on the decoded size of req's files. Files
+// that came from a source object were held to MaxSourceBytes by the fetch
+// and get the same limit here.
+func (c *Config) inputFilesLimit(req *RunRequest) int64 {
+	if req.sourced {
+		return c.MaxSourceBytes
+	}
+	return c.MaxInputFilesBytes
+}
+
+// writeInputFiles decodes the request's files (path -> base64 content) and
+// writes them into dir. Paths must be relative and stay inside dir; the
+// decoded total may not exceed maxBytes. Nothing is written unless every file
+// is valid.
+func writeInputFiles(dir string, files map[string]string, maxBytes int64, cred *jobCredential) error {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	decoded := make(map[string][]byte, len(files))
+	var total int64
+	for _, p := range paths {
+		if !filepath.IsLocal(p) {
+			return fmt.Errorf("invalid path %q: must be relative and inside the working directory", p)
+		}
+		data, err := base64.StdEncoding.DecodeString(files[p])
+		if err != nil {
+			return fmt.Errorf("invalid base64 content for %q: %w", p, err)
+		}
+		if total += int64(len(data)); total > maxBytes {
+			return fmt.Errorf("files exceed the %d byte limit", maxBytes)
+		}
+		decoded[p] = data
+	}
+
+	for _, p := range paths {
+		target := filepath.Join(dir, p)
+		if err := mkdirAllOwned(dir, filepath.Dir(target), cred); err != nil {
+			return fmt.Errorf("create directory for %q: %w", p, err)
+		}
+		if err := os.WriteFile(target, decoded[p], 0o644); err != nil {
+			return fmt.Errorf("write %q: %w", p, err)
+		}
+		if cred != nil {
+			if err := os.Lchown(target, int(cred.Uid), int(cred.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasFile reports whether files has an entry for path, however either is
+// spelled.
+func hasFile(files map[string]string, path string) bool {
+	path = filepath.Clean(path)
+	for p := range files {
+		if filepath.Clean(p) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// entrypointPath returns the path of the input file a request names as its
+// entrypoint, checking that a regular file was written there.
+func entrypointPath(dir, entrypoint string) (string, error) {
+	path := filepath.Join(dir, entrypoint)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("entrypoint %q is not a regular file", entrypoint)
+	}
+	return path, nil
+}
+
+// mkdirAllOwned creates path (inside root) and any missing parents, handing
+// each new directory to cred's user.
+func mkdirAllOwned(root, path string, cred *jobCredential) error {
+	if path == root {
+		return nil
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return nil
+	}
+	if err := mkdirAllOwned(root, filepath.Dir(path), cred); err != nil {
+		return err
+	}
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return err
+	}
+	if cred != nil {
+		return os.Lchown(path, int(cred.Uid), int(cred.Gid))
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// workFetchWait is how long a fetch from the work queue waits for jobs
+// before the runner looks at its free worker slots again.
+const workFetchWait = 5 * time.Second
+
+// workItem is a job taken from the JetStream work queue, to be acked once it
+// is answered. It reports the job in progress every third of the ack wait
+// until then, so long jobs aren't redelivered while they run.
+type workItem struct {
+	msg         jetstream.Msg
+	nc          *nats.Conn // for dead letters
+	delivered   int        // how many times JetStream has delivered it, this time included
+	maxDeliver  int
+	nakDelay    time.Duration
+	deadLetters string
+	stop        chan struct{}
+	once        sync.Once
+}
+
+// DeadLetter is published on the dead-letter subject for a job from the work
+// queue that was given up on, for someone to look into.
+type DeadLetter struct {
+	// PublicID is empty if the request couldn't be decoded.
+	PublicID   string `json:"publicId,omitempty"`
+	Subject    string `json:"subject"`
+	Sequence   uint64 `json:"sequence"` // in the work-queue stream
+	Deliveries int    `json:"deliveries"`
+	Reason     string `json:"reason"`
+	// Request is the job as it was submitted: the request object, or a
+	// string holding the payload if it isn't valid JSON.
+	Request json.RawMessage `json:"request"`
+	// LastResult is the result of the last attempt, when there was one.
+	LastResult *RunResult `json:"lastResult,omitempty"`
+}
+
+func newWorkItem(cfg *Config, nc *nats.Conn, msg jetstream.Msg) *workItem {
+	w := &workItem{msg: msg, nc: nc, delivered: 1, maxDeliver: cfg.WorkMaxDeliver, nakDelay: cfg.WorkNakDelay,
+		deadLetters: cfg.DeadLetterSubject, stop: make(chan struct{})}
+	if meta, err := msg.Metadata(); err == nil {
+		w.delivered = int(meta.NumDelivered)
+	}
+	go func() {
+		t := time.NewTicker(cfg.WorkAckWait / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-t.C:
+				if err := msg.InProgress(); err != nil {
+					log.Printf("[WORK] Failed to report %s in progress: %v", msg.Subject(), err)
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// redeliverable reports whether res means the runner couldn't run the job,
+// rather than the job's own outcome, so another attempt may succeed.
+func redeliverable(res RunResult) bool {
+	switch res.ErrorCode {
+	case errorCodeBusy, errorCodeShutdown, errorCodeSpawnFailed, errorCodeInternal:
+		return true
+	}
+	return false
+}
+
+// retry naks a job the runner couldn't run, so that it is redelivered after
+// the nak delay, and reports whether it did. A job out of attempts goes to
+// the dead-letter subject instead, and res is its final result.
+func (w *workItem) retry(res RunResult) bool {
+	if w.delivered >= w.maxDeliver {
+		w.deadLetter(fmt.Sprintf("%s on each of %d deliveries: %s", res.ErrorCode, w.delivered, res.Error), &res)
+		return false
+	}
+	w.once.Do(func() {
+		close(w.stop)
+		log.Printf("[WORK] Returning %s to the work queue for redelivery in %v: %s", res.PublicID, w.nakDelay, res.ErrorCode)
+		if err := w.msg.NakWithDelay(w.nakDelay); err != nil {
+			log.Printf("[WORK] Failed to nak %s: %v", res.PublicID, err)
+		}
+	})
+	return true
+}
+
+// settle acks the job once it has been answered. Like retry and deadLetter,
+// it has no effect if one of them came first, and it is a no-op on a nil
+// item, for jobs that came as core NATS requests.
+func (w *workItem) settle(res RunResult) {
+	if w == nil {
+		return
+	}
+	w.once.Do(func() {
+		close(w.stop)
+		if err := w.msg.Ack(); err != nil {
+			log.Printf("[WORK] Failed to ack %s: %v", res.PublicID, err)
+		}
+	})
+}
+
+// deadLetter tells JetStream never to deliver the job again and publishes
+// it, with why it was given up on, to the dead-letter subject.
+func (w *workItem) deadLetter(reason string, last *RunResult) {
+	if w == nil {
+		return
+	}
+	w.once.Do(func() {
+		close(w.stop)
+		if err := w.msg.Term(); err != nil {
+			log.Printf("[WORK] Failed to terminate job: %v", err)
+		}
+		dl := DeadLetter{Subject: w.msg.Subject(), Deliveries: w.delivered, Reason: reason, Request: w.msg.Data(), LastResult: last}
+		if !json.Valid(dl.Request) {
+			dl.Request, _ = json.Marshal(string(w.msg.Data()))
+		}
+		if last != nil {
+			dl.PublicID = last.PublicID
+			last.stamp()
+		}
+		if meta, err := w.msg.Metadata(); err == nil {
+			dl.Sequence = meta.Sequence.Stream
+		}
+		log.Printf("[WORK] Dead-lettering stream sequence %d to %s: %s", dl.Sequence, w.deadLetters, reason)
+		data, err := json.Marshal(dl)
+		if err == nil {
+			err = w.nc.Publish(w.deadLetters, data)
+		}
+		if err != nil {
+			log.Printf("[WORK] Failed to publish dead letter: %v", err)
+		}
+	})
+}
+
+// openWorkQueue creates or updates the work-queue stream and the durable
+// consumer the runners share.
+func openWorkQueue(cfg *Config, nc *nats.Conn) (jetstream.Consumer, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        cfg.WorkStream,
+		Description: "runner jobs",
+		Subjects:    []string{cfg.WorkSubject},
+		Retention:   jetstream.WorkQueuePolicy,
+	}); err != nil {
+		return nil, fmt.Errorf("stream %q: %w", cfg.WorkStream, err)
+	}
+	cons, err := js.CreateOrUpdateConsumer(ctx, cfg.WorkStream, jetstream.ConsumerConfig{
+		Durable:     cfg.WorkConsumer,
+		Description: "runners taking jobs from the work queue",
+		AckPolicy:   jetstream.AckExplicitPolicy,
+		AckWait:     cfg.WorkAckWait,
+		// One more than the runner tries a job, for the delivery that dead-letters it.
+		MaxDeliver: cfg.WorkMaxDeliver + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("consumer %q: %w", cfg.WorkConsumer, err)
+	}
+	return cons, nil
+}
+
+// consumeWork takes jobs from the work queue until ctx is canceled. It
+// fetches only as many as there are idle workers, so jobs this runner
+// can't start yet stay in the stream for other runners.
+func (r *Runner) consumeWork(ctx context.Context, cons jetstream.Consumer) {
+	for ctx.Err() == nil {
+		n := r.pool.free()
+		if n == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+		batch, err := cons.Fetch(n, jetstream.FetchMaxWait(workFetchWait))
+		if err != nil {
+			log.Printf("[WORK] Fetch failed: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		for msg := range batch.Messages() {
+			r.handleWork(msg)
+		}
+		if err := batch.Error(); err != nil && !errors.Is(err, nats.ErrTimeout) {
+			log.Printf("[WORK] Fetch failed: %v", err)
+		}
+	}
+}
+
+// handleWork runs a job from the work queue as if it had been requested on
+// runner.execute without a reply inbox, so its result goes to its
+// replySubject or the results subject.
+func (r *Runner) handleWork(msg jetstream.Msg) {
+	work := newWorkItem(r.cfg, r.nc, msg)
+	if work.delivered > 1 {
+		log.Printf("[WORK] Delivery %d of a job (tried at most %d times)", work.delivered, work.maxDeliver)
+	}
+	// Only a runner that crashed or lost its connection mid-job fails to
+	// settle it, and the job may be why.
+	if work.delivered > work.maxDeliver {
+		work.deadLetter(fmt.Sprintf("delivered %d times without being answered", work.maxDeliver), nil)
+		return
+	}
+	m := &nats.Msg{Subject: msg.Subject(), Header: msg.Headers(), Data: msg.Data()}
+	r.submit(m, work)
+}
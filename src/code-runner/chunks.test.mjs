@@ -0,0 +1,96 @@
+// Run with `npm test` (Node 22.18 or later, which loads chunks.ts directly).
+import { test } from 'node:test';
+import assert from 'node:assert/strict';
+import { createHash } from 'node:crypto';
+import { assembleChunks, CHUNK_CHECKSUM_HEADER, CHUNK_COUNT_HEADER, CHUNK_HEADER } from './chunks.ts';
+
+const headers = (entries) => ({ get: (key) => entries[key] ?? '' });
+
+// split cuts data into chunks of size bytes the way the runner does, and
+// returns them with their terminator.
+function split(data, size) {
+  const chunks = [];
+  for (let off = 0; off === 0 || off < data.length; off += size) {
+    chunks.push({ data: data.subarray(off, off + size), headers: headers({ [CHUNK_HEADER]: String(chunks.length + 1) }) });
+  }
+  const terminator = {
+    data: new Uint8Array(0),
+    headers: headers({
+      [CHUNK_COUNT_HEADER]: String(chunks.length),
+      [CHUNK_CHECKSUM_HEADER]: 'sha256=' + createHash('sha256').update(data).digest('hex'),
+    }),
+  };
+  return { chunks, terminator };
+}
+
+// 112 bytes: seven full chunks of 16.
+const payload = Buffer.from('{"output":"' + 'x'.repeat(99) + '"}');
+
+test('joins chunks in order', () => {
+  const { chunks, terminator } = split(payload, 16);
+  assert.deepEqual(Buffer.from(assembleChunks(chunks, terminator)), payload);
+});
+
+test('joins a single chunk and an empty reply', () => {
+  const one = split(payload, payload.length);
+  assert.equal(one.chunks.length, 1);
+  assert.deepEqual(Buffer.from(assembleChunks(one.chunks, one.terminator)), payload);
+  const empty = split(Buffer.alloc(0), 16);
+  assert.equal(assembleChunks(empty.chunks, empty.terminator).length, 0);
+});
+
+test('reorders chunks that arrive out of order', () => {
+  const { chunks, terminator } = split(payload, 16);
+  const shuffled = [...chunks].reverse();
+  [shuffled[1], shuffled[3]] = [shuffled[3], shuffled[1]];
+  assert.deepEqual(Buffer.from(assembleChunks(shuffled, terminator)), payload);
+});
+
+test('rejects a missing chunk', () => {
+  const { chunks, terminator } = split(payload, 16);
+  chunks.splice(2, 1);
+  assert.throws(() => assembleChunks(chunks, terminator), /has 6 chunks, expected 7/);
+});
+
+test('rejects a gap in the indexes', () => {
+  const { chunks, terminator } = split(payload, 16);
+  chunks[2] = { ...chunks[2], headers: headers({ [CHUNK_HEADER]: '9' }) };
+  assert.throws(() => assembleChunks(chunks, terminator), /missing chunk 3 of 7/);
+});
+
+test('rejects a chunk without an index', () => {
+  const { chunks, terminator } = split(payload, 16);
+  chunks[0] = { ...chunks[0], headers: headers({}) };
+  assert.throws(() => assembleChunks(chunks, terminator), /missing chunk 1 of 7/);
+});
+
+test('rejects a duplicated chunk', () => {
+  const { chunks, terminator } = split(payload, 16);
+  assert.throws(() => assembleChunks([...chunks, chunks[4]], terminator), /chunk 5 more than once/);
+});
+
+test('rejects extra chunks past the count', () => {
+  const { chunks, terminator } = split(payload, 16);
+  const extra = { data: Buffer.from('x'), headers: headers({ [CHUNK_HEADER]: '8' }) };
+  assert.throws(() => assembleChunks([...chunks, extra], terminator), /has 8 chunks, expected 7/);
+});
+
+test('rejects a corrupted chunk', () => {
+  const { chunks, terminator } = split(payload, 16);
+  const corrupt = Buffer.from(chunks[3].data);
+  corrupt[0] ^= 1;
+  chunks[3] = { ...chunks[3], data: corrupt };
+  assert.throws(() => assembleChunks(chunks, terminator), /failed its checksum/);
+});
+
+test('rejects a truncated chunk', () => {
+  const { chunks, terminator } = split(payload, 16);
+  chunks[6] = { ...chunks[6], data: chunks[6].data.subarray(1) };
+  assert.throws(() => assembleChunks(chunks, terminator), /failed its checksum/);
+});
+
+test('rejects a terminator without a checksum or count', () => {
+  const { chunks } = split(payload, 16);
+  assert.throws(() => assembleChunks(chunks, { data: new Uint8Array(0), headers: headers({ [CHUNK_COUNT_HEADER]: '7' }) }), /failed its checksum/);
+  assert.throws(() => assembleChunks(chunks, { data: new Uint8Array(0), headers: headers({}) }), /expected 0/);
+});
@@ -0,0 +1,45 @@
+import { createHash } from 'node:crypto';
+
+// Headers of chunked replies; see runner/chunked.go.
+export const CHUNK_HEADER = 'Runner-Chunk';
+export const CHUNK_COUNT_HEADER = 'Runner-Chunks';
+export const CHUNK_CHECKSUM_HEADER = 'Runner-Chunk-Checksum';
+
+/** The parts of a NATS message a chunked reply is read from. */
+export type ChunkMsg = {
+  data: Uint8Array;
+  headers?: { get(key: string): string };
+};
+
+/**
+ * Join the chunks of a chunked reply in index order, checking them against
+ * the count and checksum on the terminator.
+ */
+export function assembleChunks(chunks: ChunkMsg[], terminator: ChunkMsg): Uint8Array {
+  const count = Number(terminator.headers?.get(CHUNK_COUNT_HEADER));
+  const byIndex = new Map<number, Uint8Array>();
+  for (const chunk of chunks) {
+    const index = Number(chunk.headers?.get(CHUNK_HEADER));
+    if (byIndex.has(index)) {
+      throw new Error(`Chunked reply has chunk ${index} more than once`);
+    }
+    byIndex.set(index, chunk.data);
+  }
+  if (byIndex.size !== count) {
+    throw new Error(`Chunked reply has ${byIndex.size} chunks, expected ${count}`);
+  }
+  const parts: Uint8Array[] = [];
+  for (let i = 1; i <= count; i++) {
+    const part = byIndex.get(i);
+    if (!part) {
+      throw new Error(`Chunked reply is missing chunk ${i} of ${count}`);
+    }
+    parts.push(part);
+  }
+  const data = Buffer.concat(parts);
+  const checksum = 'sha256=' + createHash('sha256').update(data).digest('hex');
+  if (checksum !== terminator.headers?.get(CHUNK_CHECKSUM_HEADER)) {
+    throw new Error('Chunked reply failed its checksum');
+  }
+  return data;
+}
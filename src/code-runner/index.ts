@@ -1,24 +1,53 @@
-import { connect, ErrorCode, NatsConnection, JSONCodec } from 'nats';
+import { connect, createInbox, ErrorCode, Msg, NatsConnection, JSONCodec } from 'nats';
 import path from 'node:path';
 import os from 'node:os';
+import { gunzipSync } from 'node:zlib';
+import { createHash } from 'node:crypto';
+import { assembleChunks, CHUNK_COUNT_HEADER, CHUNK_HEADER } from './chunks';
+
+export { assembleChunks } from './chunks';
 
 export type RunRequest = {
   publicId: string;
   code: string;
   permissions?: string[];
+  /** Have the result sent as a chunked reply, for outputs bigger than a NATS message. */
+  chunkedReply?: boolean;
 };
 
 export type RunResponse = {
   output: string;
+  stdout?: string;
+  stderr?: string;
   exitCode: number;
   error?: string;
+  /** "gzip" when the output fields are base64-encoded gzip (large results). */
+  contentEncoding?: string;
+  /** Set when the result was too big for a reply; the full one is in the object store. */
+  offloaded?: OffloadedResult;
+};
+
+export type OffloadedResult = {
+  bucket: string;
+  name: string;
+  size: number;
+  /** "SHA-256=" and the base64url digest of the object. */
+  digest: string;
 };
 
 export type RunOptions = {
   timeoutMs?: number;
   permissions?: string[];
+  /** Ask for a chunked reply, so results over the server's max_payload still arrive. */
+  chunked?: boolean;
+  /** Runtime to run on, sent as runner.execute.<runtime>; the runner's default (deno) when unset. */
+  runtime?: string;
 };
 
+// Prefix of the runner's subjects; must match the runner's RUNNER_SUBJECT_PREFIX.
+const SUBJECT_PREFIX = process.env.RUNNER_SUBJECT_PREFIX || 'runner';
+const EXECUTE_SUBJECT = `${SUBJECT_PREFIX}.execute`;
+
 /**
  * Legacy config for workspace sync (still uses shared directory).
  * Code execution now uses NATS and doesn't need this.
@@ -81,16 +110,17 @@ export async function runCode(
     publicId,
     code,
     permissions: opts.permissions,
+    chunkedReply: opts.chunked || undefined,
   };
 
+  const subject = opts.runtime ? `${EXECUTE_SUBJECT}.${opts.runtime}` : EXECUTE_SUBJECT;
+
   try {
-    const response = await connection.request(
-      'runner.execute',
-      jsonCodec.encode(request),
-      { timeout: timeoutMs }
-    );
+    const data = opts.chunked
+      ? await requestChunked(connection, subject, jsonCodec.encode(request), timeoutMs)
+      : (await connection.request(subject, jsonCodec.encode(request), { timeout: timeoutMs })).data;
 
-    const result = responseCodec.decode(response.data);
+    const result = decodeResponse(await fetchOffloaded(connection, responseCodec.decode(data)));
     
     // Combine output with error if present
     let output = result.output || '';
@@ -127,6 +157,89 @@ export async function runCode(
   }
 }
 
+/**
+ * Send a request with chunkedReply set and return the reassembled reply.
+ * Requests the runner rejects outright get a plain reply, returned as is.
+ */
+export async function requestChunked(
+  connection: NatsConnection,
+  subject: string,
+  data: Uint8Array,
+  timeoutMs: number
+): Promise<Uint8Array> {
+  const inbox = createInbox();
+  const sub = connection.subscribe(inbox, { timeout: timeoutMs });
+  connection.publish(subject, data, { reply: inbox });
+  const chunks: Msg[] = [];
+  try {
+    for await (const msg of sub) {
+      if (msg.headers?.get(CHUNK_HEADER)) {
+        chunks.push(msg);
+        continue;
+      }
+      if (!msg.headers?.get(CHUNK_COUNT_HEADER)) {
+        return msg.data;
+      }
+      return assembleChunks(chunks, msg);
+    }
+  } finally {
+    sub.unsubscribe();
+  }
+  throw new Error('Chunked reply ended before its terminator');
+}
+
+/**
+ * Replace a reply that points at an offloaded result with the full result
+ * from the JetStream object store, checking its digest.
+ */
+export async function fetchOffloaded(connection: NatsConnection, result: RunResponse): Promise<RunResponse> {
+  const ref = result.offloaded;
+  if (!ref) {
+    return result;
+  }
+  const store = await connection.jetstream().views.os(ref.bucket);
+  const object = await store.get(ref.name);
+  if (!object) {
+    throw new Error(`Offloaded result ${ref.bucket}/${ref.name} no longer exists`);
+  }
+  const chunks: Uint8Array[] = [];
+  const reader = object.data.getReader();
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) break;
+    chunks.push(value);
+  }
+  const error = await object.error;
+  if (error) {
+    throw error;
+  }
+  const data = Buffer.concat(chunks);
+  const digest = 'SHA-256=' + createHash('sha256').update(data).digest('base64url');
+  if (digest.replace(/=+$/, '') !== ref.digest.replace(/=+$/, '')) {
+    throw new Error(`Offloaded result ${ref.bucket}/${ref.name} failed its digest check`);
+  }
+  return responseCodec.decode(data);
+}
+
+/**
+ * Undo the runner's compression of large results so callers always see
+ * plain-text output fields.
+ */
+export function decodeResponse(result: RunResponse): RunResponse {
+  if (result.contentEncoding !== 'gzip') {
+    return result;
+  }
+  const gunzip = (field?: string) =>
+    field ? gunzipSync(Buffer.from(field, 'base64')).toString('utf8') : field;
+  return {
+    ...result,
+    output: gunzip(result.output) ?? '',
+    stdout: gunzip(result.stdout),
+    stderr: gunzip(result.stderr),
+    contentEncoding: undefined,
+  };
+}
+
 /**
  * Close the NATS connection (useful for cleanup).
  */